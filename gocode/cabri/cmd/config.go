@@ -23,9 +23,10 @@ var configCmd = &coral.Command{
 			err error
 		)
 		if ff, err = cabriui.MutualExcludeFlags(
-			[]string{"encrypt", "decrypt", "dump", "gen", "get", "put", "remove"},
+			[]string{"encrypt", "decrypt", "dump", "gen", "get", "put", "remove", "ssh", "passphrase", "recovery", "recover", "gensign"},
 			configOptions.Encrypt, configOptions.Decrypt, configOptions.Dump,
-			configOptions.Gen, configOptions.Get, configOptions.Put, configOptions.Remove); err != nil {
+			configOptions.Gen, configOptions.Get, configOptions.Put, configOptions.Remove,
+			configOptions.Ssh, configOptions.Pass, configOptions.Recovery, configOptions.Recover, configOptions.GenSign); err != nil {
 			return err
 		}
 		if ff == "" {
@@ -48,4 +49,9 @@ func init() {
 	configCmd.Flags().BoolVarP(&configOptions.Get, "get", "", false, "display an identity for one or several aliases")
 	configCmd.Flags().BoolVarP(&configOptions.Put, "put", "", false, "<alias> <pkey> [<secret>] import or update an identity for an alias, secret may be unknown")
 	configCmd.Flags().BoolVarP(&configOptions.Remove, "remove", "", false, "remove an identity alias")
+	configCmd.Flags().BoolVarP(&configOptions.Ssh, "ssh", "", false, "<alias> <public-key-file> [<private-key-file>] import an ssh-ed25519 or ssh-rsa key as the identity for an alias, public key file may be \"\"")
+	configCmd.Flags().BoolVarP(&configOptions.Pass, "passphrase", "", false, "<alias> [<salt>] derive the identity for an alias from a passphrase, with the salt displayed at creation for rebuilding it")
+	configCmd.Flags().BoolVarP(&configOptions.Recovery, "recovery", "", false, "<shares> <threshold> export the configuration as printable recovery shares, threshold of them rebuilding it")
+	configCmd.Flags().BoolVarP(&configOptions.Recover, "recover", "", false, "<share-file>... rebuild the configuration from recovery shares, encrypting it if a master password is given")
+	configCmd.Flags().BoolVarP(&configOptions.GenSign, "gensign", "", false, "[<alias>...] add a new signing key to identities, the default one if none is given, meta data they write being signed")
 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/muesli/coral"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabriui"
@@ -100,6 +101,34 @@ var dssUpdnsCmd = &coral.Command{
 	SilenceUsage: true,
 }
 
+var dssMvOptions cabriui.DSSMvOptions
+
+var dssMvCmd = &coral.Command{
+	Use:   "mv <dss-type:/path/to/dss@path/in/dss> <new/path/in/dss>",
+	Short: "rename a namespace or some content",
+	Long:  `rename a namespace or some content within a DSS, contents are not transferred if the DSS stores them by checksum`,
+	Args: func(cmd *coral.Command, args []string) error {
+		if len(args) != 2 {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("a DSS path and its new path in the DSS must be provided")
+		}
+		_, _, _, err := cabriui.CheckDssPath(args[0])
+		if err != nil {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("%v\nsyntax: dss-type:/path/to/dss@path/in/dss\nfor instance\n\tfsy:/home/guest@Downloads", err)
+		}
+		return nil
+	},
+	RunE: func(cmd *coral.Command, args []string) error {
+		dssMvOptions.BaseOptions = baseOptions
+		return cabriui.CLIRun[cabriui.DSSMvOptions, *cabriui.DSSMvVars](
+			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+			dssMvOptions, args,
+			cabriui.DSSMvStartup, cabriui.DSSMvShutdown)
+	},
+	SilenceUsage: true,
+}
+
 var dssGetPutOptions cabriui.DSSGetPutOptions
 
 var dssGetCmd = &coral.Command{
@@ -333,6 +362,160 @@ var dssRmHistoCmd = &coral.Command{
 	SilenceUsage: true,
 }
 
+var dssRetainOptions cabriui.DSSRetainOptions
+
+var dssRetainCmd = &coral.Command{
+	Use:   "retain",
+	Short: "removes history entries not kept by a retention policy",
+	Long: `removes history entries not kept by a retention policy, then purges unused content
+the policy is a comma-separated list of period:duration rules, the first rule applying to an entry age wins,
+period is all, hourly, daily, weekly, monthly or yearly, duration is a number followed by h, d, w, m or y, or forever
+for instance: all:48h,daily:30d,weekly:1y,monthly:forever`,
+	Args: func(cmd *coral.Command, args []string) error {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("a DSS entry must be provided")
+		}
+		_, _, _, err := cabriui.CheckDssPath(args[0])
+		if err != nil {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("%v\nsyntax: dss-type:/path/to/dss@path/in/dss\nfor instance\n\tolf:/home/guest/olf@Downloads", err)
+		}
+		return nil
+	},
+	RunE: func(cmd *coral.Command, args []string) error {
+		dssRetainOptions.BaseOptions = baseOptions
+		if err := cabriui.CheckRetentionPolicy(dssRetainOptions.Policy); err != nil {
+			return err
+		}
+		return cabriui.CLIRun[cabriui.DSSRetainOptions, *cabriui.DSSRetainVars](
+			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+			dssRetainOptions, args,
+			cabriui.DSSRetainStartup, cabriui.DSSRetainShutdown)
+	},
+	SilenceUsage: true,
+}
+
+var dssTagOptions cabriui.DSSTagOptions
+
+var dssTagCmd = &coral.Command{
+	Use:   "tag",
+	Short: "lists, creates or deletes DSS tags",
+	Long: `lists, creates or deletes DSS tags
+a tag names an immutable point in the DSS history which may be used instead of a time in --lasttime like options,
+history entries visible at the time of a tag are kept by rmhisto and retain unless forced`,
+	Args: func(cmd *coral.Command, args []string) error {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("a DSS must be provided")
+		}
+		_, _, err := cabriui.CheckDssSpec(args[0])
+		if err != nil {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("%v\nsyntax: dss-type:/path/to/dss\nfor instance\n\tolf:/home/guest/olf", err)
+		}
+		return nil
+	},
+	RunE: func(cmd *coral.Command, args []string) error {
+		dssTagOptions.BaseOptions = baseOptions
+		if dssTagOptions.Create != "" && dssTagOptions.Delete != "" {
+			return fmt.Errorf("--create and --delete are mutually exclusive")
+		}
+		if dssTagOptions.Create != "" {
+			if err := cabriui.CheckTagName(dssTagOptions.Create); err != nil {
+				return err
+			}
+		}
+		if _, err := cabriui.CheckTimeStamp(dssTagOptions.Time); err != nil {
+			return err
+		}
+		return cabriui.CLIRun[cabriui.DSSTagOptions, *cabriui.DSSTagVars](
+			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+			dssTagOptions, args,
+			cabriui.DSSTagStartup, cabriui.DSSTagShutdown)
+	},
+	SilenceUsage: true,
+}
+
+var dssRekeyOptions cabriui.DSSRekeyOptions
+
+var dssRekeyCmd = &coral.Command{
+	Use:   "rekey",
+	Short: "re-encrypts the history of encrypted DSS entries for new users",
+	Long: `re-encrypts the metadata, and optionally the content, of the history of encrypted DSS entries
+for the users mapping the ones of their ACL, users being revoked if not mapped to any other one,
+the former encrypted data being purged afterwards, an interrupted rekey is resumed by running it again`,
+	Args: func(cmd *coral.Command, args []string) error {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("a DSS entry must be provided")
+		}
+		dssType, _, _, err := cabriui.CheckDssPath(args[0])
+		if err != nil {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("%v\nsyntax: dss-type:/path/to/dss@path/in/dss\nfor instance\n\txolf:/home/guest/xolf@Downloads", err)
+		}
+		if dssType[0] != 'x' {
+			return fmt.Errorf("DSS type %s is not encrypted", dssType)
+		}
+		return nil
+	},
+	RunE: func(cmd *coral.Command, args []string) error {
+		dssRekeyOptions.BaseOptions = baseOptions
+		if len(dssRekeyOptions.MapUsers) == 0 && len(dssRekeyOptions.Revoke) == 0 {
+			return fmt.Errorf("at least one --map or --revoke must be provided")
+		}
+		for _, mapping := range dssRekeyOptions.MapUsers {
+			if err := cabriui.CheckRekeyMapping(mapping); err != nil {
+				return err
+			}
+		}
+		return cabriui.CLIRun[cabriui.DSSRekeyOptions, *cabriui.DSSRekeyVars](
+			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+			dssRekeyOptions, args,
+			cabriui.DSSRekeyStartup, cabriui.DSSRekeyShutdown)
+	},
+	SilenceUsage: true,
+}
+
+var dssTrustOptions cabriui.DSSTrustOptions
+
+var dssTrustCmd = &coral.Command{
+	Use:   "trust",
+	Short: "manage the trust list of the signers of DSS metadata",
+	Long: `manage and display the client trust list of the signers of the DSS repository metadata,
+with the flag policy unsigned or untrusted metadata are reported by audit and scan,
+with the reject policy they cannot be read either`,
+	Args: func(cmd *coral.Command, args []string) error {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("a DSS must be provided")
+		}
+		dssType, _, err := cabriui.CheckDssSpec(args[0])
+		if err != nil {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("%v\nsyntax: dss-type:/path/to/dss\nfor instance\n\tolf:/home/guest/olf", err)
+		}
+		if dssType == "fsy" {
+			return fmt.Errorf("DSS type %s has no repository", dssType)
+		}
+		return nil
+	},
+	RunE: func(cmd *coral.Command, args []string) error {
+		dssTrustOptions.BaseOptions = baseOptions
+		if dssTrustOptions.Policy != "" {
+			if err := cabriui.CheckTrustPolicy(dssTrustOptions.Policy); err != nil {
+				return err
+			}
+		}
+		return cabriui.CLIRun[cabriui.DSSTrustOptions, *cabriui.DSSTrustVars](
+			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+			dssTrustOptions, args,
+			cabriui.DSSTrustStartup, cabriui.DSSTrustShutdown)
+	},
+	SilenceUsage: true,
+}
+
 var dssCleanOptions cabriui.DSSCleanOptions
 
 var dssCleanCmd = &coral.Command{
@@ -362,6 +545,34 @@ var dssCleanCmd = &coral.Command{
 	SilenceUsage: true,
 }
 
+var dssAbortMpOptions cabriui.DSSAbortMpOptions
+
+var dssAbortMpCmd = &coral.Command{
+	Use:   "abortmp",
+	Short: "abort stale multipart uploads of an OBS DSS",
+	Long:  `abort multipart uploads of an OBS DSS left pending for longer than a given duration`,
+	Args: func(cmd *coral.Command, args []string) error {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("a DSS must be provided")
+		}
+		_, _, err := cabriui.CheckDssSpec(args[0])
+		if err != nil {
+			cmd.UsageFunc()(cmd)
+			return fmt.Errorf("%v\nsyntax: dss-type:/path/to/dss\nfor instance\n\tobs:/home/guest/cabri_obs", err)
+		}
+		return nil
+	},
+	RunE: func(cmd *coral.Command, args []string) error {
+		dssAbortMpOptions.BaseOptions = baseOptions
+		return cabriui.CLIRun[cabriui.DSSAbortMpOptions, *cabriui.DSSAbortMpVars](
+			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+			dssAbortMpOptions, args,
+			cabriui.DSSAbortMpStartup, cabriui.DSSAbortMpShutdown)
+	},
+	SilenceUsage: true,
+}
+
 var dssConfigOptions cabriui.DSSConfigOptions
 
 var dssConfigCmd = &coral.Command{
@@ -393,11 +604,18 @@ var dssConfigCmd = &coral.Command{
 func init() {
 	cliCmd.AddCommand(dssCmd)
 	dssMkCmd.Flags().StringVarP(&dssMkOptions.Size, "size", "s", "", "size is \"s\" for small, \"m\" for medium or \"l\" for large")
+	dssMkCmd.Flags().BoolVar(&dssMkOptions.Chunked, "chunked", false, "split content in chunks for deduplication (olf, obs and smf only, not encrypted)")
+	dssMkCmd.Flags().BoolVar(&dssMkOptions.Compressed, "compressed", false, "compress content before storage or encryption (olf, obs and smf only)")
+	dssMkCmd.Flags().StringVar(&dssMkOptions.Compression, "compression", "", "compression algorithm of compressed content: gzip (default) or zstd, implies --compressed")
+	dssMkCmd.Flags().BoolVar(&dssMkOptions.Convergent, "convergent", false, "deduplicate encrypted content with convergent encryption (xolf and xobs only)")
+	dssMkCmd.Flags().IntVar(&dssMkOptions.MultipartThreshold, "mpthreshold", 0, "content size in MiB above which a multipart upload is used, negative disables it (obs and smf only, default 64)")
+	dssMkCmd.Flags().IntVar(&dssMkOptions.MultipartPartSize, "mppartsize", 0, "multipart upload part size in MiB, at least 5 (obs and smf only, default 16)")
 	dssCmd.AddCommand(dssMkCmd)
 	dssMknsCmd.Flags().StringArrayVarP(&dssMknsOptions.Children, "children", "c", nil, "children")
 	dssCmd.AddCommand(dssMknsCmd)
 	dssUpdnsCmd.Flags().StringArrayVarP(&dssMknsOptions.Children, "children", "c", nil, "children")
 	dssCmd.AddCommand(dssUpdnsCmd)
+	dssCmd.AddCommand(dssMvCmd)
 	dssCmd.AddCommand(dssGetCmd)
 	dssCmd.AddCommand(dssPutCmd)
 	dssUnlockCmd.Flags().BoolVar(&dssUnlockOptions.RepairIndex, "repair", false, "repair the index if persistent")
@@ -419,10 +637,35 @@ func init() {
 	dssCmd.AddCommand(dssLsHistoCmd)
 	dssRmHistoCmd.Flags().BoolVarP(&dssRmHistoOptions.Recursive, "recursive", "r", false, "recursively remove the history of all namespace children")
 	dssRmHistoCmd.Flags().BoolVarP(&dssRmHistoOptions.DryRun, "dryrun", "d", false, "don't remove the history, just report work to be done")
+	dssRmHistoCmd.Flags().BoolVar(&dssRmHistoOptions.Force, "force", false, "also remove history entries pinned by a tag")
 	dssRmHistoCmd.Flags().StringVar(&dssRmHistoOptions.StartTime, "st", "", "inclusive index time above which entries must be removed, default to all past entries")
 	dssRmHistoCmd.Flags().StringVar(&dssRmHistoOptions.EndTime, "et", "", "the inclusive index time below which entries must be removed, default to all future entries")
 	dssCmd.AddCommand(dssRmHistoCmd)
+	dssRetainCmd.Flags().BoolVarP(&dssRetainOptions.Recursive, "recursive", "r", false, "recursively apply the policy to all namespace children")
+	dssRetainCmd.Flags().BoolVarP(&dssRetainOptions.DryRun, "dryrun", "d", false, "don't remove the history, just report work to be done")
+	dssRetainCmd.Flags().BoolVar(&dssRetainOptions.Force, "force", false, "also remove history entries pinned by a tag")
+	dssRetainCmd.Flags().StringVarP(&dssRetainOptions.Policy, "policy", "p", "all:48h,daily:30d,weekly:1y,monthly:forever", "retention policy")
+	dssRetainCmd.Flags().BoolVar(&dssRetainOptions.NoPurge, "nopurge", false, "don't purge unused content")
+	dssCmd.AddCommand(dssRetainCmd)
+	dssTagCmd.Flags().StringVar(&dssTagOptions.Create, "create", "", "name of the tag to create")
+	dssTagCmd.Flags().StringVar(&dssTagOptions.Time, "time", "", "time of the DSS state to tag, defaults to the current time")
+	dssTagCmd.Flags().StringVar(&dssTagOptions.Delete, "delete", "", "name of the tag to delete")
+	dssCmd.AddCommand(dssTagCmd)
+	dssRekeyCmd.Flags().BoolVarP(&dssRekeyOptions.Recursive, "recursive", "r", false, "recursively rekey all namespace children")
+	dssRekeyCmd.Flags().StringArrayVar(&dssRekeyOptions.MapUsers, "map", nil, "list of ACL user mapping <old-user:new-user> items, identity aliases, the default being empty")
+	dssRekeyCmd.Flags().StringArrayVar(&dssRekeyOptions.Revoke, "revoke", nil, "list of ACL users to revoke, identity aliases, the default being empty")
+	dssRekeyCmd.Flags().BoolVar(&dssRekeyOptions.Content, "content", false, "also re-encrypt the content, otherwise still readable by the former users")
+	dssRekeyCmd.Flags().BoolVar(&dssRekeyOptions.NoPurge, "nopurge", false, "don't purge the former encrypted data yet, a later rekey will")
+	dssCmd.AddCommand(dssRekeyCmd)
+	dssTrustCmd.Flags().StringVar(&dssTrustOptions.Policy, "policy", "", "trust policy: flag, reject or none to remove the trust list")
+	dssTrustCmd.Flags().StringArrayVar(&dssTrustOptions.Signers, "signer", nil, "list of trusted signers, identity aliases or signing public keys")
+	dssTrustCmd.Flags().StringArrayVar(&dssTrustOptions.Untrust, "untrust", nil, "list of signers to remove from the trust list, identity aliases or signing public keys")
+	dssCmd.AddCommand(dssTrustCmd)
 	dssCmd.AddCommand(dssCleanCmd)
+	dssAbortMpCmd.Flags().DurationVar(&dssAbortMpOptions.Older, "older", 24*time.Hour, "abort uploads initiated for longer than this duration")
+	dssCmd.AddCommand(dssAbortMpCmd)
 	dssConfigCmd.Flags().BoolVar(&dssConfigOptions.Raw, "raw", false, "displays the raw configuration")
+	dssConfigCmd.Flags().IntVar(&dssConfigOptions.MultipartThreshold, "mpthreshold", 0, "content size in MiB above which a multipart upload is used, negative disables it")
+	dssConfigCmd.Flags().IntVar(&dssConfigOptions.MultipartPartSize, "mppartsize", 0, "multipart upload part size in MiB, at least 5")
 	dssCmd.AddCommand(dssConfigCmd)
 }
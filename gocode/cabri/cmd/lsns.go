@@ -28,7 +28,7 @@ var lsnsCmd = &coral.Command{
 	},
 	RunE: func(cmd *coral.Command, args []string) error {
 		lsnsOptions.BaseOptions = baseOptions
-		if _, err := cabriui.CheckTimeStamp(lsnsOptions.LastTime); err != nil {
+		if _, _, err := cabriui.CheckTimeOrTag(lsnsOptions.LastTime); err != nil {
 			return err
 		}
 		return cabriui.CLIRun[cabriui.LsnsOptions, *cabriui.LsnsVars](
@@ -47,5 +47,5 @@ func init() {
 	lsnsCmd.Flags().BoolVarP(&lsnsOptions.Long, "long", "l", false, "long format display")
 	lsnsCmd.Flags().BoolVarP(&lsnsOptions.Checksum, "checksum", "c", false, "calculate content's checksum if not available and display it")
 	lsnsCmd.Flags().BoolVar(&lsnsOptions.Reverse, "reverse", false, "sort is reversed")
-	lsnsCmd.Flags().StringVar(&lsnsOptions.LastTime, "lasttime", "", "upper time of entries retrieved in historized DSS")
+	lsnsCmd.Flags().StringVar(&lsnsOptions.LastTime, "lasttime", "", "upper time or tag of entries retrieved in historized DSS")
 }
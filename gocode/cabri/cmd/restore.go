@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/muesli/coral"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabriui"
+)
+
+var restoreOptions cabriui.RestoreOptions
+
+var restoreCmd = &coral.Command{
+	Use:   "restore <dss-type:/path/to/dss@path/in/dss> <dss-type:/path/to/target@namespace>",
+	Short: "restores a DSS entry as it was at a given time",
+	Long: `restores a DSS content or namespace as it was at a given time into a target namespace
+the time is given either with --lasttime or as an --index in the entry history listed by lshisto`,
+	Args: func(cmd *coral.Command, args []string) error {
+		returnUsageAndErr := func(err error) error {
+			cmd.UsageFunc()(cmd)
+			return err
+		}
+		if len(args) != 2 {
+			return returnUsageAndErr(fmt.Errorf("a DSS entry and a target DSS namespace must be provided"))
+		}
+		for _, arg := range args {
+			if _, _, _, err := cabriui.CheckDssPath(arg); err != nil {
+				return returnUsageAndErr(fmt.Errorf("%v\nsyntax: dss-type:/path/to/dss@path/in/dss\nfor instance\n\tolf:/home/guest/olf@Downloads", err))
+			}
+		}
+		return nil
+	},
+	RunE: func(cmd *coral.Command, args []string) error {
+		if _, err := cabriui.CheckUiACL(baseOptions.ACL); err != nil {
+			return err
+		}
+		baseOptions.LeftUsers = restoreOptions.LeftUsers
+		restoreOptions.BaseOptions = baseOptions
+		if _, _, err := cabriui.CheckTimeOrTag(restoreOptions.LeftTime); err != nil {
+			return err
+		}
+		if restoreOptions.LeftTime != "" && restoreOptions.Index != 0 {
+			return fmt.Errorf("--lasttime and --index are mutually exclusive")
+		}
+		if err := cabriui.CheckRestoreConflict(restoreOptions.Conflict); err != nil {
+			return err
+		}
+		return cabriui.CLIRun[cabriui.RestoreOptions, *cabriui.RestoreVars](
+			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+			restoreOptions, args,
+			cabriui.RestoreStartup, cabriui.RestoreShutdown)
+	},
+	SilenceUsage: true,
+}
+
+func init() {
+	cliCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().BoolVarP(&restoreOptions.Recursive, "recursive", "r", false, "restore sub-namespaces content recursively")
+	restoreCmd.Flags().BoolVarP(&restoreOptions.DryRun, "dryrun", "d", false, "don't restore, just report work to be done")
+	restoreCmd.Flags().StringVar(&restoreOptions.LeftTime, "lasttime", "", "time or tag of the DSS state to restore, defaults to the latest one")
+	restoreCmd.Flags().IntVar(&restoreOptions.Index, "index", 0, "index of the entry state to restore in its history, from 1 for the oldest, negative from -1 for the latest")
+	restoreCmd.Flags().StringVar(&restoreOptions.Conflict, "conflict", cabriui.RestoreOverwrite, "policy when the target content exists and differs: overwrite, rename or skip")
+	restoreCmd.Flags().StringVar(&restoreOptions.Suffix, "suffix", ".restored", "suffix appended to the name of the restored content with the rename conflict policy")
+	restoreCmd.Flags().BoolVarP(&restoreOptions.NoCh, "nocheck", "n", false, "don't evaluate checksum when not available, compare content's size and modification time")
+	restoreCmd.Flags().StringArrayVar(&restoreOptions.Exclude, "excl", nil, "list of regular expression patterns to exclude from restoration")
+	restoreCmd.Flags().StringArrayVar(&restoreOptions.ExcludeFrom, "exclfile", nil, "list of files containing regular expression patterns to exclude from restoration")
+	restoreCmd.Flags().BoolVar(&restoreOptions.Summary, "summary", false, "only displays restoration summary")
+	restoreCmd.Flags().BoolVar(&restoreOptions.DisplayRight, "dispright", false, "display target entries in report even if equal to source")
+	restoreCmd.Flags().BoolVarP(&restoreOptions.Verbose, "verbose", "v", false, "display restoration statistics")
+	restoreCmd.Flags().BoolVar(&restoreOptions.NoACL, "noacl", false, "don't check ACL")
+	restoreCmd.Flags().StringArrayVar(&restoreOptions.MapACL, "macl", nil, "list of ACL user mapping <source-user:target-user> items")
+	restoreCmd.PersistentFlags().StringArrayVar(&restoreOptions.LeftUsers, "leftuser", nil, "list of ACL users for source retrieval")
+}
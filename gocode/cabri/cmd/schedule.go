@@ -35,5 +35,6 @@ func init() {
 	scheduleCmd.Flags().BoolVar(&scheduleOptions.HasLog, "haslog", false, "output http access log for the API")
 	scheduleCmd.Flags().StringVarP(&scheduleOptions.SpecFile, "sfile", "s", "", "file containing the scheduling specification")
 	scheduleCmd.Flags().BoolVar(&scheduleOptions.HasHttp, "http", false, "launches an http server to trigger updates or report status")
+	scheduleCmd.Flags().IntVar(&scheduleOptions.Reports, "reports", 10, "number of json or ndjson reports of cabriSync actions kept per entry for the http API")
 	scheduleCmd.Flags().StringVarP(&scheduleOptions.Address, "address", "", ":3000", "host:port to listen to, defaults :3000")
 }
@@ -51,12 +51,45 @@ for instance
 		baseOptions.LeftUsers = syncOptions.LeftUsers
 		baseOptions.LeftACL = syncOptions.LeftACL
 		syncOptions.BaseOptions = baseOptions
-		if _, err := cabriui.CheckTimeStamp(syncOptions.LeftTime); err != nil {
+		if _, _, err := cabriui.CheckTimeOrTag(syncOptions.LeftTime); err != nil {
 			return err
 		}
-		if _, err := cabriui.CheckTimeStamp(syncOptions.RightTime); err != nil {
+		if _, _, err := cabriui.CheckTimeOrTag(syncOptions.RightTime); err != nil {
 			return err
 		}
+		for _, size := range []string{syncOptions.MinSize, syncOptions.MaxSize} {
+			if _, err := cabriui.CheckSize(size); err != nil {
+				return err
+			}
+		}
+		for _, ts := range []string{syncOptions.NewerThan, syncOptions.OlderThan} {
+			if _, err := cabriui.CheckTimeStamp(ts); err != nil {
+				return err
+			}
+		}
+		if err := cabriui.CheckSyncOutput(syncOptions.Output); err != nil {
+			return err
+		}
+		if syncOptions.ReportFile != "" && syncOptions.Output != "json" && syncOptions.Output != "ndjson" {
+			return fmt.Errorf("--report requires a json or ndjson output")
+		}
+		if err := cabriui.CheckSyncConflicts(syncOptions.Conflicts); err != nil {
+			return err
+		}
+		if syncOptions.Conflicts != "" && !syncOptions.BiDir {
+			return fmt.Errorf("--conflicts requires --bidir")
+		}
+		if syncOptions.Incremental && syncOptions.BiDir {
+			return fmt.Errorf("--incremental and --bidir are mutually exclusive")
+		}
+		if syncOptions.Watch {
+			if dssType, _, _, _ := cabriui.CheckDssPath(args[0]); dssType != "fsy" {
+				return fmt.Errorf("--watch requires a fsy left DSS")
+			}
+			if syncOptions.BiDir || syncOptions.Incremental {
+				return fmt.Errorf("--watch is not available with --bidir or --incremental")
+			}
+		}
 		return cabriui.CLIRun[cabriui.SyncOptions, *cabriui.SyncVars](
 			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
 			syncOptions, args,
@@ -74,13 +107,30 @@ func init() {
 	syncCmd.Flags().BoolVarP(&syncOptions.NoCh, "nocheck", "n", false, "don't evaluate checksum when not available, compare content's size and modification time")
 	syncCmd.Flags().StringArrayVar(&syncOptions.Exclude, "excl", nil, "list of regular expression patterns to exclude from sync")
 	syncCmd.Flags().StringArrayVar(&syncOptions.ExcludeFrom, "exclfile", nil, "list of files containing regular expression patterns to exclude from sync")
+	syncCmd.Flags().StringVar(&syncOptions.IgnoreFile, "ignorefile", ".cabriignore", "name of the files containing gitignore-style patterns to ignore in their namespace, none if empty")
+	syncCmd.Flags().StringArrayVar(&syncOptions.Ignore, "ignore", nil, "list of gitignore-style patterns to ignore from sync")
+	syncCmd.Flags().StringArrayVar(&syncOptions.Include, "include", nil, "list of gitignore-style patterns of the only contents to sync")
+	syncCmd.Flags().StringVar(&syncOptions.MinSize, "minsize", "", "don't sync contents smaller than this size, optionally followed by K, M, G or T")
+	syncCmd.Flags().StringVar(&syncOptions.MaxSize, "maxsize", "", "don't sync contents larger than this size, optionally followed by K, M, G or T")
+	syncCmd.Flags().StringVar(&syncOptions.NewerThan, "newer", "", "don't sync contents modified before this time")
+	syncCmd.Flags().StringVar(&syncOptions.OlderThan, "older", "", "don't sync contents modified after this time")
 	syncCmd.Flags().BoolVar(&syncOptions.Summary, "summary", false, "only displays synchronization summary")
+	syncCmd.Flags().StringVar(&syncOptions.Output, "output", "text", "report format: text, or json and ndjson for a machine-readable report always displayed")
+	syncCmd.Flags().StringVar(&syncOptions.ReportFile, "report", "", "write the json or ndjson report to this file instead of displaying it")
 	syncCmd.Flags().BoolVar(&syncOptions.DisplayRight, "dispright", false, "display right entries in report even if equal to left")
 	syncCmd.Flags().BoolVarP(&syncOptions.Verbose, "verbose", "v", false, "display synchronization statistics")
 	syncCmd.Flags().IntVar(&syncOptions.VerboseLevel, "debug", 0, "display synchronization debug messages if level >= 2")
-	syncCmd.Flags().StringVar(&syncOptions.LeftTime, "lefttime", "", "upper time of entries retrieved in left historized DSS")
-	syncCmd.Flags().StringVar(&syncOptions.RightTime, "righttime", "", "upper time of entries retrieved in right historized DSS")
+	syncCmd.Flags().StringVar(&syncOptions.LeftTime, "lefttime", "", "upper time or tag of entries retrieved in left historized DSS")
+	syncCmd.Flags().StringVar(&syncOptions.RightTime, "righttime", "", "upper time or tag of entries retrieved in right historized DSS")
 	syncCmd.Flags().BoolVar(&syncOptions.NoACL, "noacl", false, "don't check ACL")
+	syncCmd.Flags().BoolVar(&syncOptions.Delta, "delta", false, "only transfer the differences of updated content to remote DSS supporting it")
+	syncCmd.Flags().BoolVar(&syncOptions.Moves, "moves", false, "copy moved or copied content within the target DSS instead of transferring it")
+	syncCmd.Flags().StringVar(&syncOptions.Conflicts, "conflicts", "", "detect content changed on both sides since the last bidirectional synchronization and resolve it: keep, left, right or abort")
+	syncCmd.Flags().BoolVar(&syncOptions.Resume, "resume", false, "skip the entries already synchronized by an interrupted synchronization if unchanged since")
+	syncCmd.Flags().BoolVar(&syncOptions.Incremental, "incremental", false, "only synchronize the entries of the left indexed DSS changed since the last synchronization")
+	syncCmd.Flags().BoolVar(&syncOptions.Watch, "watch", false, "keep running and synchronize the entries changed in the fsy left DSS as soon as they are stable")
+	syncCmd.Flags().IntVar(&syncOptions.WatchDelay, "watchdelay", 2, "with --watch, seconds without change before synchronizing the changed entries")
+	syncCmd.Flags().IntVar(&syncOptions.WatchFull, "watchfull", 3600, "with --watch, seconds between full synchronizations")
 	syncCmd.Flags().StringArrayVar(&syncOptions.MapACL, "macl", nil, "list of ACL user mapping <left-user:right-user> items")
 	syncCmd.PersistentFlags().StringArrayVar(&syncOptions.LeftUsers, "leftuser", nil, "list of ACL users for left-side retrieval")
 	syncCmd.PersistentFlags().StringArrayVar(&syncOptions.LeftACL, "leftacl", nil, "list of ACL <user:rights> items (defaults to rw) for left-side creation and update")
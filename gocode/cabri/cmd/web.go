@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/muesli/coral"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabriui"
 )
 
@@ -92,6 +93,84 @@ for instance
 	SilenceUsage: true,
 }
 
+var davApiCmd = &coral.Command{
+	Use:   "dav",
+	Short: "launches WebDAV server",
+	Long:  `launches WebDAV server enabling standard clients to browse and update local files or cloud object storage data`,
+	Args:  restApiCmd.Args,
+	RunE: func(cmd *coral.Command, args []string) error {
+		webApiOptions.BaseOptions = baseOptions
+		webApiOptions.IsDav = true
+		return cabriui.CLIRun[cabriui.WebApiOptions, *cabriui.WebApiVars](
+			cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+			webApiOptions, args,
+			cabriui.WebApiStartup, cabriui.WebApiShutdown)
+	},
+	SilenceUsage: true,
+}
+
+var webTokenOptions cabriui.WebTokenOptions
+
+func webTokenRunE(cmd *coral.Command, args []string) error {
+	webTokenOptions.BaseOptions = baseOptions
+	return cabriui.CLIRun[cabriui.WebTokenOptions, *cabriui.WebTokenVars](
+		cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+		webTokenOptions, args,
+		cabriui.WebTokenStartup, cabriui.WebTokenShutdown)
+}
+
+var webTokenCmd = &coral.Command{
+	Use:   "token",
+	Short: "manage API tokens",
+	Long:  `manage API tokens authenticating requests to https web API servers`,
+}
+
+var webTokenAddCmd = &coral.Command{
+	Use:   "add <name>",
+	Short: "add an API token",
+	Long:  `add an API token, displayed once as only its hash is stored`,
+	Args:  coral.ExactArgs(1),
+	RunE: func(cmd *coral.Command, args []string) error {
+		webTokenOptions.Add = true
+		for _, op := range webTokenOptions.Ops {
+			if err := cabridss.CheckApiOp(op); err != nil {
+				return err
+			}
+		}
+		if webTokenOptions.Expires != "" {
+			if _, err := cabriui.CheckTimeStamp(webTokenOptions.Expires); err != nil {
+				return err
+			}
+		}
+		return webTokenRunE(cmd, args)
+	},
+	SilenceUsage: true,
+}
+
+var webTokenListCmd = &coral.Command{
+	Use:   "list",
+	Short: "list API tokens",
+	Long:  `list API tokens with their operations, DSS roots, creation and expiry times`,
+	Args:  coral.NoArgs,
+	RunE: func(cmd *coral.Command, args []string) error {
+		webTokenOptions.List = true
+		return webTokenRunE(cmd, args)
+	},
+	SilenceUsage: true,
+}
+
+var webTokenRevokeCmd = &coral.Command{
+	Use:   "revoke <name>...",
+	Short: "revoke API tokens",
+	Long:  `revoke API tokens, running servers rejecting them immediately`,
+	Args:  coral.MinimumNArgs(1),
+	RunE: func(cmd *coral.Command, args []string) error {
+		webTokenOptions.Revoke = true
+		return webTokenRunE(cmd, args)
+	},
+	SilenceUsage: true,
+}
+
 func init() {
 	rootCmd.AddCommand(webApiCmd)
 	webApiCmd.PersistentFlags().StringVar(&baseOptions.ConfigDir, "cdir", "", "load configuration files from this directory instead of .cabri in home directory")
@@ -113,6 +192,21 @@ func init() {
 	restApiCmd.PersistentFlags().StringVar(&baseOptions.HUser, "huser", "", "http client user")
 	restApiCmd.PersistentFlags().StringVar(&baseOptions.HPFile, "hpfile", "", "file containing the http client user password")
 	restApiCmd.PersistentFlags().BoolVar(&baseOptions.HPassword, "hpassword", false, "force http client user password prompt")
-	restApiCmd.Flags().StringVar(&webApiOptions.LastTime, "lasttime", "", "upper time of entries retrieved in historized DSS")
+	restApiCmd.Flags().StringVar(&webApiOptions.LastTime, "lasttime", "", "upper time or tag of entries retrieved in historized DSS")
 	restApiCmd.Flags().StringVar(&webApiOptions.TlsClientCert, "tlsclientcrt", "", "untrusted CA on https client")
+	webApiCmd.AddCommand(davApiCmd)
+	davApiCmd.Flags().StringArrayVarP(&baseOptions.Users, "user", "u", nil, "list of ACL users for retrieval")
+	davApiCmd.Flags().StringArrayVar(&baseOptions.ACL, "acl", nil, "list of ACL <user:rights> items (defaults to rw) for creation and update")
+	davApiCmd.PersistentFlags().StringVar(&baseOptions.HUser, "huser", "", "http client user")
+	davApiCmd.PersistentFlags().StringVar(&baseOptions.HPFile, "hpfile", "", "file containing the http client user password")
+	davApiCmd.PersistentFlags().BoolVar(&baseOptions.HPassword, "hpassword", false, "force http client user password prompt")
+	davApiCmd.Flags().StringVar(&webApiOptions.LastTime, "lasttime", "", "upper time or tag of entries retrieved in historized DSS")
+	davApiCmd.Flags().StringVar(&webApiOptions.TlsClientCert, "tlsclientcrt", "", "untrusted CA on https client")
+	webApiCmd.AddCommand(webTokenCmd)
+	webTokenCmd.AddCommand(webTokenAddCmd)
+	webTokenAddCmd.Flags().StringArrayVar(&webTokenOptions.Roots, "root", nil, "list of DSS roots the token is valid for, all if none is given")
+	webTokenAddCmd.Flags().StringArrayVar(&webTokenOptions.Ops, "op", nil, "list of operations allowed with the token: read (default), write and/or admin (history removal, scan and purge, tags and convergence key storage)")
+	webTokenAddCmd.Flags().StringVar(&webTokenOptions.Expires, "expires", "", "expiry time of the token (RFC3339 timestamp or unix time), never if not given")
+	webTokenCmd.AddCommand(webTokenListCmd)
+	webTokenCmd.AddCommand(webTokenRevokeCmd)
 }
@@ -0,0 +1,273 @@
+package cabridss
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"filippo.io/age"
+	"filippo.io/edwards25519"
+	"fmt"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"sort"
+	"strings"
+)
+
+// convergent encryption produces the same age file for the same plaintext, recipients and repository secret:
+// the file key, the payload nonce and the ephemeral keys, which age draws at random,
+// are derived with HMAC-SHA256 from the repository secret, the plaintext checksum and the recipient.
+// The result is a regular age file that Decrypt and DecryptRange read as any other.
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// convergentKey derives 32 bytes from the repository secret, a label and the parts identifying their use
+func convergentKey(secret []byte, label string, parts ...string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(label))
+	for _, part := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return h.Sum(nil)
+}
+
+// x25519RecipientKey returns the public key bytes of an X25519 recipient encoded as a string
+func x25519RecipientKey(sr string) ([]byte, error) {
+	if _, err := age.ParseX25519Recipient(sr); err != nil {
+		return nil, err
+	}
+	// the bech32 data part is between the separator and the 6 characters checksum
+	data := strings.ToLower(sr[strings.LastIndexByte(sr, '1')+1 : len(sr)-6])
+	var (
+		key        []byte
+		acc, nbits int
+	)
+	for _, c := range data {
+		acc = acc<<5 | strings.IndexRune(bech32Charset, c)
+		nbits += 5
+		if nbits >= 8 {
+			nbits -= 8
+			key = append(key, byte(acc>>nbits))
+		}
+		acc &= 1<<nbits - 1
+	}
+	if len(key) != curve25519.PointSize {
+		return nil, fmt.Errorf("invalid X25519 recipient %s", sr)
+	}
+	return key, nil
+}
+
+// writeAgeStanza writes an age recipient stanza, its body being wrapped at ageColumns
+func writeAgeStanza(hdr *bytes.Buffer, st age.Stanza) {
+	hdr.WriteString("-> " + st.Type)
+	for _, arg := range st.Args {
+		hdr.WriteString(" " + arg)
+	}
+	hdr.WriteString("\n")
+	b64 := base64.RawStdEncoding.EncodeToString(st.Body)
+	for {
+		n := min(len(b64), ageColumns)
+		hdr.WriteString(b64[:n] + "\n")
+		if n < ageColumns {
+			break
+		}
+		b64 = b64[n:]
+	}
+}
+
+// convergentStanza wraps fileKey for the X25519 or SSH recipient sr with an ephemeral key derived from the secret
+func convergentStanza(secret []byte, ch, sr string, fileKey []byte) (age.Stanza, error) {
+	if isSshRecipient(sr) {
+		return convergentSshStanza(secret, ch, sr, fileKey)
+	}
+	theirKey, err := x25519RecipientKey(sr)
+	if err != nil {
+		return age.Stanza{}, err
+	}
+	ephemeral := convergentKey(secret, "ephemeral", ch, sr)
+	ourKey, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return age.Stanza{}, err
+	}
+	shared, err := curve25519.X25519(ephemeral, theirKey)
+	if err != nil {
+		return age.Stanza{}, err
+	}
+	wrappingKey := make([]byte, chacha20poly1305.KeySize)
+	salt := append(append([]byte{}, ourKey...), theirKey...)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte("age-encryption.org/v1/X25519")), wrappingKey); err != nil {
+		return age.Stanza{}, err
+	}
+	aead, err := chacha20poly1305.New(wrappingKey)
+	if err != nil {
+		return age.Stanza{}, err
+	}
+	return age.Stanza{
+		Type: "X25519",
+		Args: []string{base64.RawStdEncoding.EncodeToString(ourKey)},
+		Body: aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil),
+	}, nil
+}
+
+// convergentSshStanza wraps fileKey for the ssh-ed25519 or ssh-rsa recipient sr as agessh does,
+// the ephemeral key or the OAEP seed being derived from the secret
+func convergentSshStanza(secret []byte, ch, sr string, fileKey []byte) (age.Stanza, error) {
+	pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(sr))
+	if err != nil {
+		return age.Stanza{}, err
+	}
+	cpk, ok := pk.(ssh.CryptoPublicKey)
+	if !ok {
+		return age.Stanza{}, fmt.Errorf("unsupported SSH recipient %s", sr)
+	}
+	fp := sha256.Sum256(pk.Marshal())
+	fingerprint := base64.RawStdEncoding.EncodeToString(fp[:4])
+	switch key := cpk.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		// the OAEP seed is the only random input of the stanza
+		seed := hkdf.New(sha256.New, convergentKey(secret, "ephemeral", ch, sr), nil, []byte("ssh-rsa"))
+		body, err := rsa.EncryptOAEP(sha256.New(), seed, key, fileKey, []byte("age-encryption.org/v1/ssh-rsa"))
+		if err != nil {
+			return age.Stanza{}, err
+		}
+		return age.Stanza{Type: "ssh-rsa", Args: []string{fingerprint}, Body: body}, nil
+	case ed25519.PublicKey:
+		label := "age-encryption.org/v1/ssh-ed25519"
+		p, err := new(edwards25519.Point).SetBytes(key)
+		if err != nil {
+			return age.Stanza{}, err
+		}
+		theirKey := p.BytesMontgomery()
+		ephemeral := convergentKey(secret, "ephemeral", ch, sr)
+		ourKey, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+		if err != nil {
+			return age.Stanza{}, err
+		}
+		shared, err := curve25519.X25519(ephemeral, theirKey)
+		if err != nil {
+			return age.Stanza{}, err
+		}
+		tweak := make([]byte, curve25519.ScalarSize)
+		if _, err = io.ReadFull(hkdf.New(sha256.New, nil, pk.Marshal(), []byte(label)), tweak); err != nil {
+			return age.Stanza{}, err
+		}
+		if shared, err = curve25519.X25519(tweak, shared); err != nil {
+			return age.Stanza{}, err
+		}
+		wrappingKey := make([]byte, chacha20poly1305.KeySize)
+		salt := append(append([]byte{}, ourKey...), theirKey...)
+		if _, err = io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(label)), wrappingKey); err != nil {
+			return age.Stanza{}, err
+		}
+		aead, err := chacha20poly1305.New(wrappingKey)
+		if err != nil {
+			return age.Stanza{}, err
+		}
+		return age.Stanza{
+			Type: "ssh-ed25519",
+			Args: []string{fingerprint, base64.RawStdEncoding.EncodeToString(ourKey)},
+			Body: aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil),
+		}, nil
+	}
+	return age.Stanza{}, fmt.Errorf("unsupported SSH recipient %s", sr)
+}
+
+type convergentWriter struct {
+	dst   io.Writer
+	aead  cipher.AEAD
+	chunk int64
+	buf   []byte
+}
+
+func (cw *convergentWriter) seal(last bool) error {
+	var nonce [chacha20poly1305.NonceSize]byte
+	for i, c := 10, cw.chunk; i >= 0 && c != 0; i, c = i-1, c>>8 {
+		nonce[i] = byte(c)
+	}
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	if _, err := cw.dst.Write(cw.aead.Seal(nil, nonce[:], cw.buf, nil)); err != nil {
+		return err
+	}
+	cw.chunk++
+	cw.buf = cw.buf[:0]
+	return nil
+}
+
+func (cw *convergentWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is only sealed when more data follows, the last chunk being sealed on Close
+		if len(cw.buf) == ageChunkSize {
+			if err := cw.seal(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(cw.buf[len(cw.buf):ageChunkSize], p)
+		cw.buf = cw.buf[:len(cw.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (cw *convergentWriter) Close() error { return cw.seal(true) }
+
+// EncryptConvergent encrypts a file to one or more X25519 or SSH srs recipients encoded as strings,
+// deterministically for the same secret, plaintext checksum ch and recipients.
+//
+// Writes to the returned WriteCloser are encrypted and written to dst as an age file.
+// Every recipient will be able to decrypt the file.
+//
+// The caller must call Close on the WriteCloser when done for the last chunk to be encrypted and flushed to dst.
+func EncryptConvergent(dst io.Writer, secret []byte, ch string, srs ...string) (io.WriteCloser, error) {
+	if len(srs) == 0 {
+		return nil, fmt.Errorf("in EncryptConvergent: no recipient")
+	}
+	rcpts := append([]string{}, srs...)
+	sort.Strings(rcpts)
+	fileKey := convergentKey(secret, "file-key", ch)[:16]
+	hdr := bytes.Buffer{}
+	hdr.WriteString(ageIntro)
+	for i, sr := range rcpts {
+		if i > 0 && sr == rcpts[i-1] {
+			continue
+		}
+		st, err := convergentStanza(secret, ch, sr, fileKey)
+		if err != nil {
+			return nil, fmt.Errorf("in EncryptConvergent: %w", err)
+		}
+		writeAgeStanza(&hdr, st)
+	}
+	hdr.WriteString(ageFooter)
+	hmacKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, fileKey, nil, []byte("header")), hmacKey); err != nil {
+		return nil, fmt.Errorf("in EncryptConvergent: %w", err)
+	}
+	hh := hmac.New(sha256.New, hmacKey)
+	hh.Write(hdr.Bytes())
+	hdr.WriteString(" " + base64.RawStdEncoding.EncodeToString(hh.Sum(nil)) + "\n")
+
+	nonce := convergentKey(secret, "nonce", ch)[:ageNonceSize]
+	hdr.Write(nonce)
+	streamKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, fileKey, nonce, []byte("payload")), streamKey); err != nil {
+		return nil, fmt.Errorf("in EncryptConvergent: %w", err)
+	}
+	aead, err := chacha20poly1305.New(streamKey)
+	if err != nil {
+		return nil, fmt.Errorf("in EncryptConvergent: %w", err)
+	}
+	if _, err = dst.Write(hdr.Bytes()); err != nil {
+		return nil, fmt.Errorf("in EncryptConvergent: %w", err)
+	}
+	return &convergentWriter{dst: dst, aead: aead, buf: make([]byte, 0, ageChunkSize)}, nil
+}
@@ -0,0 +1,178 @@
+package cabridss
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh"
+	"strings"
+)
+
+// besides native X25519 age identities, IdentityConfig supports ssh-ed25519 and ssh-rsa keys:
+// PKey is then the public key in authorized_keys format without comment
+// and Secret the private key in unencrypted OpenSSH PEM format.
+//
+// A passphrase identity is an ssh-ed25519 key whose seed is derived with scrypt from a passphrase and a random salt,
+// so that it can be rebuilt anywhere from both for break-glass access.
+// Native age scrypt recipients cannot be used as age requires them to be the only recipient of a file.
+
+const (
+	scryptSaltSize = 16
+	scryptN        = 1 << 17
+	scryptR        = 8
+	scryptP        = 1
+)
+
+func isSshRecipient(sr string) bool {
+	return strings.HasPrefix(sr, "ssh-")
+}
+
+func isSshIdentity(sid string) bool {
+	return strings.HasPrefix(sid, "-----BEGIN")
+}
+
+// parseRecipient parses an X25519, ssh-ed25519 or ssh-rsa recipient encoded as a string
+func parseRecipient(sr string) (age.Recipient, error) {
+	if isSshRecipient(sr) {
+		return agessh.ParseRecipient(sr)
+	}
+	return age.ParseX25519Recipient(sr)
+}
+
+// parseIdentity parses an X25519 identity or an unencrypted ssh-ed25519 or ssh-rsa private key encoded as a string
+func parseIdentity(sid string) (age.Identity, error) {
+	if isSshIdentity(sid) {
+		return agessh.ParseIdentity([]byte(sid))
+	}
+	return age.ParseX25519Identity(sid)
+}
+
+func parseRecipients(srs []string) ([]age.Recipient, error) {
+	var rs []age.Recipient
+	for _, sr := range srs {
+		r, err := parseRecipient(sr)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func parseIdentities(sids []string) ([]age.Identity, error) {
+	var ids []age.Identity
+	for _, sid := range sids {
+		id, err := parseIdentity(sid)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func sshPKey(pk ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pk)))
+}
+
+func sshIdentityConfig(alias string, key interface{}) (IdentityConfig, error) {
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return IdentityConfig{}, err
+	}
+	pb, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return IdentityConfig{}, err
+	}
+	return IdentityConfig{Alias: alias, PKey: sshPKey(signer.PublicKey()), Secret: string(pem.EncodeToMemory(pb))}, nil
+}
+
+// SshIdentity returns the identity for an ssh-ed25519 or ssh-rsa key,
+// pubKey being in authorized_keys format and privKey in OpenSSH or PEM format, possibly encrypted with passphrase.
+//
+// If privKey is empty, the identity can only be used for encryption,
+// if pubKey is empty, it is derived from privKey.
+func SshIdentity(alias string, pubKey, privKey []byte, passphrase string) (IdentityConfig, error) {
+	var (
+		pk  ssh.PublicKey
+		err error
+	)
+	if len(pubKey) != 0 {
+		if pk, _, _, _, err = ssh.ParseAuthorizedKey(pubKey); err != nil {
+			return IdentityConfig{}, fmt.Errorf("in SshIdentity: %w", err)
+		}
+		if pk.Type() != ssh.KeyAlgoED25519 && pk.Type() != ssh.KeyAlgoRSA {
+			return IdentityConfig{}, fmt.Errorf("in SshIdentity: unsupported SSH key type %s", pk.Type())
+		}
+	}
+	if len(privKey) == 0 {
+		if pk == nil {
+			return IdentityConfig{}, fmt.Errorf("in SshIdentity: no key provided")
+		}
+		return IdentityConfig{Alias: alias, PKey: sshPKey(pk)}, nil
+	}
+	var key interface{}
+	if passphrase != "" {
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(privKey, []byte(passphrase))
+	} else {
+		key, err = ssh.ParseRawPrivateKey(privKey)
+	}
+	if err != nil {
+		var pme *ssh.PassphraseMissingError
+		if errors.As(err, &pme) {
+			return IdentityConfig{}, fmt.Errorf("in SshIdentity: %w", ErrPasswordRequired)
+		}
+		return IdentityConfig{}, fmt.Errorf("in SshIdentity: %w", err)
+	}
+	switch k := key.(type) {
+	case *ed25519.PrivateKey:
+		key = *k
+	case ed25519.PrivateKey, *rsa.PrivateKey:
+	default:
+		return IdentityConfig{}, fmt.Errorf("in SshIdentity: unsupported SSH key type %T", key)
+	}
+	idc, err := sshIdentityConfig(alias, key)
+	if err != nil {
+		return IdentityConfig{}, fmt.Errorf("in SshIdentity: %w", err)
+	}
+	if pk != nil && sshPKey(pk) != idc.PKey {
+		return IdentityConfig{}, fmt.Errorf("in SshIdentity: public and private keys don't match")
+	}
+	return idc, nil
+}
+
+// PassphraseIdentity returns the identity derived from a passphrase and an hex encoded salt,
+// a new salt being generated if none is provided
+func PassphraseIdentity(alias, passphrase, salt string) (IdentityConfig, error) {
+	if passphrase == "" {
+		return IdentityConfig{}, fmt.Errorf("in PassphraseIdentity: empty passphrase")
+	}
+	if salt == "" {
+		bs := make([]byte, scryptSaltSize)
+		if _, err := rand.Read(bs); err != nil {
+			return IdentityConfig{}, fmt.Errorf("in PassphraseIdentity: %w", err)
+		}
+		salt = hex.EncodeToString(bs)
+	}
+	sbs, err := hex.DecodeString(salt)
+	if err != nil || len(sbs) != scryptSaltSize {
+		return IdentityConfig{}, fmt.Errorf("in PassphraseIdentity: invalid salt %s", salt)
+	}
+	seed, err := scrypt.Key([]byte(passphrase), sbs, scryptN, scryptR, scryptP, ed25519.SeedSize)
+	if err != nil {
+		return IdentityConfig{}, fmt.Errorf("in PassphraseIdentity: %w", err)
+	}
+	idc, err := sshIdentityConfig(alias, ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		return IdentityConfig{}, fmt.Errorf("in PassphraseIdentity: %w", err)
+	}
+	idc.Salt = salt
+	return idc, nil
+}
@@ -0,0 +1,224 @@
+package cabridss
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"filippo.io/age"
+	"fmt"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"strings"
+)
+
+// an age file is made of a text header wrapping the file key for each recipient,
+// a 16 bytes nonce and the payload encrypted in chunks of 64 KiB, each chunk being
+// sealed independently with a nonce derived from its index (STREAM construction):
+// this enables decrypting a range of the plaintext without decrypting from the start
+
+const (
+	ageIntro        = "age-encryption.org/v1\n"
+	ageFooter       = "---"
+	ageColumns      = 64
+	ageMaxHeader    = 1024 * 1024
+	ageNonceSize    = 16
+	ageChunkSize    = 64 * 1024
+	ageEncChunkSize = ageChunkSize + chacha20poly1305.Overhead
+	ageRangeBatch   = 16 // number of chunks read at once
+)
+
+// ageStreamKey parses the header of the age file in src and unwraps its file key with one of ids,
+// returns the payload key and the offset of the first encrypted chunk
+func ageStreamKey(src io.ReaderAt, ids []age.Identity) ([]byte, int64, error) {
+	br := bufio.NewReader(io.NewSectionReader(src, 0, ageMaxHeader))
+	var hdr bytes.Buffer
+	readLine := func() (string, error) {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("failed to read header: %w", err)
+		}
+		hdr.WriteString(line)
+		return line, nil
+	}
+	line, err := readLine()
+	if err != nil {
+		return nil, 0, err
+	}
+	if line != ageIntro {
+		return nil, 0, fmt.Errorf("unexpected intro: %q", line)
+	}
+	var (
+		stanzas []*age.Stanza
+		mac     []byte
+		macLen  int
+	)
+	for {
+		if line, err = readLine(); err != nil {
+			return nil, 0, err
+		}
+		if strings.HasPrefix(line, ageFooter+" ") {
+			macLen = hdr.Len() - len(line) + len(ageFooter)
+			if mac, err = base64.RawStdEncoding.DecodeString(strings.TrimSuffix(line[len(ageFooter)+1:], "\n")); err != nil {
+				return nil, 0, fmt.Errorf("malformed closing line: %w", err)
+			}
+			break
+		}
+		if !strings.HasPrefix(line, "-> ") {
+			return nil, 0, fmt.Errorf("malformed stanza line: %q", line)
+		}
+		args := strings.Split(strings.TrimSuffix(line[3:], "\n"), " ")
+		var b64 string
+		for {
+			if line, err = readLine(); err != nil {
+				return nil, 0, err
+			}
+			bl := strings.TrimSuffix(line, "\n")
+			b64 += bl
+			if len(bl) < ageColumns {
+				break
+			}
+		}
+		body, err := base64.RawStdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("malformed stanza body: %w", err)
+		}
+		stanzas = append(stanzas, &age.Stanza{Type: args[0], Args: args[1:], Body: body})
+	}
+
+	var fileKey []byte
+	for _, id := range ids {
+		fileKey, err = id.Unwrap(stanzas)
+		if errors.Is(err, age.ErrIncorrectIdentity) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		break
+	}
+	if fileKey == nil {
+		return nil, 0, fmt.Errorf("no identity matched any of the recipients")
+	}
+	hmacKey := make([]byte, 32)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, fileKey, nil, []byte("header")), hmacKey); err != nil {
+		return nil, 0, err
+	}
+	hh := hmac.New(sha256.New, hmacKey)
+	hh.Write(hdr.Bytes()[:macLen])
+	if !hmac.Equal(hh.Sum(nil), mac) {
+		return nil, 0, fmt.Errorf("bad header MAC")
+	}
+
+	nonce := make([]byte, ageNonceSize)
+	if _, err = src.ReadAt(nonce, int64(hdr.Len())); err != nil {
+		return nil, 0, fmt.Errorf("failed to read nonce: %w", err)
+	}
+	streamKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, fileKey, nonce, []byte("payload")), streamKey); err != nil {
+		return nil, 0, err
+	}
+	return streamKey, int64(hdr.Len()) + ageNonceSize, nil
+}
+
+type ageRangeReader struct {
+	src     io.ReaderAt
+	aead    cipher.AEAD
+	payload int64  // offset of the first encrypted chunk
+	last    int64  // index of the last chunk
+	chunk   int64  // index of the next chunk to decrypt
+	skip    int    // plaintext bytes to skip in the next chunk
+	left    int64  // plaintext bytes left to read
+	encBuf  []byte // encrypted chunks read and not yet decrypted
+	unread  []byte // decrypted bytes not yet read
+}
+
+func (arr *ageRangeReader) nextChunk() error {
+	if len(arr.encBuf) == 0 {
+		n := min(int64(ageRangeBatch), arr.last-arr.chunk+1)
+		buf := make([]byte, n*ageEncChunkSize)
+		rn, err := arr.src.ReadAt(buf, arr.payload+arr.chunk*ageEncChunkSize)
+		if err != nil && !(err == io.EOF && arr.chunk+n-1 == arr.last) {
+			return err
+		}
+		arr.encBuf = buf[:rn]
+	}
+	in := arr.encBuf[:min(len(arr.encBuf), ageEncChunkSize)]
+	arr.encBuf = arr.encBuf[len(in):]
+	var nonce [chacha20poly1305.NonceSize]byte
+	for i, c := 10, arr.chunk; i >= 0 && c != 0; i, c = i-1, c>>8 {
+		nonce[i] = byte(c)
+	}
+	if arr.chunk == arr.last {
+		nonce[len(nonce)-1] = 1
+	}
+	out, err := arr.aead.Open(nil, nonce[:], in, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt and authenticate payload chunk %d", arr.chunk)
+	}
+	arr.chunk++
+	if arr.skip > len(out) {
+		return io.ErrUnexpectedEOF
+	}
+	arr.unread = out[arr.skip:]
+	arr.skip = 0
+	return nil
+}
+
+func (arr *ageRangeReader) Read(p []byte) (int, error) {
+	if arr.left == 0 {
+		return 0, io.EOF
+	}
+	if len(arr.unread) == 0 {
+		if arr.chunk > arr.last {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err := arr.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p[:min(int64(len(p)), arr.left)], arr.unread)
+	arr.unread = arr.unread[n:]
+	arr.left -= int64(n)
+	return n, nil
+}
+
+// DecryptRange decrypts length bytes from offset of the plaintext of an age file read from src,
+// size being the plaintext size, with one of the sids X25519 or SSH identities encoded as strings.
+//
+// It returns a Reader reading the decrypted range, only the chunks it covers being read from src.
+func DecryptRange(src io.ReaderAt, size, offset, length int64, sids ...string) (io.Reader, error) {
+	ids, err := parseIdentities(sids)
+	if err != nil {
+		return nil, fmt.Errorf("in DecryptRange: %w", err)
+	}
+	length, err = checkRange(size, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("in DecryptRange: %w", err)
+	}
+	key, payload, err := ageStreamKey(src, ids)
+	if err != nil {
+		return nil, fmt.Errorf("in DecryptRange: %w", err)
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("in DecryptRange: %w", err)
+	}
+	last := int64(0)
+	if size > 0 {
+		last = (size - 1) / ageChunkSize
+	}
+	return &ageRangeReader{
+		src:     src,
+		aead:    aead,
+		payload: payload,
+		last:    last,
+		chunk:   offset / ageChunkSize,
+		skip:    int(offset % ageChunkSize),
+		left:    length,
+	}, nil
+}
@@ -13,13 +13,17 @@ import (
 var ageId age.Identity
 
 // IdentityConfig refers to an age identity identified by an alias,
+// either a native X25519 identity, an ssh-ed25519 or ssh-rsa key, or a key derived from a passphrase and a salt.
 // Identities are used for encryption (PKeys of the ACL users using identities aliases)
 // and for decryption (secrets of the DSS aclusers using identities aliases)
 // "" is the default alias for an identity when none is provided
 type IdentityConfig struct {
-	Alias  string `json:"alias"`
-	PKey   string `json:"pKey"`
-	Secret string `json:"secret"`
+	Alias    string `json:"alias"`
+	PKey     string `json:"pKey"`
+	Secret   string `json:"secret"`
+	Salt     string `json:"salt,omitempty"`     // scrypt salt of a passphrase identity
+	SignPKey string `json:"signPKey,omitempty"` // ed25519 signing public key
+	SignKey  string `json:"signKey,omitempty"`  // ed25519 signing key seed, possibly unknown (see sign.go)
 }
 
 func GenIdentity(alias string) (IdentityConfig, error) {
@@ -27,23 +31,19 @@ func GenIdentity(alias string) (IdentityConfig, error) {
 	if err != nil {
 		return IdentityConfig{}, fmt.Errorf("in GenIdentity: %w", err)
 	}
-	return IdentityConfig{alias, xi.Recipient().String(), xi.String()}, nil
+	return IdentityConfig{Alias: alias, PKey: xi.Recipient().String(), Secret: xi.String()}, nil
 }
 
-// EncryptMsg encrypts a msg to one or more X25519 srs recipients encoded as strings.
+// EncryptMsg encrypts a msg to one or more X25519 or SSH srs recipients encoded as strings.
 //
 // Every recipient will be able to decrypt the result.
 //
 // It returns the encrypted content as json encoded bytes.
 func EncryptMsg(msg string, srs ...string) ([]byte, error) {
 	bsa := bytes.Buffer{}
-	var rs []age.Recipient
-	for _, sr := range srs {
-		r, err := age.ParseX25519Recipient(sr)
-		if err != nil {
-			return nil, fmt.Errorf("in EncryptMsg: %w", err)
-		}
-		rs = append(rs, r)
+	rs, err := parseRecipients(srs)
+	if err != nil {
+		return nil, fmt.Errorf("in EncryptMsg: %w", err)
 	}
 	wc, err := age.Encrypt(&bsa, rs...)
 	if err != nil {
@@ -64,7 +64,7 @@ func EncryptMsg(msg string, srs ...string) ([]byte, error) {
 	return bsb, nil
 }
 
-// DecryptMsg decrypts jbs encrypted content to one or more sids X25519 identities encoded as strings.
+// DecryptMsg decrypts jbs encrypted content to one or more sids X25519 or SSH identities encoded as strings.
 // It returns the message in cleartext
 //
 // jbs are the json encoded bytes
@@ -76,13 +76,9 @@ func DecryptMsg(jbs []byte, sids ...string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("in DecryptMsg: %w", err)
 	}
-	var ids []age.Identity
-	for _, sid := range sids {
-		id, err := age.ParseX25519Identity(sid)
-		if err != nil {
-			return "", fmt.Errorf("in DecryptMsg: %w", err)
-		}
-		ids = append(ids, id)
+	ids, err := parseIdentities(sids)
+	if err != nil {
+		return "", fmt.Errorf("in DecryptMsg: %w", err)
 	}
 	rd, err := age.Decrypt(bytes.NewReader(bs), ids...)
 	if err != nil {
@@ -95,36 +91,28 @@ func DecryptMsg(jbs []byte, sids ...string) (string, error) {
 	return string(bss), nil
 }
 
-// Encrypt encrypts a file to one or more X25519 srs recipients encoded as strings.
+// Encrypt encrypts a file to one or more X25519 or SSH srs recipients encoded as strings.
 //
 // Writes to the returned WriteCloser are encrypted and written to dst as an age file.
 // Every recipient will be able to decrypt the file.
 //
 // The caller must call Close on the WriteCloser when done for the last chunk to be encrypted and flushed to dst.
 func Encrypt(dst io.Writer, srs ...string) (io.WriteCloser, error) {
-	var rs []age.Recipient
-	for _, sr := range srs {
-		r, err := age.ParseX25519Recipient(sr)
-		if err != nil {
-			return nil, fmt.Errorf("in EncryptMsg: %w", err)
-		}
-		rs = append(rs, r)
+	rs, err := parseRecipients(srs)
+	if err != nil {
+		return nil, fmt.Errorf("in Encrypt: %w", err)
 	}
 	return age.Encrypt(dst, rs...)
 }
 
-// Decrypt decrypts a file encrypted to one or more sids X25519 identities encoded as strings.
+// Decrypt decrypts a file encrypted to one or more sids X25519 or SSH identities encoded as strings.
 //
 // It returns a Reader reading the decrypted plaintext of the age file read from src.
 // All identities will be tried until one successfully decrypts the file.
 func Decrypt(src io.Reader, sids ...string) (io.Reader, error) {
-	var ids []age.Identity
-	for _, sid := range sids {
-		id, err := age.ParseX25519Identity(sid)
-		if err != nil {
-			return nil, fmt.Errorf("in DecryptMsg: %w", err)
-		}
-		ids = append(ids, id)
+	ids, err := parseIdentities(sids)
+	if err != nil {
+		return nil, fmt.Errorf("in Decrypt: %w", err)
 	}
 	return age.Decrypt(src, ids...)
 }
@@ -2,10 +2,17 @@ package cabridss
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"filippo.io/age"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"golang.org/x/crypto/ssh"
 	"io"
 	"strings"
 	"testing"
@@ -267,3 +274,198 @@ func TestEncryptFileWithPass(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestEncryptConvergent(t *testing.T) {
+	id1, err := GenIdentity("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := GenIdentity("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypt := func(secret string, msg []byte, srs ...string) []byte {
+		bsa := bytes.Buffer{}
+		wc, err := EncryptConvergent(&bsa, []byte(secret), internal.BytesToSha256Str(msg), srs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = wc.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		if err = wc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return bsa.Bytes()
+	}
+	for _, size := range []int{0, 10, ageChunkSize, ageChunkSize + 1, 3*ageChunkSize + 100} {
+		msg := bytes.Repeat([]byte("0123456789abcdef"), size/16+1)[:size]
+		ebs := encrypt("secret", msg, id1.PKey, id2.PKey)
+		if !bytes.Equal(ebs, encrypt("secret", msg, id2.PKey, id1.PKey)) {
+			t.Fatalf("TestEncryptConvergent size %d is not deterministic", size)
+		}
+		if bytes.Equal(ebs, encrypt("other secret", msg, id1.PKey, id2.PKey)) {
+			t.Fatalf("TestEncryptConvergent size %d doesn't depend on the secret", size)
+		}
+		for _, sid := range []string{id1.Secret, id2.Secret} {
+			rd, err := Decrypt(bytes.NewReader(ebs), sid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bs, err := io.ReadAll(rd)
+			if err != nil || !bytes.Equal(bs, msg) {
+				t.Fatalf("TestEncryptConvergent size %d decrypted %d %v", size, len(bs), err)
+			}
+		}
+		if size > 10 {
+			rd, err := DecryptRange(bytes.NewReader(ebs), int64(size), int64(size-10), 10, id2.Secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bs, err := io.ReadAll(rd)
+			if err != nil || !bytes.Equal(bs, msg[size-10:]) {
+				t.Fatalf("TestEncryptConvergent size %d range %s %v", size, bs, err)
+			}
+		}
+	}
+	if _, err = EncryptConvergent(&bytes.Buffer{}, []byte("secret"), "ch", "age1invalid"); err == nil {
+		t.Fatal("TestEncryptConvergent invalid recipient should fail")
+	}
+}
+
+func genSshKeys(t *testing.T, rsaKey bool, passphrase string) (pubKey, privKey []byte) {
+	var key interface{}
+	if rsaKey {
+		rk, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key = rk
+	} else {
+		_, ek, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key = ek
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pb *pem.Block
+	if passphrase != "" {
+		pb, err = ssh.MarshalPrivateKeyWithPassphrase(key, "comment", []byte(passphrase))
+	} else {
+		pb, err = ssh.MarshalPrivateKey(key, "comment")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ssh.MarshalAuthorizedKey(signer.PublicKey()), pem.EncodeToMemory(pb)
+}
+
+func TestSshIdentity(t *testing.T) {
+	xid, err := GenIdentity("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rsaKey := range []bool{false, true} {
+		pub, priv := genSshKeys(t, rsaKey, "")
+		eid, err := SshIdentity("ssh", pub, priv, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(eid.PKey, "ssh-") || strings.Contains(eid.PKey, "comment") || !strings.HasPrefix(eid.Secret, "-----BEGIN") {
+			t.Fatalf("TestSshIdentity %v", eid)
+		}
+		epub, err := SshIdentity("ssh", pub, nil, "")
+		if err != nil || epub.PKey != eid.PKey || epub.Secret != "" {
+			t.Fatalf("TestSshIdentity public only %v %v", epub, err)
+		}
+		pub2, priv2 := genSshKeys(t, rsaKey, "passphrase")
+		if _, err = SshIdentity("ssh", pub2, priv2, ""); !errors.Is(err, ErrPasswordRequired) {
+			t.Fatalf("TestSshIdentity should require a passphrase %v", err)
+		}
+		eid2, err := SshIdentity("ssh2", nil, priv2, "passphrase")
+		if err != nil || eid2.PKey != strings.TrimSpace(string(pub2)) {
+			t.Fatalf("TestSshIdentity with passphrase %v %v", eid2, err)
+		}
+		if _, err = SshIdentity("ssh", pub, priv2, "passphrase"); err == nil {
+			t.Fatal("TestSshIdentity keys mismatch should fail")
+		}
+
+		em, err := EncryptMsg("TestSshIdentity", xid.PKey, eid.PKey, eid2.PKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sid := range []string{xid.Secret, eid.Secret, eid2.Secret} {
+			if dm, err := DecryptMsg(em, sid); err != nil || dm != "TestSshIdentity" {
+				t.Fatal(err, dm)
+			}
+		}
+		msg := bytes.Repeat([]byte("0123456789abcdef"), ageChunkSize/8)
+		for _, secret := range []string{"", "secret"} {
+			bsa := bytes.Buffer{}
+			var wc io.WriteCloser
+			if secret == "" {
+				wc, err = Encrypt(&bsa, eid.PKey, xid.PKey)
+			} else {
+				wc, err = EncryptConvergent(&bsa, []byte(secret), internal.BytesToSha256Str(msg), eid.PKey, xid.PKey)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = wc.Write(msg); err != nil {
+				t.Fatal(err)
+			}
+			if err = wc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			rd, err := Decrypt(bytes.NewReader(bsa.Bytes()), eid.Secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bs, err := io.ReadAll(rd); err != nil || !bytes.Equal(bs, msg) {
+				t.Fatalf("TestSshIdentity decrypted %d %v", len(bs), err)
+			}
+			rd, err = DecryptRange(bytes.NewReader(bsa.Bytes()), int64(len(msg)), int64(len(msg)-10), 10, eid.Secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bs, err := io.ReadAll(rd); err != nil || !bytes.Equal(bs, msg[len(msg)-10:]) {
+				t.Fatalf("TestSshIdentity range %s %v", bs, err)
+			}
+		}
+	}
+}
+
+func TestPassphraseIdentity(t *testing.T) {
+	pid, err := PassphraseIdentity("break-glass", "passphrase", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid.Salt == "" || !strings.HasPrefix(pid.PKey, "ssh-ed25519 ") {
+		t.Fatalf("TestPassphraseIdentity %v", pid)
+	}
+	pid2, err := PassphraseIdentity("break-glass", "passphrase", pid.Salt)
+	if err != nil || pid2.PKey != pid.PKey {
+		t.Fatalf("TestPassphraseIdentity rebuild %v %v", pid2, err)
+	}
+	pid3, err := PassphraseIdentity("break-glass", "other", pid.Salt)
+	if err != nil || pid3.PKey == pid.PKey {
+		t.Fatalf("TestPassphraseIdentity other passphrase %v %v", pid3, err)
+	}
+	if _, err = PassphraseIdentity("break-glass", "passphrase", "00"); err == nil {
+		t.Fatal("TestPassphraseIdentity invalid salt should fail")
+	}
+	em, err := EncryptMsg("TestPassphraseIdentity", pid.PKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dm, err := DecryptMsg(em, pid2.Secret); err != nil || dm != "TestPassphraseIdentity" {
+		t.Fatal(err, dm)
+	}
+	if _, err := DecryptMsg(em, pid3.Secret); err == nil {
+		t.Fatal("TestPassphraseIdentity other passphrase should not decrypt")
+	}
+}
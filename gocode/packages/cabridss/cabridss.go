@@ -72,6 +72,52 @@ type Dss interface {
 	// - err error if any happens
 	GetContentReader(npath string) (io.ReadCloser, error)
 
+	// GetContentRangeReader opens a range of some content for reading
+	//
+	// npath is the full namespace + name without leading slash
+	// offset is the position of the first byte of the range
+	// length is the length of the range, up to the end of the content if negative
+	//
+	// returns:
+	// - a reader to retrieve the range of content, see also NewContentReaderAt
+	// - err error if any happens, wrapping ErrInvalidRange if offset is beyond the end of the content
+	GetContentRangeReader(npath string, offset, length int64) (io.ReadCloser, error)
+
+	// GetContentSignatures computes the block signatures of some existing content for a delta transfer
+	//
+	// npath is the full namespace + name without leading slash
+	// blockSize is the size of the blocks, see DeltaBlockSize
+	//
+	// returns:
+	// - the signatures to be provided to WriteDelta
+	// - err error if any happens, wrapping ErrDeltaNotSupported if the DSS cannot provide delta transfer
+	GetContentSignatures(npath string, blockSize int) (*ContentSignatures, error)
+
+	// GetContentDeltaWriter updates some existing content from a delta
+	//
+	// npath is the full namespace + name without leading slash
+	// mtime is the last modification POSIX time
+	// acl is the access control List to the content
+	// cb if not nil is a callback called when writer is closed
+	//
+	// returns:
+	// - a writer to provide the delta as produced by WriteDelta against the content signatures
+	// - err error if any happens
+	GetContentDeltaWriter(npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (io.WriteCloser, error)
+
+	// CopyContent creates or updates some content with the content of another one in the same DSS,
+	// without transferring it when the DSS stores content by checksum
+	//
+	// spath is the full namespace + name of the source content without leading slash
+	// npath is the full namespace + name without leading slash
+	// ch is the expected checksum of the source content, the copy fails if it has changed
+	// mtime is the last modification POSIX time
+	// acl is the access control List to the content
+	//
+	// returns:
+	// - err error if any happens, wrapping ErrCopyNotSupported if the DSS cannot share the source content
+	CopyContent(spath, npath, ch string, mtime int64, acl []ACLEntry) error
+
 	// Symlink makes a symlink from npath to target path
 	//
 	// npath is the full namespace + name without leading slash
@@ -83,6 +129,16 @@ type Dss interface {
 	// - err error if any happens
 	Symlink(npath string, tpath string, mtime int64, acl []ACLEntry) error
 
+	// Rename moves a namespace (and recursively its children) or some content to a new path
+	//
+	// src is the full namespace + name without leading slash, trailing slash indicates it is a namespace
+	// dst is the new full namespace + name, with a trailing slash if src has one, it must not exist
+	// mtime is the last modification POSIX time of the updated parent namespaces
+	//
+	// returns:
+	// - err error if any happens
+	Rename(src, dst string, mtime int64) error
+
 	// Remove removes a namespace (and recursively its children) or some content
 	//
 	// npath is the full namespace + name without leading slash, trailing slash indicates it is a namespace
@@ -186,7 +242,7 @@ func (hi HistoryInfo) String() string {
 }
 
 type AuditIndexInfo struct {
-	Error string // "IndexInternal", "IndexMissing", "StorageMissing", "Inconsistent"
+	Error string // "IndexInternal", "IndexMissing", "StorageMissing", "Inconsistent", "ChunkMissing", "Untrusted"
 	Err   error  // origin error
 	Time  int64  // the time of the entry in the DSS
 	Bytes []byte // the metadata
@@ -210,9 +266,11 @@ type StorageInfo struct {
 	ExistingEcs   map[string]bool             `json:"existingEcs"`
 	Path2Content  map[string]string           `json:"path2Content"`
 	Path2CContent map[string]string           `json:"path2CContent"`
+	Path2Manifest map[string]string           `json:"path2Manifest"` // chunk manifests
 	Path2Error    map[string]error            `json:"path2Error"`
-	XLMetas       map[string]map[int64][]byte `json:"xlmetas"` // Local meta data
-	XRMetas       map[string]map[int64][]byte `json:"xrmetas"` // Remote meta data
+	Untrusted     map[string]string           `json:"untrusted"` // untrusted meta data, still used so not invalidating the scan
+	XLMetas       map[string]map[int64][]byte `json:"xlmetas"`   // Local meta data
+	XRMetas       map[string]map[int64][]byte `json:"xrmetas"`   // Remote meta data
 }
 
 type HistoryChunk struct {
@@ -247,13 +305,44 @@ type HDss interface {
 	// npath is the full namespace + name without leading slash, trailing slash indicates it is a namespace
 	// recursive requests the service to recursively remove the history of all namespace children,
 	// evaluate don't remove, just report work to be done
+	// force removes entries pinned by a tag, otherwise an error is returned if any would be removed
 	// start is the inclusive index time above which entries must be removed, zero meanning all past entries
 	// end is the inclusive index time below which entries must be removed, zero meaning all future entries
 	//
 	// returns:
 	// - the history (inclusive times when the entry is removed) for all entries
 	// - err error if any happens
-	RemoveHistory(npath string, recursive, evaluate bool, start, end int64) (map[string][]HistoryInfo, error)
+	RemoveHistory(npath string, recursive, evaluate, force bool, start, end int64) (map[string][]HistoryInfo, error)
+
+	// ApplyRetention removes history entries not kept by a retention policy
+	//
+	// as with RemoveHistory, removing a parent history may cause children to be removed for a larger period of time,
+	// content no longer referenced is not removed, see ScanStorage for this
+	//
+	// npath is the full namespace + name without leading slash, trailing slash indicates it is a namespace
+	// recursive requests the service to recursively apply the policy to all namespace children,
+	// evaluate don't remove, just report work to be done
+	// force removes entries pinned by a tag, which are otherwise kept
+	// rp is the retention policy
+	// now is the POSIX time from which entries age is measured, zero meaning the current time
+	//
+	// returns:
+	// - the history (inclusive times when the entry is removed) for all entries
+	// - err error if any happens
+	ApplyRetention(npath string, recursive, evaluate, force bool, rp RetentionPolicy, now int64) (map[string][]HistoryInfo, error)
+
+	// GetChanges provides the entries whose metadata were indexed since the previous call for a given client,
+	// including the ones indexed with an older time, such as those received from other clients
+	//
+	// npath is the namespace path without leading slash, the entries being provided within this namespace
+	// clId is the client id recorded in the DSS index on the first call
+	// reset discards the pending changes, for instance when they could not be processed
+	//
+	// returns:
+	// - the full paths of the changed entries, trailing slash indicating a namespace, the root one being ""
+	// - isFull true if the client was just recorded or reset, all entries being to be processed
+	// - err error if any happens, the DSS index being required to be persistent
+	GetChanges(npath string, clId string, reset bool) (changed []string, isFull bool, err error)
 
 	// GetIndex provides the DSS index or nil
 	GetIndex() Index
@@ -270,6 +359,15 @@ type HDss interface {
 	// IsRepoEncrypted tells if repository configuration is set to encrypted
 	IsRepoEncrypted() bool
 
+	// IsRepoCompressed tells if repository configuration is set to compressed
+	IsRepoCompressed() bool
+
+	// GetRepoCompression returns the compression algorithm of a compressed repository, gzip if empty
+	GetRepoCompression() string
+
+	// IsRepoConvergent tells if repository configuration is set to convergent encryption
+	IsRepoConvergent() bool
+
 	// AuditIndex compares the DSS index with meta and content actually stored
 	AuditIndex() (map[string][]AuditIndexInfo, error)
 
@@ -289,6 +387,38 @@ type HDss interface {
 	// - err error if any happens
 	GetHistoryChunks(resolution string) ([]HistoryChunk, error)
 
+	// AtTime provides a read-only view of the DSS with entries as of slsttime,
+	// as if it were opened with this last time, closing the view has no effect
+	//
+	// if the DSS was itself opened with a last time, the view doesn't go beyond it
+	AtTime(slsttime int64) (HDss, error)
+
+	// CreateTag names the DSS state at slsttime POSIX time, zero meaning the current time
+	//
+	// tags are stored in the repository, they are immutable and pin the history entries visible at their time
+	CreateTag(name string, slsttime int64) error
+
+	// ListTags returns the DSS tags sorted by time
+	ListTags() ([]Tag, error)
+
+	// DeleteTag removes a tag, releasing the history entries it pins
+	DeleteTag(name string) error
+
+	// ResolveTag returns the POSIX time of a tag
+	ResolveTag(name string) (int64, error)
+
+	// Rekey re-encrypts the history entries of npath for the users their ACL users are mapped to,
+	// only supported by encrypted DSS, see RekeyOptions
+	//
+	// npath is the full namespace + name without leading slash, trailing slash indicates it is a namespace
+	// recursive requests the service to recursively rekey all namespace children
+	// opts tells how entries are rekeyed
+	//
+	// returns:
+	// - the history entries rekeyed with their new metadata
+	// - err error if any happens, wrapping ErrRekeyNotSupported if the DSS is not encrypted
+	Rekey(npath string, recursive bool, opts RekeyOptions) (map[string][]HistoryInfo, error)
+
 	// Reindex scans the DSS storage and loads meta and content sha256 sum into the index
 	Reindex() (StorageInfo, *ErrorCollector)
 }
@@ -368,6 +498,32 @@ func checkMknsArgs(npath string, children []string, acl []ACLEntry) error {
 	return nil
 }
 
+// checkRenameArgs checks that src and dst are both namespaces or both contents and that dst is not within src
+func checkRenameArgs(src, dst string) (isNS bool, sipath, dipath string, err error) {
+	var dIsNS bool
+	if isNS, sipath, err = checkNCpath(src); err != nil {
+		return
+	}
+	if dIsNS, dipath, err = checkNCpath(dst); err != nil {
+		return
+	}
+	if sipath == "" || dipath == "" {
+		err = fmt.Errorf("cannot rename root")
+		return
+	}
+	if isNS != dIsNS {
+		err = fmt.Errorf("%s and %s must be both namespaces or both contents", src, dst)
+		return
+	}
+	if err = checkName(ufpath.Base(dipath)); err != nil {
+		return
+	}
+	if dipath == sipath || strings.HasPrefix(dipath, sipath+"/") {
+		err = fmt.Errorf("cannot rename %s to %s", src, dst)
+	}
+	return
+}
+
 func checkMkcontentArgs(npath string, acl []ACLEntry) error {
 	if err := checkNpath(npath); err != nil {
 		return err
@@ -384,6 +540,9 @@ type CreateNewParams struct {
 	Size           string                                                      // if olf: s,m,l
 	LocalPath      string                                                      // fsy, obs, smf (Root assumed if olf or smf)
 	Encrypted      bool                                                        // all but fsy: enable repository encryption
+	Compressed     bool                                                        // all but fsy: enable content compression
+	Compression    string                                                      // all but fsy: compression algorithm if compressed, gzip if empty or zstd
+	Convergent     bool                                                        // all but fsy: enable convergent encryption if encrypted
 	GetIndex       func(config DssBaseConfig, localPath string) (Index, error) // see DssBaseConfig
 	Lsttime        int64                                                       // all but fsy: if not zero is the upper time of entries retrieved in it
 	Aclusers       []string                                                    // all but fsy: if not nil is a List of ACL users for access check
@@ -402,7 +561,7 @@ func CreateOrNewDss(params CreateNewParams) (dss Dss, err error) {
 			localPath = params.Root
 		}
 		config := OlfConfig{
-			DssBaseConfig: DssBaseConfig{ConfigDir: params.ConfigDir, ConfigPassword: params.ConfigPassword, LocalPath: localPath, GetIndex: params.GetIndex, Encrypted: params.Encrypted, ReducerLimit: params.RedLimit},
+			DssBaseConfig: DssBaseConfig{ConfigDir: params.ConfigDir, ConfigPassword: params.ConfigPassword, LocalPath: localPath, GetIndex: params.GetIndex, Encrypted: params.Encrypted, Compressed: params.Compressed, Compression: params.Compression, Convergent: params.Convergent, ReducerLimit: params.RedLimit},
 			Root:          params.Root, Size: params.Size,
 		}
 		if params.Create {
@@ -414,7 +573,7 @@ func CreateOrNewDss(params CreateNewParams) (dss Dss, err error) {
 	}
 	if params.DssType == "obs" {
 		config := ObsConfig{
-			DssBaseConfig: DssBaseConfig{ConfigDir: params.ConfigDir, ConfigPassword: params.ConfigPassword, LocalPath: params.LocalPath, GetIndex: params.GetIndex, Encrypted: params.Encrypted, ReducerLimit: params.RedLimit},
+			DssBaseConfig: DssBaseConfig{ConfigDir: params.ConfigDir, ConfigPassword: params.ConfigPassword, LocalPath: params.LocalPath, GetIndex: params.GetIndex, Encrypted: params.Encrypted, Compressed: params.Compressed, Compression: params.Compression, Convergent: params.Convergent, ReducerLimit: params.RedLimit},
 			Endpoint:      params.Endpoint,
 			Region:        params.Region,
 			AccessKey:     params.AccessKey,
@@ -434,7 +593,7 @@ func CreateOrNewDss(params CreateNewParams) (dss Dss, err error) {
 			localPath = params.Root
 		}
 		config := ObsConfig{
-			DssBaseConfig: DssBaseConfig{ConfigDir: params.ConfigDir, ConfigPassword: params.ConfigPassword, LocalPath: localPath, GetIndex: params.GetIndex, Encrypted: params.Encrypted, ReducerLimit: params.RedLimit},
+			DssBaseConfig: DssBaseConfig{ConfigDir: params.ConfigDir, ConfigPassword: params.ConfigPassword, LocalPath: localPath, GetIndex: params.GetIndex, Encrypted: params.Encrypted, Compressed: params.Compressed, Compression: params.Compression, Convergent: params.Convergent, ReducerLimit: params.RedLimit},
 			Endpoint:      params.Endpoint,
 			Region:        params.Region,
 			AccessKey:     params.AccessKey,
@@ -0,0 +1,233 @@
+package cabridss
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/afero"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
+	"io"
+)
+
+// content-defined chunking (CDC) is enabled per repository with DssBaseConfig.Chunked:
+// content is split with a gear rolling hash into chunks, each chunk is stored as a regular content
+// blob named by its own checksum, and a chunk manifest (the ordered list of chunk checksums)
+// is stored under the whole content checksum. The manifest is also inlined in Meta.Chunks
+// unless it would exceed MAX_META_CHUNKS entries.
+// Content smaller than CHUNK_MIN_SIZE is stored as a single regular blob, as without chunking.
+
+const (
+	CHUNK_MIN_SIZE  = 256 * 1024      // minimum chunk size
+	CHUNK_MAX_SIZE  = 4 * 1024 * 1024 // maximum chunk size
+	CHUNK_AVG_BITS  = 20              // log2 of the average chunk size (1 MiB)
+	MAX_META_CHUNKS = 2000            // maximum number of chunks inlined in Meta
+)
+
+var chunkMask = uint64(1<<CHUNK_AVG_BITS-1) << (64 - CHUNK_AVG_BITS)
+
+var gearTable = func() (gt [256]uint64) {
+	// splitmix64 gives a fixed pseudo-random table, which must never change
+	// as chunk boundaries of stored content depend on it
+	seed := uint64(0x636162726963646)
+	for i := range gt {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gt[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+type chunker struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func newChunker(r io.Reader) *chunker {
+	return &chunker{r: bufio.NewReaderSize(r, 256*1024), buf: make([]byte, 0, CHUNK_MAX_SIZE)}
+}
+
+// next returns the next chunk, the returned slice is only valid until the following call
+// returns io.EOF when the content is exhausted
+func (ck *chunker) next() ([]byte, error) {
+	ck.buf = ck.buf[:0]
+	var h uint64
+	for {
+		b, err := ck.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(ck.buf) > 0 {
+				return ck.buf, nil
+			}
+			return nil, err
+		}
+		ck.buf = append(ck.buf, b)
+		h = (h << 1) + gearTable[b]
+		if len(ck.buf) < CHUNK_MIN_SIZE {
+			continue
+		}
+		if h&chunkMask == 0 || len(ck.buf) >= CHUNK_MAX_SIZE {
+			return ck.buf, nil
+		}
+	}
+}
+
+// splitChunks calls cb for each chunk of r with its checksum
+func splitChunks(r io.Reader, cb func(cch string, bs []byte) error) error {
+	ck := newChunker(r)
+	for {
+		bs, err := ck.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(bs)
+		if err = cb(internal.Sha256ToStr32(h[:]), bs); err != nil {
+			return err
+		}
+	}
+}
+
+func encodeChunkManifest(chunks []string) ([]byte, error) {
+	return json.Marshal(chunks)
+}
+
+func decodeChunkManifest(bs []byte) (chunks []string, err error) {
+	err = json.Unmarshal(bs, &chunks)
+	return
+}
+
+// storeChunks splits the content of file cf with checksum ch and stores the chunks not yet existing
+// returns the chunk manifest, or nil if the content is made of a single chunk,
+// which is not pushed as a chunk, the caller storing the content as is,
+// and the total stored size of the chunks if content is compressed
+func (odbi *oDssBaseImpl) storeChunks(ch string, cf afero.File) ([]string, int64, error) {
+	if chunks, err := odbi.me.loadChunkManifest(ch); err == nil {
+		csize, err := odbi.chunksCSize(chunks)
+		if err != nil {
+			return nil, 0, fmt.Errorf("in storeChunks: %w", err)
+		}
+		return chunks, csize, nil
+	}
+	r, err := odbi.me.getAfs().Open(cf.Name())
+	if err != nil {
+		return nil, 0, fmt.Errorf("in storeChunks: %w", err)
+	}
+	defer r.Close()
+	var chunks []string
+	if err = splitChunks(r, func(cch string, bs []byte) error {
+		chunks = append(chunks, cch)
+		if cch == ch {
+			// single chunk content, stored as is by the caller
+			return nil
+		}
+		if ex, err := odbi.me.queryContent(cch); err == nil && ex {
+			return nil
+		}
+		return odbi.me.pushChunk(cch, bs)
+	}); err != nil {
+		return nil, 0, fmt.Errorf("in storeChunks: %w", err)
+	}
+	if len(chunks) < 2 {
+		return nil, 0, nil
+	}
+	if err = odbi.me.storeChunkManifest(ch, chunks); err != nil {
+		return nil, 0, fmt.Errorf("in storeChunks: %w", err)
+	}
+	csize, err := odbi.chunksCSize(chunks)
+	if err != nil {
+		return nil, 0, fmt.Errorf("in storeChunks: %w", err)
+	}
+	return chunks, csize, nil
+}
+
+// chunksCSize returns the total stored size of chunks if content is compressed, 0 otherwise
+func (odbi *oDssBaseImpl) chunksCSize(chunks []string) (int64, error) {
+	if !odbi.isContentCompressed() {
+		return 0, nil
+	}
+	var csize int64
+	for _, cch := range chunks {
+		size, err := odbi.me.contentSize(cch)
+		if err != nil {
+			return 0, err
+		}
+		csize += size
+	}
+	return csize, nil
+}
+
+// setMetaChunks inlines the chunk manifest in the meta data bytes if not too large
+func (odbi *oDssBaseImpl) setMetaChunks(mbs []byte, chunks []string) ([]byte, error) {
+	if len(chunks) == 0 || len(chunks) > MAX_META_CHUNKS {
+		return mbs, nil
+	}
+	meta, err := odbi.decodeMeta(mbs)
+	if err != nil {
+		return nil, fmt.Errorf("in setMetaChunks: %w", err)
+	}
+	meta.Chunks = chunks
+	if mbs, err = odbi.encodeMeta(meta); err != nil {
+		return nil, fmt.Errorf("in setMetaChunks: %w", err)
+	}
+	return mbs, nil
+}
+
+// metaChunks returns the chunk manifest of a content meta data, or nil if the content is not chunked
+func (odbi *oDssBaseImpl) metaChunks(meta Meta) []string {
+	if meta.IsNs || meta.IsSymLink || !odbi.repoChunked {
+		return nil
+	}
+	if len(meta.Chunks) > 0 {
+		return meta.Chunks
+	}
+	chunks, err := odbi.me.loadChunkManifest(meta.Ch)
+	if err != nil {
+		return nil
+	}
+	return chunks
+}
+
+type chunksReader struct {
+	me     oDssProxy
+	chunks []string
+	cur    io.ReadCloser
+}
+
+func (chr *chunksReader) Read(p []byte) (n int, err error) {
+	for {
+		if chr.cur == nil {
+			if len(chr.chunks) == 0 {
+				return 0, io.EOF
+			}
+			if chr.cur, err = chr.me.spGetContentReader(chr.chunks[0]); err != nil {
+				return 0, fmt.Errorf("in chunksReader: %w", err)
+			}
+			chr.chunks = chr.chunks[1:]
+		}
+		n, err = chr.cur.Read(p)
+		if err == io.EOF {
+			err = chr.cur.Close()
+			chr.cur = nil
+			if n > 0 || err != nil {
+				return
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (chr *chunksReader) Close() error {
+	if chr.cur != nil {
+		return chr.cur.Close()
+	}
+	return nil
+}
+
+func (odbi *oDssBaseImpl) newChunksReader(chunks []string) io.ReadCloser {
+	return &chunksReader{me: odbi.me, chunks: chunks}
+}
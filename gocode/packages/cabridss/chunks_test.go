@@ -0,0 +1,204 @@
+package cabridss
+
+import (
+	"bytes"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func randBytes(seed int64, size int) []byte {
+	bs := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(bs)
+	return bs
+}
+
+func TestSplitChunks(t *testing.T) {
+	bs := randBytes(1, 12*1024*1024)
+	var chunks []string
+	var rbs []byte
+	if err := splitChunks(bytes.NewReader(bs), func(cch string, cbs []byte) error {
+		chunks = append(chunks, cch)
+		rbs = append(rbs, cbs...)
+		if len(cbs) > CHUNK_MAX_SIZE {
+			t.Fatalf("chunk %d size %d", len(chunks), len(cbs))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bs, rbs) || len(chunks) < 3 {
+		t.Fatalf("TestSplitChunks failed %d chunks", len(chunks))
+	}
+	ubs := append(append(append([]byte{}, bs[:5*1024*1024]...), []byte("inserted")...), bs[5*1024*1024:]...)
+	known := map[string]bool{}
+	for _, cch := range chunks {
+		known[cch] = true
+	}
+	news := 0
+	if err := splitChunks(bytes.NewReader(ubs), func(cch string, cbs []byte) error {
+		if !known[cch] {
+			news++
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if news > 2 {
+		t.Fatalf("TestSplitChunks %d new chunks out of %d", news, len(chunks))
+	}
+}
+
+func writeTestContent(dss Dss, npath string, bs []byte) error {
+	wc, err := dss.GetContentWriter(npath, 0, nil, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = wc.Write(bs); err != nil {
+		return err
+	}
+	return wc.Close()
+}
+
+func TestOlfChunked(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestOlfChunked", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex, Chunked: true}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	ttr := time.Date(2022, time.January, 8, 18, 52, 0, 0, time.UTC).Unix()
+	dss.SetCurrentTime(ttr * 1e9)
+	if err = dss.Mkns("", 0, []string{"a.bin", "b.bin", "c.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	abs := randBytes(2, 6*1024*1024)
+	bbs := append(append([]byte{}, abs[:3*1024*1024]...), abs[3*1024*1024+10:]...)
+	for _, c := range []struct {
+		npath string
+		bs    []byte
+	}{{"a.bin", abs}, {"b.bin", bbs}, {"c.txt", []byte("small content")}} {
+		if err = writeTestContent(dss, c.npath, c.bs); err != nil {
+			t.Fatal(err)
+		}
+		rc, err := dss.GetContentReader(c.npath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rbs, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(rbs, c.bs) {
+			t.Fatalf("TestOlfChunked %s read back failed %v", c.npath, err)
+		}
+	}
+	if des, err := os.ReadDir(ufpath.Join(tfs.Path(), "tmp")); err != nil || len(des) != 0 {
+		t.Fatalf("TestOlfChunked temporary files remain %v %v", des, err)
+	}
+	ma, err := dss.GetMeta("a.bin", true)
+	if err != nil || len(ma.(Meta).Chunks) < 2 {
+		t.Fatalf("TestOlfChunked a.bin chunks %v %v", ma, err)
+	}
+	mb, _ := dss.GetMeta("b.bin", true)
+	shared := 0
+	for _, ach := range ma.(Meta).Chunks {
+		for _, bch := range mb.(Meta).Chunks {
+			if ach == bch {
+				shared++
+			}
+		}
+	}
+	if shared < len(ma.(Meta).Chunks)-2 {
+		t.Fatalf("TestOlfChunked only %d chunks shared out of %d", shared, len(ma.(Meta).Chunks))
+	}
+	mc, _ := dss.GetMeta("c.txt", true)
+	if len(mc.(Meta).Chunks) != 0 {
+		t.Fatalf("TestOlfChunked c.txt should not be chunked")
+	}
+	if ok, err := dss.IsDuplicate(ma.GetCh()); err != nil || !ok {
+		t.Fatalf("TestOlfChunked IsDuplicate %v %v", ok, err)
+	}
+	mai, err := dss.AuditIndex()
+	if err != nil || len(mai) != 0 {
+		t.Fatalf("TestOlfChunked AuditIndex %v %v", mai, err)
+	}
+	if _, errs := dss.ScanStorage(true, false, false); errs != nil {
+		t.Fatalf("TestOlfChunked ScanStorage %v", errs)
+	}
+
+	dss.SetCurrentTime((ttr + 3600) * 1e9)
+	if err = dss.Updatens("", 0, []string{"b.bin", "c.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dss.RemoveHistory("a.bin", false, false, false, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := dss.ScanStorage(false, true, false); errs == nil {
+		t.Fatalf("TestOlfChunked ScanStorage should report unused chunks")
+	}
+	if _, errs := dss.ScanStorage(true, false, false); errs != nil {
+		t.Fatalf("TestOlfChunked ScanStorage after purge %v", errs)
+	}
+	rc, err := dss.GetContentReader("b.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbs, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || !bytes.Equal(rbs, bbs) {
+		t.Fatalf("TestOlfChunked b.bin read back after purge failed %v", err)
+	}
+}
+
+type pushChunkCounter struct {
+	*oDssOlfImpl
+	pushed []string
+}
+
+func (pcc *pushChunkCounter) pushChunk(ch string, bs []byte) error {
+	pcc.pushed = append(pcc.pushed, ch)
+	return pcc.oDssOlfImpl.pushChunk(ch, bs)
+}
+
+func TestStoreChunksSingle(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestStoreChunksSingle", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), Chunked: true}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	odoi := dss.(*ODss).proxy.(*oDssOlfImpl)
+	pcc := &pushChunkCounter{oDssOlfImpl: odoi}
+	odoi.me = pcc
+	if err = dss.Mkns("", 0, []string{"a.bin", "c.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "c.txt", []byte("small content")); err != nil {
+		t.Fatal(err)
+	}
+	if len(pcc.pushed) != 0 {
+		t.Fatalf("TestStoreChunksSingle single chunk content pushed as chunk %v", pcc.pushed)
+	}
+	if err = writeTestContent(dss, "a.bin", randBytes(3, 6*1024*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if len(pcc.pushed) < 2 {
+		t.Fatalf("TestStoreChunksSingle chunks pushed %v", pcc.pushed)
+	}
+}
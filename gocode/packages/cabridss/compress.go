@@ -0,0 +1,164 @@
+package cabridss
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+	"io"
+)
+
+// content compression is enabled per repository with DssBaseConfig.Compressed:
+// olf and obs DSS compress each content blob (or chunk) before storing it, unless the repository is encrypted,
+// in which case the encrypted DSS client compresses the content before encrypting it.
+// The algorithm is given per repository by DssBaseConfig.Compression, gzip being the default
+// and the one of repositories created before zstd was available, zstd being faster with similar ratios.
+// Readers detect the algorithm from the compressed data itself.
+// Content is still named by the checksum of its plaintext, and the compressed size is recorded
+// in Meta.CSize, for chunked content as the sum of its stored chunks sizes.
+
+const (
+	COMPRESSION_GZIP = "gzip"
+	COMPRESSION_ZSTD = "zstd"
+)
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// CheckCompression checks a compression algorithm, empty meaning the default gzip
+func CheckCompression(compression string) error {
+	if compression != "" && compression != COMPRESSION_GZIP && compression != COMPRESSION_ZSTD {
+		return fmt.Errorf("compression %s is invalid (must be %s or %s)", compression, COMPRESSION_GZIP, COMPRESSION_ZSTD)
+	}
+	return nil
+}
+
+// newCompressor returns a writer compressing into w with the compression algorithm
+func newCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	if compression == COMPRESSION_ZSTD {
+		return zstd.NewWriter(w)
+	}
+	return gzip.NewWriter(w), nil
+}
+
+// isContentCompressed tells if content blobs are stored compressed by the DSS itself
+func (odbi *oDssBaseImpl) isContentCompressed() bool {
+	return odbi.repoCompressed && !odbi.repoEncrypted
+}
+
+// compressFile compresses the content of file cf into a new temporary file in dir
+// returns the closed temporary file and its size
+func compressFile(afs afero.Fs, dir string, cf afero.File, compression string) (afero.File, int64, error) {
+	r, err := afs.Open(cf.Name())
+	if err != nil {
+		return nil, 0, fmt.Errorf("in compressFile: %w", err)
+	}
+	defer r.Close()
+	zf, err := afero.TempFile(afs, dir, "zc")
+	if err != nil {
+		return nil, 0, fmt.Errorf("in compressFile: %w", err)
+	}
+	zw, err := newCompressor(zf, compression)
+	if err == nil {
+		_, err = io.Copy(zw, r)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = zf.Close()
+	} else {
+		zf.Close()
+	}
+	if err != nil {
+		_ = afs.Remove(zf.Name())
+		return nil, 0, fmt.Errorf("in compressFile: %w", err)
+	}
+	fi, err := afs.Stat(zf.Name())
+	if err != nil {
+		_ = afs.Remove(zf.Name())
+		return nil, 0, fmt.Errorf("in compressFile: %w", err)
+	}
+	return zf, fi.Size(), nil
+}
+
+func compressBytes(bs []byte, compression string) ([]byte, error) {
+	var b bytes.Buffer
+	zw, err := newCompressor(&b, compression)
+	if err != nil {
+		return nil, fmt.Errorf("in compressBytes: %w", err)
+	}
+	if _, err := zw.Write(bs); err != nil {
+		return nil, fmt.Errorf("in compressBytes: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("in compressBytes: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+type compressWriter struct {
+	io.WriteCloser
+	wc io.WriteCloser
+}
+
+func (cw *compressWriter) Close() error {
+	if err := cw.WriteCloser.Close(); err != nil {
+		cw.wc.Close()
+		return err
+	}
+	return cw.wc.Close()
+}
+
+// newCompressWriter returns a writer compressing into wc, csize is set to the compressed size when closed
+func newCompressWriter(wc io.WriteCloser, csize *int64, compression string) (io.WriteCloser, error) {
+	cwc := NewWriteCloserWithCb(wc, func(err error, size int64, ch string, me *WriteCloserWithCb) error {
+		*csize = size
+		return err
+	})
+	zw, err := newCompressor(cwc, compression)
+	if err != nil {
+		cwc.Close()
+		return nil, fmt.Errorf("in newCompressWriter: %w", err)
+	}
+	return &compressWriter{WriteCloser: zw, wc: cwc}, nil
+}
+
+// newDecompressReader returns a reader of the gzip or zstd content of rc, rc is closed with it
+func newDecompressReader(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	if magic, err := br.Peek(len(zstdMagic)); err == nil && bytes.Equal(magic, zstdMagic) {
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("in newDecompressReader: %w", err)
+		}
+		return NewReadCloserWithCb(zr, func() error {
+			zr.Close()
+			return rc.Close()
+		})
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("in newDecompressReader: %w", err)
+	}
+	return NewReadCloserWithCb(zr, func() error {
+		zr.Close()
+		return rc.Close()
+	})
+}
+
+// setMetaCSize records the compressed content size in the meta data bytes
+func (odbi *oDssBaseImpl) setMetaCSize(mbs []byte, csize int64) ([]byte, error) {
+	meta, err := odbi.decodeMeta(mbs)
+	if err != nil {
+		return nil, fmt.Errorf("in setMetaCSize: %w", err)
+	}
+	meta.CSize = csize
+	if mbs, err = odbi.encodeMeta(meta); err != nil {
+		return nil, fmt.Errorf("in setMetaCSize: %w", err)
+	}
+	return mbs, nil
+}
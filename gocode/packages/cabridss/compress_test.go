@@ -0,0 +1,189 @@
+package cabridss
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"os"
+	"testing"
+)
+
+func compressibleBytes(size int) []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "line %d of some quite compressible content\n", i)
+	}
+	return b.Bytes()[:size]
+}
+
+func runCompressedTest(dss HDss, cpaths ...string) error {
+	for i, cpath := range cpaths {
+		bs := compressibleBytes(2*1024*1024 + i)
+		if err := writeTestContent(dss, cpath, bs); err != nil {
+			return err
+		}
+		rc, err := dss.GetContentReader(cpath)
+		if err != nil {
+			return err
+		}
+		rbs, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(rbs, bs) {
+			return fmt.Errorf("runCompressedTest %s read back failed %v", cpath, err)
+		}
+		meta, err := dss.GetMeta(cpath, true)
+		if err != nil {
+			return err
+		}
+		if cs := meta.(Meta).CSize; cs == 0 || cs > meta.GetSize()/4 {
+			return fmt.Errorf("runCompressedTest %s compressed size %d size %d", cpath, cs, meta.GetSize())
+		}
+	}
+	if _, errs := dss.ScanStorage(true, false, false); errs != nil {
+		return fmt.Errorf("runCompressedTest ScanStorage %v", errs)
+	}
+	return nil
+}
+
+func TestOlfCompressed(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestOlfCompressed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex, Compressed: true}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if !dss.IsRepoCompressed() {
+		t.Fatalf("TestOlfCompressed repository should be compressed")
+	}
+	if err = dss.Mkns("", 0, []string{"a.txt", "b.txt", "c.bin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = runCompressedTest(dss, "a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	obs, nbs := deltaTestContents()
+	if err = runDeltaTest(dss, "c.bin", obs, nbs); err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := dss.ScanStorage(true, false, false); errs != nil {
+		t.Fatalf("TestOlfCompressed ScanStorage %v", errs)
+	}
+}
+
+func runEDssClientOlfCompressed(t *testing.T, name, compression string) {
+	tfs, err := testfs.CreateFs(name, tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(tfs, ":3000", "",
+		CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex, Encrypted: true, Compressed: true, Compression: compression},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	dss, err := NewEDss(
+		EDssConfig{WebDssConfig: WebDssConfig{DssBaseConfig: DssBaseConfig{ConfigDir: ufpath.Join(tfs.Path(), ".cabri"), WebPort: "3000"}}},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, []string{"a.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = runCompressedTest(dss, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEDssClientOlfCompressed(t *testing.T) {
+	optionalSkip(t)
+	runEDssClientOlfCompressed(t, "TestEDssClientOlfCompressed", "")
+}
+
+func TestEDssClientOlfCompressedZstd(t *testing.T) {
+	optionalSkip(t)
+	runEDssClientOlfCompressed(t, "TestEDssClientOlfCompressedZstd", COMPRESSION_ZSTD)
+}
+
+func TestCompressDetection(t *testing.T) {
+	bs := compressibleBytes(100000)
+	for _, compression := range []string{"", COMPRESSION_GZIP, COMPRESSION_ZSTD} {
+		zbs, err := compressBytes(bs, compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (compression == COMPRESSION_ZSTD) != bytes.HasPrefix(zbs, zstdMagic) {
+			t.Fatalf("TestCompressDetection %s magic", compression)
+		}
+		rc, err := newDecompressReader(io.NopCloser(bytes.NewReader(zbs)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rbs, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(rbs, bs) {
+			t.Fatalf("TestCompressDetection %s read back failed %v", compression, err)
+		}
+	}
+	if err := CheckCompression("lz4"); err == nil {
+		t.Fatalf("TestCompressDetection lz4 should be invalid")
+	}
+}
+
+func TestOlfCompressedZstdChunked(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestOlfCompressedZstdChunked", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex, Compressed: true, Compression: COMPRESSION_ZSTD, Chunked: true}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if dss.GetRepoCompression() != COMPRESSION_ZSTD {
+		t.Fatalf("TestOlfCompressedZstdChunked repository compression %s", dss.GetRepoCompression())
+	}
+	if err = dss.Mkns("", 0, []string{"a.txt", "b.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = runCompressedTest(dss, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	ma, err := dss.GetMeta("a.txt", true)
+	if err != nil || len(ma.(Meta).Chunks) < 2 {
+		t.Fatalf("TestOlfCompressedZstdChunked a.txt should be chunked %v", err)
+	}
+	if err = writeTestContent(dss, "b.txt", []byte("small content, single chunk")); err != nil {
+		t.Fatal(err)
+	}
+	mb, err := dss.GetMeta("b.txt", true)
+	if err != nil || mb.(Meta).CSize == 0 {
+		t.Fatalf("TestOlfCompressedZstdChunked b.txt compressed size %v", err)
+	}
+	zbs, err := os.ReadFile(ufpath.Join(tfs.Path(), "content", internal.Str32ToPath(mb.GetCh(), "s")))
+	if err != nil || !bytes.HasPrefix(zbs, zstdMagic) || int64(len(zbs)) != mb.(Meta).CSize {
+		t.Fatalf("TestOlfCompressedZstdChunked b.txt stored content %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package cabridss
+
+import (
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"testing"
+)
+
+func runCopyContentTest(dss Dss, spath, npath string) error {
+	smeta, err := dss.GetMeta(spath, true)
+	if err != nil {
+		return err
+	}
+	if err = dss.CopyContent(spath, npath, "badch", 12345, nil); err == nil {
+		return fmt.Errorf("CopyContent with a changed checksum should fail")
+	}
+	if err = dss.CopyContent(spath, npath, smeta.GetChUnsafe(), 12345, nil); err != nil {
+		return err
+	}
+	meta, err := dss.GetMeta(npath, true)
+	if err != nil {
+		return err
+	}
+	if meta.GetChUnsafe() != smeta.GetChUnsafe() || meta.GetSize() != smeta.GetSize() || meta.GetMtime() != 12345 {
+		return fmt.Errorf("copied meta %+v differs from %+v", meta, smeta)
+	}
+	rc, err := dss.GetContentReader(npath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	bs, err := io.ReadAll(rc)
+	if err != nil || int64(len(bs)) != smeta.GetSize() {
+		return fmt.Errorf("copied content size %d %v", len(bs), err)
+	}
+	return nil
+}
+
+func TestFsyCopyContent(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestFsyCopyContent", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	dss, err := NewFsyDss(FsyConfig{}, tfs.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = runCopyContentTest(dss, "a.txt", "d/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOlfCopyContent(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestOlfCopyContent", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, []string{"a.bin", "b.bin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "a.bin", randBytes(1, 100000)); err != nil {
+		t.Fatal(err)
+	}
+	if err = dss.CopyContent("a.bin", "c.bin", "", 0, nil); err == nil {
+		t.Fatal("TestOlfCopyContent CopyContent outside of the namespace should fail")
+	}
+	if err = runCopyContentTest(dss, "a.bin", "b.bin"); err != nil {
+		t.Fatal(err)
+	}
+	sti, errs := dss.ScanStorage(true, false, false)
+	if errs != nil || len(sti.Path2Content) != 1 {
+		t.Fatalf("TestOlfCopyContent ScanStorage %d contents %v", len(sti.Path2Content), errs)
+	}
+}
+
+func TestWfsDssCopyContent(t *testing.T) {
+	if err := runWfsDssTest(t, func(tfs *testfs.Fs, dss Dss) error {
+		return runCopyContentTest(dss, "a.txt", "d/c.txt")
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
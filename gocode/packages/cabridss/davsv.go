@@ -0,0 +1,479 @@
+package cabridss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/webdav"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// the WebDAV front-end maps WebDAV requests onto the Dss interface:
+// PROPFIND onto GetMeta and Lsns, GET onto GetContentRangeReader, PUT onto GetContentWriter,
+// MKCOL onto Mkns and DELETE onto Remove, the parent namespace being updated as required
+
+type DavServerConfig struct {
+	WebServerConfig
+	Dss Dss
+	ACL []ACLEntry // ACL of created namespaces and content
+}
+
+var davMethods = []string{
+	"OPTIONS", "GET", "HEAD", "POST", "PUT", "DELETE",
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// davFs implements webdav.FileSystem on a DSS
+type davFs struct {
+	dss Dss
+	acl []ACLEntry
+}
+
+func davNpath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+func davParent(npath string) string {
+	parent := path.Dir(npath)
+	if parent == "." {
+		parent = ""
+	}
+	return parent
+}
+
+func davNotExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+// getMeta returns the meta data of the namespace or content npath,
+// the root namespace of a new DSS being created on first access
+func (dfs *davFs) getMeta(npath string) (IMeta, error) {
+	if npath == "" {
+		meta, err := dfs.dss.GetMeta("", false)
+		if err == nil && meta.GetIsNs() {
+			return meta, nil
+		}
+		if err = dfs.dss.Mkns("", time.Now().Unix(), nil, dfs.acl); err != nil {
+			return nil, err
+		}
+		return dfs.dss.GetMeta("", false)
+	}
+	if meta, err := dfs.dss.GetMeta(npath+"/", false); err == nil {
+		return meta, nil
+	}
+	return dfs.dss.GetMeta(npath, false)
+}
+
+// checkChild checks that child can be added to the parent namespace of npath,
+// returns the parent meta data and if child is already present
+func (dfs *davFs) checkChild(op, npath, child string) (IMeta, bool, error) {
+	parent := davParent(npath)
+	pm, err := dfs.getMeta(parent)
+	if err != nil || !pm.GetIsNs() {
+		return nil, false, davNotExist(op, parent)
+	}
+	for _, c := range pm.GetChildren() {
+		if c == child {
+			return pm, true, nil
+		}
+		if strings.TrimSuffix(c, "/") == strings.TrimSuffix(child, "/") {
+			return nil, false, &os.PathError{Op: op, Path: npath, Err: os.ErrExist}
+		}
+	}
+	return pm, false, nil
+}
+
+// removeChild removes child from the parent namespace of npath
+func (dfs *davFs) removeChild(npath, child string) error {
+	parent := davParent(npath)
+	pm, err := dfs.getMeta(parent)
+	if err != nil {
+		return err
+	}
+	var children []string
+	for _, c := range pm.GetChildren() {
+		if c != child {
+			children = append(children, c)
+		}
+	}
+	return dfs.dss.Updatens(parent, time.Now().Unix(), children, pm.GetAcl())
+}
+
+// addChild adds child to the parent namespace of npath unless already present
+func (dfs *davFs) addChild(op, npath, child string) error {
+	pm, present, err := dfs.checkChild(op, npath, child)
+	if err != nil || present {
+		return err
+	}
+	if err = dfs.dss.Updatens(davParent(npath), time.Now().Unix(), append(pm.GetChildren(), child), pm.GetAcl()); err != nil {
+		return fmt.Errorf("in %s: %w", op, err)
+	}
+	return nil
+}
+
+func (dfs *davFs) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	npath := davNpath(name)
+	if npath == "" {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if _, err := dfs.getMeta(npath); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if err := dfs.addChild("mkdir", npath, path.Base(npath)+"/"); err != nil {
+		return err
+	}
+	if err := dfs.dss.Mkns(npath, time.Now().Unix(), nil, dfs.acl); err != nil {
+		return fmt.Errorf("in mkdir: %w", err)
+	}
+	return nil
+}
+
+func (dfs *davFs) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	npath := davNpath(name)
+	meta, err := dfs.getMeta(npath)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) == 0 {
+		if err != nil {
+			return nil, davNotExist("open", name)
+		}
+		if meta.GetIsNs() {
+			return &davDir{dfs: dfs, npath: npath, meta: meta}, nil
+		}
+		return &davReader{dfs: dfs, npath: npath, meta: meta}, nil
+	}
+	acl := dfs.acl
+	isNew := err != nil
+	if !isNew {
+		if meta.GetIsNs() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a namespace")}
+		}
+		acl = meta.GetAcl()
+	} else if _, _, err = dfs.checkChild("open", npath, path.Base(npath)); err != nil {
+		return nil, err
+	}
+	// the content is written to a temporary file, and only stored in the DSS once completely written,
+	// a new content being added to its parent namespace at this time
+	tf, err := os.CreateTemp("", "cabri-dav")
+	if err != nil {
+		return nil, fmt.Errorf("in open: %w", err)
+	}
+	return &davWriter{ctx: ctx, tf: tf, name: path.Base(npath), store: func(tpath string) error {
+		if isNew {
+			if err := dfs.addChild("open", npath, path.Base(npath)); err != nil {
+				return err
+			}
+		}
+		if err := dfs.storeContent(npath, acl, tpath); err != nil {
+			if isNew {
+				dfs.removeChild(npath, path.Base(npath))
+			}
+			return err
+		}
+		return nil
+	}}, nil
+}
+
+// storeContent stores the content of file tpath as npath
+func (dfs *davFs) storeContent(npath string, acl []ACLEntry, tpath string) error {
+	tf, err := os.Open(tpath)
+	if err != nil {
+		return fmt.Errorf("in storeContent: %w", err)
+	}
+	defer tf.Close()
+	wc, err := dfs.dss.GetContentWriter(npath, time.Now().Unix(), acl, nil)
+	if err != nil {
+		return fmt.Errorf("in storeContent: %w", err)
+	}
+	if _, err = io.Copy(wc, tf); err != nil {
+		wc.Close()
+		return fmt.Errorf("in storeContent: %w", err)
+	}
+	if err = wc.Close(); err != nil {
+		return fmt.Errorf("in storeContent: %w", err)
+	}
+	return nil
+}
+
+func (dfs *davFs) RemoveAll(ctx context.Context, name string) error {
+	npath := davNpath(name)
+	if npath == "" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	meta, err := dfs.getMeta(npath)
+	if err != nil {
+		return davNotExist("remove", name)
+	}
+	if meta.GetIsNs() {
+		npath += "/"
+	}
+	return dfs.dss.Remove(npath)
+}
+
+// Rename moves a namespace or some content to its new name with the DSS native operation
+func (dfs *davFs) Rename(ctx context.Context, oldName, newName string) error {
+	opath, npath := davNpath(oldName), davNpath(newName)
+	meta, err := dfs.getMeta(opath)
+	if err != nil {
+		return davNotExist("rename", oldName)
+	}
+	if _, err = dfs.getMeta(npath); err == nil {
+		return &os.PathError{Op: "rename", Path: newName, Err: os.ErrExist}
+	}
+	if meta.GetIsNs() {
+		opath, npath = opath+"/", npath+"/"
+	}
+	if err = dfs.dss.Rename(opath, npath, time.Now().Unix()); err != nil {
+		return fmt.Errorf("in rename: %w", err)
+	}
+	return nil
+}
+
+func (dfs *davFs) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	npath := davNpath(name)
+	meta, err := dfs.getMeta(npath)
+	if err != nil {
+		return nil, davNotExist("stat", name)
+	}
+	return davInfo(path.Base("/"+npath), meta), nil
+}
+
+// davFileInfo implements os.FileInfo, webdav.ETager and webdav.ContentTyper from meta data
+type davFileInfo struct {
+	name  string
+	size  int64
+	mtime int64
+	isNs  bool
+	ch    string
+}
+
+func davInfo(name string, meta IMeta) *davFileInfo {
+	return &davFileInfo{name: name, size: meta.GetSize(), mtime: meta.GetMtime(), isNs: meta.GetIsNs(), ch: meta.GetChUnsafe()}
+}
+
+func (dfi *davFileInfo) Name() string { return dfi.name }
+
+func (dfi *davFileInfo) Size() int64 { return dfi.size }
+
+func (dfi *davFileInfo) Mode() fs.FileMode {
+	if dfi.isNs {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func (dfi *davFileInfo) ModTime() time.Time { return time.Unix(dfi.mtime, 0) }
+
+func (dfi *davFileInfo) IsDir() bool { return dfi.isNs }
+
+func (dfi *davFileInfo) Sys() any { return nil }
+
+func (dfi *davFileInfo) ETag(ctx context.Context) (string, error) {
+	if dfi.ch == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + dfi.ch + `"`, nil
+}
+
+// ContentType avoids reading the content to detect its type
+func (dfi *davFileInfo) ContentType(ctx context.Context) (string, error) {
+	if ct := mime.TypeByExtension(path.Ext(dfi.name)); ct != "" {
+		return ct, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// davReader reads content sequentially from the current offset, a new range being read after a seek
+type davReader struct {
+	dfs    *davFs
+	npath  string
+	meta   IMeta
+	offset int64
+	rc     io.ReadCloser
+}
+
+func (dr *davReader) Read(p []byte) (int, error) {
+	if dr.offset >= dr.meta.GetSize() {
+		return 0, io.EOF
+	}
+	if dr.rc == nil {
+		rc, err := dr.dfs.dss.GetContentRangeReader(dr.npath, dr.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		dr.rc = rc
+	}
+	n, err := dr.rc.Read(p)
+	dr.offset += int64(n)
+	return n, err
+}
+
+func (dr *davReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += dr.offset
+	case io.SeekEnd:
+		offset += dr.meta.GetSize()
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("in Seek: negative offset %d", offset)
+	}
+	if offset != dr.offset && dr.rc != nil {
+		dr.rc.Close()
+		dr.rc = nil
+	}
+	dr.offset = offset
+	return offset, nil
+}
+
+func (dr *davReader) Close() error {
+	if dr.rc != nil {
+		return dr.rc.Close()
+	}
+	return nil
+}
+
+func (dr *davReader) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, fmt.Errorf("in Readdir: %s is not a namespace", dr.npath)
+}
+
+func (dr *davReader) Stat() (fs.FileInfo, error) {
+	return davInfo(path.Base("/"+dr.npath), dr.meta), nil
+}
+
+func (dr *davReader) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("in Write: %s is opened read-only", dr.npath)
+}
+
+type davDir struct {
+	dfs   *davFs
+	npath string
+	meta  IMeta
+	done  bool
+}
+
+func (dd *davDir) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("in Read: %s is a namespace", dd.npath)
+}
+
+func (dd *davDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
+
+func (dd *davDir) Close() error { return nil }
+
+func (dd *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if dd.done {
+		if count > 0 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	}
+	dd.done = true
+	var fis []fs.FileInfo
+	for _, child := range dd.meta.GetChildren() {
+		cpath := path.Join(dd.npath, child)
+		if strings.HasSuffix(child, "/") {
+			cpath += "/"
+		}
+		meta, err := dd.dfs.dss.GetMeta(cpath, false)
+		if err != nil {
+			return nil, fmt.Errorf("in Readdir: %w", err)
+		}
+		fis = append(fis, davInfo(strings.TrimSuffix(child, "/"), meta))
+	}
+	return fis, nil
+}
+
+func (dd *davDir) Stat() (fs.FileInfo, error) {
+	return davInfo(path.Base("/"+dd.npath), dd.meta), nil
+}
+
+func (dd *davDir) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("in Write: %s is a namespace", dd.npath)
+}
+
+type davWriter struct {
+	ctx   context.Context // the request context, canceled if the client aborts
+	tf    *os.File        // temporary file receiving the content
+	name  string
+	size  int64
+	wErr  error                    // first write error if any
+	store func(tpath string) error // stores the content once successfully written
+}
+
+func (dw *davWriter) Write(p []byte) (int, error) {
+	n, err := dw.tf.Write(p)
+	dw.size += int64(n)
+	if err != nil && dw.wErr == nil {
+		dw.wErr = err
+	}
+	return n, err
+}
+
+// Close stores the content unless writing it failed or was aborted,
+// the webdav handler closing the file even if copying the request body failed
+func (dw *davWriter) Close() error {
+	defer os.Remove(dw.tf.Name())
+	err := dw.tf.Close()
+	if err == nil {
+		err = dw.wErr
+	}
+	if err == nil {
+		err = dw.ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("in Close: %w", err)
+	}
+	return dw.store(dw.tf.Name())
+}
+
+func (dw *davWriter) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("in Read: %s is opened write-only", dw.name)
+}
+
+func (dw *davWriter) Seek(offset int64, whence int) (int64, error) {
+	return 0, fmt.Errorf("in Seek: %s is opened write-only", dw.name)
+}
+
+func (dw *davWriter) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, fmt.Errorf("in Readdir: %s is not a namespace", dw.name)
+}
+
+func (dw *davWriter) Stat() (fs.FileInfo, error) {
+	return &davFileInfo{name: dw.name, size: dw.size, mtime: time.Now().Unix()}, nil
+}
+
+func DavServerConfigurator(e *echo.Echo, root string, configs map[string]interface{}) error {
+	config := configs[root].(DavServerConfig)
+	h := &webdav.Handler{
+		Prefix:     strings.TrimSuffix(root, "/"),
+		FileSystem: &davFs{dss: config.Dss, acl: config.ACL},
+		LockSystem: webdav.NewMemLS(),
+	}
+	e.Match(davMethods, root+"*", echo.WrapHandler(h))
+	if root != "/" {
+		e.Match(davMethods, strings.TrimSuffix(root, "/"), echo.WrapHandler(h))
+	}
+	return nil
+}
+
+// NewDavServer serves the DSS of the config over WebDAV
+func NewDavServer(root string, config DavServerConfig) (WebServer, error) {
+	var tlsConfig *TlsConfig
+	if config.IsTls {
+		tlsConfig = getTlsServerConfig(config.WebServerConfig)
+	}
+	s := NewEServer(config.Addr, config.HasLog, tlsConfig)
+	s.ConfigureApi(root, config, func(root string, customConfigs map[string]interface{}) error {
+		return customConfigs[root].(DavServerConfig).Dss.Close()
+	},
+		DavServerConfigurator)
+	err := s.Serve()
+	return s, err
+}
@@ -0,0 +1,200 @@
+package cabridss
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func davRequest(method, url string, body []byte, headers ...string) (int, []byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	rbs, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, rbs, err
+}
+
+func runDavTest(dss Dss, url string) error {
+	expect := func(status int, method, path string, body []byte, headers ...string) ([]byte, error) {
+		st, rbs, err := davRequest(method, url+path, body, headers...)
+		if err != nil {
+			return nil, err
+		}
+		if st != status {
+			return nil, fmt.Errorf("runDavTest %s %s status %d: %s", method, path, st, rbs)
+		}
+		return rbs, nil
+	}
+	if _, err := expect(http.StatusCreated, "MKCOL", "d1/", nil); err != nil {
+		return err
+	}
+	if _, err := expect(http.StatusMethodNotAllowed, "MKCOL", "d1/", nil); err != nil {
+		return err
+	}
+	if _, err := expect(http.StatusConflict, "MKCOL", "d2/d3/", nil); err != nil {
+		return err
+	}
+	bs := randBytes(12, 100*1024)
+	if _, err := expect(http.StatusCreated, "PUT", "d1/a.bin", bs); err != nil {
+		return err
+	}
+	if _, err := expect(http.StatusCreated, "PUT", "b.txt", []byte("some text")); err != nil {
+		return err
+	}
+	rbs, err := expect(http.StatusOK, "GET", "d1/a.bin", nil)
+	if err != nil || !bytes.Equal(rbs, bs) {
+		return fmt.Errorf("runDavTest GET d1/a.bin %d bytes %v", len(rbs), err)
+	}
+	rbs, err = expect(http.StatusPartialContent, "GET", "d1/a.bin", nil, "Range", "bytes=100-199")
+	if err != nil || !bytes.Equal(rbs, bs[100:200]) {
+		return fmt.Errorf("runDavTest GET range d1/a.bin %d bytes %v", len(rbs), err)
+	}
+	rbs, err = expect(http.StatusMultiStatus, "PROPFIND", "", nil, "Depth", "1")
+	if err != nil {
+		return err
+	}
+	for _, href := range []string{"/d1/", "/b.txt"} {
+		if !strings.Contains(string(rbs), href) {
+			return fmt.Errorf("runDavTest PROPFIND missing %s in %s", href, rbs)
+		}
+	}
+	if _, err = expect(http.StatusCreated, "MOVE", "b.txt", nil, "Destination", url+"d1/c.txt"); err != nil {
+		return err
+	}
+	children, err := dss.Lsns("d1")
+	if err != nil || len(children) != 2 {
+		return fmt.Errorf("runDavTest Lsns d1 %v %v", children, err)
+	}
+	if _, err = expect(http.StatusNotFound, "GET", "b.txt", nil); err != nil {
+		return err
+	}
+	if _, err = expect(http.StatusNoContent, "DELETE", "d1/a.bin", nil); err != nil {
+		return err
+	}
+	if _, err = expect(http.StatusNoContent, "DELETE", "d1/", nil); err != nil {
+		return err
+	}
+	if children, err = dss.Lsns(""); err != nil || len(children) != 0 {
+		return fmt.Errorf("runDavTest Lsns root %v %v", children, err)
+	}
+	return nil
+}
+
+func TestDavServerOlf(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestDavServerOlf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sv, err := NewDavServer("dav", DavServerConfig{
+		WebServerConfig: WebServerConfig{Addr: ":3000"},
+		Dss:             dss,
+		ACL:             []ACLEntry{{Rights: Rights{Read: true, Write: true}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	if err = runDavTest(dss, "http://localhost:3000/dav/"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDavServerFsy(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestDavServerFsy", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	dss, err := NewFsyDss(FsyConfig{}, tfs.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sv, err := NewDavServer("", DavServerConfig{WebServerConfig: WebServerConfig{Addr: ":3000"}, Dss: dss})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	if err = runDavTest(dss, "http://localhost:3000/"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDavFsAbortedWrite(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestDavFsAbortedWrite", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	dfs := &davFs{dss: dss, acl: []ACLEntry{{Rights: Rights{Read: true, Write: true}}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := dfs.OpenFile(ctx, "/a.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	if children, err := dss.Lsns(""); err != nil || len(children) != 0 {
+		t.Fatalf("TestDavFsAbortedWrite child added before write %v %v", children, err)
+	}
+	cancel()
+	if err = f.Close(); err == nil {
+		t.Fatalf("TestDavFsAbortedWrite aborted write should fail")
+	}
+	if children, err := dss.Lsns(""); err != nil || len(children) != 0 {
+		t.Fatalf("TestDavFsAbortedWrite child added after aborted write %v %v", children, err)
+	}
+	if f, err = dfs.OpenFile(context.Background(), "/a.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("complete")); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if children, err := dss.Lsns(""); err != nil || len(children) != 1 || children[0] != "a.txt" {
+		t.Fatalf("TestDavFsAbortedWrite child not added after write %v %v", children, err)
+	}
+	if _, err = dfs.OpenFile(context.Background(), "/d/b.txt", os.O_WRONLY|os.O_CREATE, 0o666); err == nil {
+		t.Fatalf("TestDavFsAbortedWrite open without parent should fail")
+	}
+}
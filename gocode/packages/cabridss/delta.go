@@ -0,0 +1,428 @@
+package cabridss
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"github.com/spf13/afero"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
+	"io"
+	"math"
+)
+
+// delta transfer (rsync like): the target DSS computes the block signatures of its current content
+// (Dss.GetContentSignatures), the origin computes a delta of the new content against them (WriteDelta)
+// and the target rebuilds the new content from the delta and its current content (Dss.GetContentDeltaWriter)
+//
+// delta stream format, integers are big endian:
+//   'D' uint32 block size: header
+//   'C' uint64 first block index, uint32 block count: copy blocks from the current content
+//   'L' uint32 length, bytes: literal bytes
+//   'E' uint64 size, [32]byte sha256: end of delta with size and checksum of the new content
+
+const (
+	DELTA_MIN_SIZE       = 1024 * 1024 // content size below which a delta transfer is not worth it
+	DELTA_MIN_BLOCK_SIZE = 2 * 1024    // minimum signature block size
+	DELTA_MAX_BLOCK_SIZE = 1024 * 1024 // maximum signature block size
+	deltaMaxLiteral      = 64 * 1024   // maximum size of a literal op
+	deltaTrailerSize     = 1 + 8 + sha256.Size
+)
+
+// BlockSignature is the signature of a content block
+type BlockSignature struct {
+	Weak   uint32 `json:"weak"`   // rolling checksum
+	Strong []byte `json:"strong"` // truncated SHA256
+}
+
+// ContentSignatures is the list of block signatures of a content
+type ContentSignatures struct {
+	BlockSize int              `json:"blockSize"`
+	Size      int64            `json:"size,string"`
+	Blocks    []BlockSignature `json:"blocks"`
+}
+
+// DeltaBlockSize returns the signature block size to be used for a content of the given size
+func DeltaBlockSize(size int64) int {
+	bs := (int(math.Sqrt(float64(size))) + 1023) / 1024 * 1024
+	if bs < DELTA_MIN_BLOCK_SIZE {
+		return DELTA_MIN_BLOCK_SIZE
+	}
+	if bs > DELTA_MAX_BLOCK_SIZE {
+		return DELTA_MAX_BLOCK_SIZE
+	}
+	return bs
+}
+
+func weakSum(bs []byte) (a, b uint32) {
+	n := uint32(len(bs))
+	for i, c := range bs {
+		a += uint32(c)
+		b += (n - uint32(i)) * uint32(c)
+	}
+	return a & 0xffff, b & 0xffff
+}
+
+func strongSum(bs []byte) []byte {
+	h := sha256.Sum256(bs)
+	return h[:16]
+}
+
+// ComputeSignatures reads r and returns the signatures of its blocks of blockSize bytes
+func ComputeSignatures(r io.Reader, blockSize int) (*ContentSignatures, error) {
+	if blockSize < DELTA_MIN_BLOCK_SIZE || blockSize > DELTA_MAX_BLOCK_SIZE {
+		return nil, fmt.Errorf("in ComputeSignatures: invalid block size %d", blockSize)
+	}
+	sigs := &ContentSignatures{BlockSize: blockSize}
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			a, b := weakSum(buf[:n])
+			sigs.Blocks = append(sigs.Blocks, BlockSignature{Weak: a | b<<16, Strong: strongSum(buf[:n])})
+			sigs.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sigs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("in ComputeSignatures: %w", err)
+		}
+	}
+}
+
+type deltaEncoder struct {
+	w      *bufio.Writer
+	cIndex uint64
+	cCount uint32
+	lit    []byte
+}
+
+func (de *deltaEncoder) flushCopy() error {
+	if de.cCount == 0 {
+		return nil
+	}
+	var op [13]byte
+	op[0] = 'C'
+	binary.BigEndian.PutUint64(op[1:], de.cIndex)
+	binary.BigEndian.PutUint32(op[9:], de.cCount)
+	de.cCount = 0
+	_, err := de.w.Write(op[:])
+	return err
+}
+
+func (de *deltaEncoder) flushLiteral() error {
+	if len(de.lit) == 0 {
+		return nil
+	}
+	var op [5]byte
+	op[0] = 'L'
+	binary.BigEndian.PutUint32(op[1:], uint32(len(de.lit)))
+	if _, err := de.w.Write(op[:]); err != nil {
+		return err
+	}
+	_, err := de.w.Write(de.lit)
+	de.lit = de.lit[:0]
+	return err
+}
+
+func (de *deltaEncoder) copyBlock(ix int) error {
+	if err := de.flushLiteral(); err != nil {
+		return err
+	}
+	if de.cCount > 0 && de.cIndex+uint64(de.cCount) == uint64(ix) {
+		de.cCount++
+		return nil
+	}
+	if err := de.flushCopy(); err != nil {
+		return err
+	}
+	de.cIndex = uint64(ix)
+	de.cCount = 1
+	return nil
+}
+
+func (de *deltaEncoder) literal(bs ...byte) error {
+	if err := de.flushCopy(); err != nil {
+		return err
+	}
+	de.lit = append(de.lit, bs...)
+	if len(de.lit) >= deltaMaxLiteral {
+		return de.flushLiteral()
+	}
+	return nil
+}
+
+func (de *deltaEncoder) end(size int64, sum []byte) error {
+	if err := de.flushCopy(); err != nil {
+		return err
+	}
+	if err := de.flushLiteral(); err != nil {
+		return err
+	}
+	var op [deltaTrailerSize]byte
+	op[0] = 'E'
+	binary.BigEndian.PutUint64(op[1:], uint64(size))
+	copy(op[9:], sum)
+	if _, err := de.w.Write(op[:]); err != nil {
+		return err
+	}
+	return de.w.Flush()
+}
+
+// WriteDelta reads the new content from r and writes to w its delta against the content having signatures sigs
+// returns the size and checksum of the new content
+func WriteDelta(sigs *ContentSignatures, r io.Reader, w io.Writer) (size int64, ch string, err error) {
+	bsz := sigs.BlockSize
+	if bsz < DELTA_MIN_BLOCK_SIZE || bsz > DELTA_MAX_BLOCK_SIZE {
+		return 0, "", fmt.Errorf("in WriteDelta: invalid block size %d", bsz)
+	}
+	table := map[uint32][]int{}
+	for ix, bs := range sigs.Blocks {
+		table[bs.Weak] = append(table[bs.Weak], ix)
+	}
+	lastLen := 0
+	if len(sigs.Blocks) > 0 {
+		lastLen = int(sigs.Size - int64(len(sigs.Blocks)-1)*int64(bsz))
+	}
+	findBlock := func(weak uint32, win []byte) int {
+		var strong []byte
+		for _, ix := range table[weak] {
+			if ix == len(sigs.Blocks)-1 && lastLen != len(win) || ix < len(sigs.Blocks)-1 && bsz != len(win) {
+				continue
+			}
+			if strong == nil {
+				strong = strongSum(win)
+			}
+			if bytes.Equal(strong, sigs.Blocks[ix].Strong) {
+				return ix
+			}
+		}
+		return -1
+	}
+
+	h := sha256.New()
+	de := &deltaEncoder{w: bufio.NewWriter(w)}
+	var op [5]byte
+	op[0] = 'D'
+	binary.BigEndian.PutUint32(op[1:], uint32(bsz))
+	if _, err = de.w.Write(op[:]); err != nil {
+		return 0, "", fmt.Errorf("in WriteDelta: %w", err)
+	}
+	buf := make([]byte, 0, 2*bsz)
+	pos := 0
+	eof := false
+	fill := func() error {
+		for !eof && len(buf)-pos < bsz {
+			if cap(buf)-len(buf) < bsz {
+				n := copy(buf, buf[pos:])
+				buf = buf[:n]
+				pos = 0
+			}
+			n, err := r.Read(buf[len(buf):cap(buf)])
+			h.Write(buf[len(buf) : len(buf)+n])
+			size += int64(n)
+			buf = buf[:len(buf)+n]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var a, b uint32
+	rolling := false
+	for {
+		if err = fill(); err != nil {
+			return 0, "", fmt.Errorf("in WriteDelta: %w", err)
+		}
+		wl := len(buf) - pos
+		if wl < bsz {
+			if wl > 0 {
+				ta, tb := weakSum(buf[pos:])
+				if ix := findBlock(ta|tb<<16, buf[pos:]); ix >= 0 {
+					err = de.copyBlock(ix)
+				} else {
+					err = de.literal(buf[pos:]...)
+				}
+				if err != nil {
+					return 0, "", fmt.Errorf("in WriteDelta: %w", err)
+				}
+			}
+			break
+		}
+		win := buf[pos : pos+bsz]
+		if !rolling {
+			a, b = weakSum(win)
+			rolling = true
+		}
+		if ix := findBlock(a|b<<16, win); ix >= 0 {
+			if err = de.copyBlock(ix); err != nil {
+				return 0, "", fmt.Errorf("in WriteDelta: %w", err)
+			}
+			pos += bsz
+			rolling = false
+			continue
+		}
+		out := uint32(buf[pos])
+		if err = de.literal(buf[pos]); err != nil {
+			return 0, "", fmt.Errorf("in WriteDelta: %w", err)
+		}
+		pos++
+		if err = fill(); err != nil {
+			return 0, "", fmt.Errorf("in WriteDelta: %w", err)
+		}
+		if len(buf)-pos >= bsz {
+			in := uint32(buf[pos+bsz-1])
+			a = (a - out + in) & 0xffff
+			b = (b - uint32(bsz)*out + a) & 0xffff
+		} else {
+			rolling = false
+		}
+	}
+	sum := h.Sum(nil)
+	if err = de.end(size, sum); err != nil {
+		return 0, "", fmt.Errorf("in WriteDelta: %w", err)
+	}
+	return size, internal.Sha256ToStr32(sum), nil
+}
+
+// ApplyDelta reads a delta from delta, rebuilds the new content from it and the current content basis
+// and writes it to out
+// returns the size and checksum of the new content
+func ApplyDelta(basis io.ReaderAt, delta io.Reader, out io.Writer) (size int64, ch string, err error) {
+	br := bufio.NewReader(delta)
+	var hdr [5]byte
+	if _, err = io.ReadFull(br, hdr[:]); err != nil {
+		return 0, "", fmt.Errorf("in ApplyDelta: %w", err)
+	}
+	bsz := int(binary.BigEndian.Uint32(hdr[1:]))
+	if hdr[0] != 'D' || bsz < DELTA_MIN_BLOCK_SIZE || bsz > DELTA_MAX_BLOCK_SIZE {
+		return 0, "", fmt.Errorf("in ApplyDelta: invalid header")
+	}
+	h := sha256.New()
+	w := io.MultiWriter(out, h)
+	buf := make([]byte, bsz)
+	var args [deltaTrailerSize - 1]byte
+	for {
+		op, err := br.ReadByte()
+		if err != nil {
+			return 0, "", fmt.Errorf("in ApplyDelta: %w", err)
+		}
+		switch op {
+		case 'C':
+			if _, err = io.ReadFull(br, args[:12]); err != nil {
+				return 0, "", fmt.Errorf("in ApplyDelta: %w", err)
+			}
+			ix := int64(binary.BigEndian.Uint64(args[:]))
+			count := int64(binary.BigEndian.Uint32(args[8:]))
+			for i := ix; i < ix+count; i++ {
+				n, err := basis.ReadAt(buf, i*int64(bsz))
+				if err != nil && (err != io.EOF || n == 0) {
+					return 0, "", fmt.Errorf("in ApplyDelta: block %d %w", i, err)
+				}
+				if _, err = w.Write(buf[:n]); err != nil {
+					return 0, "", fmt.Errorf("in ApplyDelta: %w", err)
+				}
+				size += int64(n)
+			}
+		case 'L':
+			if _, err = io.ReadFull(br, args[:4]); err != nil {
+				return 0, "", fmt.Errorf("in ApplyDelta: %w", err)
+			}
+			l := int64(binary.BigEndian.Uint32(args[:]))
+			if _, err = io.CopyN(w, br, l); err != nil {
+				return 0, "", fmt.Errorf("in ApplyDelta: %w", err)
+			}
+			size += l
+		case 'E':
+			if _, err = io.ReadFull(br, args[:]); err != nil {
+				return 0, "", fmt.Errorf("in ApplyDelta: %w", err)
+			}
+			sum := h.Sum(nil)
+			if int64(binary.BigEndian.Uint64(args[:])) != size || !bytes.Equal(args[8:], sum) {
+				return 0, "", fmt.Errorf("in ApplyDelta: rebuilt content does not match (size %d)", size)
+			}
+			return size, internal.Sha256ToStr32(sum), nil
+		default:
+			return 0, "", fmt.Errorf("in ApplyDelta: invalid op %d", op)
+		}
+	}
+}
+
+// readDeltaTrailer returns the size and checksum of the new content from a delta stored in file dpath
+func readDeltaTrailer(afs afero.Fs, dpath string) (size int64, ch string, err error) {
+	df, err := afs.Open(dpath)
+	if err != nil {
+		return 0, "", fmt.Errorf("in readDeltaTrailer: %w", err)
+	}
+	defer df.Close()
+	fi, err := df.Stat()
+	if err != nil {
+		return 0, "", fmt.Errorf("in readDeltaTrailer: %w", err)
+	}
+	var tr [deltaTrailerSize]byte
+	if _, err = df.ReadAt(tr[:], fi.Size()-deltaTrailerSize); err != nil || tr[0] != 'E' {
+		return 0, "", fmt.Errorf("in readDeltaTrailer: invalid delta %v", err)
+	}
+	return int64(binary.BigEndian.Uint64(tr[1:])), internal.Sha256ToStr32(tr[9:]), nil
+}
+
+// newDeltaBasis returns the current content from rc with random access,
+// spooling it to a temporary file if rc doesn't provide it
+func newDeltaBasis(afs afero.Fs, rc io.ReadCloser) (io.ReaderAt, func() error, error) {
+	if ra, ok := rc.(io.ReaderAt); ok {
+		return ra, rc.Close, nil
+	}
+	defer rc.Close()
+	tf, err := afero.TempFile(afs, "", "db")
+	if err != nil {
+		return nil, nil, fmt.Errorf("in newDeltaBasis: %w", err)
+	}
+	cleanup := func() error {
+		tf.Close()
+		return afs.Remove(tf.Name())
+	}
+	if _, err = io.Copy(tf, rc); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("in newDeltaBasis: %w", err)
+	}
+	return tf, cleanup, nil
+}
+
+type deltaWriteCloser struct {
+	pw      *io.PipeWriter
+	done    chan error
+	out     io.WriteCloser
+	applied func(err error)
+}
+
+func (dwc *deltaWriteCloser) Write(p []byte) (int, error) {
+	return dwc.pw.Write(p)
+}
+
+func (dwc *deltaWriteCloser) Close() error {
+	dwc.pw.Close()
+	err := <-dwc.done
+	dwc.applied(err)
+	cErr := dwc.out.Close()
+	if err != nil {
+		return fmt.Errorf("in deltaWriteCloser: %w", err)
+	}
+	return cErr
+}
+
+// newDeltaWriteCloser returns a WriteCloser applying the delta written to it against basis and writing the result to out
+// applied is called with the result of the delta application before out is closed
+func newDeltaWriteCloser(basis io.ReaderAt, out io.WriteCloser, applied func(err error)) io.WriteCloser {
+	pr, pw := io.Pipe()
+	dwc := &deltaWriteCloser{pw: pw, done: make(chan error, 1), out: out, applied: applied}
+	go func() {
+		_, _, err := ApplyDelta(basis, pr, out)
+		pr.CloseWithError(err)
+		dwc.done <- err
+	}()
+	return dwc
+}
@@ -0,0 +1,191 @@
+package cabridss
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"testing"
+)
+
+func deltaTestContents() (obs, nbs []byte) {
+	obs = randBytes(3, 3*1024*1024+100)
+	nbs = append([]byte{}, obs[:1024*1024]...)
+	nbs = append(nbs, []byte("inserted")...)
+	nbs = append(nbs, obs[1024*1024:2*1024*1024]...)
+	nbs = append(nbs, obs[2*1024*1024+5000:]...)
+	nbs = append(nbs, []byte("appended")...)
+	copy(nbs[500*1024:], "overwritten")
+	return
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	obs, nbs := deltaTestContents()
+	sigs, err := ComputeSignatures(bytes.NewReader(obs), DeltaBlockSize(int64(len(obs))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sigs.Size != int64(len(obs)) {
+		t.Fatalf("TestDeltaRoundTrip signatures size %d", sigs.Size)
+	}
+	var delta bytes.Buffer
+	size, ch, err := WriteDelta(sigs, bytes.NewReader(nbs), &delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(nbs)) || delta.Len() > 10*sigs.BlockSize {
+		t.Fatalf("TestDeltaRoundTrip size %d delta size %d", size, delta.Len())
+	}
+	var out bytes.Buffer
+	rsize, rch, err := ApplyDelta(bytes.NewReader(obs), bytes.NewReader(delta.Bytes()), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), nbs) || rsize != size || rch != ch {
+		t.Fatalf("TestDeltaRoundTrip rebuilt content differs")
+	}
+	out.Reset()
+	if _, _, err = ApplyDelta(bytes.NewReader(nbs), bytes.NewReader(delta.Bytes()), &out); err == nil {
+		t.Fatalf("TestDeltaRoundTrip should fail with another basis")
+	}
+}
+
+func runDeltaTest(dss Dss, npath string, obs, nbs []byte) error {
+	if err := writeTestContent(dss, npath, obs); err != nil {
+		return err
+	}
+	sigs, err := dss.GetContentSignatures(npath, DeltaBlockSize(int64(len(obs))))
+	if err != nil {
+		return err
+	}
+	var cbErr error
+	wc, err := dss.GetContentDeltaWriter(npath, 0, nil, func(err error, size int64, ch string) {
+		if err == nil && size != int64(len(nbs)) {
+			err = fmt.Errorf("size %d", size)
+		}
+		cbErr = err
+	})
+	if err != nil {
+		return err
+	}
+	if _, _, err = WriteDelta(sigs, bytes.NewReader(nbs), wc); err != nil {
+		wc.Close()
+		return err
+	}
+	if err = wc.Close(); err != nil {
+		return err
+	}
+	if cbErr != nil {
+		return fmt.Errorf("runDeltaTest callback %v", cbErr)
+	}
+	rc, err := dss.GetContentReader(npath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	rbs, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(rbs, nbs) {
+		return fmt.Errorf("runDeltaTest %s content differs", npath)
+	}
+	return nil
+}
+
+func TestFsyDelta(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestFsyDelta", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	dss, err := NewFsyDss(FsyConfig{}, tfs.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	obs, nbs := deltaTestContents()
+	if err = runDeltaTest(dss, "d/c.bin", obs, nbs); err != nil {
+		t.Fatal(err)
+	}
+	children, err := dss.Lsns("d")
+	if err != nil || len(children) != 2 {
+		t.Fatalf("TestFsyDelta delta basis not removed %v %v", children, err)
+	}
+	sigs, err := ComputeSignatures(bytes.NewReader(obs), DeltaBlockSize(int64(len(obs))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cbErr error
+	wc, err := dss.GetContentDeltaWriter("d/c.bin", 0, nil, func(err error, size int64, ch string) { cbErr = err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = WriteDelta(sigs, bytes.NewReader(obs), wc); err != nil {
+		t.Fatal(err)
+	}
+	if err = wc.Close(); err == nil || cbErr == nil {
+		t.Fatalf("TestFsyDelta delta against another basis should fail %v", cbErr)
+	}
+	rc, err := dss.GetContentReader("d/c.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbs, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || !bytes.Equal(rbs, nbs) {
+		t.Fatalf("TestFsyDelta content not preserved after failed delta %v", err)
+	}
+	children, err = dss.Lsns("d")
+	if err != nil || len(children) != 2 {
+		t.Fatalf("TestFsyDelta failed delta left files %v %v", children, err)
+	}
+}
+
+func TestWebDssClientOlfDelta(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestWebDssClientOlfDelta", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(tfs, ":3000", "",
+		CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	dss, err := NewWebDss(
+		WebDssConfig{DssBaseConfig: DssBaseConfig{ConfigDir: ufpath.Join(tfs.Path(), ".cabri-i1"), WebPort: "3000"}},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, []string{"c.bin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	obs, nbs := deltaTestContents()
+	if err = runDeltaTest(dss, "c.bin", obs, nbs); err != nil {
+		t.Fatal(err)
+	}
+	mai, err := dss.AuditIndex()
+	if err != nil || len(mai) != 0 {
+		t.Fatalf("TestWebDssClientOlfDelta AuditIndex %v %v", mai, err)
+	}
+}
+
+func TestWfsDssDelta(t *testing.T) {
+	if err := runWfsDssTest(t, func(tfs *testfs.Fs, dss Dss) error {
+		obs, nbs := deltaTestContents()
+		return runDeltaTest(dss, "d/c.bin", obs, nbs)
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
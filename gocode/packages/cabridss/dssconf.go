@@ -9,28 +9,32 @@ import (
 )
 
 type DssBaseConfig struct {
-	ConfigDir         string                                                      `json:"-"`          // if not "" path to the user's configuration directory
-	ConfigPassword    string                                                      `json:"-"`          // if master password is used to encrypt client configuration
-	LocalPath         string                                                      `json:"-"`          // local path for configuration and index, or "" if unused (index will be memory based)
-	RepoId            string                                                      `json:"repoId"`     // uuid of the repository
-	Unlock            bool                                                        `json:"-"`          // unlocks index concurrent updates lock
-	AutoRepair        bool                                                        `json:"autoRepair"` // if unlock required, automatically repairs the index
-	ReIndex           bool                                                        `json:"-"`          // forces full content reindexation
-	GetIndex          func(config DssBaseConfig, localPath string) (Index, error) `json:"-"`          // non-default function to instantiate an index
-	XImpl             string                                                      `json:"xImpl"`      // index implementation code: bdb, memory, no
-	LibApi            bool                                                        `json:"-"`          // prevents using a web API server for local DSS access
-	WebProtocol       string                                                      `json:"-"`          // web API server protocol
-	WebHost           string                                                      `json:"-"`          // web API server host
-	WebPort           string                                                      `json:"-"`          // web API server port
-	WebClientTimeout  time.Duration                                               `json:"-"`          // client timeout in seconds, a Timeout of zero means no timeout
-	TlsCert           string                                                      `json:"-"`          // certificate file on https server or untrusted CA on https client
-	TlsKey            string                                                      `json:"-"`          // certificate key file on https server
-	TlsNoCheck        bool                                                        `json:"-"`          // no check of certificate by https client
-	BasicAuthUser     string                                                      `json:"-"`          // adds basic authentication
-	BasicAuthPassword string                                                      `json:"-"`          // basic authentication password
-	WebRoot           string                                                      `json:"-"`          // web API server root
-	Encrypted         bool                                                        `json:"encrypted"`  // repository is encrypted
-	ReducerLimit      int                                                         `json:"-"`          // if not 0 max number of parallel I/O
+	ConfigDir         string                                                      `json:"-"`           // if not "" path to the user's configuration directory
+	ConfigPassword    string                                                      `json:"-"`           // if master password is used to encrypt client configuration
+	LocalPath         string                                                      `json:"-"`           // local path for configuration and index, or "" if unused (index will be memory based)
+	RepoId            string                                                      `json:"repoId"`      // uuid of the repository
+	Unlock            bool                                                        `json:"-"`           // unlocks index concurrent updates lock
+	AutoRepair        bool                                                        `json:"autoRepair"`  // if unlock required, automatically repairs the index
+	ReIndex           bool                                                        `json:"-"`           // forces full content reindexation
+	GetIndex          func(config DssBaseConfig, localPath string) (Index, error) `json:"-"`           // non-default function to instantiate an index
+	XImpl             string                                                      `json:"xImpl"`       // index implementation code: bdb, memory, no
+	LibApi            bool                                                        `json:"-"`           // prevents using a web API server for local DSS access
+	WebProtocol       string                                                      `json:"-"`           // web API server protocol
+	WebHost           string                                                      `json:"-"`           // web API server host
+	WebPort           string                                                      `json:"-"`           // web API server port
+	WebClientTimeout  time.Duration                                               `json:"-"`           // client timeout in seconds, a Timeout of zero means no timeout
+	TlsCert           string                                                      `json:"-"`           // certificate file on https server or untrusted CA on https client
+	TlsKey            string                                                      `json:"-"`           // certificate key file on https server
+	TlsNoCheck        bool                                                        `json:"-"`           // no check of certificate by https client
+	BasicAuthUser     string                                                      `json:"-"`           // adds basic authentication
+	BasicAuthPassword string                                                      `json:"-"`           // basic authentication password
+	WebRoot           string                                                      `json:"-"`           // web API server root
+	Encrypted         bool                                                        `json:"encrypted"`   // repository is encrypted
+	Chunked           bool                                                        `json:"chunked"`     // content is split in chunks for deduplication (see chunks.go)
+	Compressed        bool                                                        `json:"compressed"`  // content is compressed before being stored or encrypted (see compress.go)
+	Compression       string                                                      `json:"compression"` // compression algorithm, gzip if empty or zstd
+	Convergent        bool                                                        `json:"convergent"`  // encrypted content is deduplicated with convergent encryption (see ageconv.go)
+	ReducerLimit      int                                                         `json:"-"`           // if not 0 max number of parallel I/O
 }
 
 func writeDssConfig(bc DssBaseConfig, dssConfig interface{}) error {
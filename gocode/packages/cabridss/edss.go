@@ -1,6 +1,8 @@
 package cabridss
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
@@ -9,6 +11,7 @@ import (
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/plumber"
 	"io"
 	"sort"
+	"strings"
 	"sync"
 )
 
@@ -22,6 +25,12 @@ type eDssBaseImpl struct {
 
 type eDssImpl struct {
 	webDssImpl
+	convergence *convergenceState // repository secret for convergent encryption, shared by views
+}
+
+type convergenceState struct {
+	mx     sync.Mutex
+	secret []byte
 }
 
 func (edi *eDssImpl) initialize(me oDssProxy, config interface{}, lsttime int64, aclusers []string) error {
@@ -36,11 +45,36 @@ func (edi *eDssImpl) initialize(me oDssProxy, config interface{}, lsttime int64,
 }
 
 func (edi *eDssImpl) isDuplicate(ch string) (bool, error) {
-	return false, nil // encrypted content is never the same
+	if !edi.repoConvergent {
+		return false, nil // encrypted content is never the same
+	}
+	// with convergent encryption, content is the same if already encrypted for the recipients of the default ACL
+	mbss, err := edi.index.(*pIndex).queryMetasByCh(ch)
+	if err != nil {
+		return false, fmt.Errorf("in isDuplicate: %w", err)
+	}
+	recipients := edi.convergentRecipients(edi.defaultAcl(nil))
+	for _, mbs := range mbss {
+		var meta Meta
+		if err = json.Unmarshal(mbs, &meta); err != nil || meta.Ch != ch || meta.ECh == "" || len(meta.EUsers) != 0 {
+			continue
+		}
+		if edi.convergentRecipients(meta.ACL) == recipients {
+			return edi.queryContent(meta.ECh)
+		}
+	}
+	return false, nil
 }
 
 func (edi *eDssImpl) isEncrypted() bool { return true }
 
+func (edi *eDssImpl) atTime(lsttime int64) oDssProxy {
+	view := *edi
+	view.me = &view
+	view.lsttime = lsttime
+	return &view
+}
+
 func (edi *eDssImpl) defaultUser() string {
 	for _, id := range edi.apc.GetConfig().(webDssClientConfig).identities {
 		if id.Alias == "" {
@@ -91,6 +125,14 @@ func (edi *eDssImpl) pkeys(users []string) (res []string) {
 	return
 }
 
+// contentUsers returns the users the content of meta is encrypted for
+func (edi *eDssImpl) contentUsers(meta Meta) []string {
+	if len(meta.EUsers) != 0 {
+		return meta.EUsers
+	}
+	return Users(edi.defaultAcl(meta.ACL))
+}
+
 func (edi *eDssImpl) doUpdatens(npath string, mtime int64, children []string, acl []ACLEntry) error {
 	content, css, _ := internal.Ns2Content(children, "")
 	sort.Strings(children)
@@ -103,6 +145,9 @@ func (edi *eDssImpl) doUpdatens(npath string, mtime int64, children []string, ac
 		Children: children,
 		ACL:      acl,
 	}
+	if err := edi.pageNsMeta(npath, &meta); err != nil {
+		return fmt.Errorf("in doUpdatens: %w", err)
+	}
 	meta.EMId = uuid.New().String()
 	mbs, itime, err := edi.getMetaBytes(meta)
 	if err != nil {
@@ -149,6 +194,39 @@ func (edi *eDssImpl) doSymlink(npath, tpath string, mtime int64, acl []ACLEntry)
 	return nil
 }
 
+// doCopyContent shares the encrypted content of smeta, which is only possible for the same recipients
+func (edi *eDssImpl) doCopyContent(smeta Meta, npath string, mtime int64, acl []ACLEntry) error {
+	if smeta.ECh == "" || len(smeta.EUsers) != 0 || edi.convergentRecipients(smeta.ACL) != edi.convergentRecipients(acl) {
+		return fmt.Errorf("in doCopyContent: %w", ErrCopyNotSupported)
+	}
+	meta := Meta{
+		Path:   npath,
+		Mtime:  mtime,
+		Size:   smeta.Size,
+		Ch:     smeta.Ch,
+		ACL:    acl,
+		ECh:    smeta.ECh,
+		EMId:   uuid.New().String(),
+		Chunks: smeta.Chunks,
+		CSize:  smeta.CSize,
+	}
+	mbs, itime, err := edi.getMetaBytes(meta)
+	if err != nil {
+		return fmt.Errorf("in doCopyContent: %w", err)
+	}
+	embs, err := EncryptMsg(string(mbs), edi.pkeys(Users(acl))...)
+	if err != nil {
+		return fmt.Errorf("in doCopyContent: %w", err)
+	}
+	if err := edi.storeMeta(meta.EMId, MIN_TIME, embs); err != nil {
+		return fmt.Errorf("in doCopyContent: %w", err)
+	}
+	if err := edi.index.storeMeta(meta.Path, itime, mbs); err != nil {
+		return fmt.Errorf("in doCopyContent: %w", err)
+	}
+	return nil
+}
+
 func (edi *eDssImpl) doGetMetaTimesFor(npath string) ([]int64, error) {
 	return nil, nil // encrypted meta is only retrieved from local index
 }
@@ -167,19 +245,137 @@ func (edi *eDssImpl) doGetMetaAt(npath string, time int64) (Meta, error) {
 	if err != nil {
 		return Meta{}, err
 	}
+	if err = edi.checkReadTrust(meta); err != nil {
+		return Meta{}, err
+	}
+	if err = edi.unpageNsMeta(&meta); err != nil {
+		return Meta{}, err
+	}
 	return meta, nil
 }
 
+func (edi *eDssImpl) storeNsPage(children []string, acl []ACLEntry, prev map[string]NsPage) (NsPage, error) {
+	bs, err := json.Marshal(children)
+	if err != nil {
+		return NsPage{}, fmt.Errorf("in storeNsPage: %w", err)
+	}
+	page := NsPage{Ch: internal.BytesToSha256Str(bs), Count: len(children)}
+	if pp, ok := prev[page.Ch]; ok && pp.ECh != "" {
+		return pp, nil
+	}
+	ebs, err := EncryptMsg(string(bs), edi.pkeys(Users(acl))...)
+	if err != nil {
+		return NsPage{}, fmt.Errorf("in storeNsPage: %w", err)
+	}
+	page.ECh = internal.BytesToSha256Str(ebs)
+	if err = edi.pushChunk(page.ECh, ebs); err != nil {
+		return NsPage{}, fmt.Errorf("in storeNsPage: %w", err)
+	}
+	return page, nil
+}
+
+func (edi *eDssImpl) loadNsPage(page NsPage, acl []ACLEntry) (children []string, err error) {
+	rc, err := edi.spGetContentReader(page.ECh)
+	if err != nil {
+		return nil, fmt.Errorf("in loadNsPage: %w", err)
+	}
+	defer rc.Close()
+	ebs, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("in loadNsPage: %w", err)
+	}
+	smbs, err := DecryptMsg(ebs, edi.secrets(Users(acl))...)
+	if err != nil {
+		return nil, fmt.Errorf("in loadNsPage: %w", err)
+	}
+	if err = json.Unmarshal([]byte(smbs), &children); err != nil {
+		return nil, fmt.Errorf("in loadNsPage: %w", err)
+	}
+	return
+}
+
+// convergentRecipients returns the sorted public keys content is encrypted to for the acl
+func (edi *eDssImpl) convergentRecipients(acl []ACLEntry) string {
+	pkeys := edi.pkeys(Users(acl))
+	sort.Strings(pkeys)
+	return strings.Join(pkeys, ",")
+}
+
+// convergenceSecret returns the repository secret for convergent encryption,
+// it is created by the first client needing it and stored encrypted for all its identities
+func (edi *eDssImpl) convergenceSecret() ([]byte, error) {
+	edi.convergence.mx.Lock()
+	defer edi.convergence.mx.Unlock()
+	if edi.convergence.secret != nil {
+		return edi.convergence.secret, nil
+	}
+	ebs, err := edi.loadConvergenceKey()
+	if err != nil {
+		return nil, fmt.Errorf("in convergenceSecret: %w", err)
+	}
+	if len(ebs) == 0 {
+		secret := make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("in convergenceSecret: %w", err)
+		}
+		if ebs, err = EncryptMsg(hex.EncodeToString(secret), edi.allPkeys()...); err != nil {
+			return nil, fmt.Errorf("in convergenceSecret: %w", err)
+		}
+		if err = edi.storeConvergenceKey(ebs); err != nil {
+			// another client may have stored it meanwhile
+			if ebs, err = edi.loadConvergenceKey(); err != nil || len(ebs) == 0 {
+				return nil, fmt.Errorf("in convergenceSecret: %v", err)
+			}
+		}
+	}
+	ssecret, err := DecryptMsg(ebs, edi.allSecrets()...)
+	if err != nil {
+		return nil, fmt.Errorf("in convergenceSecret: %w", err)
+	}
+	if edi.convergence.secret, err = hex.DecodeString(ssecret); err != nil {
+		return nil, fmt.Errorf("in convergenceSecret: %w", err)
+	}
+	return edi.convergence.secret, nil
+}
+
+// encryptFileConvergent encrypts the plaintext file pn of checksum ch to w with convergent encryption
+func encryptFileConvergent(afs afero.Fs, w io.Writer, pn string, secret []byte, ch string, recipients []string) error {
+	pr, err := afs.Open(pn)
+	if err != nil {
+		return fmt.Errorf("in encryptFileConvergent: %w", err)
+	}
+	defer pr.Close()
+	wc, err := EncryptConvergent(w, secret, ch, recipients...)
+	if err != nil {
+		return fmt.Errorf("in encryptFileConvergent: %w", err)
+	}
+	if _, err = io.Copy(wc, pr); err != nil {
+		return fmt.Errorf("in encryptFileConvergent: %w", err)
+	}
+	if err = wc.Close(); err != nil {
+		return fmt.Errorf("in encryptFileConvergent: %w", err)
+	}
+	return nil
+}
+
 func (edi *eDssImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEntry) (io.WriteCloser, error) {
 	var (
-		eWcwc *WriteCloserWithCb
-		eErr  error
-		eSize int64
-		eCh   string
-		cErr  error
-		cSize int64
-		cCh   string
+		eWcwc  *WriteCloserWithCb
+		eErr   error
+		eSize  int64
+		eCh    string
+		cErr   error
+		cSize  int64
+		cCh    string
+		zSize  int64
+		secret []byte
+		err    error
 	)
+	if edi.repoConvergent {
+		if secret, err = edi.convergenceSecret(); err != nil {
+			return nil, fmt.Errorf("in spGetContentWriter: %w", err)
+		}
+	}
 
 	ecw, err := NewTempFileWriteCloserWithCb(edi.getAfs(), "", "ecw", func(err error, size int64, ch string, me *WriteCloserWithCb) error {
 		eWcwc, eErr, eSize, eCh = me, err, size, ch
@@ -202,6 +398,9 @@ func (edi *eDssImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEntry)
 		meta.Size = cSize
 		meta.Ch = cCh
 		meta.ECh = eCh
+		if edi.repoCompressed {
+			meta.CSize = zSize
+		}
 		mbs, itime, err := edi.getMetaBytes(meta)
 		if err != nil {
 			outError = fmt.Errorf("in spGetContentWriter %w", err)
@@ -213,7 +412,20 @@ func (edi *eDssImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEntry)
 			return outError
 		}
 		cf := eWcwc.Underlying.(afero.File)
-		if err := edi.pushContent(size, ch, embs, emid, cf); err != nil {
+		dup := false
+		if edi.repoConvergent {
+			if dup, err = edi.queryContent(eCh); err != nil {
+				outError = fmt.Errorf("in spGetContentWriter: %w", err)
+				return outError
+			}
+		}
+		if dup {
+			// the same content is already stored encrypted for the same recipients
+			err = edi.storeMeta(emid, MIN_TIME, embs)
+		} else {
+			err = edi.pushContent(size, ch, embs, emid, cf)
+		}
+		if err != nil {
 			outError = fmt.Errorf("in spGetContentWriter: %w", err)
 			return outError
 		}
@@ -228,10 +440,21 @@ func (edi *eDssImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEntry)
 	if err != nil {
 		return nil, fmt.Errorf("in spGetContentWriter: %w", err)
 	}
+	if edi.repoConvergent {
+		return edi.convergentContentWriter(ecw, cwcbs, acl, secret, &zSize, func(err error, size int64, ch string) {
+			cErr, cSize, cCh = err, size, ch
+		})
+	}
 	wc, err := Encrypt(ecw, edi.pkeys(Users(acl))...)
 	if err != nil {
 		return nil, fmt.Errorf("in spGetContentWriter: %w", err)
 	}
+	if edi.repoCompressed {
+		// compression must be applied before encryption
+		if wc, err = newCompressWriter(wc, &zSize, edi.repoCompression); err != nil {
+			return nil, fmt.Errorf("in spGetContentWriter: %w", err)
+		}
+	}
 	return NewWriteCloserWithCb(wc, func(err error, size int64, ch string, me *WriteCloserWithCb) error {
 		outError := err
 		defer func() {
@@ -254,17 +477,95 @@ func (edi *eDssImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEntry)
 	}), nil
 }
 
+// convergentContentWriter returns a writer of the plaintext to a temporary file,
+// encrypted to ecw with convergent encryption when closed, as the key depends on its checksum
+func (edi *eDssImpl) convergentContentWriter(ecw io.WriteCloser, cwcbs contentWriterCbs, acl []ACLEntry, secret []byte, zSize *int64, plainCb func(err error, size int64, ch string)) (io.WriteCloser, error) {
+	abort := func() {
+		ecf := ecw.(*WriteCloserWithCb).Underlying.(afero.File)
+		ecf.Close()
+		edi.getAfs().Remove(ecf.Name())
+	}
+	pcf, err := afero.TempFile(edi.getAfs(), "", "pcw")
+	if err != nil {
+		abort()
+		return nil, fmt.Errorf("in convergentContentWriter: %w", err)
+	}
+	var pwc io.WriteCloser = pcf
+	if edi.repoCompressed {
+		// compression must be applied before encryption, the temporary file must outlive the compress writer
+		if pwc, err = newCompressWriter(struct{ io.WriteCloser }{pcf}, zSize, edi.repoCompression); err != nil {
+			pcf.Close()
+			edi.getAfs().Remove(pcf.Name())
+			abort()
+			return nil, fmt.Errorf("in convergentContentWriter: %w", err)
+		}
+	}
+	return NewWriteCloserWithCb(pwc, func(err error, size int64, ch string, me *WriteCloserWithCb) error {
+		outError := err
+		defer func() {
+			if cwcbs.closeCb != nil {
+				cwcbs.closeCb(outError, size, ch)
+			}
+		}()
+		defer edi.getAfs().Remove(pcf.Name())
+
+		plainCb(err, size, ch)
+		if err == nil {
+			err = encryptFileConvergent(edi.getAfs(), ecw, pcf.Name(), secret, ch, edi.pkeys(Users(acl)))
+		}
+		if err != nil {
+			abort()
+			outError = err
+			return outError
+		}
+		if err = ecw.Close(); err != nil {
+			outError = err
+			return err
+		}
+		return nil
+	}), nil
+}
+
 func (edi *eDssImpl) doGetContentReader(npath string, meta Meta) (io.ReadCloser, error) {
 	erc, err := edi.spGetContentReader(meta.ECh)
 	if err != nil {
 		return nil, fmt.Errorf("in doGetContentReader: %w", err)
 	}
-	crc, err := Decrypt(erc, edi.secrets(Users(meta.ACL))...)
-	return NewReadCloserWithCb(crc, func() error {
+	crc, err := Decrypt(erc, edi.secrets(edi.contentUsers(meta))...)
+	if err != nil {
+		erc.Close()
+		return nil, fmt.Errorf("in doGetContentReader: %w", err)
+	}
+	rc := io.NopCloser(crc)
+	if meta.CSize > 0 {
+		if rc, err = newDecompressReader(rc); err != nil {
+			erc.Close()
+			return nil, fmt.Errorf("in doGetContentReader: %w", err)
+		}
+	}
+	return NewReadCloserWithCb(rc, func() error {
+		rc.Close()
 		return erc.Close()
 	})
 }
 
+// doGetContentRangeReader only reads and decrypts the chunks of the encrypted content covering the range,
+// unless the content is compressed before encryption
+func (edi *eDssImpl) doGetContentRangeReader(npath string, meta Meta, offset, length int64) (io.ReadCloser, error) {
+	if meta.CSize > 0 {
+		rc, err := edi.doGetContentReader(npath, meta)
+		if err != nil {
+			return nil, fmt.Errorf("in doGetContentRangeReader: %w", err)
+		}
+		return newSkipReader(rc, offset, length)
+	}
+	rd, err := DecryptRange(spReaderAt{me: edi.me, ch: meta.ECh}, meta.Size, offset, length, edi.secrets(edi.contentUsers(meta))...)
+	if err != nil {
+		return nil, fmt.Errorf("in doGetContentRangeReader: %w", err)
+	}
+	return io.NopCloser(rd), nil
+}
+
 func (edi *eDssImpl) xRemoveMeta(meta Meta) error {
 	if err := cXRemoveMeta(edi.apc, meta.EMId, MIN_TIME); err != nil {
 		return fmt.Errorf("in xRemoveMeta: %v", err)
@@ -273,6 +574,43 @@ func (edi *eDssImpl) xRemoveMeta(meta Meta) error {
 	return edi.index.removeMeta(ipath, meta.Itime)
 }
 
+// allPkeys returns the public keys of all identities, so that any of them can read DSS-wide data such as tags
+func (edi *eDssImpl) allPkeys() (res []string) {
+	for _, id := range edi.apc.GetConfig().(webDssClientConfig).identities {
+		res = append(res, id.PKey)
+	}
+	return
+}
+
+func (edi *eDssImpl) allSecrets() (res []string) {
+	for _, id := range edi.apc.GetConfig().(webDssClientConfig).identities {
+		if id.Secret != "" {
+			res = append(res, id.Secret)
+		}
+	}
+	return
+}
+
+func (edi *eDssImpl) loadTags() ([]byte, error) {
+	ebs, err := edi.webDssImpl.loadTags()
+	if err != nil || len(ebs) == 0 {
+		return ebs, err
+	}
+	bs, err := DecryptMsg(ebs, edi.allSecrets()...)
+	if err != nil {
+		return nil, fmt.Errorf("in loadTags: %w", err)
+	}
+	return []byte(bs), nil
+}
+
+func (edi *eDssImpl) storeTags(bs []byte) error {
+	ebs, err := EncryptMsg(string(bs), edi.allPkeys()...)
+	if err != nil {
+		return fmt.Errorf("in storeTags: %w", err)
+	}
+	return edi.webDssImpl.storeTags(ebs)
+}
+
 func (edi *eDssImpl) spUpdateClient(cix Index, eud UpdatedData, isFull bool) error {
 	udd := UpdatedData{Changed: map[string][]TimedMeta{}, Deleted: map[string]bool{}}
 	for _, etms := range eud.Changed {
@@ -319,6 +657,9 @@ func (edi *eDssImpl) decryptScannedStorage(checksum bool, sts *mSPS, sti Storage
 			continue
 		}
 		if meta.IsNs {
+			for _, page := range meta.Pages {
+				sti.ExistingEcs[page.ECh] = true
+			}
 			continue
 		}
 		sti.ExistingCs[meta.Ch] = true
@@ -379,6 +720,7 @@ func (edi *eDssImpl) spScanPhysicalStorageClient(checksum bool, sts *mSPS, sti S
 	copyMap(sti.Path2HnIt, sts.Sti.Path2HnIt)
 	copyMap(sti.Path2Content, sts.Sti.Path2Content)
 	copyMap(sti.Path2Error, sts.Sti.Path2Error)
+	copyMap(sti.Untrusted, sts.Sti.Untrusted)
 
 	errs = &sts.Errs
 	edi.decryptScannedStorage(checksum, sts, sti, errs)
@@ -450,7 +792,7 @@ func newEDssProxy(config EDssConfig, lsttime int64, aclusers []string) (oDssProx
 	if err != nil {
 		return nil, nil, fmt.Errorf("in newEDssProxy: %w", err)
 	}
-	impl := eDssImpl{webDssImpl: *wdp.(*webDssImpl)}
+	impl := eDssImpl{webDssImpl: *wdp.(*webDssImpl), convergence: &convergenceState{}}
 	return &impl, dss, nil
 }
 
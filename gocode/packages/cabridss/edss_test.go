@@ -4,8 +4,11 @@ import (
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEDssClientOlfBase(t *testing.T) {
@@ -534,3 +537,300 @@ func TestEDssApiClientOlfMultiHistory(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestEDssClientOlfTags(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs(t.Name(), tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(tfs, ":3000", "",
+		CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex, Encrypted: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	dss, err := NewEDss(
+		EDssConfig{
+			WebDssConfig: WebDssConfig{
+				DssBaseConfig: DssBaseConfig{
+					ConfigDir: ufpath.Join(tfs.Path(), ".cabri"),
+					WebPort:   "3000",
+				}},
+		},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.CreateTag("before", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err = dss.CreateTag("now", 0); err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := dss.ListTags(); err != nil || len(tags) != 2 || tags[0].Name != "before" {
+		t.Fatalf("TestEDssClientOlfTags ListTags %v %v", tags, err)
+	}
+	if tt, err := dss.ResolveTag("before"); err != nil || tt != 1000 {
+		t.Fatalf("TestEDssClientOlfTags ResolveTag %d %v", tt, err)
+	}
+	bs, err := os.ReadFile(ufpath.Join(tfs.Path(), "tags"))
+	if err != nil || strings.Contains(string(bs), "before") {
+		t.Fatalf("TestEDssClientOlfTags tags are not encrypted %v", err)
+	}
+	if err = dss.DeleteTag("before"); err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := dss.ListTags(); err != nil || len(tags) != 1 || tags[0].Name != "now" {
+		t.Fatalf("TestEDssClientOlfTags ListTags after DeleteTag %v %v", tags, err)
+	}
+}
+
+func TestEDssClientOlfLargeNs(t *testing.T) {
+	var sv WebServer
+	var err error
+	defer func() {
+		if sv != nil {
+			sv.Shutdown()
+		}
+	}()
+
+	if err := runTestLargeNs(t, true,
+		func(tfs *testfs.Fs) error {
+			getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+				return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+			}
+			sv, err = createWebDssServer(tfs, ":3000", "",
+				CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex, Encrypted: true},
+			)
+			return err
+		},
+		func(tfs *testfs.Fs) (HDss, error) {
+			dss, err := NewEDss(
+				EDssConfig{
+					WebDssConfig: WebDssConfig{
+						DssBaseConfig: DssBaseConfig{
+							ConfigDir: ufpath.Join(tfs.Path(), ".cabri"),
+							WebPort:   "3000",
+						}},
+				},
+				0, nil)
+			return dss, err
+		}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEDssClientOlfConvergent(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs(t.Name(), tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	if _, err = CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: ufpath.Join(tfs.Path(), "d"), Convergent: true}, Root: ufpath.Join(tfs.Path(), "d"), Size: "s"}); err == nil {
+		t.Fatal("TestEDssClientOlfConvergent convergent encryption of a clear repository should fail")
+	}
+	getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(tfs, ":3000", "",
+		CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex, Encrypted: true, Compressed: true, Convergent: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	dss, err := NewEDss(
+		EDssConfig{
+			WebDssConfig: WebDssConfig{
+				DssBaseConfig: DssBaseConfig{
+					ConfigDir: ufpath.Join(tfs.Path(), ".cabri"),
+					WebPort:   "3000",
+				}},
+		},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if !dss.IsRepoConvergent() {
+		t.Fatal("TestEDssClientOlfConvergent repository is not convergent")
+	}
+	if err = dss.Mkns("", 0, []string{"a.txt", "b.txt", "c.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	same := []byte(strings.Repeat("same content ", 10000))
+	for _, nc := range []struct {
+		npath string
+		bs    []byte
+	}{{"a.txt", same}, {"b.txt", same}, {"c.txt", []byte("other content")}} {
+		if err = writeTestContent(dss, nc.npath, nc.bs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ma, err := dss.GetMeta("a.txt", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb, err := dss.GetMeta("b.txt", true)
+	if err != nil || ma.(Meta).ECh != mb.(Meta).ECh {
+		t.Fatalf("TestEDssClientOlfConvergent identical contents are not deduplicated %v", err)
+	}
+	if dup, err := dss.IsDuplicate(ma.GetCh()); err != nil || !dup {
+		t.Fatalf("TestEDssClientOlfConvergent IsDuplicate %v %v", dup, err)
+	}
+	if dup, err := dss.IsDuplicate(internal.BytesToSha256Str([]byte("none"))); err != nil || dup {
+		t.Fatalf("TestEDssClientOlfConvergent IsDuplicate none %v %v", dup, err)
+	}
+	if _, err = os.Stat(ufpath.Join(tfs.Path(), "convergence")); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := dss.GetContentReader("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(bs) != string(same) {
+		t.Fatalf("TestEDssClientOlfConvergent GetContentReader %v", err)
+	}
+
+	// the shared content is kept as long as one of its metas remains
+	if err = dss.Updatens("", 0, []string{"b.txt", "c.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dss.RemoveHistory("a.txt", false, false, false, 0, time.Now().Unix()+3600); err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := dss.ScanStorage(true, true, false); errs != nil {
+		t.Fatalf("TestEDssClientOlfConvergent ScanStorage purge %v", errs)
+	}
+	sti, errs := dss.ScanStorage(true, false, false)
+	if errs != nil || len(sti.Path2Content) != 2 {
+		t.Fatalf("TestEDssClientOlfConvergent ScanStorage %d contents %v", len(sti.Path2Content), errs)
+	}
+	rc, err = dss.GetContentReader("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(bs) != string(same) {
+		t.Fatalf("TestEDssClientOlfConvergent GetContentReader after purge %v", err)
+	}
+}
+
+func TestEDssClientOlfRekey(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs(t.Name(), tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(tfs, ":3000", "",
+		CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex, Encrypted: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	newClient := func(configDir string, id IdentityConfig) (HDss, error) {
+		if id.PKey != "" {
+			if err := UserConfigPutIdentity(DssBaseConfig{}, configDir, id); err != nil {
+				return nil, err
+			}
+		}
+		return NewEDss(
+			EDssConfig{
+				WebDssConfig: WebDssConfig{
+					DssBaseConfig: DssBaseConfig{
+						ConfigDir: configDir,
+						WebPort:   "3000",
+					}},
+			},
+			0, nil)
+	}
+	uc, err := GetUserConfig(DssBaseConfig{}, ufpath.Join(tfs.Path(), ".cabri"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oid := uc.GetIdentity("")
+	nid, err := GenIdentity("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dss, err := newClient(ufpath.Join(tfs.Path(), ".cabri"), nid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, []string{"a.txt", "d/"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = dss.Mkns("d", 0, []string{"b.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, nc := range []struct{ npath, content string }{{"a.txt", "a1"}, {"a.txt", "a2"}, {"d/b.txt", "b"}} {
+		if err = writeTestContent(dss, nc.npath, []byte(nc.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jd := ufpath.Join(tfs.Path(), "rekey")
+	opts := RekeyOptions{MapUsers: map[string][]string{oid.PKey: {nid.PKey}}, Content: true, NoPurge: true, JournalDir: jd}
+	res, err := dss.Rekey("", true, opts)
+	if err != nil || len(res["a.txt"]) != 2 || len(res["d/"]) != 1 || len(res["d/b.txt"]) != 1 {
+		t.Fatalf("TestEDssClientOlfRekey Rekey %v %v", res, err)
+	}
+	meta, err := dss.GetMeta("a.txt", true)
+	if err != nil || len(meta.GetAcl()) != 1 || meta.GetAcl()[0].User != nid.PKey {
+		t.Fatalf("TestEDssClientOlfRekey GetMeta %v %v", meta, err)
+	}
+	rc, err := dss.GetContentReader("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(bs) != "a2" {
+		t.Fatalf("TestEDssClientOlfRekey GetContentReader %s %v", bs, err)
+	}
+	if _, err = newClient(ufpath.Join(tfs.Path(), ".cabri-n1"), IdentityConfig{PKey: nid.PKey, Secret: nid.Secret}); err == nil {
+		t.Fatal("TestEDssClientOlfRekey former encrypted metas should not be purged yet")
+	}
+
+	opts.NoPurge = false
+	if res, err = dss.Rekey("", true, opts); err != nil || len(res) != 0 {
+		t.Fatalf("TestEDssClientOlfRekey resumed Rekey %v %v", res, err)
+	}
+	if des, err := os.ReadDir(jd); err != nil || len(des) != 0 {
+		t.Fatalf("TestEDssClientOlfRekey journal %v %v", des, err)
+	}
+	ndss, err := newClient(ufpath.Join(tfs.Path(), ".cabri-n2"), IdentityConfig{PKey: nid.PKey, Secret: nid.Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ndss.Close()
+	rc, err = ndss.GetContentReader("d/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(bs) != "b" {
+		t.Fatalf("TestEDssClientOlfRekey GetContentReader new identity %s %v", bs, err)
+	}
+	if _, err = newClient(ufpath.Join(tfs.Path(), ".cabri-o"), IdentityConfig{PKey: oid.PKey, Secret: oid.Secret}); err == nil {
+		t.Fatal("TestEDssClientOlfRekey former identity should be revoked")
+	}
+}
@@ -22,4 +22,23 @@ var (
 	// ErrPasswordRequired is returned when accessing encrypted content
 	// without access to the user's master password
 	ErrPasswordRequired = errors.New("password required to perform this action")
+
+	// ErrDeltaNotSupported is returned when a delta transfer is requested
+	// from a DSS which cannot provide it, typically an encrypted one
+	ErrDeltaNotSupported = errors.New("delta transfer not supported")
+
+	// ErrCopyNotSupported is returned when some content cannot be copied within a DSS
+	// without transferring it, typically encrypted for other users
+	ErrCopyNotSupported = errors.New("content copy not supported")
+
+	// ErrRekeyNotSupported is returned when rekeying a DSS which is not encrypted
+	ErrRekeyNotSupported = errors.New("rekey not supported")
+
+	// ErrUntrustedMeta is returned when reading meta data which is unsigned, badly signed
+	// or signed by a signer out of the repository trust list, with the "reject" trust policy
+	ErrUntrustedMeta = errors.New("untrusted meta data")
+
+	// ErrInvalidRange is returned when a content range is out of the content
+	// or cannot be parsed
+	ErrInvalidRange = errors.New("invalid content range")
 )
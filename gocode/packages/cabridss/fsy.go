@@ -278,6 +278,207 @@ func (fsy *FsyDss) GetContentReader(npath string) (rc io.ReadCloser, err error)
 	return
 }
 
+func (fsy *FsyDss) doGetContentRangeReader(npath string, offset, length int64) (io.ReadCloser, error) {
+	cpath := ufpath.Join(fsy.root, npath)
+	fi, err := fsy.GetAfs().Stat(cpath)
+	if err != nil {
+		return nil, fmt.Errorf("in GetContentRangeReader: %w", err)
+	}
+	if length, err = checkRange(fi.Size(), offset, length); err != nil {
+		return nil, fmt.Errorf("in GetContentRangeReader: %w", err)
+	}
+	f, err := fsy.GetAfs().Open(cpath)
+	if err != nil {
+		return nil, fmt.Errorf("in GetContentRangeReader: %w", err)
+	}
+	return newSeekReader(f, offset, length)
+}
+
+func (fsy *FsyDss) GetContentRangeReader(npath string, offset, length int64) (rc io.ReadCloser, err error) {
+	if fsy.reducer == nil {
+		rc, err = fsy.doGetContentRangeReader(npath, offset, length)
+		return
+	}
+	if err = fsy.reducer.Launch(
+		fmt.Sprintf("GetContentRangeReader %s", npath),
+		func() error {
+			var iErr error
+			if rc, iErr = fsy.doGetContentRangeReader(npath, offset, length); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+func (fsy *FsyDss) doGetContentSignatures(npath string, blockSize int) (*ContentSignatures, error) {
+	rc, err := fsy.doGetContentReader(npath)
+	if err != nil {
+		return nil, fmt.Errorf("in GetContentSignatures: %w", err)
+	}
+	defer rc.Close()
+	return ComputeSignatures(rc, blockSize)
+}
+
+func (fsy *FsyDss) GetContentSignatures(npath string, blockSize int) (sigs *ContentSignatures, err error) {
+	if fsy.reducer == nil {
+		sigs, err = fsy.doGetContentSignatures(npath, blockSize)
+		return
+	}
+	if err = fsy.reducer.Launch(
+		fmt.Sprintf("GetContentSignatures %s", npath),
+		func() error {
+			var iErr error
+			if sigs, iErr = fsy.doGetContentSignatures(npath, blockSize); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+// replaceContent replaces the content of cpath with the temporary file tpath, copied if it cannot be renamed
+func (fsy *FsyDss) replaceContent(tpath, cpath string, mtime int64, acl []ACLEntry) error {
+	if err := fsy.GetAfs().Rename(tpath, cpath); err != nil {
+		tf, err := fsy.GetAfs().Open(tpath)
+		if err != nil {
+			return fmt.Errorf("in replaceContent: %w", err)
+		}
+		defer tf.Close()
+		cf, err := fsy.GetAfs().Create(cpath)
+		if err != nil {
+			return fmt.Errorf("in replaceContent: %w", err)
+		}
+		if _, err = io.Copy(cf, tf); err != nil {
+			cf.Close()
+			fsy.GetAfs().Remove(cpath)
+			return fmt.Errorf("in replaceContent: %w", err)
+		}
+		if err = cf.Close(); err != nil {
+			fsy.GetAfs().Remove(cpath)
+			return fmt.Errorf("in replaceContent: %w", err)
+		}
+	}
+	if err := fsy.GetAfs().Chtimes(cpath, time.Now(), time.Unix(mtime, 0)); err != nil {
+		return fmt.Errorf("in replaceContent: %w", err)
+	}
+	if err := setSysAcl(cpath, acl); err != nil {
+		return fmt.Errorf("in replaceContent: %w", err)
+	}
+	return nil
+}
+
+func (fsy *FsyDss) doGetContentDeltaWriter(npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (io.WriteCloser, error) {
+	if err := checkMkcontentArgs(npath, acl); err != nil {
+		return nil, err
+	}
+	// the current content is the delta basis and is left untouched, the updated content is written
+	// to a temporary file outside the DSS tree, replacing the current content only if the update succeeds
+	cpath := ufpath.Join(fsy.root, npath)
+	bf, err := fsy.GetAfs().Open(cpath)
+	if err != nil {
+		return nil, fmt.Errorf("in GetContentDeltaWriter: %w", err)
+	}
+	tf, err := afero.TempFile(fsy.GetAfs(), "", "cabri-delta")
+	if err != nil {
+		bf.Close()
+		return nil, fmt.Errorf("in GetContentDeltaWriter: %w", err)
+	}
+	var applyErr error
+	out := &ContentHandle{cf: tf, h: sha256.New(), cb: func(err error, size int64, ch string) {
+		if applyErr != nil {
+			err = applyErr
+		}
+		if err == nil {
+			err = fsy.replaceContent(tf.Name(), cpath, mtime, acl)
+		}
+		fsy.GetAfs().Remove(tf.Name())
+		if cb != nil {
+			cb(err, size, ch)
+		}
+	}}
+	return newDeltaWriteCloser(bf, out, func(err error) {
+		applyErr = err
+		bf.Close()
+	}), nil
+}
+
+func (fsy *FsyDss) GetContentDeltaWriter(npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (wc io.WriteCloser, err error) {
+	if fsy.reducer == nil {
+		wc, err = fsy.doGetContentDeltaWriter(npath, mtime, acl, cb)
+		return
+	}
+	if err = fsy.reducer.Launch(
+		fmt.Sprintf("GetContentDeltaWriter %s", npath),
+		func() error {
+			var iErr error
+			if wc, iErr = fsy.doGetContentDeltaWriter(npath, mtime, acl, cb); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+func (fsy *FsyDss) doCopyContent(spath, npath, ch string, mtime int64, acl []ACLEntry) error {
+	if err := checkNpath(spath); err != nil {
+		return err
+	}
+	smeta, err := fsy.doGetMeta(spath, true)
+	if err != nil {
+		return fmt.Errorf("in CopyContent: %w", err)
+	}
+	if smeta.GetIsNs() || smeta.GetIsSymLink() {
+		return fmt.Errorf("in CopyContent: %s is not a regular content", spath)
+	}
+	if smeta.GetChUnsafe() != ch {
+		return fmt.Errorf("in CopyContent: %s content has changed", spath)
+	}
+	in, err := fsy.doGetContentReader(spath)
+	if err != nil {
+		return fmt.Errorf("in CopyContent: %w", err)
+	}
+	defer in.Close()
+	var cbErr error
+	out, err := fsy.doGetContentWriter(npath, mtime, acl, func(err error, size int64, wch string) {
+		if err == nil && wch != ch {
+			err = fmt.Errorf("%s content has changed", spath)
+		}
+		cbErr = err
+	})
+	if err != nil {
+		return fmt.Errorf("in CopyContent: %w", err)
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("in CopyContent: %w", err)
+	}
+	if err = out.Close(); err != nil {
+		return fmt.Errorf("in CopyContent: %w", err)
+	}
+	if cbErr != nil {
+		return fmt.Errorf("in CopyContent: %w", cbErr)
+	}
+	return nil
+}
+
+func (fsy *FsyDss) CopyContent(spath, npath, ch string, mtime int64, acl []ACLEntry) error {
+	if fsy.reducer == nil {
+		return fsy.doCopyContent(spath, npath, ch, mtime, acl)
+	}
+	return fsy.reducer.Launch(
+		fmt.Sprintf("CopyContent %s", npath),
+		func() error {
+			return fsy.doCopyContent(spath, npath, ch, mtime, acl)
+		})
+}
+
 func (fsy *FsyDss) doSymlink(npath string, tpath string, mtime int64, acl []ACLEntry) error {
 	if err := checkNpath(npath); err != nil {
 		return err
@@ -380,6 +581,45 @@ func (fsy *FsyDss) Remove(npath string) (err error) {
 		})
 }
 
+func (fsy *FsyDss) doRename(src, dst string, mtime int64) error {
+	isNS, sipath, dipath, err := checkRenameArgs(src, dst)
+	if err != nil {
+		return err
+	}
+	sfp := ufpath.Join(fsy.root, sipath)
+	if _, err = fsy.ctlStat(sfp, isNS); err != nil {
+		return fmt.Errorf("in Rename: %w", err)
+	}
+	dfp := ufpath.Join(fsy.root, dipath)
+	if _, err = os.Lstat(dfp); err == nil {
+		return fmt.Errorf("in Rename: %s already exists", dst)
+	}
+	dpfp := ufpath.Dir(dfp)
+	if _, err = fsy.ctlStat(dpfp, true); err != nil {
+		return fmt.Errorf("in Rename: %w", err)
+	}
+	if err = fsy.GetAfs().Rename(sfp, dfp); err != nil {
+		return fmt.Errorf("in Rename: %w", err)
+	}
+	for _, pfp := range []string{ufpath.Dir(sfp), dpfp} {
+		if err = fsy.GetAfs().Chtimes(pfp, time.Now(), time.Unix(mtime, 0)); err != nil {
+			return fmt.Errorf("in Rename: %w", err)
+		}
+	}
+	return nil
+}
+
+func (fsy *FsyDss) Rename(src, dst string, mtime int64) error {
+	if fsy.reducer == nil {
+		return fsy.doRename(src, dst, mtime)
+	}
+	return fsy.reducer.Launch(
+		fmt.Sprintf("Rename %s", src),
+		func() error {
+			return fsy.doRename(src, dst, mtime)
+		})
+}
+
 func (fsy *FsyDss) doGetMeta(npath string, getCh bool) (IMeta, error) {
 	isNS, ipath, err := checkNCpath(npath)
 	if err != nil {
@@ -563,7 +563,8 @@ func (pix *pIndex) updateData(udd UpdatedData, isFull bool) error {
 
 			}
 			if updTms {
-				if _, _, err := tx.Set(fmt.Sprintf("mts/%s", nph), strings.Join(eTimes, " "), nil); err != nil {
+				// the clients of this index get the changes as well
+				if err := pix.doStoreMetaTimes(tx, nph, strings.Join(eTimes, " ")); err != nil {
 					return err
 				}
 			}
@@ -651,6 +652,25 @@ func loadInMemory(db *buntdb.DB) (map[string]map[int64]bool, map[string]map[int6
 	return metaTimes, metas, removed, nil
 }
 
+// queryMetasByCh returns the metadata whose content has the given checksum,
+// through a secondary index on the metadata created by buntdb on first use and maintained on update
+func (pix *pIndex) queryMetasByCh(ch string) ([][]byte, error) {
+	var mbss [][]byte
+	if err := pix.db.CreateIndex("ch", "m/*", buntdb.IndexJSONCaseSensitive("ch")); err != nil && err != buntdb.ErrIndexExists {
+		return nil, fmt.Errorf("in queryMetasByCh: %v", err)
+	}
+	pivot, _ := json.Marshal(map[string]string{"ch": ch})
+	if err := pix.db.View(func(tx *buntdb.Tx) error {
+		return tx.AscendEqual("ch", string(pivot), func(key, value string) bool {
+			mbss = append(mbss, []byte(value))
+			return true
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("in queryMetasByCh: %v", err)
+	}
+	return mbss, nil
+}
+
 func (pix *pIndex) loadInMemory() (map[string]map[int64]bool, map[string]map[int64][]byte, map[string]bool, error) {
 	return loadInMemory(pix.db)
 }
@@ -414,6 +414,73 @@ func TestMIndexClient(t *testing.T) {
 	}
 }
 
+func TestPIndexUpdateDataClient(t *testing.T) {
+	tfs, err := testfs.CreateFs("TestPIndexUpdateDataClient", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfs.Delete()
+	ix, err := NewPIndex(ufpath.Join(tfs.Path(), "pindex.dat"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	clId := uuid.New().String()
+	if _, err = ix.recordClient(clId); err != nil {
+		t.Fatal(err)
+	}
+	// data received from the remote index with an old time are provided to the clients of the local one
+	if err = ix.updateData(UpdatedData{Changed: map[string][]TimedMeta{"h1": {{Time: 1, Bytes: "m1"}}}}, false); err != nil {
+		t.Fatal(err)
+	}
+	udd, err := ix.updateClient(clId, false)
+	if err != nil || len(udd.Changed) != 1 || len(udd.Changed["h1"]) != 1 || udd.Changed["h1"][0].Bytes != "m1" {
+		t.Fatal(err, udd)
+	}
+	if udd, err = ix.updateClient(clId, false); err != nil || len(udd.Changed) != 0 {
+		t.Fatal(err, udd)
+	}
+}
+
+func TestPIndexMetasByCh(t *testing.T) {
+	tfs, err := testfs.CreateFs("TestPIndexMetasByCh", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfs.Delete()
+	ix, err := NewPIndex(ufpath.Join(tfs.Path(), "pindex.dat"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	pix := ix.(*pIndex)
+	if err = pix.storeMeta("a", 1, []byte(`{"path":"a","ch":"c1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err = pix.storeMeta("b", 1, []byte(`{"path":"b","ch":"c2"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if mbss, err := pix.queryMetasByCh("c1"); err != nil || len(mbss) != 1 {
+		t.Fatal(err, len(mbss))
+	}
+	// the index is maintained once created
+	if err = pix.storeMeta("c", 2, []byte(`{"path":"c","ch":"c1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if mbss, err := pix.queryMetasByCh("c1"); err != nil || len(mbss) != 2 {
+		t.Fatal(err, len(mbss))
+	}
+	if err = pix.removeMeta("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if mbss, err := pix.queryMetasByCh("c1"); err != nil || len(mbss) != 1 {
+		t.Fatal(err, len(mbss))
+	}
+	if mbss, err := pix.queryMetasByCh("c3"); err != nil || len(mbss) != 0 {
+		t.Fatal(err, len(mbss))
+	}
+}
+
 func TestPIndexRepair(t *testing.T) {
 	if os.Getenv("CABRIDSS_KEEP_DEV_TESTS") == "" {
 		t.Skip(fmt.Sprintf("Skipping %s because you didn't set CABRIDSS_KEEP_DEV_TESTS", t.Name()))
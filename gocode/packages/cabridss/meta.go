@@ -13,6 +13,12 @@ type Meta struct {
 	Itime         int64      `json:"itime"`                   // index time
 	ECh           string     `json:"ech"`                     // truncated SHA256 checksum of the encrypted content if encrypted else empty
 	EMId          string     `json:"emid"`                    // encrypted meta-data unique identifier if encrypted else empty
+	Chunks        []string   `json:"chunks,omitempty"`        // chunk manifest if content is chunked and the manifest is small enough
+	CSize         int64      `json:"csize,omitempty"`         // compressed content size if content is compressed and not chunked
+	Pages         []NsPage   `json:"pages,omitempty"`         // children pages if the namespace is too large for Children to be stored inline
+	EUsers        []string   `json:"eusers,omitempty"`        // users the content is encrypted for if not the ACL ones, after a rekey of the meta only
+	Signer        string     `json:"signer,omitempty"`        // signing public key if the meta data is signed (see sign.go)
+	Sig           string     `json:"sig,omitempty"`           // signature of the meta data without Sig
 }
 
 type IMeta interface {
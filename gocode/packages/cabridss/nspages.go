@@ -0,0 +1,167 @@
+package cabridss
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
+	"hash/fnv"
+	"io"
+	"sync"
+)
+
+// namespaces whose children list exceeds NS_INLINE_SIZE bytes are paged: the sorted children are split
+// into pages at content-defined boundaries, each page is stored as a regular content blob named by its checksum,
+// and the namespace Meta only lists the pages in Meta.Pages, Meta.Children being restored from them
+// when the meta is loaded. As page boundaries only depend on the children names,
+// adding or removing a child usually stores a single new page, the other ones being reused.
+
+const (
+	NS_INLINE_SIZE    = 32 * 1024 // maximum size of the children list inlined in a namespace Meta
+	NS_PAGE_MIN       = 1024      // minimum number of children in a page
+	NS_PAGE_MAX       = 16384     // maximum number of children in a page
+	NS_PAGE_AVG_BITS  = 12        // log2 of the average number of children in a page
+	NS_PAGE_CACHE_MAX = 1 << 20   // maximum number of children kept in the pages cache
+)
+
+// NsPage references a page of the children of a large namespace
+type NsPage struct {
+	Ch    string `json:"ch"`            // truncated SHA256 checksum of the page
+	ECh   string `json:"ech,omitempty"` // truncated SHA256 checksum of the encrypted page if encrypted else empty
+	Count int    `json:"count"`         // number of children in the page
+}
+
+const nsPageMask = uint64(1<<NS_PAGE_AVG_BITS - 1)
+
+// splitNsPages splits sorted children into pages, a page ends with a child whose name hash matches nsPageMask
+func splitNsPages(children []string) [][]string {
+	var pages [][]string
+	start := 0
+	for i, child := range children {
+		n := i + 1 - start
+		h := fnv.New64a()
+		h.Write([]byte(child))
+		if i == len(children)-1 || n >= NS_PAGE_MAX || (n >= NS_PAGE_MIN && h.Sum64()&nsPageMask == 0) {
+			pages = append(pages, children[start:i+1])
+			start = i + 1
+		}
+	}
+	return pages
+}
+
+// nsPageCache keeps the children of loaded pages, which never change as pages are named by their checksum
+type nsPageCache struct {
+	mx    sync.Mutex
+	pages map[string][]string
+	count int
+}
+
+var nsPages = &nsPageCache{pages: map[string][]string{}}
+
+func (npc *nsPageCache) get(key string) ([]string, bool) {
+	npc.mx.Lock()
+	defer npc.mx.Unlock()
+	children, ok := npc.pages[key]
+	return children, ok
+}
+
+func (npc *nsPageCache) put(key string, children []string) {
+	npc.mx.Lock()
+	defer npc.mx.Unlock()
+	if npc.count+len(children) > NS_PAGE_CACHE_MAX {
+		npc.pages = map[string][]string{}
+		npc.count = 0
+	}
+	npc.pages[key] = children
+	npc.count += len(children)
+}
+
+// pageNsMeta moves the children of a large namespace meta into pages,
+// reusing the pages of its current version when the ACL is unchanged
+func (odbi *oDssBaseImpl) pageNsMeta(npath string, meta *Meta) error {
+	if meta.Size <= NS_INLINE_SIZE {
+		return nil
+	}
+	prev := map[string]NsPage{}
+	if pmeta, err := odbi.doGetMeta(npath); err == nil && CmpAcl(pmeta.ACL, meta.ACL) {
+		for _, page := range pmeta.Pages {
+			prev[page.Ch] = page
+		}
+	}
+	var pages []NsPage
+	for _, children := range splitNsPages(meta.Children) {
+		page, err := odbi.me.storeNsPage(children, meta.ACL, prev)
+		if err != nil {
+			return fmt.Errorf("in pageNsMeta: %w", err)
+		}
+		pages = append(pages, page)
+	}
+	meta.Pages = pages
+	meta.Children = nil
+	return nil
+}
+
+// unpageNsMeta restores the children of a paged namespace meta
+func (odbi *oDssBaseImpl) unpageNsMeta(meta *Meta) error {
+	if len(meta.Pages) == 0 {
+		return nil
+	}
+	count := 0
+	for _, page := range meta.Pages {
+		count += page.Count
+	}
+	children := make([]string, 0, count)
+	for _, page := range meta.Pages {
+		key := page.Ch
+		if page.ECh != "" {
+			key = page.ECh
+		}
+		pChildren, ok := nsPages.get(key)
+		if !ok {
+			var err error
+			if pChildren, err = odbi.me.loadNsPage(page, meta.ACL); err != nil {
+				return fmt.Errorf("in unpageNsMeta: %s %w", meta.Path, err)
+			}
+			if len(pChildren) != page.Count {
+				return fmt.Errorf("in unpageNsMeta: %s page %s has %d children instead of %d", meta.Path, key, len(pChildren), page.Count)
+			}
+			nsPages.put(key, pChildren)
+		}
+		children = append(children, pChildren...)
+	}
+	meta.Children = children
+	return nil
+}
+
+func (odbi *oDssBaseImpl) storeNsPage(children []string, acl []ACLEntry, prev map[string]NsPage) (NsPage, error) {
+	bs, err := json.Marshal(children)
+	if err != nil {
+		return NsPage{}, fmt.Errorf("in storeNsPage: %w", err)
+	}
+	page := NsPage{Ch: internal.BytesToSha256Str(bs), Count: len(children)}
+	if _, ok := prev[page.Ch]; ok {
+		return page, nil
+	}
+	if ex, err := odbi.me.queryContent(page.Ch); err == nil && ex {
+		return page, nil
+	}
+	if err = odbi.me.pushChunk(page.Ch, bs); err != nil {
+		return NsPage{}, fmt.Errorf("in storeNsPage: %w", err)
+	}
+	return page, nil
+}
+
+func (odbi *oDssBaseImpl) loadNsPage(page NsPage, acl []ACLEntry) (children []string, err error) {
+	rc, err := odbi.me.spGetContentReader(page.Ch)
+	if err != nil {
+		return nil, fmt.Errorf("in loadNsPage: %w", err)
+	}
+	defer rc.Close()
+	bs, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("in loadNsPage: %w", err)
+	}
+	if err = json.Unmarshal(bs, &children); err != nil {
+		return nil, fmt.Errorf("in loadNsPage: %w", err)
+	}
+	return
+}
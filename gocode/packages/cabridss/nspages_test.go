@@ -0,0 +1,205 @@
+package cabridss
+
+import (
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func largeNsChildren(n int) []string {
+	children := make([]string, n)
+	for i := range children {
+		children[i] = fmt.Sprintf("mail-archive-message-%06d.eml", i)
+	}
+	sort.Strings(children)
+	return children
+}
+
+func TestSplitNsPages(t *testing.T) {
+	children := largeNsChildren(50000)
+	pages := splitNsPages(children)
+	count := 0
+	for i, page := range pages {
+		if len(page) > NS_PAGE_MAX || (i < len(pages)-1 && len(page) < NS_PAGE_MIN) {
+			t.Fatalf("TestSplitNsPages page %d size %d", i, len(page))
+		}
+		count += len(page)
+	}
+	if count != len(children) || len(pages) < 2 {
+		t.Fatalf("TestSplitNsPages %d children in %d pages", count, len(pages))
+	}
+	// adding a child only changes the page where it is inserted
+	children2 := append(append([]string{}, children...), "mail-archive-message-025000.eml.bak")
+	sort.Strings(children2)
+	pages2 := splitNsPages(children2)
+	firsts := map[string]int{}
+	for _, page := range pages {
+		firsts[fmt.Sprintf("%s-%d", page[0], len(page))] = 1
+	}
+	changed := 0
+	for _, page := range pages2 {
+		if _, ok := firsts[fmt.Sprintf("%s-%d", page[0], len(page))]; !ok {
+			changed++
+		}
+	}
+	if changed != 1 {
+		t.Fatalf("TestSplitNsPages %d pages changed", changed)
+	}
+}
+
+func TestOlfLargeNs(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestOlfLargeNs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	t1 := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC).Unix()
+	dss.SetCurrentTime(t1)
+	if err = dss.Mkns("", 0, []string{"archive/"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	children := largeNsChildren(50000)
+	if err = dss.Mkns("archive", 0, children, nil); err != nil {
+		t.Fatal(err)
+	}
+	dss.SetCurrentTime(t1 + 3600)
+	if err = writeTestContent(dss, "archive/mail-archive-message-025000.eml", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err = dss.Updatens("archive", 0, append(append([]string{}, children...), "new.eml"), nil); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := dss.Lsns("archive")
+	if err != nil || len(cs) != 50001 {
+		t.Fatalf("TestOlfLargeNs Lsns %d %v", len(cs), err)
+	}
+	meta, err := dss.GetMeta("archive/", true)
+	if err != nil || len(meta.GetChildren()) != 50001 || len(meta.(Meta).Pages) < 2 {
+		t.Fatalf("TestOlfLargeNs GetMeta %v", err)
+	}
+	if err = filepath.WalkDir(ufpath.Join(tfs.Path(), "meta"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err == nil && fi.Size() >= MAX_META_SIZE {
+			err = fmt.Errorf("%s size %d", path, fi.Size())
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mHes, err := dss.GetHistory("archive/", false, "")
+	if err != nil || len(mHes["archive/"]) != 2 || len(mHes["archive/"][0].HMeta.Children) != 50000 {
+		t.Fatalf("TestOlfLargeNs GetHistory %v", err)
+	}
+	view, err := dss.AtTime(t1 + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs, err = view.Lsns("archive"); err != nil || len(cs) != 50000 {
+		t.Fatalf("TestOlfLargeNs Lsns at time %d %v", len(cs), err)
+	}
+	view.Close()
+	if mai, err := dss.AuditIndex(); err != nil || len(mai) != 0 {
+		t.Fatalf("TestOlfLargeNs AuditIndex %v %v", mai, err)
+	}
+	sti, errs := dss.ScanStorage(false, false, false)
+	if errs != nil {
+		t.Fatalf("TestOlfLargeNs ScanStorage %v", errs)
+	}
+	// the pages of both versions but one are shared, plus a single content
+	if len(sti.Path2Content) != len(meta.(Meta).Pages)+2 {
+		t.Fatalf("TestOlfLargeNs ScanStorage %d contents for %d pages", len(sti.Path2Content), len(meta.(Meta).Pages))
+	}
+
+	if _, err = dss.RemoveHistory("archive/", false, false, false, 0, t1+3600); err != nil {
+		t.Fatal(err)
+	}
+	if _, errs = dss.ScanStorage(false, true, false); errs == nil || len(*errs) != 1 {
+		t.Fatalf("TestOlfLargeNs ScanStorage purge should report the unused page %v", errs)
+	}
+	if _, errs = dss.ScanStorage(false, false, false); errs != nil {
+		t.Fatalf("TestOlfLargeNs ScanStorage after purge %v", errs)
+	}
+	if cs, err = dss.Lsns("archive"); err != nil || len(cs) != 50001 {
+		t.Fatalf("TestOlfLargeNs Lsns after purge %d %v", len(cs), err)
+	}
+}
+
+func runTestLargeNs(t *testing.T, encrypted bool, createDssCb func(*testfs.Fs) error, newDssCb func(*testfs.Fs) (HDss, error)) error {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs(t.Name(), tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	if err = createDssCb(tfs); err != nil {
+		return err
+	}
+	dss, err := newDssCb(tfs)
+	if err != nil {
+		return err
+	}
+	if err = dss.Mkns("", 0, []string{"archive/"}, nil); err != nil {
+		return err
+	}
+	children := largeNsChildren(50000)
+	if err = dss.Mkns("archive", 0, children, nil); err != nil {
+		return err
+	}
+	if err = dss.Updatens("archive", 0, append(append([]string{}, children...), "new.eml"), nil); err != nil {
+		return err
+	}
+	if cs, err := dss.Lsns("archive"); err != nil || len(cs) != 50001 {
+		return fmt.Errorf("Lsns %d %v", len(cs), err)
+	}
+	if err = dss.Close(); err != nil {
+		return err
+	}
+
+	// pages are loaded from the storage by another client
+	nsPages = &nsPageCache{pages: map[string][]string{}}
+	if dss, err = newDssCb(tfs); err != nil {
+		return err
+	}
+	defer dss.Close()
+	if cs, err := dss.Lsns("archive"); err != nil || len(cs) != 50001 || cs[50000] != "new.eml" {
+		return fmt.Errorf("Lsns other client %d %v", len(cs), err)
+	}
+	pages := 0
+	if err = filepath.WalkDir(ufpath.Join(tfs.Path(), "content"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		pages++
+		bs, err := os.ReadFile(path)
+		if err == nil && encrypted && strings.Contains(string(bs), children[0]) {
+			err = fmt.Errorf("%s is not encrypted", path)
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+	if pages < 2 {
+		return fmt.Errorf("%d pages stored", pages)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package cabridss
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/spf13/afero"
@@ -11,21 +12,26 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 type ObsConfig struct {
 	DssBaseConfig
-	Endpoint     string            `json:"endpoint"`  // AWS S3 or Openstack Swift endpoint, eg "https://s3.gra.cloud.ovh.net"
-	Region       string            `json:"region"`    // AWS S3  or Openstack Swift region, eg "GRA"
-	AccessKey    string            `json:"accessKey"` // AWS S3 access key (Openstack Swift must generate it)
-	SecretKey    string            `json:"secretKey"` // AWS S3 secret key (Openstack Swift must generate it)
-	Container    string            `json:"container"` // AWS S3 bucket or Openstack Swift container
-	GetS3Session func() IS3Session `json:"-"`         // if not nil enables to set a mock S3 implementation
+	Endpoint           string            `json:"endpoint"`           // AWS S3 or Openstack Swift endpoint, eg "https://s3.gra.cloud.ovh.net"
+	Region             string            `json:"region"`             // AWS S3  or Openstack Swift region, eg "GRA"
+	AccessKey          string            `json:"accessKey"`          // AWS S3 access key (Openstack Swift must generate it)
+	SecretKey          string            `json:"secretKey"`          // AWS S3 secret key (Openstack Swift must generate it)
+	Container          string            `json:"container"`          // AWS S3 bucket or Openstack Swift container
+	GetS3Session       func() IS3Session `json:"-"`                  // if not nil enables to set a mock S3 implementation
+	MultipartThreshold int64             `json:"multipartThreshold"` // if not 0 content size above which multipart upload is used, negative disables it
+	MultipartPartSize  int64             `json:"multipartPartSize"`  // if not 0 multipart upload part size, at least S3_MIN_PART_SIZE
 }
 
 type oDssObjImpl struct {
 	oDssBaseImpl
-	is3 IS3Session
+	is3         IS3Session
+	mpThreshold int64 // content size above which multipart upload is used, negative if disabled
+	mpPartSize  int64 // multipart upload part size
 }
 
 func (odoi *oDssObjImpl) initialize(me oDssProxy, config interface{}, lsttime int64, aclusers []string) error {
@@ -53,13 +59,40 @@ func (odoi *oDssObjImpl) initialize(me oDssProxy, config interface{}, lsttime in
 		if obsConfig.Container == "" {
 			obsConfig.Container = pc.Container
 		}
+		if obsConfig.MultipartThreshold == 0 {
+			obsConfig.MultipartThreshold = pc.MultipartThreshold
+		}
+		if obsConfig.MultipartPartSize == 0 {
+			obsConfig.MultipartPartSize = pc.MultipartPartSize
+		}
 		odoi.repoId = pc.RepoId
 		odoi.repoEncrypted = pc.Encrypted
+		odoi.repoChunked = pc.Chunked
+		odoi.repoCompressed = pc.Compressed
+		odoi.repoCompression = pc.Compression
+		odoi.repoConvergent = pc.Convergent
 		obsConfig.XImpl = pc.XImpl
+		uc, err := CurrentUserConfig(obsConfig.DssBaseConfig)
+		if err != nil {
+			return fmt.Errorf("in Initialize: %w", err)
+		}
+		if err = odoi.setSigning(uc); err != nil {
+			return fmt.Errorf("in Initialize: %w", err)
+		}
+	}
+	if err := CheckMultipartPartSize(obsConfig.MultipartPartSize); err != nil {
+		return fmt.Errorf("in Initialize: %w", err)
 	}
 	if err := odoi.setIndex(obsConfig.DssBaseConfig, obsConfig.LocalPath); err != nil {
 		return fmt.Errorf("in Initialize: %w", err)
 	}
+	odoi.mpThreshold, odoi.mpPartSize = obsConfig.MultipartThreshold, obsConfig.MultipartPartSize
+	if odoi.mpThreshold == 0 {
+		odoi.mpThreshold = OBS_MULTIPART_THRESHOLD
+	}
+	if odoi.mpPartSize == 0 {
+		odoi.mpPartSize = OBS_MULTIPART_PART_SIZE
+	}
 	if obsConfig.GetS3Session == nil {
 		odoi.is3 = &s3Session{config: obsConfig}
 	} else {
@@ -112,7 +145,16 @@ func (odoi *oDssObjImpl) pushContent(size int64, ch string, mbs []byte, emid str
 			return fmt.Errorf("in pushContent: %w", err)
 		}
 		defer r.Close()
-		if err = odoi.is3.Upload(cName, r); err != nil {
+		fi, err := r.Stat()
+		if err != nil {
+			return fmt.Errorf("in pushContent: %w", err)
+		}
+		if odoi.mpThreshold >= 0 && fi.Size() > odoi.mpThreshold {
+			err = UploadMultipart(odoi.is3, cName, r, fi.Size(), odoi.mpPartSize)
+		} else {
+			err = odoi.is3.Upload(cName, r)
+		}
+		if err != nil {
 			if strings.Contains(err.Error(), "A conflicting conditional operation is currently in progress against this resource.") {
 				lr, _ = odoi.is3.List(cName)
 				if len(lr) != 0 {
@@ -130,12 +172,45 @@ func (odoi *oDssObjImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEnt
 		if err != nil {
 			return fmt.Errorf("in spGetContentWriter %w", err)
 		}
+		// the temporary file must not remain once its content is pushed or split into chunks
+		defer odoi.getAfs().Remove(wcwc.Underlying.(afero.File).Name())
+		var (
+			chunks []string
+			ccsize int64
+		)
+		if odoi.repoChunked && !odoi.isRepoEncrypted() {
+			if chunks, ccsize, err = odoi.storeChunks(ch, wcwc.Underlying.(afero.File)); err != nil {
+				return fmt.Errorf("in spGetContentWriter %w", err)
+			}
+		}
 		mbs, emid, err := cwcbs.getMetaBytes(err, size, ch)
 		if err != nil {
 			return fmt.Errorf("in spGetContentWriter %w", err)
 		}
-		if err = odoi.pushContent(size, ch, mbs, emid, wcwc.Underlying.(afero.File)); err != nil {
-			return fmt.Errorf("in spGetContentWriter %w", err)
+		if chunks == nil {
+			cf := wcwc.Underlying.(afero.File)
+			if odoi.isContentCompressed() {
+				var csize int64
+				if cf, csize, err = compressFile(odoi.getAfs(), "", cf, odoi.repoCompression); err != nil {
+					return fmt.Errorf("in spGetContentWriter %w", err)
+				}
+				defer odoi.getAfs().Remove(cf.Name())
+				if mbs, err = odoi.setMetaCSize(mbs, csize); err != nil {
+					return fmt.Errorf("in spGetContentWriter %w", err)
+				}
+			}
+			if err = odoi.pushContent(size, ch, mbs, emid, cf); err != nil {
+				return fmt.Errorf("in spGetContentWriter %w", err)
+			}
+		} else {
+			if mbs, err = odoi.setMetaChunks(mbs, chunks); err != nil {
+				return fmt.Errorf("in spGetContentWriter %w", err)
+			}
+			if odoi.isContentCompressed() {
+				if mbs, err = odoi.setMetaCSize(mbs, ccsize); err != nil {
+					return fmt.Errorf("in spGetContentWriter %w", err)
+				}
+			}
 		}
 		var (
 			itime int64
@@ -160,13 +235,51 @@ func (odoi *oDssObjImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEnt
 }
 
 func (odoi *oDssObjImpl) spGetContentReader(ch string) (io.ReadCloser, error) {
-	return odoi.is3.Download(fmt.Sprintf("content-%s", ch))
+	if odoi.repoChunked {
+		if ok, _ := odoi.queryChunkManifest(ch); ok {
+			chunks, err := odoi.loadChunkManifest(ch)
+			if err != nil {
+				return nil, fmt.Errorf("in GetContentReader: %w", err)
+			}
+			return odoi.newChunksReader(chunks), nil
+		}
+	}
+	rc, err := odoi.is3.Download(fmt.Sprintf("content-%s", ch))
+	if err != nil || !odoi.isContentCompressed() {
+		return rc, err
+	}
+	return newDecompressReader(rc)
 }
 
 func (odoi *oDssObjImpl) doGetContentReader(npath string, meta Meta) (io.ReadCloser, error) {
+	if len(meta.Chunks) > 0 {
+		return odoi.newChunksReader(meta.Chunks), nil
+	}
 	return odoi.spGetContentReader(meta.Ch)
 }
 
+func (odoi *oDssObjImpl) spGetContentRangeReader(ch string, offset, length int64) (io.ReadCloser, error) {
+	chunked := false
+	if odoi.repoChunked {
+		chunked, _ = odoi.queryChunkManifest(ch)
+	}
+	if chunked || odoi.isContentCompressed() {
+		rc, err := odoi.spGetContentReader(ch)
+		if err != nil {
+			return nil, fmt.Errorf("in GetContentRangeReader: %w", err)
+		}
+		return newSkipReader(rc, offset, length)
+	}
+	return odoi.is3.DownloadRange(fmt.Sprintf("content-%s", ch), offset, length)
+}
+
+func (odoi *oDssObjImpl) doGetContentRangeReader(npath string, meta Meta, offset, length int64) (io.ReadCloser, error) {
+	if len(meta.Chunks) > 0 {
+		return newSkipReader(odoi.newChunksReader(meta.Chunks), offset, length)
+	}
+	return odoi.spGetContentRangeReader(meta.Ch, offset, length)
+}
+
 func (odoi *oDssObjImpl) queryContent(ch string) (bool, error) {
 	cn := fmt.Sprintf("content-%s", ch)
 	lr, err := odoi.is3.List(cn)
@@ -174,11 +287,81 @@ func (odoi *oDssObjImpl) queryContent(ch string) (bool, error) {
 		return false, err
 	}
 	if len(lr) != 1 || lr[0] != cn {
+		if odoi.repoChunked {
+			if ok, err := odoi.queryChunkManifest(ch); err != nil || ok {
+				return ok, err
+			}
+		}
 		return false, fmt.Errorf("in queryContent: %v", lr)
 	}
 	return true, nil
 }
 
+func (odoi *oDssObjImpl) contentSize(ch string) (int64, error) {
+	m, err := odoi.is3.Meta(fmt.Sprintf("content-%s", ch))
+	if err != nil {
+		return 0, fmt.Errorf("in contentSize: %w", err)
+	}
+	return m.Length, nil
+}
+
+func (odoi *oDssObjImpl) pushChunk(ch string, bs []byte) error {
+	if odoi.isContentCompressed() {
+		var err error
+		if bs, err = compressBytes(bs, odoi.repoCompression); err != nil {
+			return fmt.Errorf("in pushChunk: %w", err)
+		}
+	}
+	if err := odoi.is3.Put(fmt.Sprintf("content-%s", ch), bs); err != nil {
+		return fmt.Errorf("in pushChunk: %w", err)
+	}
+	return nil
+}
+
+func (odoi *oDssObjImpl) queryChunkManifest(ch string) (bool, error) {
+	cn := fmt.Sprintf("chunks-%s", ch)
+	lr, err := odoi.is3.List(cn)
+	if err != nil {
+		return false, err
+	}
+	return len(lr) == 1 && lr[0] == cn, nil
+}
+
+func (odoi *oDssObjImpl) loadChunkManifest(ch string) ([]string, error) {
+	rc, err := odoi.is3.Download(fmt.Sprintf("chunks-%s", ch))
+	if err != nil {
+		return nil, fmt.Errorf("in loadChunkManifest: %w", err)
+	}
+	defer rc.Close()
+	bs, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("in loadChunkManifest: %w", err)
+	}
+	chunks, err := decodeChunkManifest(bs)
+	if err != nil {
+		return nil, fmt.Errorf("in loadChunkManifest: %w", err)
+	}
+	return chunks, nil
+}
+
+func (odoi *oDssObjImpl) storeChunkManifest(ch string, chunks []string) error {
+	bs, err := encodeChunkManifest(chunks)
+	if err != nil {
+		return fmt.Errorf("in storeChunkManifest: %w", err)
+	}
+	if err = odoi.is3.Upload(fmt.Sprintf("chunks-%s", ch), bytes.NewReader(bs)); err != nil {
+		return fmt.Errorf("in storeChunkManifest: %w", err)
+	}
+	return nil
+}
+
+func (odoi *oDssObjImpl) removeChunkManifest(ch string) error {
+	if err := odoi.is3.Delete(fmt.Sprintf("chunks-%s", ch)); err != nil {
+		return fmt.Errorf("in removeChunkManifest: %w", err)
+	}
+	return nil
+}
+
 func (odoi *oDssObjImpl) removeContent(ch string) error {
 	cn := fmt.Sprintf("content-%s", ch)
 	if err := odoi.is3.Delete(cn); err != nil {
@@ -187,8 +370,76 @@ func (odoi *oDssObjImpl) removeContent(ch string) error {
 	return nil
 }
 
+func (odoi *oDssObjImpl) loadTags() ([]byte, error) {
+	lr, err := odoi.is3.List("tags")
+	if err != nil {
+		return nil, fmt.Errorf("in loadTags: %w", err)
+	}
+	if len(lr) != 1 || lr[0] != "tags" {
+		return nil, nil
+	}
+	rc, err := odoi.is3.Download("tags")
+	if err != nil {
+		return nil, fmt.Errorf("in loadTags: %w", err)
+	}
+	defer rc.Close()
+	bs, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("in loadTags: %w", err)
+	}
+	return bs, nil
+}
+
+func (odoi *oDssObjImpl) storeTags(bs []byte) error {
+	if err := odoi.is3.Upload("tags", bytes.NewReader(bs)); err != nil {
+		return fmt.Errorf("in storeTags: %w", err)
+	}
+	return nil
+}
+
+func (odoi *oDssObjImpl) loadConvergenceKey() ([]byte, error) {
+	lr, err := odoi.is3.List("convergence")
+	if err != nil {
+		return nil, fmt.Errorf("in loadConvergenceKey: %w", err)
+	}
+	if len(lr) != 1 || lr[0] != "convergence" {
+		return nil, nil
+	}
+	rc, err := odoi.is3.Download("convergence")
+	if err != nil {
+		return nil, fmt.Errorf("in loadConvergenceKey: %w", err)
+	}
+	defer rc.Close()
+	bs, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("in loadConvergenceKey: %w", err)
+	}
+	return bs, nil
+}
+
+func (odoi *oDssObjImpl) storeConvergenceKey(bs []byte) error {
+	lr, err := odoi.is3.List("convergence")
+	if err != nil {
+		return fmt.Errorf("in storeConvergenceKey: %w", err)
+	}
+	if len(lr) == 1 && lr[0] == "convergence" {
+		return fmt.Errorf("in storeConvergenceKey: the convergence key already exists")
+	}
+	if err = odoi.is3.Upload("convergence", bytes.NewReader(bs)); err != nil {
+		return fmt.Errorf("in storeConvergenceKey: %w", err)
+	}
+	return nil
+}
+
 func (odoi *oDssObjImpl) spClose() error { return nil }
 
+func (odoi *oDssObjImpl) atTime(lsttime int64) oDssProxy {
+	view := *odoi
+	view.me = &view
+	view.lsttime = lsttime
+	return &view
+}
+
 func (odoi *oDssObjImpl) dumpIndex() string { return odoi.index.Dump() }
 
 func (odoi *oDssObjImpl) setAfs(tfs afero.Fs) { panic("inconsistent") }
@@ -287,6 +538,9 @@ func (odoi *oDssObjImpl) scanContentObjs(checksum bool, sti StorageInfo, errs *E
 	}
 	doScanContentObs := func(pcn string) {
 		cr, err := odoi.is3.Download(pcn)
+		if err == nil && odoi.isContentCompressed() {
+			cr, err = newDecompressReader(cr)
+		}
 		if err != nil {
 			lockPathErr(pcn, err)
 			return
@@ -321,9 +575,22 @@ func (odoi *oDssObjImpl) scanContentObjs(checksum bool, sti StorageInfo, errs *E
 	return
 }
 
+func (odoi *oDssObjImpl) scanChunksObjs(sti StorageInfo, errs *ErrorCollector) {
+	cns, err := odoi.is3.List("chunks-")
+	if err != nil {
+		sti.Path2Error["chunks-"] = err
+		errs.Collect(err)
+		return
+	}
+	for _, cn := range cns {
+		sti.Path2Manifest[cn] = cn[len("chunks-"):]
+	}
+}
+
 func (odoi *oDssObjImpl) scanPhysicalStorage(checksum bool, sti StorageInfo, errs *ErrorCollector) {
 	odoi.scanMetaObjs(sti, errs)
 	odoi.scanContentObjs(checksum, sti, errs)
+	odoi.scanChunksObjs(sti, errs)
 }
 
 func newObsProxy() oDssProxy {
@@ -363,6 +630,18 @@ func NewObsDss(config ObsConfig, slsttime int64, aclusers []string) (HDss, error
 // config provides the object store specification
 // returns a pointer to the ready to use DSS or an error if any occur
 func CreateObsDss(config ObsConfig) (HDss, error) {
+	if config.Chunked && config.Encrypted {
+		return nil, fmt.Errorf("in CreateObsDss: chunking is not available for encrypted repositories")
+	}
+	if config.Convergent && !config.Encrypted {
+		return nil, fmt.Errorf("in CreateObsDss: convergent encryption is only available for encrypted repositories")
+	}
+	if err := CheckCompression(config.Compression); err != nil {
+		return nil, fmt.Errorf("in CreateObsDss: %w", err)
+	}
+	if err := CheckMultipartPartSize(config.MultipartPartSize); err != nil {
+		return nil, fmt.Errorf("in CreateObsDss: %w", err)
+	}
 	if config.LocalPath != "" {
 		config.RepoId = uuid.New().String()
 		if err := SaveDssConfig(config.DssBaseConfig, config); err != nil {
@@ -388,5 +667,41 @@ func CleanObsDss(config ObsConfig) error {
 	}
 	defer ods.proxy.close()
 	odoi := ods.proxy.(*oDssObjImpl)
+	if _, err := odoi.abortMultiparts(0); err != nil {
+		return fmt.Errorf("in CleanObsDss: %w", err)
+	}
 	return odoi.is3.DeleteAll("")
 }
+
+func (odoi *oDssObjImpl) abortMultiparts(olderThan time.Duration) ([]S3Multipart, error) {
+	mps, err := odoi.is3.ListMultiparts("")
+	if err != nil {
+		return nil, err
+	}
+	var aborted []S3Multipart
+	for _, mp := range mps {
+		if time.Since(mp.Initiated) < olderThan {
+			continue
+		}
+		if err = odoi.is3.AbortMultipart(mp.Key, mp.UploadId); err != nil {
+			return aborted, err
+		}
+		aborted = append(aborted, mp)
+	}
+	return aborted, nil
+}
+
+// AbortObsMultiparts aborts the pending multipart uploads of an OBS DSS initiated for more than olderThan
+// returns the aborted uploads
+func AbortObsMultiparts(config ObsConfig, olderThan time.Duration) ([]S3Multipart, error) {
+	ods := &ODss{proxy: newObsProxy()}
+	if err := ods.proxy.initialize(ods.proxy, config, 0, nil); err != nil {
+		return nil, fmt.Errorf("in AbortObsMultiparts: %w", err)
+	}
+	defer ods.proxy.close()
+	aborted, err := ods.proxy.(*oDssObjImpl).abortMultiparts(olderThan)
+	if err != nil {
+		return aborted, fmt.Errorf("in AbortObsMultiparts: %w", err)
+	}
+	return aborted, nil
+}
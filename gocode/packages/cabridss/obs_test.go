@@ -1,9 +1,11 @@
 package cabridss
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"io"
 	"os"
 	"testing"
 	"time"
@@ -50,6 +52,131 @@ func TestNewObsDssMockFsBase(t *testing.T) {
 	}
 }
 
+func TestObsMultipartMockFs(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestObsMultipartMockFs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	failPart, failures, uploads := 0, 0, map[int]int{}
+	config := ObsConfig{
+		GetS3Session: func() IS3Session {
+			return NewS3sMockFs(tfs.Path(), func(parent IS3Session) IS3Session {
+				return NewS3sMockTests(parent, func(args ...any) interface{} {
+					if args[1] == "UploadPart" {
+						number := args[3].(int)
+						uploads[number]++
+						if number == failPart && failures > 0 {
+							failures--
+							return fmt.Errorf("part %d upload failure", number)
+						}
+					}
+					return nil
+				})
+			})
+		},
+		MultipartThreshold: 1024 * 1024,
+		MultipartPartSize:  S3_MIN_PART_SIZE,
+	}
+	dss, err := NewObsDss(config, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	is3 := dss.(*ODss).proxy.(*oDssObjImpl).is3
+	if err = dss.Mkns("", 0, []string{"a.bin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	bs := randBytes(4, 2*S3_MIN_PART_SIZE+1000)
+
+	failPart, failures = 2, OBS_PART_RETRIES
+	if err = writeTestContent(dss, "a.bin", bs); err == nil {
+		t.Fatalf("TestObsMultipartMockFs upload should fail")
+	}
+	if mps, err := is3.ListMultiparts("content-"); err != nil || len(mps) != 1 {
+		t.Fatalf("TestObsMultipartMockFs pending uploads %v %v", mps, err)
+	}
+
+	failPart, failures = 3, 1
+	if err = writeTestContent(dss, "a.bin", bs); err != nil {
+		t.Fatal(err)
+	}
+	if uploads[1] != 1 || uploads[2] != OBS_PART_RETRIES || uploads[3] != 2 {
+		t.Fatalf("TestObsMultipartMockFs part uploads %v", uploads)
+	}
+	if mps, err := is3.ListMultiparts("content-"); err != nil || len(mps) != 0 {
+		t.Fatalf("TestObsMultipartMockFs pending uploads after completion %v %v", mps, err)
+	}
+	rc, err := dss.GetContentReader("a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbs, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || !bytes.Equal(rbs, bs) {
+		t.Fatalf("TestObsMultipartMockFs read back failed %v", err)
+	}
+
+	if _, err = is3.CreateMultipart("content-stale"); err != nil {
+		t.Fatal(err)
+	}
+	if aborted, err := AbortObsMultiparts(config, time.Hour); err != nil || len(aborted) != 0 {
+		t.Fatalf("TestObsMultipartMockFs recent upload should not be aborted %v %v", aborted, err)
+	}
+	if aborted, err := AbortObsMultiparts(config, 0); err != nil || len(aborted) != 1 || aborted[0].Key != "content-stale" {
+		t.Fatalf("TestObsMultipartMockFs stale upload should be aborted %v %v", aborted, err)
+	}
+}
+
+func TestObsMultipartConfig(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestObsMultipartConfig", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	config := ObsConfig{
+		GetS3Session: func() IS3Session {
+			return NewS3sMockFs(tfs.Path(), nil)
+		},
+		MultipartThreshold: 1024 * 1024,
+		MultipartPartSize:  S3_MIN_PART_SIZE - 1,
+	}
+	config.LocalPath = tfs.Path()
+	config.DssBaseConfig.GetIndex = GetPIndex
+	if _, err = CreateObsDss(config); err == nil {
+		t.Fatal("TestObsMultipartConfig part size below S3_MIN_PART_SIZE should fail")
+	}
+	config.MultipartPartSize = 2 * S3_MIN_PART_SIZE
+	dss, err := CreateObsDss(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dss.Close()
+
+	config.MultipartThreshold, config.MultipartPartSize = 0, 0
+	dss, err = NewObsDss(config, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	odoi := dss.(*ODss).proxy.(*oDssObjImpl)
+	if odoi.mpThreshold != 1024*1024 || odoi.mpPartSize != 2*S3_MIN_PART_SIZE {
+		t.Fatalf("TestObsMultipartConfig persisted threshold %d part size %d", odoi.mpThreshold, odoi.mpPartSize)
+	}
+	dss.Close()
+
+	config.MultipartPartSize = 1024
+	if _, err = NewObsDss(config, 0, nil); err == nil {
+		t.Fatal("TestObsMultipartConfig part size below S3_MIN_PART_SIZE should fail")
+	}
+	config.MultipartPartSize = 0
+	if dss, err = NewObsDss(config, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	dss.Close()
+}
+
 func TestNewObsDssMockFsUnlock(t *testing.T) {
 	optionalSkip(t)
 	tfs, err := testfs.CreateFs("TestNewObsDssMockFsUnlock", tfsStartup)
@@ -1,7 +1,9 @@
 package cabridss
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/spf13/afero"
@@ -10,6 +12,7 @@ import (
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
 	"io"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -21,11 +24,23 @@ type oDssBaseProxy interface {
 	isDuplicate(ch string) (bool, error)
 	getContentWriter(npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (io.WriteCloser, error)
 	getContentReader(npath string) (io.ReadCloser, error)
+	getContentRangeReader(npath string, offset, length int64) (io.ReadCloser, error)
+	getContentSignatures(npath string, blockSize int) (*ContentSignatures, error)
+	getContentDeltaWriter(npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (io.WriteCloser, error)
+	copyContent(spath, npath, ch string, mtime int64, acl []ACLEntry) error
 	symlink(npath, tpath string, mtime int64, acl []ACLEntry) error
+	rename(src, dst string, mtime int64) error
 	remove(npath string) error
 	getMeta(npath string, getCh bool) (IMeta, error)
 	getHistory(npath string, recursive bool, resolution string) (map[string][]HistoryInfo, error)
-	removeHistory(npath string, recursive, evaluate bool, start, end int64) (map[string][]HistoryInfo, error)
+	removeHistory(npath string, recursive, evaluate, force bool, start, end int64) (map[string][]HistoryInfo, error)
+	applyRetention(npath string, recursive, evaluate, force bool, rp RetentionPolicy, now int64) (map[string][]HistoryInfo, error)
+	getChanges(npath string, clId string, reset bool) ([]string, bool, error)
+	createTag(name string, slsttime int64) error
+	listTags() ([]Tag, error)
+	deleteTag(name string) error
+	resolveTag(name string) (int64, error)
+	rekey(npath string, recursive bool, opts RekeyOptions) (map[string][]HistoryInfo, error)
 	setCurrentTime(time int64)
 	setMetaMockCbs(cbs *MetaMockCbs)
 	close() error
@@ -39,22 +54,31 @@ type oDssBaseProxy interface {
 	setSu()
 	setReducer(plumber.Reducer)
 	getReducer() plumber.Reducer
+	getLastTime() int64
 
 	// other
 	doUpdatens(npath string, mtime int64, children []string, acl []ACLEntry) error
 	doSymlink(npath, tpath string, mtime int64, acl []ACLEntry) error
+	doCopyContent(smeta Meta, npath string, mtime int64, acl []ACLEntry) error
 	setIndex(config DssBaseConfig, localPath string) error // to be called by oDssSpecificProxy.initialize
 	isRepoEncrypted() bool
+	isRepoCompressed() bool
+	getRepoCompression() string
+	isRepoConvergent() bool
 	defaultAcl(acl []ACLEntry) []ACLEntry
 	doGetMetaTimesFor(npath string) ([]int64, error)
 	decodeMeta(mbs []byte) (Meta, error)
 	doGetMetaAt(npath string, time int64) (Meta, error)
+	storeNsPage(children []string, acl []ACLEntry, prev map[string]NsPage) (NsPage, error)
+	loadNsPage(page NsPage, acl []ACLEntry) ([]string, error)
 	storeAndIndexMeta(npath string, time int64, bs []byte) error
 	spUpdateClient(cix Index, data UpdatedData, isFull bool) error
 	spScanPhysicalStorageClient(checksum bool, sts *mSPS, sti StorageInfo, errs *ErrorCollector)
 	spAuditIndexFromRemote(sti StorageInfo, mai map[string][]AuditIndexInfo) error
 	spLoadRemoteIndex(mai map[string][]AuditIndexInfo) (map[string]map[int64][]byte, error)
 	spReindex() (StorageInfo, *ErrorCollector)
+	spGetContentSignatures(ch string, blockSize int) (*ContentSignatures, error)
+	spGetContentDeltaWriter(basisCh string, cwcbs contentWriterCbs, acl []ACLEntry) (io.WriteCloser, error)
 }
 
 type contentWriterCbs struct {
@@ -73,9 +97,21 @@ type oDssSpecificProxy interface {
 	spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEntry) (io.WriteCloser, error)
 	spGetContentReader(ch string) (io.ReadCloser, error)
 	doGetContentReader(npath string, meta Meta) (io.ReadCloser, error)
+	spGetContentRangeReader(ch string, offset, length int64) (io.ReadCloser, error) // length negative up to the end
+	doGetContentRangeReader(npath string, meta Meta, offset, length int64) (io.ReadCloser, error)
 	queryContent(ch string) (exist bool, err error)
 	removeContent(ch string) error
+	pushChunk(ch string, bs []byte) error
+	contentSize(ch string) (int64, error) // size of the content blob as stored
+	loadChunkManifest(ch string) ([]string, error)
+	storeChunkManifest(ch string, chunks []string) error
+	removeChunkManifest(ch string) error
+	loadTags() ([]byte, error) // nil if no tag was ever stored
+	storeTags(bs []byte) error
+	loadConvergenceKey() ([]byte, error) // nil if no convergence key was ever stored
+	storeConvergenceKey(bs []byte) error // fails if a convergence key is already stored
 	spClose() error
+	atTime(lsttime int64) oDssProxy // a copy of the implementation sharing its resources with entries as of lsttime
 	dumpIndex() string
 	scanPhysicalStorage(checksum bool, sti StorageInfo, errs *ErrorCollector)
 
@@ -178,6 +214,74 @@ func (ods *ODss) GetContentReader(npath string) (rc io.ReadCloser, err error) {
 	return
 }
 
+func (ods *ODss) GetContentRangeReader(npath string, offset, length int64) (rc io.ReadCloser, err error) {
+	if ods.proxy.getReducer() == nil {
+		rc, err = ods.proxy.getContentRangeReader(npath, offset, length)
+		return
+	}
+	if err = ods.proxy.getReducer().Launch(
+		fmt.Sprintf("GetContentRangeReader %s", npath),
+		func() error {
+			var iErr error
+			if rc, iErr = ods.proxy.getContentRangeReader(npath, offset, length); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+func (ods *ODss) GetContentSignatures(npath string, blockSize int) (sigs *ContentSignatures, err error) {
+	if ods.proxy.getReducer() == nil {
+		sigs, err = ods.proxy.getContentSignatures(npath, blockSize)
+		return
+	}
+	if err = ods.proxy.getReducer().Launch(
+		fmt.Sprintf("GetContentSignatures %s", npath),
+		func() error {
+			var iErr error
+			if sigs, iErr = ods.proxy.getContentSignatures(npath, blockSize); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+func (ods *ODss) GetContentDeltaWriter(npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (wc io.WriteCloser, err error) {
+	if ods.proxy.getReducer() == nil {
+		wc, err = ods.proxy.getContentDeltaWriter(npath, mtime, acl, cb)
+		return
+	}
+	if err = ods.proxy.getReducer().Launch(
+		fmt.Sprintf("GetContentDeltaWriter %s", npath),
+		func() error {
+			var iErr error
+			if wc, iErr = ods.proxy.getContentDeltaWriter(npath, mtime, acl, cb); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+func (ods *ODss) CopyContent(spath, npath, ch string, mtime int64, acl []ACLEntry) (err error) {
+	if ods.proxy.getReducer() == nil {
+		return ods.proxy.copyContent(spath, npath, ch, mtime, acl)
+	}
+	return ods.proxy.getReducer().Launch(
+		fmt.Sprintf("CopyContent %s", npath),
+		func() error {
+			return ods.proxy.copyContent(spath, npath, ch, mtime, acl)
+		})
+}
+
 func (ods *ODss) Symlink(npath string, tpath string, mtime int64, acl []ACLEntry) (err error) {
 	if ods.proxy.getReducer() == nil {
 		return ods.proxy.symlink(npath, tpath, mtime, acl)
@@ -189,6 +293,17 @@ func (ods *ODss) Symlink(npath string, tpath string, mtime int64, acl []ACLEntry
 		})
 }
 
+func (ods *ODss) Rename(src, dst string, mtime int64) (err error) {
+	if ods.proxy.getReducer() == nil {
+		return ods.proxy.rename(src, dst, mtime)
+	}
+	return ods.proxy.getReducer().Launch(
+		fmt.Sprintf("Rename %s", src),
+		func() error {
+			return ods.proxy.rename(src, dst, mtime)
+		})
+}
+
 func (ods *ODss) Remove(npath string) (err error) {
 	if ods.proxy.getReducer() == nil {
 		return ods.proxy.remove(npath)
@@ -223,8 +338,29 @@ func (ods *ODss) GetHistory(npath string, recursive bool, resolution string) (ma
 	return ods.proxy.getHistory(npath, recursive, resolution)
 }
 
-func (ods *ODss) RemoveHistory(npath string, recursive, evaluate bool, start, end int64) (map[string][]HistoryInfo, error) {
-	return ods.proxy.removeHistory(npath, recursive, evaluate, start*1e9, end*1e9)
+func (ods *ODss) RemoveHistory(npath string, recursive, evaluate, force bool, start, end int64) (map[string][]HistoryInfo, error) {
+	return ods.proxy.removeHistory(npath, recursive, evaluate, force, start*1e9, end*1e9)
+}
+
+func (ods *ODss) ApplyRetention(npath string, recursive, evaluate, force bool, rp RetentionPolicy, now int64) (map[string][]HistoryInfo, error) {
+	if now == 0 {
+		return ods.proxy.applyRetention(npath, recursive, evaluate, force, rp, time.Now().UnixNano())
+	}
+	return ods.proxy.applyRetention(npath, recursive, evaluate, force, rp, now*1e9)
+}
+
+func (ods *ODss) CreateTag(name string, slsttime int64) error {
+	return ods.proxy.createTag(name, slsttime)
+}
+
+func (ods *ODss) ListTags() ([]Tag, error) { return ods.proxy.listTags() }
+
+func (ods *ODss) DeleteTag(name string) error { return ods.proxy.deleteTag(name) }
+
+func (ods *ODss) ResolveTag(name string) (int64, error) { return ods.proxy.resolveTag(name) }
+
+func (ods *ODss) Rekey(npath string, recursive bool, opts RekeyOptions) (map[string][]HistoryInfo, error) {
+	return ods.proxy.rekey(npath, recursive, opts)
 }
 
 func (ods *ODss) SetCurrentTime(time int64) {
@@ -257,6 +393,10 @@ func (ods *ODss) Close() error {
 	return ods.proxy.close()
 }
 
+func (ods *ODss) GetChanges(npath string, clId string, reset bool) ([]string, bool, error) {
+	return ods.proxy.getChanges(npath, clId, reset)
+}
+
 func (ods *ODss) GetIndex() Index { return ods.proxy.getIndex() }
 
 func (ods *ODss) DumpIndex() string { return ods.proxy.dumpIndex() }
@@ -267,6 +407,12 @@ func (ods *ODss) IsEncrypted() bool { return ods.proxy.isEncrypted() }
 
 func (ods *ODss) IsRepoEncrypted() bool { return ods.proxy.isRepoEncrypted() }
 
+func (ods *ODss) IsRepoCompressed() bool { return ods.proxy.isRepoCompressed() }
+
+func (ods *ODss) GetRepoCompression() string { return ods.proxy.getRepoCompression() }
+
+func (ods *ODss) IsRepoConvergent() bool { return ods.proxy.isRepoConvergent() }
+
 func (ods *ODss) AuditIndex() (map[string][]AuditIndexInfo, error) { return ods.proxy.auditIndex() }
 
 func (ods *ODss) ScanStorage(checksum, purge, purgeHidden bool) (StorageInfo, *ErrorCollector) {
@@ -279,21 +425,41 @@ func (ods *ODss) GetHistoryChunks(resolution string) ([]HistoryChunk, error) {
 
 func (ods *ODss) Reindex() (StorageInfo, *ErrorCollector) { return ods.proxy.reindex() }
 
+func (ods *ODss) AtTime(slsttime int64) (HDss, error) {
+	if slsttime <= 0 {
+		return nil, fmt.Errorf("in AtTime: invalid time %d", slsttime)
+	}
+	lsttime := slsttime * 1e9
+	if ods.proxy.getLastTime() != 0 && ods.proxy.getLastTime() < lsttime {
+		lsttime = ods.proxy.getLastTime()
+	}
+	// the view shares the index and the client sessions of ods, it must not release them
+	return &ODss{proxy: ods.proxy.atTime(lsttime), closed: true}, nil
+}
+
 func (ods *ODss) SetSu() { ods.proxy.setSu() }
 
 func (ods *ODss) SuEnableWrite(string) error { return nil }
 
 type oDssBaseImpl struct {
-	me            oDssProxy
-	lsttime       int64           // if not zero is the upper time of entries retrieved in it
-	aclusers      []string        // if not nil List of ACL users to check access
-	isSu          bool            // superuser access to enable synchro
-	mockct        int64           // if not zero mock current time
-	metamockcbs   *MetaMockCbs    // if not nil callbacks for json marshal/unmarshal
-	index         Index           // the DSS index, possibly nIndex which is a noop index
-	repoId        string          // the DSS repoId or ""
-	repoEncrypted bool            // repository is encrypted
-	reducer       plumber.Reducer // a reducer
+	me              oDssProxy
+	lsttime         int64              // if not zero is the upper time of entries retrieved in it
+	aclusers        []string           // if not nil List of ACL users to check access
+	isSu            bool               // superuser access to enable synchro
+	mockct          int64              // if not zero mock current time
+	metamockcbs     *MetaMockCbs       // if not nil callbacks for json marshal/unmarshal
+	index           Index              // the DSS index, possibly nIndex which is a noop index
+	repoId          string             // the DSS repoId or ""
+	repoEncrypted   bool               // repository is encrypted
+	repoChunked     bool               // repository content is chunked
+	repoCompressed  bool               // repository content is compressed
+	repoCompression string             // compression algorithm of the repository content
+	repoConvergent  bool               // repository content is encrypted with convergent encryption
+	reducer         plumber.Reducer    // a reducer
+	signKey         ed25519.PrivateKey // if not nil key signing the stored meta data
+	signPKey        string             // public key of signKey
+	trust           map[string]bool    // if not nil trusted meta data signers
+	trustReject     bool               // meta data not trusted cannot be read
 }
 
 func (odbi *oDssBaseImpl) metaTimesFor(npath string, allTimes bool) ([]int64, error) {
@@ -493,7 +659,11 @@ func (odbi *oDssBaseImpl) getContentWriter(npath string, mtime int64, acl []ACLE
 	if err == nil && !odbi.hasWriteAcl(meta) {
 		return nil, fmt.Errorf("in GetContentWriter: %s read-only", npath)
 	}
-	return odbi.me.spGetContentWriter(contentWriterCbs{
+	return odbi.me.spGetContentWriter(odbi.newContentWriterCbs(npath, mtime, acl, closeCb), acl)
+}
+
+func (odbi *oDssBaseImpl) newContentWriterCbs(npath string, mtime int64, acl []ACLEntry, closeCb WriteCloserCb) contentWriterCbs {
+	return contentWriterCbs{
 		closeCb: closeCb,
 		getMetaBytes: func(iErr error, size int64, ch string) (mbs []byte, emid string, oErr error) {
 			if iErr == nil {
@@ -510,7 +680,7 @@ func (odbi *oDssBaseImpl) getContentWriter(npath string, mtime int64, acl []ACLE
 			}
 			return nil, "", fmt.Errorf("in getMetaBytes: %w", iErr)
 		},
-	}, acl)
+	}
 }
 
 func (odbi *oDssBaseImpl) getContentReader(npath string) (io.ReadCloser, error) {
@@ -534,6 +704,126 @@ func (odbi *oDssBaseImpl) getContentReader(npath string) (io.ReadCloser, error)
 	}
 	return odbi.me.doGetContentReader(npath, meta)
 }
+
+func (odbi *oDssBaseImpl) getContentRangeReader(npath string, offset, length int64) (io.ReadCloser, error) {
+	err := checkNpath(npath)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := odbi.hasParent(npath, false)
+	if err != nil {
+		return nil, fmt.Errorf("in GetContentRangeReader: %v", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("no such entry: %s", npath)
+	}
+	meta, err := odbi.doGetMeta(npath)
+	if err != nil {
+		return nil, fmt.Errorf("in GetContentRangeReader: %v", err)
+	}
+	if !odbi.hasReadAcl(meta) {
+		return nil, fmt.Errorf("in GetContentRangeReader: %s access denied", npath)
+	}
+	if length, err = checkRange(meta.Size, offset, length); err != nil {
+		return nil, fmt.Errorf("in GetContentRangeReader: %w", err)
+	}
+	if length == 0 {
+		return emptyReadCloser(), nil
+	}
+	return odbi.me.doGetContentRangeReader(npath, meta, offset, length)
+}
+
+// getDeltaBasisMeta returns the meta data of the existing content npath against which a delta is computed
+func (odbi *oDssBaseImpl) getDeltaBasisMeta(npath string) (Meta, error) {
+	if odbi.me.isEncrypted() || odbi.isRepoEncrypted() {
+		return Meta{}, ErrDeltaNotSupported
+	}
+	ok, err := odbi.hasParent(npath, false)
+	if err != nil {
+		return Meta{}, err
+	}
+	if !ok {
+		return Meta{}, fmt.Errorf("no such entry: %s", npath)
+	}
+	meta, err := odbi.doGetMeta(npath)
+	if err != nil {
+		return Meta{}, err
+	}
+	if meta.IsNs || meta.IsSymLink {
+		return Meta{}, fmt.Errorf("%s is not a regular content", npath)
+	}
+	if !odbi.hasReadAcl(meta) {
+		return Meta{}, fmt.Errorf("%s access denied", npath)
+	}
+	return meta, nil
+}
+
+func (odbi *oDssBaseImpl) getContentSignatures(npath string, blockSize int) (*ContentSignatures, error) {
+	if err := checkNpath(npath); err != nil {
+		return nil, err
+	}
+	meta, err := odbi.getDeltaBasisMeta(npath)
+	if err != nil {
+		return nil, fmt.Errorf("in GetContentSignatures: %w", err)
+	}
+	return odbi.me.spGetContentSignatures(meta.Ch, blockSize)
+}
+
+func (odbi *oDssBaseImpl) getContentDeltaWriter(npath string, mtime int64, acl []ACLEntry, closeCb WriteCloserCb) (io.WriteCloser, error) {
+	if odbi.lsttime != 0 {
+		return nil, fmt.Errorf("read-only DSS")
+	}
+	if err := checkMkcontentArgs(npath, acl); err != nil {
+		return nil, err
+	}
+	meta, err := odbi.getDeltaBasisMeta(npath)
+	if err != nil {
+		return nil, fmt.Errorf("in GetContentDeltaWriter: %w", err)
+	}
+	if !odbi.hasWriteAcl(meta) {
+		return nil, fmt.Errorf("in GetContentDeltaWriter: %s read-only", npath)
+	}
+	return odbi.me.spGetContentDeltaWriter(meta.Ch, odbi.newContentWriterCbs(npath, mtime, acl, closeCb), acl)
+}
+
+func (odbi *oDssBaseImpl) copyContent(spath, npath, ch string, mtime int64, acl []ACLEntry) error {
+	if odbi.lsttime != 0 {
+		return fmt.Errorf("read-only DSS")
+	}
+	if err := checkNpath(spath); err != nil {
+		return err
+	}
+	if err := checkMkcontentArgs(npath, acl); err != nil {
+		return err
+	}
+	for _, p := range []string{spath, npath} {
+		ok, err := odbi.hasParent(p, false)
+		if err != nil {
+			return fmt.Errorf("in CopyContent: %v", err)
+		}
+		if !ok {
+			return fmt.Errorf("no such entry: %s", p)
+		}
+	}
+	smeta, err := odbi.doGetMeta(spath)
+	if err != nil {
+		return fmt.Errorf("in CopyContent: %w", err)
+	}
+	if smeta.IsNs || smeta.IsSymLink {
+		return fmt.Errorf("in CopyContent: %s is not a regular content", spath)
+	}
+	if !odbi.hasReadAcl(smeta) {
+		return fmt.Errorf("in CopyContent: %s access denied", spath)
+	}
+	if smeta.Ch != ch {
+		return fmt.Errorf("in CopyContent: %s content has changed", spath)
+	}
+	meta, err := odbi.doGetMeta(npath)
+	if err == nil && !odbi.hasWriteAcl(meta) {
+		return fmt.Errorf("in CopyContent: %s read-only", npath)
+	}
+	return odbi.me.doCopyContent(smeta, npath, mtime, acl)
+}
 func (odbi *oDssBaseImpl) symlink(npath, tpath string, mtime int64, acl []ACLEntry) error {
 	if odbi.lsttime != 0 {
 		return fmt.Errorf("read-only DSS")
@@ -599,6 +889,101 @@ func (odbi *oDssBaseImpl) remove(npath string) error {
 	return odbi.me.doUpdatens(parent, time.Now().Unix(), uchildren, meta.ACL)
 }
 
+func (odbi *oDssBaseImpl) rename(src, dst string, mtime int64) error {
+	if odbi.lsttime != 0 {
+		return fmt.Errorf("read-only DSS")
+	}
+	isNS, sipath, dipath, err := checkRenameArgs(src, dst)
+	if err != nil {
+		return err
+	}
+	ok, err := odbi.hasParent(sipath, isNS)
+	if err != nil {
+		return fmt.Errorf("in Rename: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("no such entry: %s", src)
+	}
+	metas, err := odbi.doGetMeta(sipath)
+	if err != nil {
+		return fmt.Errorf("in Rename: %w", err)
+	}
+	if !odbi.hasWriteAcl(metas) {
+		return fmt.Errorf("in Rename: %s read-only", src)
+	}
+	sparent, dparent := ufpath.Dir(sipath), ufpath.Dir(dipath)
+	if sparent == "." {
+		sparent = ""
+	}
+	if dparent == "." {
+		dparent = ""
+	}
+	if ok, err = odbi.hasParent(dparent, true); err != nil || !ok {
+		return fmt.Errorf("no such entry: %s", dparent+"/")
+	}
+	spmeta, err := odbi.doGetMeta(sparent)
+	if err != nil {
+		return fmt.Errorf("in Rename: %w", err)
+	}
+	dpmeta, err := odbi.doGetMeta(dparent)
+	if err != nil {
+		return fmt.Errorf("in Rename: %w", err)
+	}
+	if !odbi.hasWriteAcl(spmeta) || !odbi.hasWriteAcl(dpmeta) {
+		return fmt.Errorf("in Rename: %s read-only", src)
+	}
+	sname, dname := ufpath.Base(sipath), ufpath.Base(dipath)
+	if isNS {
+		sname, dname = sname+"/", dname+"/"
+	}
+	for _, child := range dpmeta.Children {
+		if strings.TrimSuffix(child, "/") == strings.TrimSuffix(dname, "/") {
+			return fmt.Errorf("in Rename: %s already exists", dst)
+		}
+	}
+
+	// the entries are stored at their new path before being moved in the namespaces
+	if err = odbi.renameEntry(metas, dipath); err != nil {
+		return fmt.Errorf("in Rename: %w", err)
+	}
+	var schildren []string
+	for _, child := range spmeta.Children {
+		if child != sname {
+			schildren = append(schildren, child)
+		}
+	}
+	if sparent == dparent {
+		return odbi.me.doUpdatens(dparent, mtime, append(schildren, dname), dpmeta.ACL)
+	}
+	if err = odbi.me.doUpdatens(dparent, mtime, append(dpmeta.Children, dname), dpmeta.ACL); err != nil {
+		return err
+	}
+	return odbi.me.doUpdatens(sparent, mtime, schildren, spmeta.ACL)
+}
+
+// renameEntry stores the entry of meta and recursively its children at dipath,
+// contents are not transferred as they are stored by checksum
+func (odbi *oDssBaseImpl) renameEntry(meta Meta, dipath string) error {
+	if meta.IsSymLink {
+		return odbi.me.doSymlink(dipath, meta.SymLinkTarget, meta.Mtime, meta.ACL)
+	}
+	if !meta.IsNs {
+		return odbi.me.doCopyContent(meta, dipath, meta.Mtime, meta.ACL)
+	}
+	sipath := RemoveSlashIf(meta.Path)
+	for _, child := range meta.Children {
+		name := strings.TrimSuffix(child, "/")
+		cmeta, err := odbi.doGetMeta(ufpath.Join(sipath, name))
+		if err != nil {
+			return err
+		}
+		if err = odbi.renameEntry(cmeta, ufpath.Join(dipath, name)); err != nil {
+			return err
+		}
+	}
+	return odbi.me.doUpdatens(dipath, meta.Mtime, meta.Children, meta.ACL)
+}
+
 func (odbi *oDssBaseImpl) getMeta(npath string, getCh bool) (IMeta, error) {
 	isDir, ipath, err := checkNCpath(npath)
 	if err != nil {
@@ -612,6 +997,9 @@ func (odbi *oDssBaseImpl) getMeta(npath string, getCh bool) (IMeta, error) {
 		return nil, fmt.Errorf("no such entry: %s", npath)
 	}
 	meta, err := odbi.doGetMeta(ipath)
+	if errors.Is(err, ErrUntrustedMeta) {
+		return nil, fmt.Errorf("in GetMeta: %w", err)
+	}
 	if err == nil && !odbi.hasReadAcl(meta) {
 		return nil, fmt.Errorf("in GetMeta: %s access denied", npath)
 	}
@@ -814,11 +1202,28 @@ func (odbi *oDssBaseImpl) doRemoveHistory(ripath string, isDir bool, recursive b
 	return nil
 }
 
-func (odbi *oDssBaseImpl) removeHistory(npath string, recursive, evaluate bool, start, end int64) (map[string][]HistoryInfo, error) {
+func (odbi *oDssBaseImpl) removeHistory(npath string, recursive, evaluate, force bool, start, end int64) (map[string][]HistoryInfo, error) {
 	isDir, ipath, err := checkNCpath(npath)
 	if err != nil {
 		return nil, err
 	}
+	if !force {
+		tags, err := odbi.loadAllTags()
+		if err != nil {
+			return nil, fmt.Errorf("in RemoveHistory: %v", err)
+		}
+		eRes := map[string][]historyEntry{}
+		if err = odbi.doRemoveHistory(ipath, isDir, recursive, true, start, end, eRes); err != nil {
+			return nil, fmt.Errorf("in RemoveHistory: %v", err)
+		}
+		for np, hes := range eRes {
+			for _, he := range hes {
+				if name := pinningTag(tags, he.start, he.end); name != "" {
+					return nil, fmt.Errorf("in RemoveHistory: history of %s at %s is pinned by tag %s", np, UnixUTC(he.start), name)
+				}
+			}
+		}
+	}
 	oRes := map[string][]historyEntry{}
 	if err = odbi.doRemoveHistory(ipath, isDir, recursive, evaluate, start, end, oRes); err != nil {
 		return nil, fmt.Errorf("in RemoveHistory: %v", err)
@@ -834,6 +1239,123 @@ func (odbi *oDssBaseImpl) removeHistory(npath string, recursive, evaluate bool,
 	return eRes, err
 }
 
+func (odbi *oDssBaseImpl) applyRetention(npath string, recursive, evaluate, force bool, rp RetentionPolicy, now int64) (map[string][]HistoryInfo, error) {
+	isDir, ipath, err := checkNCpath(npath)
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]Tag{}
+	if !force {
+		if tags, err = odbi.loadAllTags(); err != nil {
+			return nil, fmt.Errorf("in ApplyRetention: %v", err)
+		}
+	}
+	iRes := map[string][]historyEntry{}
+	if err = odbi.doGetHistory(ipath, isDir, recursive, "s", iRes); err != nil {
+		return nil, fmt.Errorf("in ApplyRetention: %v", err)
+	}
+	eRes := map[string][]HistoryInfo{}
+	for np, hes := range iRes {
+		his := make([]HistoryInfo, len(hes))
+		for i, he := range hes {
+			his[i] = HistoryInfo{Start: he.start, End: he.end, HMeta: he.meta}
+		}
+		var drop []HistoryInfo
+		for _, hi := range rp.Drop(his, now) {
+			if pinningTag(tags, hi.Start, hi.End) == "" {
+				drop = append(drop, hi)
+			}
+		}
+		if len(drop) == 0 {
+			continue
+		}
+		// a meta may be seen in several entries and is only removed if none of them is kept
+		dropped := map[int64]bool{}
+		starts := map[int64]bool{}
+		for _, hi := range drop {
+			dropped[hi.HMeta.Itime] = true
+			starts[hi.Start] = true
+		}
+		for _, hi := range his {
+			if !starts[hi.Start] {
+				dropped[hi.HMeta.Itime] = false
+			}
+		}
+		for _, hi := range drop {
+			if !dropped[hi.HMeta.Itime] {
+				continue
+			}
+			eRes[np] = append(eRes[np], hi)
+			if evaluate {
+				continue
+			}
+			delete(dropped, hi.HMeta.Itime)
+			if err = odbi.me.xRemoveMeta(hi.HMeta); err != nil {
+				return nil, fmt.Errorf("in ApplyRetention: %v", err)
+			}
+			ipath, itime := RemoveSlashIfNsIf(np, hi.HMeta.IsNs), hi.HMeta.Itime
+			if odbi.isRepoEncrypted() {
+				ipath = hi.HMeta.EMId
+				itime = MIN_TIME
+			}
+			if err = odbi.me.removeMeta(ipath, itime); err != nil {
+				return nil, fmt.Errorf("in ApplyRetention: %v", err)
+			}
+		}
+	}
+	return eRes, nil
+}
+
+func (odbi *oDssBaseImpl) getChanges(npath string, clId string, reset bool) ([]string, bool, error) {
+	if err := checkNpath(npath); err != nil {
+		return nil, false, err
+	}
+	if !odbi.index.IsPersistent() {
+		return nil, false, fmt.Errorf("in GetChanges: the DSS index is not persistent")
+	}
+	known, err := odbi.index.isClientKnown(clId)
+	if err != nil {
+		return nil, false, fmt.Errorf("in GetChanges: %w", err)
+	}
+	if !known {
+		if _, err = odbi.index.recordClient(clId); err != nil {
+			return nil, false, fmt.Errorf("in GetChanges: %w", err)
+		}
+		return nil, true, nil
+	}
+	udd, err := odbi.index.updateClient(clId, reset)
+	if err != nil {
+		return nil, false, fmt.Errorf("in GetChanges: %w", err)
+	}
+	if reset {
+		return nil, true, nil
+	}
+	changed := []string{}
+	for _, tms := range udd.Changed {
+		for _, tm := range tms {
+			meta, err := odbi.me.decodeMeta([]byte(tm.Bytes))
+			if err != nil {
+				// encrypted metadata are only indexed in clear by the clients
+				continue
+			}
+			path := meta.Path
+			if path == "/" {
+				path = ""
+			}
+			if npath == "" || path == npath+"/" || strings.HasPrefix(path, npath+"/") {
+				changed = append(changed, path)
+			}
+			break
+		}
+	}
+	sort.Strings(changed)
+	return changed, false, nil
+}
+
+func (odbi *oDssBaseImpl) rekey(npath string, recursive bool, opts RekeyOptions) (map[string][]HistoryInfo, error) {
+	return nil, fmt.Errorf("in Rekey: %w", ErrRekeyNotSupported)
+}
+
 func (odbi *oDssBaseImpl) setCurrentTime(time int64) { odbi.mockct = time }
 
 func (odbi *oDssBaseImpl) setMetaMockCbs(cbs *MetaMockCbs) { odbi.metamockcbs = cbs }
@@ -883,6 +1405,9 @@ func (odbi *oDssBaseImpl) doUpdatens(npath string, mtime int64, children []strin
 		Children: children,
 		ACL:      acl,
 	}
+	if err := odbi.pageNsMeta(npath, &meta); err != nil {
+		return fmt.Errorf("in doUpdatens: %w", err)
+	}
 	mbs, itime, err := odbi.getMetaBytes(meta)
 	if err != nil {
 		return fmt.Errorf("in doUpdatens: %w", err)
@@ -907,6 +1432,27 @@ func (odbi *oDssBaseImpl) doSymlink(npath, tpath string, mtime int64, acl []ACLE
 	return odbi.storeAndIndexMeta(meta.Path, itime, mbs)
 }
 
+// doCopyContent stores a new meta referencing the content of smeta, which is already stored by checksum
+func (odbi *oDssBaseImpl) doCopyContent(smeta Meta, npath string, mtime int64, acl []ACLEntry) error {
+	if odbi.isRepoEncrypted() {
+		return fmt.Errorf("in doCopyContent: %w", ErrCopyNotSupported)
+	}
+	meta := Meta{
+		Path:   npath,
+		Mtime:  mtime,
+		Size:   smeta.Size,
+		Ch:     smeta.Ch,
+		ACL:    acl,
+		Chunks: smeta.Chunks,
+		CSize:  smeta.CSize,
+	}
+	mbs, itime, err := odbi.getMetaBytes(meta)
+	if err != nil {
+		return fmt.Errorf("in doCopyContent: %w", err)
+	}
+	return odbi.storeAndIndexMeta(meta.Path, itime, mbs)
+}
+
 func (odbi *oDssBaseImpl) doAuditIndexFromStorage(sti StorageInfo, mai map[string][]AuditIndexInfo) error {
 	appMai := func(k string, aii AuditIndexInfo) {
 		if aii.Error == "" {
@@ -992,6 +1538,9 @@ func (odbi *oDssBaseImpl) doAuditIndexFromIndex(sti StorageInfo, mai map[string]
 				appMai(k, AuditIndexInfo{"Inconsistent", fmt.Errorf("%s (meta %s) ITime %d stored %d d %f", k, RemoveSlashIfNsIf(meta.Path, meta.IsNs), meta.Itime, t, float32(meta.Itime-t)/1e9), t, m})
 				continue
 			}
+			if err := odbi.checkMetaTrust(meta); err != nil {
+				appMai(k, AuditIndexInfo{"Untrusted", err, t, m})
+			}
 			if _, ok := smetas[k]; !ok {
 				appMai(k, AuditIndexInfo{"", err, t, m})
 				continue
@@ -1014,6 +1563,77 @@ func (odbi *oDssBaseImpl) doAuditIndexFromIndex(sti StorageInfo, mai map[string]
 	return nil
 }
 
+func (odbi *oDssBaseImpl) doAuditChunks(sti StorageInfo, mai map[string][]AuditIndexInfo) error {
+	if odbi.repoEncrypted || !odbi.repoChunked {
+		return nil
+	}
+	appMai := func(k string, aii AuditIndexInfo) {
+		mai[k] = append(mai[k], aii)
+	}
+	contents := map[string]bool{}
+	for _, cch := range sti.Path2Content {
+		contents[cch] = true
+	}
+	manifests := map[string]bool{}
+	for _, mch := range sti.Path2Manifest {
+		manifests[mch] = true
+	}
+	for path, bs := range sti.Path2Meta {
+		var meta Meta
+		if err := json.Unmarshal(bs, &meta); err != nil {
+			continue
+		}
+		if meta.IsNs || meta.IsSymLink || contents[meta.Ch] {
+			continue
+		}
+		if !manifests[meta.Ch] {
+			appMai(path, AuditIndexInfo{"ChunkMissing", fmt.Errorf("%s (meta %s) neither content nor chunk manifest for %s", path, meta.Path, meta.Ch), meta.Itime, bs})
+			continue
+		}
+		for _, cch := range odbi.metaChunks(meta) {
+			if !contents[cch] {
+				appMai(path, AuditIndexInfo{"ChunkMissing", fmt.Errorf("%s (meta %s) chunk %s is missing", path, meta.Path, cch), meta.Itime, bs})
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (odbi *oDssBaseImpl) doAuditNsPages(sti StorageInfo, mai map[string][]AuditIndexInfo) error {
+	appMai := func(k string, aii AuditIndexInfo) {
+		mai[k] = append(mai[k], aii)
+	}
+	contents := map[string]bool{}
+	for _, cch := range sti.Path2Content {
+		contents[cch] = true
+	}
+	for path, bs := range sti.Path2Meta {
+		if odbi.repoEncrypted {
+			cbs, ok := sti.Path2CMeta[path]
+			if !ok {
+				continue
+			}
+			bs = cbs
+		}
+		var meta Meta
+		if err := json.Unmarshal(bs, &meta); err != nil || !meta.IsNs {
+			continue
+		}
+		for _, page := range meta.Pages {
+			pch := page.Ch
+			if odbi.repoEncrypted {
+				pch = page.ECh
+			}
+			if !contents[pch] {
+				appMai(path, AuditIndexInfo{"NsPageMissing", fmt.Errorf("%s (meta %s) children page %s is missing", path, meta.Path, pch), meta.Itime, bs})
+				break
+			}
+		}
+	}
+	return nil
+}
+
 func (odbi *oDssBaseImpl) auditIndex() (map[string][]AuditIndexInfo, error) {
 	if !odbi.getIndex().IsPersistent() {
 		return nil, fmt.Errorf("in AuditIndex: not persistent")
@@ -1027,7 +1647,12 @@ func (odbi *oDssBaseImpl) auditIndex() (map[string][]AuditIndexInfo, error) {
 	}
 	sti, errs := odbi.scanStorage(false, false, false)
 	if errs != nil {
-		return nil, fmt.Errorf("in doAuditIndexFromStorage: %v", errs)
+		for _, err := range *errs {
+			// untrusted meta data are reported by doAuditIndexFromIndex
+			if !errors.Is(err, ErrUntrustedMeta) {
+				return nil, fmt.Errorf("in doAuditIndexFromStorage: %v", errs)
+			}
+		}
 	}
 	res := map[string][]AuditIndexInfo{}
 	if err = odbi.doAuditIndexFromStorage(sti, res); err != nil {
@@ -1040,6 +1665,12 @@ func (odbi *oDssBaseImpl) auditIndex() (map[string][]AuditIndexInfo, error) {
 			return nil, fmt.Errorf("in AuditIndex: %v", err)
 		}
 	}
+	if err = odbi.doAuditChunks(sti, res); err != nil {
+		return nil, fmt.Errorf("in AuditIndex: %v", err)
+	}
+	if err = odbi.doAuditNsPages(sti, res); err != nil {
+		return nil, fmt.Errorf("in AuditIndex: %v", err)
+	}
 	if err = odbi.me.spAuditIndexFromRemote(sti, res); err != nil {
 		if err != nil {
 			return nil, fmt.Errorf("in AuditIndex: %v", err)
@@ -1050,15 +1681,9 @@ func (odbi *oDssBaseImpl) auditIndex() (map[string][]AuditIndexInfo, error) {
 
 func (odbi *oDssBaseImpl) purgeContent(sti StorageInfo, errs *ErrorCollector) {
 	if odbi.isRepoEncrypted() {
+		// with convergent encryption, an encrypted content may be shared by several metas
 		for _, ech := range sti.Path2Content {
-			found := false
-			for eEch, _ := range sti.ExistingEcs {
-				if eEch == ech {
-					found = true
-					break
-				}
-			}
-			if !found {
+			if !sti.ExistingEcs[ech] {
 				if err := odbi.me.removeContent(ech); err != nil {
 					errs.Collect(err)
 				}
@@ -1066,6 +1691,13 @@ func (odbi *oDssBaseImpl) purgeContent(sti StorageInfo, errs *ErrorCollector) {
 		}
 		return
 	}
+	for _, mch := range sti.Path2Manifest {
+		if _, ok := sti.ExistingCs[mch]; !ok {
+			if err := odbi.me.removeChunkManifest(mch); err != nil {
+				errs.Collect(err)
+			}
+		}
+	}
 	for _, ch := range sti.Path2Content {
 		found := false
 		for eCh, _ := range sti.ExistingCs {
@@ -1101,6 +1733,10 @@ func (odbi *oDssBaseImpl) scanStorage(checksum, purge, purgeHidden bool) (Storag
 			pathErr(path, err)
 			continue
 		}
+		if err := odbi.checkMetaTrust(meta); err != nil {
+			// reported but still used, its content must not be purged
+			sti.Untrusted[path] = err.Error()
+		}
 		ipath := meta.Path
 		if meta.IsNs {
 			ipath = RemoveSlashIf(ipath)
@@ -1115,9 +1751,15 @@ func (odbi *oDssBaseImpl) scanStorage(checksum, purge, purgeHidden bool) (Storag
 			continue
 		}
 		if meta.IsNs {
+			for _, page := range meta.Pages {
+				sti.ExistingCs[page.Ch] = true
+			}
 			continue
 		}
 		sti.ExistingCs[meta.Ch] = true
+		for _, cch := range odbi.metaChunks(meta) {
+			sti.ExistingCs[cch] = true
+		}
 		cr, err := odbi.me.doGetContentReader(ipath, meta)
 		if err != nil {
 			pathErr(path, err)
@@ -1133,6 +1775,15 @@ func (odbi *oDssBaseImpl) scanStorage(checksum, purge, purgeHidden bool) (Storag
 			continue
 		}
 	}
+	for path, bs := range sti.Path2CMeta {
+		var meta Meta
+		if err := json.Unmarshal(bs, &meta); err != nil {
+			continue
+		}
+		if err := odbi.checkMetaTrust(meta); err != nil {
+			sti.Untrusted[path] = err.Error()
+		}
+	}
 	_, isEdss := (odbi.me).(*eDssImpl)
 	for path, ccs := range sti.Path2Content {
 		if odbi.isRepoEncrypted() && !isEdss {
@@ -1151,6 +1802,11 @@ func (odbi *oDssBaseImpl) scanStorage(checksum, purge, purgeHidden bool) (Storag
 			continue
 		}
 	}
+	for path, mch := range sti.Path2Manifest {
+		if _, ok := sti.ExistingCs[mch]; !ok {
+			pathErr(path, fmt.Errorf("%s (chunk manifest %s) is not used anymore", path, mch))
+		}
+	}
 	if purge {
 		odbi.purgeContent(sti, errs)
 	}
@@ -1224,8 +1880,16 @@ func (odbi *oDssBaseImpl) setReducer(red plumber.Reducer) { odbi.reducer = red }
 
 func (odbi *oDssBaseImpl) getReducer() plumber.Reducer { return odbi.reducer }
 
+func (odbi *oDssBaseImpl) getLastTime() int64 { return odbi.lsttime }
+
 func (odbi *oDssBaseImpl) isRepoEncrypted() bool { return odbi.repoEncrypted }
 
+func (odbi *oDssBaseImpl) isRepoCompressed() bool { return odbi.repoCompressed }
+
+func (odbi *oDssBaseImpl) getRepoCompression() string { return odbi.repoCompression }
+
+func (odbi *oDssBaseImpl) isRepoConvergent() bool { return odbi.repoConvergent }
+
 func (odbi *oDssBaseImpl) defaultAcl(acl []ACLEntry) []ACLEntry { return acl }
 
 func (odbi *oDssBaseImpl) doGetMetaTimesFor(npath string) (times []int64, err error) {
@@ -1267,6 +1931,12 @@ func (odbi *oDssBaseImpl) doGetMetaAt(npath string, time int64) (Meta, error) {
 	if err != nil {
 		return Meta{}, err
 	}
+	if err = odbi.checkReadTrust(meta); err != nil {
+		return Meta{}, err
+	}
+	if err = odbi.unpageNsMeta(&meta); err != nil {
+		return Meta{}, err
+	}
 	if err = odbi.index.storeMeta(npath, time, bs); err != nil {
 		return Meta{}, err
 	}
@@ -1283,13 +1953,7 @@ func (odbi *oDssBaseImpl) getMetaBytes(meta Meta) ([]byte, int64, error) {
 		odbi.mockct += 1
 	}
 	meta.Itime = time
-	var bs []byte
-	var err error
-	if odbi.metamockcbs != nil && odbi.metamockcbs.MockMarshal != nil {
-		bs, err = odbi.metamockcbs.MockMarshal(meta)
-	} else {
-		bs, err = json.Marshal(meta)
-	}
+	bs, err := odbi.encodeMeta(meta)
 	if err != nil {
 		return nil, 0, fmt.Errorf("in getMetaBytes: %w", err)
 	}
@@ -1322,6 +1986,44 @@ func (odbi *oDssBaseImpl) spLoadRemoteIndex(mai map[string][]AuditIndexInfo) (ma
 	return map[string]map[int64][]byte{}, nil
 }
 
+func (odbi *oDssBaseImpl) spGetContentSignatures(ch string, blockSize int) (*ContentSignatures, error) {
+	rc, err := odbi.me.spGetContentReader(ch)
+	if err != nil {
+		return nil, fmt.Errorf("in spGetContentSignatures: %w", err)
+	}
+	defer rc.Close()
+	return ComputeSignatures(rc, blockSize)
+}
+
+func (odbi *oDssBaseImpl) spGetContentDeltaWriter(basisCh string, cwcbs contentWriterCbs, acl []ACLEntry) (io.WriteCloser, error) {
+	rc, err := odbi.me.spGetContentReader(basisCh)
+	if err != nil {
+		return nil, fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+	}
+	basis, closeBasis, err := newDeltaBasis(odbi.me.getAfs(), rc)
+	if err != nil {
+		return nil, fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+	}
+	var applyErr error
+	out, err := odbi.me.spGetContentWriter(contentWriterCbs{
+		closeCb: cwcbs.closeCb,
+		getMetaBytes: func(iErr error, size int64, ch string) ([]byte, string, error) {
+			if applyErr != nil {
+				return nil, "", applyErr
+			}
+			return cwcbs.getMetaBytes(iErr, size, ch)
+		},
+	}, acl)
+	if err != nil {
+		closeBasis()
+		return nil, fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+	}
+	return newDeltaWriteCloser(basis, out, func(err error) {
+		applyErr = err
+		closeBasis()
+	}), nil
+}
+
 func (odbi *oDssBaseImpl) spReindex() (StorageInfo, *ErrorCollector) {
 	sti := getInitStorageInfo()
 	errs := &ErrorCollector{}
@@ -489,7 +489,7 @@ func runTestHistory(t *testing.T, createDssCb func(*testfs.Fs) error, newDssCb f
 		if endH != 0 && endH < 1000 {
 			end = ttr + endH*3600
 		}
-		mHes, err = dss.RemoveHistory(npath, recursive, evaluate, start, end)
+		mHes, err = dss.RemoveHistory(npath, recursive, evaluate, false, start, end)
 		if err != nil {
 			return err
 		}
@@ -632,7 +632,7 @@ func runTestMultiHistory(t *testing.T, createDssCb func(*testfs.Fs) error, newDs
 		if endH != 0 && endH < 1000 {
 			end = ttr + endH*3600
 		}
-		mHes, err = dss.RemoveHistory(npath, recursive, evaluate, start, end)
+		mHes, err = dss.RemoveHistory(npath, recursive, evaluate, false, start, end)
 		if err != nil {
 			return err
 		}
@@ -42,6 +42,17 @@ func (odoi *oDssOlfImpl) initialize(me oDssProxy, config interface{}, lsttime in
 	}
 	odoi.repoId = pc.RepoId
 	odoi.repoEncrypted = pc.Encrypted
+	odoi.repoChunked = pc.Chunked
+	odoi.repoCompressed = pc.Compressed
+	odoi.repoCompression = pc.Compression
+	odoi.repoConvergent = pc.Convergent
+	uc, err := CurrentUserConfig(olfConfig.DssBaseConfig)
+	if err != nil {
+		return fmt.Errorf("in Initialize: %w", err)
+	}
+	if err = odoi.setSigning(uc); err != nil {
+		return fmt.Errorf("in Initialize: %w", err)
+	}
 	odoi.root = olfConfig.Root
 	odoi.size = pc.Size
 	olfConfig.XImpl = pc.XImpl
@@ -161,12 +172,45 @@ func (odoi *oDssOlfImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEnt
 		if err != nil {
 			return fmt.Errorf("in spGetContentWriter %w", err)
 		}
+		// the temporary file must not remain once its content is pushed or split into chunks
+		defer odoi.getAfs().Remove(wcwc.Underlying.(afero.File).Name())
+		var (
+			chunks []string
+			ccsize int64
+		)
+		if odoi.repoChunked && !odoi.isRepoEncrypted() {
+			if chunks, ccsize, err = odoi.storeChunks(ch, wcwc.Underlying.(afero.File)); err != nil {
+				return fmt.Errorf("in spGetContentWriter %w", err)
+			}
+		}
 		mbs, emid, err := cwcbs.getMetaBytes(err, size, ch)
 		if err != nil {
 			return fmt.Errorf("in spGetContentWriter %w", err)
 		}
-		if err = odoi.pushContent(size, ch, mbs, emid, wcwc.Underlying.(afero.File)); err != nil {
-			return fmt.Errorf("in spGetContentWriter %w", err)
+		if chunks == nil {
+			cf := wcwc.Underlying.(afero.File)
+			if odoi.isContentCompressed() {
+				var csize int64
+				if cf, csize, err = compressFile(odoi.getAfs(), ufpath.Join(odoi.root, "tmp"), cf, odoi.repoCompression); err != nil {
+					return fmt.Errorf("in spGetContentWriter %w", err)
+				}
+				defer odoi.getAfs().Remove(cf.Name())
+				if mbs, err = odoi.setMetaCSize(mbs, csize); err != nil {
+					return fmt.Errorf("in spGetContentWriter %w", err)
+				}
+			}
+			if err = odoi.pushContent(size, ch, mbs, emid, cf); err != nil {
+				return fmt.Errorf("in spGetContentWriter %w", err)
+			}
+		} else {
+			if mbs, err = odoi.setMetaChunks(mbs, chunks); err != nil {
+				return fmt.Errorf("in spGetContentWriter %w", err)
+			}
+			if odoi.isContentCompressed() {
+				if mbs, err = odoi.setMetaCSize(mbs, ccsize); err != nil {
+					return fmt.Errorf("in spGetContentWriter %w", err)
+				}
+			}
 		}
 		var (
 			itime int64
@@ -194,18 +238,113 @@ func (odoi *oDssOlfImpl) spGetContentReader(ch string) (io.ReadCloser, error) {
 	cpath := ufpath.Join(odoi.root, "content", internal.Str32ToPath(ch, odoi.size))
 	cf, err := odoi.getAfs().Open(cpath)
 	if err != nil {
+		if odoi.repoChunked {
+			if chunks, cerr := odoi.loadChunkManifest(ch); cerr == nil {
+				return odoi.newChunksReader(chunks), nil
+			}
+		}
 		return nil, fmt.Errorf("in GetContentReader: %w", err)
 	}
+	if odoi.isContentCompressed() {
+		return newDecompressReader(cf)
+	}
 	return cf, nil
 }
 
 func (odoi *oDssOlfImpl) doGetContentReader(npath string, meta Meta) (io.ReadCloser, error) {
+	if len(meta.Chunks) > 0 {
+		return odoi.newChunksReader(meta.Chunks), nil
+	}
 	return odoi.spGetContentReader(meta.Ch)
 }
 
+func (odoi *oDssOlfImpl) spGetContentRangeReader(ch string, offset, length int64) (io.ReadCloser, error) {
+	cpath := ufpath.Join(odoi.root, "content", internal.Str32ToPath(ch, odoi.size))
+	cf, err := odoi.getAfs().Open(cpath)
+	if err != nil || odoi.isContentCompressed() {
+		if cf != nil {
+			cf.Close()
+		}
+		rc, err := odoi.spGetContentReader(ch)
+		if err != nil {
+			return nil, fmt.Errorf("in GetContentRangeReader: %w", err)
+		}
+		return newSkipReader(rc, offset, length)
+	}
+	return newSeekReader(cf, offset, length)
+}
+
+func (odoi *oDssOlfImpl) doGetContentRangeReader(npath string, meta Meta, offset, length int64) (io.ReadCloser, error) {
+	if len(meta.Chunks) > 0 {
+		return newSkipReader(odoi.newChunksReader(meta.Chunks), offset, length)
+	}
+	return odoi.spGetContentRangeReader(meta.Ch, offset, length)
+}
+
 func (odoi *oDssOlfImpl) queryContent(ch string) (bool, error) {
 	cpath := ufpath.Join(odoi.root, "content", internal.Str32ToPath(ch, odoi.size))
 	_, err := odoi.getAfs().Stat(cpath)
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		if odoi.repoChunked {
+			return odoi.queryChunkManifest(ch)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (odoi *oDssOlfImpl) contentSize(ch string) (int64, error) {
+	fi, err := odoi.getAfs().Stat(ufpath.Join(odoi.root, "content", internal.Str32ToPath(ch, odoi.size)))
+	if err != nil {
+		return 0, fmt.Errorf("in contentSize: %w", err)
+	}
+	return fi.Size(), nil
+}
+
+func (odoi *oDssOlfImpl) writeFile(path string, bs []byte) error {
+	if err := odoi.getAfs().MkdirAll(ufpath.Dir(path), 0o777); err != nil {
+		return err
+	}
+	tf, err := afero.TempFile(odoi.getAfs(), ufpath.Join(odoi.root, "tmp"), "ck")
+	if err != nil {
+		return err
+	}
+	n, err := tf.Write(bs)
+	if n != len(bs) || err != nil {
+		tf.Close()
+		_ = odoi.getAfs().Remove(tf.Name())
+		return fmt.Errorf("write %s %d < %d error %v", tf.Name(), n, len(bs), err)
+	}
+	if err = tf.Close(); err != nil {
+		_ = odoi.getAfs().Remove(tf.Name())
+		return err
+	}
+	return odoi.getAfs().Rename(tf.Name(), path)
+}
+
+func (odoi *oDssOlfImpl) pushChunk(ch string, bs []byte) error {
+	cpath := ufpath.Join(odoi.root, "content", internal.Str32ToPath(ch, odoi.size))
+	if odoi.isContentCompressed() {
+		var err error
+		if bs, err = compressBytes(bs, odoi.repoCompression); err != nil {
+			return fmt.Errorf("in pushChunk: %w", err)
+		}
+	}
+	if err := odoi.writeFile(cpath, bs); err != nil {
+		return fmt.Errorf("in pushChunk: %w", err)
+	}
+	return nil
+}
+
+func (odoi *oDssOlfImpl) chunkManifestPath(ch string) string {
+	return ufpath.Join(odoi.root, "chunks", internal.Str32ToPath(ch, odoi.size))
+}
+
+func (odoi *oDssOlfImpl) queryChunkManifest(ch string) (bool, error) {
+	_, err := odoi.getAfs().Stat(odoi.chunkManifestPath(ch))
 	if err != nil && errors.Is(err, fs.ErrNotExist) {
 		return false, nil
 	}
@@ -215,6 +354,36 @@ func (odoi *oDssOlfImpl) queryContent(ch string) (bool, error) {
 	return true, nil
 }
 
+func (odoi *oDssOlfImpl) loadChunkManifest(ch string) ([]string, error) {
+	bs, err := afero.ReadFile(odoi.getAfs(), odoi.chunkManifestPath(ch))
+	if err != nil {
+		return nil, fmt.Errorf("in loadChunkManifest: %w", err)
+	}
+	chunks, err := decodeChunkManifest(bs)
+	if err != nil {
+		return nil, fmt.Errorf("in loadChunkManifest: %w", err)
+	}
+	return chunks, nil
+}
+
+func (odoi *oDssOlfImpl) storeChunkManifest(ch string, chunks []string) error {
+	bs, err := encodeChunkManifest(chunks)
+	if err != nil {
+		return fmt.Errorf("in storeChunkManifest: %w", err)
+	}
+	if err = odoi.writeFile(odoi.chunkManifestPath(ch), bs); err != nil {
+		return fmt.Errorf("in storeChunkManifest: %w", err)
+	}
+	return nil
+}
+
+func (odoi *oDssOlfImpl) removeChunkManifest(ch string) error {
+	if err := odoi.getAfs().Remove(odoi.chunkManifestPath(ch)); err != nil {
+		return fmt.Errorf("in removeChunkManifest: %w", err)
+	}
+	return nil
+}
+
 func (odoi *oDssOlfImpl) removeContent(ch string) error {
 	cpath := ufpath.Join(odoi.root, "content", internal.Str32ToPath(ch, odoi.size))
 	if err := odoi.getAfs().Remove(cpath); err != nil {
@@ -223,8 +392,59 @@ func (odoi *oDssOlfImpl) removeContent(ch string) error {
 	return nil
 }
 
+func (odoi *oDssOlfImpl) loadTags() ([]byte, error) {
+	bs, err := afero.ReadFile(odoi.getAfs(), ufpath.Join(odoi.root, "tags"))
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("in loadTags: %w", err)
+	}
+	return bs, nil
+}
+
+func (odoi *oDssOlfImpl) storeTags(bs []byte) error {
+	if err := odoi.writeFile(ufpath.Join(odoi.root, "tags"), bs); err != nil {
+		return fmt.Errorf("in storeTags: %w", err)
+	}
+	return nil
+}
+
+func (odoi *oDssOlfImpl) loadConvergenceKey() ([]byte, error) {
+	bs, err := afero.ReadFile(odoi.getAfs(), ufpath.Join(odoi.root, "convergence"))
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("in loadConvergenceKey: %w", err)
+	}
+	return bs, nil
+}
+
+func (odoi *oDssOlfImpl) storeConvergenceKey(bs []byte) error {
+	f, err := odoi.getAfs().OpenFile(ufpath.Join(odoi.root, "convergence"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return fmt.Errorf("in storeConvergenceKey: %w", err)
+	}
+	if _, err = f.Write(bs); err != nil {
+		f.Close()
+		return fmt.Errorf("in storeConvergenceKey: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("in storeConvergenceKey: %w", err)
+	}
+	return nil
+}
+
 func (odoi *oDssOlfImpl) spClose() error { return nil }
 
+func (odoi *oDssOlfImpl) atTime(lsttime int64) oDssProxy {
+	view := *odoi
+	view.me = &view
+	view.lsttime = lsttime
+	return &view
+}
+
 func (odoi *oDssOlfImpl) dumpIndex() string { return odoi.index.Dump() }
 
 func (odoi *oDssOlfImpl) setAfs(tfs afero.Fs) { odoi.afs = tfs }
@@ -324,7 +544,11 @@ func (odoi *oDssOlfImpl) scanContentDir(path string, checksum bool, sti StorageI
 		pathErr(mn, err)
 	}
 	doScanContentOlf := func(pcp, pcch string) {
+		var cr io.ReadCloser
 		cr, err := odoi.getAfs().Open(pcp)
+		if err == nil && odoi.isContentCompressed() {
+			cr, err = newDecompressReader(cr)
+		}
 		if err != nil {
 			lockPathErr(path, err)
 			return
@@ -359,9 +583,37 @@ func (odoi *oDssOlfImpl) scanContentDir(path string, checksum bool, sti StorageI
 	return
 }
 
+func (odoi *oDssOlfImpl) scanChunksDir(path string, sti StorageInfo, errs *ErrorCollector) {
+	df, err := odoi.getAfs().Open(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			sti.Path2Error[path] = err
+			errs.Collect(err)
+		}
+		return
+	}
+	defer df.Close()
+	fil, err := df.Readdir(0)
+	if err != nil {
+		sti.Path2Error[path] = err
+		errs.Collect(err)
+		return
+	}
+	for _, fi := range fil {
+		cPath := ufpath.Join(path, fi.Name())
+		if fi.IsDir() {
+			odoi.scanChunksDir(cPath, sti, errs)
+			continue
+		}
+		relPath := cPath[strings.LastIndex(cPath, "/chunks/")+len("/chunks") : len(cPath)]
+		sti.Path2Manifest[cPath] = strings.Join(strings.Split(relPath, "/"), "")
+	}
+}
+
 func (odoi *oDssOlfImpl) scanPhysicalStorage(checksum bool, sti StorageInfo, errs *ErrorCollector) {
 	odoi.scanMetaDir(ufpath.Join(odoi.root, "meta"), sti, errs)
 	odoi.scanContentDir(ufpath.Join(odoi.root, "content"), checksum, sti, errs)
+	odoi.scanChunksDir(ufpath.Join(odoi.root, "chunks"), sti, errs)
 }
 
 func newOlfProxy() oDssProxy {
@@ -425,6 +677,15 @@ func CreateOlfDss(config OlfConfig) (HDss, error) {
 	if config.LocalPath == "" {
 		return nil, fmt.Errorf("in CreateOlfDss: please provide a LocalPath")
 	}
+	if config.Chunked && config.Encrypted {
+		return nil, fmt.Errorf("in CreateOlfDss: chunking is not available for encrypted repositories")
+	}
+	if config.Convergent && !config.Encrypted {
+		return nil, fmt.Errorf("in CreateOlfDss: convergent encryption is only available for encrypted repositories")
+	}
+	if err := CheckCompression(config.Compression); err != nil {
+		return nil, fmt.Errorf("in CreateOlfDss: %w", err)
+	}
 	config.RepoId = uuid.New().String()
 	if err := SaveDssConfig(config.DssBaseConfig, config); err != nil {
 		return nil, fmt.Errorf("in CreateObsDss: %w", err)
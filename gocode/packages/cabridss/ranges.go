@@ -0,0 +1,171 @@
+package cabridss
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// checkRange checks that offset is within content of the given size
+// returns the length of the range, up to the end of the content if length is negative or too large
+func checkRange(size, offset, length int64) (int64, error) {
+	if offset < 0 || offset > size {
+		return 0, fmt.Errorf("offset %d size %d: %w", offset, size, ErrInvalidRange)
+	}
+	if length < 0 || offset+length > size {
+		length = size - offset
+	}
+	return length, nil
+}
+
+type limitReadCloser struct {
+	io.Reader
+	rc io.ReadCloser
+}
+
+func (lrc *limitReadCloser) Close() error {
+	return lrc.rc.Close()
+}
+
+// newLimitReadCloser returns a reader of at most length bytes of rc, or all of it if length is negative
+func newLimitReadCloser(rc io.ReadCloser, length int64) io.ReadCloser {
+	if length < 0 {
+		return rc
+	}
+	return &limitReadCloser{Reader: io.LimitReader(rc, length), rc: rc}
+}
+
+// newSkipReader returns a reader of length bytes of rc from offset, discarding the first ones,
+// it is used when the content cannot be seeked in, as when it is compressed or split in chunks
+func newSkipReader(rc io.ReadCloser, offset, length int64) (io.ReadCloser, error) {
+	if _, err := io.CopyN(io.Discard, rc, offset); err != nil {
+		rc.Close()
+		if err == io.EOF {
+			err = ErrInvalidRange
+		}
+		return nil, fmt.Errorf("in newSkipReader: %w", err)
+	}
+	return newLimitReadCloser(rc, length), nil
+}
+
+// newSeekReader returns a reader of length bytes of rsc from offset
+func newSeekReader(rsc io.ReadSeekCloser, offset, length int64) (io.ReadCloser, error) {
+	if _, err := rsc.Seek(offset, io.SeekStart); err != nil {
+		rsc.Close()
+		return nil, fmt.Errorf("in newSeekReader: %w", err)
+	}
+	return newLimitReadCloser(rsc, length), nil
+}
+
+func emptyReadCloser() io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(nil))
+}
+
+// readFullAt reads len(p) bytes from the range reader rc, returns io.EOF if less are available as io.ReaderAt does
+func readFullAt(rc io.ReadCloser, p []byte) (int, error) {
+	defer rc.Close()
+	n, err := io.ReadFull(rc, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// spReaderAt reads stored content by its checksum, its size being unknown
+type spReaderAt struct {
+	me oDssProxy
+	ch string
+}
+
+func (spra spReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	rc, err := spra.me.spGetContentRangeReader(spra.ch, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	return readFullAt(rc, p)
+}
+
+// ContentReaderAt implements io.ReaderAt on some content of a DSS
+type ContentReaderAt struct {
+	dss   Dss
+	npath string
+	size  int64
+}
+
+// NewContentReaderAt returns a ContentReaderAt on the content npath of the dss,
+// each ReadAt being served by a ranged read of the content
+func NewContentReaderAt(dss Dss, npath string) (*ContentReaderAt, error) {
+	meta, err := dss.GetMeta(npath, false)
+	if err != nil {
+		return nil, fmt.Errorf("in NewContentReaderAt: %w", err)
+	}
+	if meta.GetIsNs() {
+		return nil, fmt.Errorf("in NewContentReaderAt: %s is a namespace", npath)
+	}
+	return &ContentReaderAt{dss: dss, npath: npath, size: meta.GetSize()}, nil
+}
+
+// Size returns the size of the content
+func (cra *ContentReaderAt) Size() int64 {
+	return cra.size
+}
+
+func (cra *ContentReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= cra.size {
+		return 0, io.EOF
+	}
+	rc, err := cra.dss.GetContentRangeReader(cra.npath, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	return readFullAt(rc, p)
+}
+
+// parseHttpRange parses the value of an HTTP Range header, only a single range being supported,
+// size is the size of the content or negative if unknown, in which case a suffix range is rejected
+// returns the offset and the length of the range, negative if up to the end of the content
+func parseHttpRange(hr string, size int64) (offset, length int64, err error) {
+	spec, ok := strings.CutPrefix(hr, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("unsupported range %s: %w", hr, ErrInvalidRange)
+	}
+	sFirst, sLast, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok || (sFirst == "" && sLast == "") {
+		return 0, 0, fmt.Errorf("malformed range %s: %w", hr, ErrInvalidRange)
+	}
+	var first, last int64
+	if sFirst == "" {
+		if last, err = strconv.ParseInt(sLast, 10, 64); err != nil || last <= 0 || size < 0 {
+			return 0, 0, fmt.Errorf("unsupported range %s: %w", hr, ErrInvalidRange)
+		}
+		return max(0, size-last), -1, nil
+	}
+	if first, err = strconv.ParseInt(sFirst, 10, 64); err != nil || first < 0 || (size >= 0 && first >= size) {
+		return 0, 0, fmt.Errorf("unsatisfiable range %s: %w", hr, ErrInvalidRange)
+	}
+	if sLast == "" {
+		return first, -1, nil
+	}
+	if last, err = strconv.ParseInt(sLast, 10, 64); err != nil || last < first {
+		return 0, 0, fmt.Errorf("malformed range %s: %w", hr, ErrInvalidRange)
+	}
+	return first, last - first + 1, nil
+}
+
+// httpRange formats the value of an HTTP Range header
+func httpRange(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// httpContentRange formats the value of an HTTP Content-Range header for a range checked with checkRange
+func httpContentRange(offset, length, size int64) string {
+	if length == 0 {
+		return fmt.Sprintf("bytes */%d", size)
+	}
+	return fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size)
+}
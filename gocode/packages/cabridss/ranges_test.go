@@ -0,0 +1,310 @@
+package cabridss
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestParseHttpRange(t *testing.T) {
+	for _, c := range []struct {
+		hr             string
+		size           int64
+		offset, length int64
+		ok             bool
+	}{
+		{"bytes=0-9", 100, 0, 10, true},
+		{"bytes=10-", 100, 10, -1, true},
+		{"bytes=-10", 100, 90, -1, true},
+		{"bytes=-200", 100, 0, -1, true},
+		{"bytes=5-5", -1, 5, 1, true},
+		{"bytes=100-", 100, 0, 0, false},
+		{"bytes=-10", -1, 0, 0, false},
+		{"bytes=9-5", 100, 0, 0, false},
+		{"bytes=0-1,5-6", 100, 0, 0, false},
+		{"items=0-1", 100, 0, 0, false},
+		{"bytes=-", 100, 0, 0, false},
+	} {
+		offset, length, err := parseHttpRange(c.hr, c.size)
+		if c.ok != (err == nil) || (c.ok && (offset != c.offset || length != c.length)) {
+			t.Fatalf("TestParseHttpRange %s size %d: %d %d %v", c.hr, c.size, offset, length, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidRange) {
+			t.Fatalf("TestParseHttpRange %s: %v", c.hr, err)
+		}
+	}
+}
+
+func rangeTestCases(size int64) [][2]int64 {
+	return [][2]int64{{0, -1}, {0, 1}, {size / 2, -1}, {size / 3, size / 3}, {size - 1, 10}, {size, -1},
+		{ageChunkSize - 10, 20}, {ageChunkSize, ageChunkSize + 1}, {2*ageChunkSize + 7, 3*ageChunkSize - 3}}
+}
+
+func runRangeTest(dss Dss, npath string, bs []byte) error {
+	if err := writeTestContent(dss, npath, bs); err != nil {
+		return err
+	}
+	size := int64(len(bs))
+	for _, c := range rangeTestCases(size) {
+		offset, length := c[0], c[1]
+		if offset < 0 || offset > size {
+			continue
+		}
+		end := size
+		if length >= 0 && offset+length < size {
+			end = offset + length
+		}
+		rc, err := dss.GetContentRangeReader(npath, offset, length)
+		if err != nil {
+			return fmt.Errorf("runRangeTest %s %d %d: %w", npath, offset, length, err)
+		}
+		rbs, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(rbs, bs[offset:end]) {
+			return fmt.Errorf("runRangeTest %s %d %d read %d bytes error %v", npath, offset, length, len(rbs), err)
+		}
+	}
+	if _, err := dss.GetContentRangeReader(npath, size+1, -1); !errors.Is(err, ErrInvalidRange) {
+		return fmt.Errorf("runRangeTest %s range beyond end error %v", npath, err)
+	}
+	cra, err := NewContentReaderAt(dss, npath)
+	if err != nil {
+		return err
+	}
+	if cra.Size() != size {
+		return fmt.Errorf("runRangeTest %s ContentReaderAt size %d", npath, cra.Size())
+	}
+	rbs, err := io.ReadAll(io.NewSectionReader(cra, 0, cra.Size()))
+	if err != nil || !bytes.Equal(rbs, bs) {
+		return fmt.Errorf("runRangeTest %s ContentReaderAt read %d bytes error %v", npath, len(rbs), err)
+	}
+	return nil
+}
+
+func TestFsyRange(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestFsyRange", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	dss, err := NewFsyDss(FsyConfig{}, tfs.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = runRangeTest(dss, "d/c.bin", randBytes(5, 300*1024)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOlfRange(t *testing.T) {
+	optionalSkip(t)
+	for _, c := range []struct {
+		name              string
+		chunked, compress bool
+	}{{"plain", false, false}, {"chunked", true, false}, {"compressed", false, true}} {
+		tfs, err := testfs.CreateFs("TestOlfRange", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+			return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+		}
+		dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex,
+			Chunked: c.chunked, Compressed: c.compress}, Root: tfs.Path(), Size: "s"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = dss.Mkns("", 0, []string{"a.bin", "b.bin"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err = runRangeTest(dss, "a.bin", randBytes(6, 5*1024*1024)); err != nil {
+			t.Fatalf("TestOlfRange %s: %v", c.name, err)
+		}
+		if err = runRangeTest(dss, "b.bin", compressibleBytes(300*1024)); err != nil {
+			t.Fatalf("TestOlfRange %s: %v", c.name, err)
+		}
+		dss.Close()
+		tfs.Delete()
+	}
+}
+
+func TestObsRangeMockFs(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestObsRangeMockFs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	ranges := 0
+	dss, err := NewObsDss(ObsConfig{
+		GetS3Session: func() IS3Session {
+			return NewS3sMockFs(tfs.Path(), func(parent IS3Session) IS3Session {
+				return NewS3sMockTests(parent, func(args ...any) interface{} {
+					if args[1] == "DownloadRange" {
+						ranges++
+					}
+					return nil
+				})
+			})
+		},
+	}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, []string{"a.bin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = runRangeTest(dss, "a.bin", randBytes(7, 300*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if ranges == 0 {
+		t.Fatalf("TestObsRangeMockFs ranged downloads not used")
+	}
+}
+
+func TestWebDssClientOlfRange(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestWebDssClientOlfRange", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(tfs, ":3000", "",
+		CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	dss, err := NewWebDss(
+		WebDssConfig{DssBaseConfig: DssBaseConfig{ConfigDir: ufpath.Join(tfs.Path(), ".cabri-i1"), WebPort: "3000"}},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, []string{"a.bin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = runRangeTest(dss, "a.bin", randBytes(8, 300*1024)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEDssClientOlfRange(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestEDssClientOlfRange", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(tfs, ":3000", "",
+		CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex, Encrypted: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	dss, err := NewEDss(
+		EDssConfig{WebDssConfig: WebDssConfig{DssBaseConfig: DssBaseConfig{ConfigDir: ufpath.Join(tfs.Path(), ".cabri"), WebPort: "3000"}}},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	sizes := []int{0, 1, ageChunkSize, ageChunkSize + 1, 20*ageChunkSize + 5}
+	var children []string
+	for i := range sizes {
+		children = append(children, fmt.Sprintf("c%d.bin", i))
+	}
+	if err = dss.Mkns("", 0, children, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i, size := range sizes {
+		if err = runRangeTest(dss, children[i], randBytes(int64(9+i), size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWfsDssRange(t *testing.T) {
+	if err := runWfsDssTest(t, func(tfs *testfs.Fs, dss Dss) error {
+		return runRangeTest(dss, "d/c.bin", randBytes(10, 300*1024))
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestRange(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestRestRange", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dss.Mkns("", 0, []string{"c.bin"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	bs := randBytes(11, 1000)
+	if err = writeTestContent(dss, "c.bin", bs); err != nil {
+		t.Fatal(err)
+	}
+	sv, err := NewRestServer("", WebDssServerConfig{WebServerConfig: WebServerConfig{Addr: ":3000"}, Dss: dss})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	for _, c := range []struct {
+		hr     string
+		status int
+		cr     string
+		rbs    []byte
+	}{
+		{"", http.StatusOK, "", bs},
+		{"bytes=10-19", http.StatusPartialContent, "bytes 10-19/1000", bs[10:20]},
+		{"bytes=990-", http.StatusPartialContent, "bytes 990-999/1000", bs[990:]},
+		{"bytes=-5", http.StatusPartialContent, "bytes 995-999/1000", bs[995:]},
+		{"bytes=1000-", http.StatusRequestedRangeNotSatisfiable, "bytes */1000", nil},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:3000/c.bin", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.hr != "" {
+			req.Header.Set("Range", c.hr)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rbs, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != c.status || resp.Header.Get("Content-Range") != c.cr {
+			t.Fatalf("TestRestRange %s status %d Content-Range %s error %v", c.hr, resp.StatusCode, resp.Header.Get("Content-Range"), err)
+		}
+		if c.rbs != nil && !bytes.Equal(rbs, c.rbs) {
+			t.Fatalf("TestRestRange %s read %d bytes", c.hr, len(rbs))
+		}
+	}
+}
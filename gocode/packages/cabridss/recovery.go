@@ -0,0 +1,219 @@
+package cabridss
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
+	"io"
+	"os"
+	"strings"
+)
+
+// the user configuration is exported as N printable shares, any M of them rebuilding it:
+// the secret shared is the compressed JSON configuration prefixed with its truncated checksum,
+// each share block carries a set id, its index, the threshold and its own checksum for detecting typos.
+
+const (
+	recoveryBegin   = "-----BEGIN CABRI RECOVERY SHARE-----"
+	recoveryEnd     = "-----END CABRI RECOVERY SHARE-----"
+	recoveryColumns = 64
+	recoveryCsSize  = 8
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type recoveryShare struct {
+	set       string
+	index     int
+	threshold int
+	data      []byte
+}
+
+func recoveryCheck(data []byte) string {
+	cs := sha256.Sum256(data)
+	return hex.EncodeToString(cs[:4])
+}
+
+func (rs recoveryShare) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(recoveryBegin + "\n")
+	sb.WriteString(fmt.Sprintf("Set: %s Share: %d Threshold: %d Check: %s\n", rs.set, rs.index, rs.threshold, recoveryCheck(rs.data)))
+	b32 := recoveryEncoding.EncodeToString(rs.data)
+	for len(b32) > 0 {
+		n := min(len(b32), recoveryColumns)
+		sb.WriteString(b32[:n] + "\n")
+		b32 = b32[n:]
+	}
+	sb.WriteString(recoveryEnd + "\n")
+	return sb.String()
+}
+
+func parseRecoveryShares(text string) (shares []recoveryShare, err error) {
+	var (
+		rs     *recoveryShare
+		check  string
+		b32    strings.Builder
+		header bool
+	)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == recoveryBegin {
+			rs = &recoveryShare{}
+			header = true
+			b32.Reset()
+			continue
+		}
+		if rs == nil {
+			continue
+		}
+		if header {
+			if _, err = fmt.Sscanf(line, "Set: %s Share: %d Threshold: %d Check: %s", &rs.set, &rs.index, &rs.threshold, &check); err != nil {
+				return nil, fmt.Errorf("in parseRecoveryShares: line %d: %w", i+1, err)
+			}
+			header = false
+			continue
+		}
+		if line != recoveryEnd {
+			b32.WriteString(strings.ToUpper(strings.ReplaceAll(line, " ", "")))
+			continue
+		}
+		if rs.data, err = recoveryEncoding.DecodeString(b32.String()); err != nil {
+			return nil, fmt.Errorf("in parseRecoveryShares: share %d: %w", rs.index, err)
+		}
+		if recoveryCheck(rs.data) != check {
+			return nil, fmt.Errorf("in parseRecoveryShares: share %d: invalid checksum, please check for typos", rs.index)
+		}
+		if len(rs.data) < 2 || int(rs.data[len(rs.data)-1]) != rs.index {
+			return nil, fmt.Errorf("in parseRecoveryShares: share %d: inconsistent index", rs.index)
+		}
+		shares = append(shares, *rs)
+		rs = nil
+	}
+	if rs != nil {
+		return nil, fmt.Errorf("in parseRecoveryShares: share %d is truncated", rs.index)
+	}
+	return
+}
+
+func combineRecoveryShares(shares []recoveryShare) (UserConfig, error) {
+	var uc UserConfig
+	if len(shares) == 0 {
+		return uc, fmt.Errorf("in combineRecoveryShares: no share")
+	}
+	var datas [][]byte
+	for _, rs := range shares {
+		if rs.set != shares[0].set {
+			return uc, fmt.Errorf("in combineRecoveryShares: shares belong to sets %s and %s", shares[0].set, rs.set)
+		}
+		datas = append(datas, rs.data)
+	}
+	if len(shares) < shares[0].threshold {
+		return uc, fmt.Errorf("in combineRecoveryShares: %d shares provided, %d required", len(shares), shares[0].threshold)
+	}
+	secret, err := internal.ShamirCombine(datas)
+	if err != nil {
+		return uc, fmt.Errorf("in combineRecoveryShares: %w", err)
+	}
+	if len(secret) < recoveryCsSize {
+		return uc, fmt.Errorf("in combineRecoveryShares: the shares don't rebuild the configuration")
+	}
+	if cs := sha256.Sum256(secret[recoveryCsSize:]); !bytes.Equal(cs[:recoveryCsSize], secret[:recoveryCsSize]) {
+		return uc, fmt.Errorf("in combineRecoveryShares: the shares don't rebuild the configuration")
+	}
+	bs, err := io.ReadAll(flate.NewReader(bytes.NewReader(secret[recoveryCsSize:])))
+	if err != nil {
+		return uc, fmt.Errorf("in combineRecoveryShares: %w", err)
+	}
+	if err = json.Unmarshal(bs, &uc); err != nil {
+		return uc, fmt.Errorf("in combineRecoveryShares: %w", err)
+	}
+	return uc, nil
+}
+
+// RecoveryShares exports uc as n printable shares, any m of them rebuilding it with RecoverUserConfig.
+//
+// The shares are checked to rebuild uc before being returned.
+func RecoveryShares(uc UserConfig, n, m int) ([]string, error) {
+	bs, err := json.Marshal(uc)
+	if err != nil {
+		return nil, fmt.Errorf("in RecoveryShares: %w", err)
+	}
+	cbs := bytes.Buffer{}
+	fw, _ := flate.NewWriter(&cbs, flate.BestCompression)
+	if _, err = fw.Write(bs); err != nil {
+		return nil, fmt.Errorf("in RecoveryShares: %w", err)
+	}
+	if err = fw.Close(); err != nil {
+		return nil, fmt.Errorf("in RecoveryShares: %w", err)
+	}
+	cs := sha256.Sum256(cbs.Bytes())
+	secret := append(cs[:recoveryCsSize:recoveryCsSize], cbs.Bytes()...)
+	datas, err := internal.ShamirSplit(secret, n, m)
+	if err != nil {
+		return nil, fmt.Errorf("in RecoveryShares: %w", err)
+	}
+	sbs := make([]byte, 4)
+	if _, err = rand.Read(sbs); err != nil {
+		return nil, fmt.Errorf("in RecoveryShares: %w", err)
+	}
+	var res []string
+	for i, data := range datas {
+		res = append(res, recoveryShare{set: hex.EncodeToString(sbs), index: i + 1, threshold: m, data: data}.String())
+	}
+
+	// round trip check with the first and the last m printed shares
+	shares, err := parseRecoveryShares(strings.Join(res, "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("in RecoveryShares: round trip check failed: %w", err)
+	}
+	if len(shares) != n {
+		return nil, fmt.Errorf("in RecoveryShares: round trip check failed: %d shares parsed", len(shares))
+	}
+	for _, sel := range [][]recoveryShare{shares[:m], shares[n-m:]} {
+		ruc, err := combineRecoveryShares(sel)
+		if err != nil {
+			return nil, fmt.Errorf("in RecoveryShares: round trip check failed: %w", err)
+		}
+		if rbs, _ := json.Marshal(ruc); !bytes.Equal(rbs, bs) {
+			return nil, fmt.Errorf("in RecoveryShares: round trip check failed: configurations differ")
+		}
+	}
+	return res, nil
+}
+
+// RecoverUserConfig rebuilds the user configuration from text containing at least the threshold number of shares
+// returned by RecoveryShares
+func RecoverUserConfig(text string) (UserConfig, error) {
+	shares, err := parseRecoveryShares(text)
+	if err != nil {
+		return UserConfig{}, fmt.Errorf("in RecoverUserConfig: %w", err)
+	}
+	uc, err := combineRecoveryShares(shares)
+	if err != nil {
+		return UserConfig{}, fmt.Errorf("in RecoverUserConfig: %w", err)
+	}
+	return uc, nil
+}
+
+// RestoreUserConfig writes a recovered user configuration in configDir,
+// encrypted if a config password is provided, the configuration must not exist yet
+func RestoreUserConfig(config DssBaseConfig, configDir string, uc UserConfig) error {
+	if err := checkDir(configDir); err != nil {
+		if err = os.Mkdir(configDir, 0o777); err != nil {
+			return fmt.Errorf("in RestoreUserConfig: %w", err)
+		}
+	}
+	if _, err := os.Stat(ccPath(configDir)); err == nil {
+		return fmt.Errorf("in RestoreUserConfig: configuration %s already exists", ccPath(configDir))
+	}
+	if err := writeUserConfig(config, configDir, uc, config.ConfigPassword != ""); err != nil {
+		return fmt.Errorf("in RestoreUserConfig: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,502 @@
+package cabridss
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/afero"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RekeyOptions tells Rekey how to re-encrypt the entries of an encrypted DSS
+type RekeyOptions struct {
+	MapUsers   map[string][]string // ACL users mapped to the users replacing them with the same rights, an empty list revoking them
+	Content    bool                // also re-encrypt the content, which otherwise remains encrypted for its former recipients
+	NoPurge    bool                // keep the former encrypted metadata and content, they are purged by a later Rekey with the same journal
+	JournalDir string              // if not empty, directory where the progress is journaled, so that an interrupted Rekey can be resumed
+}
+
+// rekeying keeps the history: each history entry keeps its index time and gets a new encrypted meta,
+// the former one being purged once all entries are rekeyed;
+// everything is decrypted and re-encrypted on the client side, the server only stores and removes encrypted data;
+// with a JournalDir, the former encrypted data of the rekeyed entries is journaled until it is purged,
+// an interrupted Rekey being resumed by running it again with the same options,
+// as the entries already rekeyed are then skipped
+
+type rekeyHeader struct {
+	RepoId string `json:"repoId"`
+	Path   string `json:"path"`
+}
+
+type rekeyRecord struct {
+	Path   string   `json:"p"`
+	Itime  int64    `json:"t"`
+	EMId   string   `json:"em"`           // former encrypted meta identifier
+	EChs   []string `json:"ec,omitempty"` // former encrypted content or namespace pages
+	NECh   string   `json:"nc,omitempty"` // re-encrypted content replacing EChs[0]
+	Rcp    string   `json:"r,omitempty"`  // recipients of the re-encrypted content
+	Purged bool     `json:"x,omitempty"`  // the former encrypted data is purged
+}
+
+type rekeyJournal struct {
+	path    string
+	f       *os.File
+	records []rekeyRecord
+	purged  map[string]bool
+}
+
+func openRekeyJournal(dir, repoId, npath string) (*rekeyJournal, error) {
+	jnl := &rekeyJournal{purged: map[string]bool{}}
+	if dir == "" {
+		return jnl, nil
+	}
+	hdr := rekeyHeader{RepoId: repoId, Path: npath}
+	jnl.path = ufpath.Join(dir, internal.BytesToSha256Str([]byte(hdr.RepoId+"|"+hdr.Path))+".rkj")
+	if err := jnl.load(hdr); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("in openRekeyJournal: %w", err)
+	}
+	var err error
+	if len(jnl.records) > 0 {
+		jnl.f, err = os.OpenFile(jnl.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	} else {
+		if jnl.f, err = os.OpenFile(jnl.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600); err == nil {
+			err = jnl.writeLine(hdr)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("in openRekeyJournal: %w", err)
+	}
+	return jnl, nil
+}
+
+func (jnl *rekeyJournal) load(hdr rekeyHeader) error {
+	f, err := os.Open(jnl.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("in loadRekeyJournal: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var fHdr rekeyHeader
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &fHdr) != nil || fHdr != hdr {
+		return nil
+	}
+	for scanner.Scan() {
+		var rr rekeyRecord
+		// the last line may be truncated if the rekey was interrupted
+		if json.Unmarshal(scanner.Bytes(), &rr) != nil {
+			continue
+		}
+		if rr.Purged {
+			jnl.purged[rr.EMId] = true
+			continue
+		}
+		jnl.records = append(jnl.records, rr)
+	}
+	return nil
+}
+
+func (jnl *rekeyJournal) writeLine(v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = jnl.f.Write(append(bs, '\n'))
+	return err
+}
+
+// journal records an entry whose former encrypted data must be purged once rekeyed
+func (jnl *rekeyJournal) journal(rr rekeyRecord) error {
+	if rr.Purged {
+		jnl.purged[rr.EMId] = true
+	} else {
+		jnl.records = append(jnl.records, rr)
+	}
+	if jnl.f == nil {
+		return nil
+	}
+	if err := jnl.writeLine(rr); err != nil {
+		return fmt.Errorf("in journal: %w", err)
+	}
+	return nil
+}
+
+// contents returns the re-encrypted contents by former encrypted content and recipients
+func (jnl *rekeyJournal) contents() map[string]string {
+	res := map[string]string{}
+	for _, rr := range jnl.records {
+		if rr.NECh != "" {
+			res[rr.EChs[0]+"|"+rr.Rcp] = rr.NECh
+		}
+	}
+	return res
+}
+
+// close closes the journal file, removing it if the former encrypted data is purged
+func (jnl *rekeyJournal) close(completed bool) error {
+	if jnl.f == nil {
+		return nil
+	}
+	if err := jnl.f.Close(); err != nil {
+		return fmt.Errorf("in closeRekeyJournal: %w", err)
+	}
+	if completed {
+		if err := os.Remove(jnl.path); err != nil {
+			return fmt.Errorf("in closeRekeyJournal: %w", err)
+		}
+	}
+	return nil
+}
+
+// mapAcl maps the ACL users, the rights of a replaced user being given to the users replacing it
+func (ro RekeyOptions) mapAcl(acl []ACLEntry) []ACLEntry {
+	rights := map[string]Rights{}
+	add := func(user string, r Rights) {
+		or := rights[user]
+		rights[user] = Rights{Read: or.Read || r.Read, Write: or.Write || r.Write, Execute: or.Execute || r.Execute}
+	}
+	for _, ace := range acl {
+		users, ok := ro.MapUsers[ace.User]
+		if !ok {
+			users = []string{ace.User}
+		}
+		for _, user := range users {
+			add(user, ace.Rights)
+		}
+	}
+	var macl []ACLEntry
+	for user, r := range rights {
+		macl = append(macl, ACLEntry{User: user, Rights: r})
+	}
+	sort.Slice(macl, func(i, j int) bool {
+		return macl[i].User < macl[j].User
+	})
+	return macl
+}
+
+// isRevoked tells if the user is replaced by no other one
+func (ro RekeyOptions) isRevoked(user string) bool {
+	users, ok := ro.MapUsers[user]
+	return ok && len(users) == 0
+}
+
+func sameUsers(users1, users2 []string) bool {
+	s1, s2 := append([]string{}, users1...), append([]string{}, users2...)
+	sort.Strings(s1)
+	sort.Strings(s2)
+	return strings.Join(s1, ",") == strings.Join(s2, ",")
+}
+
+func isRekeyIn(ipath string, isDir, recursive bool, mipath string) bool {
+	if mipath == ipath {
+		return true
+	}
+	if !isDir || !recursive {
+		return false
+	}
+	return ipath == "" || strings.HasPrefix(mipath, ipath+"/")
+}
+
+// reencryptContent decrypts the content of meta and encrypts it again to the pkeys in a temporary file
+func (edi *eDssImpl) reencryptContent(meta Meta, pkeys []string, secret []byte) (cf afero.File, size int64, ech string, err error) {
+	erc, err := edi.spGetContentReader(meta.ECh)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("in reencryptContent: %w", err)
+	}
+	defer erc.Close()
+	// compressed content is re-encrypted as is
+	crc, err := Decrypt(erc, edi.secrets(edi.contentUsers(meta))...)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("in reencryptContent: %w", err)
+	}
+	if cf, err = afero.TempFile(edi.getAfs(), "", "rkc"); err != nil {
+		return nil, 0, "", fmt.Errorf("in reencryptContent: %w", err)
+	}
+	abort := func(err error) (afero.File, int64, string, error) {
+		cf.Close()
+		edi.getAfs().Remove(cf.Name())
+		return nil, 0, "", fmt.Errorf("in reencryptContent: %w", err)
+	}
+	var ewc io.WriteCloser
+	if secret != nil {
+		ewc, err = EncryptConvergent(cf, secret, meta.Ch, pkeys...)
+	} else {
+		ewc, err = Encrypt(cf, pkeys...)
+	}
+	if err != nil {
+		return abort(err)
+	}
+	if _, err = io.Copy(ewc, crc); err != nil {
+		return abort(err)
+	}
+	if err = ewc.Close(); err != nil {
+		return abort(err)
+	}
+	if size, err = cf.Seek(0, io.SeekCurrent); err != nil {
+		return abort(err)
+	}
+	if _, err = cf.Seek(0, io.SeekStart); err != nil {
+		return abort(err)
+	}
+	if ech, err = internal.ShaFrom(cf); err != nil {
+		return abort(err)
+	}
+	return cf, size, ech, nil
+}
+
+// rekeyEntry stores the meta re-encrypted for the mapped ACL, journaling the former encrypted data
+func (edi *eDssImpl) rekeyEntry(meta Meta, opts RekeyOptions, secret []byte, contents map[string]string, jnl *rekeyJournal) (Meta, error) {
+	ipath := RemoveSlashIfNsIf(meta.Path, meta.IsNs)
+	rr := rekeyRecord{Path: ipath, Itime: meta.Itime, EMId: meta.EMId}
+	nmeta := meta
+	nmeta.ACL = opts.mapAcl(edi.defaultAcl(meta.ACL))
+	nmeta.EMId = uuid.New().String()
+	pkeys := edi.pkeys(Users(nmeta.ACL))
+	if len(pkeys) == 0 {
+		return Meta{}, fmt.Errorf("in rekeyEntry: no recipient for %s", meta.Path)
+	}
+	var (
+		cf    afero.File
+		csize int64
+	)
+	if meta.IsNs && len(meta.Pages) > 0 {
+		for _, page := range meta.Pages {
+			rr.EChs = append(rr.EChs, page.ECh)
+		}
+		// the pages are decrypted with the former ACL
+		if err := edi.unpageNsMeta(&meta); err != nil {
+			return Meta{}, fmt.Errorf("in rekeyEntry: %w", err)
+		}
+		nmeta.Children, nmeta.Pages = meta.Children, nil
+		if err := edi.pageNsMeta(ipath, &nmeta); err != nil {
+			return Meta{}, fmt.Errorf("in rekeyEntry: %w", err)
+		}
+	}
+	if !meta.IsNs && !meta.IsSymLink && meta.ECh != "" {
+		cusers := edi.contentUsers(meta)
+		nmeta.EUsers = nil
+		if opts.Content && !sameUsers(cusers, Users(nmeta.ACL)) {
+			rr.EChs = []string{meta.ECh}
+			sort.Strings(pkeys)
+			rr.Rcp = strings.Join(pkeys, ",")
+			if ech, ok := contents[meta.ECh+"|"+rr.Rcp]; ok {
+				nmeta.ECh = ech
+			} else {
+				var (
+					err error
+					ech string
+				)
+				if cf, csize, ech, err = edi.reencryptContent(meta, pkeys, secret); err != nil {
+					return Meta{}, fmt.Errorf("in rekeyEntry: %w", err)
+				}
+				defer func() {
+					cf.Close()
+					edi.getAfs().Remove(cf.Name())
+				}()
+				nmeta.ECh = ech
+				rr.NECh = ech
+			}
+		} else if !sameUsers(cusers, Users(nmeta.ACL)) {
+			nmeta.EUsers = cusers
+		}
+	}
+	mbs, err := edi.encodeMeta(nmeta)
+	if err != nil {
+		return Meta{}, fmt.Errorf("in rekeyEntry: %w", err)
+	}
+	embs, err := EncryptMsg(string(mbs), pkeys...)
+	if err != nil {
+		return Meta{}, fmt.Errorf("in rekeyEntry: %w", err)
+	}
+	if cf != nil {
+		err = edi.pushContent(csize, nmeta.ECh, embs, nmeta.EMId, cf)
+	} else {
+		err = edi.storeMeta(nmeta.EMId, MIN_TIME, embs)
+	}
+	if err != nil {
+		return Meta{}, fmt.Errorf("in rekeyEntry: %w", err)
+	}
+	// the former encrypted data is journaled before the index references the new one
+	if err = jnl.journal(rr); err != nil {
+		return Meta{}, fmt.Errorf("in rekeyEntry: %w", err)
+	}
+	if rr.NECh != "" {
+		contents[rr.EChs[0]+"|"+rr.Rcp] = rr.NECh
+	}
+	if err = edi.index.storeMeta(ipath, meta.Itime, mbs); err != nil {
+		return Meta{}, fmt.Errorf("in rekeyEntry: %w", err)
+	}
+	return nmeta, nil
+}
+
+// purgeRekeyed removes the former encrypted metas of the rekeyed entries
+// and their former encrypted content no longer referenced
+func (edi *eDssImpl) purgeRekeyed(jnl *rekeyJournal) error {
+	pix := edi.index.(*pIndex)
+	_, metas, _, err := pix.loadInMemory()
+	if err != nil {
+		return fmt.Errorf("in purgeRekeyed: %w", err)
+	}
+	used := map[string]bool{}
+	for _, mm := range metas {
+		for _, mbs := range mm {
+			var meta Meta
+			if err = json.Unmarshal(mbs, &meta); err != nil {
+				continue
+			}
+			used[meta.EMId] = true
+			used[meta.ECh] = true
+			for _, page := range meta.Pages {
+				used[page.ECh] = true
+			}
+		}
+	}
+	echs := map[string]bool{}
+	for _, rr := range jnl.records {
+		if jnl.purged[rr.EMId] || used[rr.EMId] {
+			// already purged or not rekeyed
+			continue
+		}
+		if err = cXRemoveMeta(edi.apc, rr.EMId, MIN_TIME); err != nil {
+			return fmt.Errorf("in purgeRekeyed: %w", err)
+		}
+		if err = edi.removeMeta(rr.EMId, MIN_TIME); err != nil {
+			return fmt.Errorf("in purgeRekeyed: %w", err)
+		}
+		if err = jnl.journal(rekeyRecord{EMId: rr.EMId, Purged: true}); err != nil {
+			return fmt.Errorf("in purgeRekeyed: %w", err)
+		}
+		for _, ech := range rr.EChs {
+			echs[ech] = true
+		}
+	}
+	for ech := range echs {
+		if used[ech] {
+			continue
+		}
+		if err = edi.removeContent(ech); err != nil {
+			return fmt.Errorf("in purgeRekeyed: %w", err)
+		}
+	}
+	return nil
+}
+
+// rekeyTags re-encrypts the tags for all identities but the revoked ones
+func (edi *eDssImpl) rekeyTags(opts RekeyOptions) error {
+	bs, err := edi.loadTags()
+	if err != nil || len(bs) == 0 {
+		return err
+	}
+	var pkeys []string
+	for _, pkey := range edi.allPkeys() {
+		if !opts.isRevoked(pkey) {
+			pkeys = append(pkeys, pkey)
+		}
+	}
+	ebs, err := EncryptMsg(string(bs), pkeys...)
+	if err != nil {
+		return fmt.Errorf("in rekeyTags: %w", err)
+	}
+	return edi.webDssImpl.storeTags(ebs)
+}
+
+func (edi *eDssImpl) rekey(npath string, recursive bool, opts RekeyOptions) (map[string][]HistoryInfo, error) {
+	if edi.lsttime != 0 {
+		return nil, fmt.Errorf("in Rekey: read-only DSS")
+	}
+	isDir, ipath, err := checkNCpath(npath)
+	if err != nil {
+		return nil, fmt.Errorf("in Rekey: %w", err)
+	}
+	for user, users := range opts.MapUsers {
+		for _, nuser := range users {
+			if len(edi.pkeys([]string{nuser})) == 0 {
+				return nil, fmt.Errorf("in Rekey: user %s replacing %s is not an identity of the configuration", nuser, user)
+			}
+		}
+	}
+	pix, ok := edi.index.(*pIndex)
+	if !ok {
+		return nil, fmt.Errorf("in Rekey: the DSS index is not persistent")
+	}
+	_, metas, _, err := pix.loadInMemory()
+	if err != nil {
+		return nil, fmt.Errorf("in Rekey: %w", err)
+	}
+	jnl, err := openRekeyJournal(opts.JournalDir, edi.repoId, npath)
+	if err != nil {
+		return nil, fmt.Errorf("in Rekey: %w", err)
+	}
+	var secret []byte
+	if opts.Content && edi.repoConvergent {
+		if secret, err = edi.convergenceSecret(); err != nil {
+			jnl.close(false)
+			return nil, fmt.Errorf("in Rekey: %w", err)
+		}
+	}
+	contents := jnl.contents()
+	var todo []Meta
+	for _, mm := range metas {
+		for _, mbs := range mm {
+			var meta Meta
+			if err = json.Unmarshal(mbs, &meta); err != nil {
+				jnl.close(false)
+				return nil, fmt.Errorf("in Rekey: %w", err)
+			}
+			if !isRekeyIn(ipath, isDir, recursive, RemoveSlashIfNsIf(meta.Path, meta.IsNs)) {
+				continue
+			}
+			acl := edi.defaultAcl(meta.ACL)
+			if CmpAcl(acl, opts.mapAcl(acl)) && (!opts.Content || len(meta.EUsers) == 0) {
+				continue
+			}
+			if !edi.hasWriteAcl(meta) {
+				jnl.close(false)
+				return nil, fmt.Errorf("in Rekey: %s read-only", meta.Path)
+			}
+			todo = append(todo, meta)
+		}
+	}
+	// in path and time order for a reproducible journal
+	sort.Slice(todo, func(i, j int) bool {
+		if todo[i].Path != todo[j].Path {
+			return todo[i].Path < todo[j].Path
+		}
+		return todo[i].Itime < todo[j].Itime
+	})
+	res := map[string][]HistoryInfo{}
+	for _, meta := range todo {
+		nmeta, err := edi.rekeyEntry(meta, opts, secret, contents, jnl)
+		if err != nil {
+			jnl.close(false)
+			return res, fmt.Errorf("in Rekey: %w", err)
+		}
+		res[meta.Path] = append(res[meta.Path], HistoryInfo{Start: meta.Itime, End: meta.Itime, HMeta: nmeta})
+	}
+	if ipath == "" && isDir && recursive {
+		if err = edi.rekeyTags(opts); err != nil {
+			jnl.close(false)
+			return res, fmt.Errorf("in Rekey: %w", err)
+		}
+	}
+	if opts.NoPurge {
+		return res, jnl.close(false)
+	}
+	if err = edi.purgeRekeyed(jnl); err != nil {
+		jnl.close(false)
+		return res, fmt.Errorf("in Rekey: %w", err)
+	}
+	return res, jnl.close(true)
+}
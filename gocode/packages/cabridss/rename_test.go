@@ -0,0 +1,180 @@
+package cabridss
+
+import (
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"testing"
+)
+
+func lsnsHas(dss Dss, npath string, child string) (bool, error) {
+	children, err := dss.Lsns(npath)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range children {
+		if c == child {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// runRenameTest expects a.txt and d/b.txt in dss
+func runRenameTest(dss Dss) error {
+	ma, err := dss.GetMeta("a.txt", true)
+	if err != nil {
+		return err
+	}
+	for _, sd := range [][]string{{"a.txt", "d/"}, {"d/", "e"}, {"d/", "d/e/"}, {"a.txt", "d/b.txt"}, {"a.txt", "a.txt"}, {"x.txt", "y.txt"}, {"", "f/"}} {
+		if err = dss.Rename(sd[0], sd[1], 12345); err == nil {
+			return fmt.Errorf("Rename %s to %s should fail", sd[0], sd[1])
+		}
+	}
+	if err = dss.Rename("a.txt", "d/c.txt", 12345); err != nil {
+		return err
+	}
+	if has, err := lsnsHas(dss, "", "a.txt"); err != nil || has {
+		return fmt.Errorf("Rename a.txt still listed %v", err)
+	}
+	if has, err := lsnsHas(dss, "d", "c.txt"); err != nil || !has {
+		return fmt.Errorf("Rename d/c.txt not listed %v", err)
+	}
+	if err = dss.Rename("d/", "e/", 12346); err != nil {
+		return err
+	}
+	if has, err := lsnsHas(dss, "", "d/"); err != nil || has {
+		return fmt.Errorf("Rename d/ still listed %v", err)
+	}
+	for _, c := range []string{"b.txt", "c.txt"} {
+		if has, err := lsnsHas(dss, "e", c); err != nil || !has {
+			return fmt.Errorf("Rename e/%s not listed %v", c, err)
+		}
+	}
+	meta, err := dss.GetMeta("e/c.txt", true)
+	if err != nil {
+		return err
+	}
+	if meta.GetChUnsafe() != ma.GetChUnsafe() || meta.GetSize() != ma.GetSize() {
+		return fmt.Errorf("renamed meta %+v differs from %+v", meta, ma)
+	}
+	rc, err := dss.GetContentReader("e/c.txt")
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	bs, err := io.ReadAll(rc)
+	if err != nil || int64(len(bs)) != ma.GetSize() {
+		return fmt.Errorf("renamed content size %d %v", len(bs), err)
+	}
+	return nil
+}
+
+func TestFsyRename(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestFsyRename", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	dss, err := NewFsyDss(FsyConfig{}, tfs.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = runRenameTest(dss); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOlfRename(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestOlfRename", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, []string{"a.txt", "d/"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = dss.Mkns("d", 0, []string{"b.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "a.txt", randBytes(1, 100000)); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "d/b.txt", randBytes(2, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err = runRenameTest(dss); err != nil {
+		t.Fatal(err)
+	}
+	sti, errs := dss.ScanStorage(true, false, false)
+	if errs != nil || len(sti.Path2Content) != 2 {
+		t.Fatalf("TestOlfRename ScanStorage %d contents %v", len(sti.Path2Content), errs)
+	}
+}
+
+func TestWfsDssRename(t *testing.T) {
+	if err := runWfsDssTest(t, func(tfs *testfs.Fs, dss Dss) error {
+		return runRenameTest(dss)
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEDssClientOlfRename(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs(t.Name(), tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(tfs, ":3000", "",
+		CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex, Encrypted: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	dss, err := NewEDss(
+		EDssConfig{
+			WebDssConfig: WebDssConfig{
+				DssBaseConfig: DssBaseConfig{
+					ConfigDir: ufpath.Join(tfs.Path(), ".cabri"),
+					WebPort:   "3000",
+				}},
+		},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if err = dss.Mkns("", 0, []string{"a.txt", "d/"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = dss.Mkns("d", 0, []string{"b.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "a.txt", randBytes(1, 100000)); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "d/b.txt", randBytes(2, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err = runRenameTest(dss); err != nil {
+		t.Fatal(err)
+	}
+}
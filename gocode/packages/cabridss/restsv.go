@@ -1,11 +1,14 @@
 package cabridss
 
 import (
+	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 func sRestGet(c echo.Context) error {
@@ -25,11 +28,13 @@ func sRestGet(c echo.Context) error {
 	if err != nil {
 		return NewServerErr("sRestGet", err)
 	}
-	dss := GetCustomConfig(c).(WebDssServerConfig).Dss
-	qm, ok := c.QueryParams()["meta"]
-	_, _ = qm, ok
-	var im IMeta
-	im, err = dss.GetMeta(path, true)
+	return sRestGetDss(c, GetCustomConfig(c).(WebDssServerConfig).Dss, path)
+}
+
+func sRestGetDss(c echo.Context, dss Dss, path string) error {
+	req := c.Request()
+	_, ok := c.QueryParams()["meta"]
+	im, err := dss.GetMeta(path, true)
 	if err != nil {
 		return c.JSON(http.StatusConflict, &mError{Error: err.Error()})
 	}
@@ -39,8 +44,12 @@ func sRestGet(c echo.Context) error {
 	if im.GetIsNs() {
 		return c.JSON(http.StatusOK, im.GetChildren())
 	}
+	if hr := req.Header.Get("Range"); hr != "" {
+		return sRestGetRange(c, dss, path, hr, im.GetSize())
+	}
 	resp := c.Response()
 	resp.Writer.Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	resp.Writer.Header().Set("Accept-Ranges", "bytes")
 	rder, err := dss.GetContentReader(path)
 	if err != nil {
 		return c.JSON(http.StatusConflict, &mError{Error: err.Error()})
@@ -51,6 +60,92 @@ func sRestGet(c echo.Context) error {
 	return nil
 }
 
+func sRestGetRange(c echo.Context, dss Dss, path string, hr string, size int64) error {
+	resp := c.Response()
+	offset, length, err := parseHttpRange(hr, size)
+	if err == nil {
+		length, err = checkRange(size, offset, length)
+	}
+	if err != nil {
+		resp.Writer.Header().Set("Content-Range", httpContentRange(0, 0, size))
+		return c.JSON(http.StatusRequestedRangeNotSatisfiable, &mError{Error: err.Error()})
+	}
+	rder, err := dss.GetContentRangeReader(path, offset, length)
+	if err != nil {
+		return c.JSON(http.StatusConflict, &mError{Error: err.Error()})
+	}
+	defer rder.Close()
+	resp.Writer.Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	resp.Writer.Header().Set(echo.HeaderContentLength, strconv.FormatInt(length, 10))
+	resp.Writer.Header().Set("Content-Range", httpContentRange(offset, length, size))
+	resp.WriteHeader(http.StatusPartialContent)
+	io.Copy(resp.Writer, rder)
+	return nil
+}
+
+// sRestGetAt serves the GET request from a read-only view of the DSS as of the time or tag given in the URL
+func sRestGetAt(c echo.Context) error {
+	var stime, path string
+	var err error
+	if err = echo.PathParamsBinder(c).String("time", &stime).String("path", &path).BindError(); err != nil {
+		return NewServerErr("sRestGetAt", err)
+	}
+	path, err = url.PathUnescape(path)
+	if err != nil {
+		return NewServerErr("sRestGetAt", err)
+	}
+	hdss := GetCustomConfig(c).(WebDssServerConfig).Dss
+	lsttime, err := internal.CheckTimeStamp(stime)
+	if err != nil && CheckTagName(stime) == nil {
+		lsttime, err = hdss.ResolveTag(stime)
+	}
+	if err != nil {
+		err = &ErrBadParameter{Key: "time", Value: internal.StringStringer(stime), Err: err}
+		return c.JSON(http.StatusUnprocessableEntity, &mError{Error: err.Error()})
+	}
+	dss, err := hdss.AtTime(lsttime)
+	if err != nil {
+		err = &ErrBadParameter{Key: "time", Value: internal.StringStringer(stime), Err: err}
+		return c.JSON(http.StatusUnprocessableEntity, &mError{Error: err.Error()})
+	}
+	return sRestGetDss(c, dss, path)
+}
+
+func sRestReadOnly(c echo.Context) error {
+	return c.JSON(http.StatusMethodNotAllowed, &mError{Error: "the DSS history is read-only"})
+}
+
+type mRestHistoryChunk struct {
+	Start string `json:"start"` // period start time RFC3339
+	End   string `json:"end"`   // period end time RFC3339, to be used to browse the DSS as of the end of the period
+	Count int    `json:"count"` // number of history updates in the time period
+}
+
+// sRestGetHistory lists the DSS activity periods with the resolution given in the query
+func sRestGetHistory(c echo.Context) error {
+	resolution := "s"
+	if err := echo.QueryParamsBinder(c).String("resolution", &resolution).BindError(); err != nil {
+		return NewServerErr("sRestGetHistory", err)
+	}
+	if resolution != "s" && resolution != "m" && resolution != "h" && resolution != "d" {
+		err := &ErrBadParameter{Key: "resolution", Value: internal.StringStringer(resolution), Err: fmt.Errorf("must be s, m, h or d")}
+		return c.JSON(http.StatusUnprocessableEntity, &mError{Error: err.Error()})
+	}
+	hcs, err := GetCustomConfig(c).(WebDssServerConfig).Dss.GetHistoryChunks(resolution)
+	if err != nil {
+		return c.JSON(http.StatusConflict, &mError{Error: err.Error()})
+	}
+	rfc3339 := func(t int64) string {
+		sec, nano := internal.Nano2SecNano(t)
+		return time.Unix(sec, nano).UTC().Format(time.RFC3339)
+	}
+	res := []mRestHistoryChunk{}
+	for _, hc := range hcs {
+		res = append(res, mRestHistoryChunk{Start: rfc3339(hc.Start), End: rfc3339(hc.End), Count: hc.Count})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
 func getUpdateQueryParams(c echo.Context) (mtime int64, acl []ACLEntry, err error) {
 	var smtime string
 	var sacl []string
@@ -103,12 +198,19 @@ func sRestPost(c echo.Context) error {
 	if err = echo.QueryParamsBinder(c).Strings("child", &children).BindError(); err != nil {
 		return err
 	}
-	var symlink string
-	if err = echo.QueryParamsBinder(c).String("symlink", &symlink).BindError(); err != nil {
+	var symlink, rename string
+	if err = echo.QueryParamsBinder(c).String("symlink", &symlink).String("rename", &rename).BindError(); err != nil {
 		return err
 	}
 	dss := GetCustomConfig(c).(WebDssServerConfig).Dss
-	if symlink != "" {
+	if rename != "" {
+		if mtime == 0 {
+			mtime = time.Now().Unix()
+		}
+		if err := dss.Rename(rename, path, mtime); err != nil {
+			return c.JSON(http.StatusConflict, &mError{Error: err.Error()})
+		}
+	} else if symlink != "" {
 		if err := dss.Symlink(path, symlink, mtime, acl); err != nil {
 			return c.JSON(http.StatusConflict, &mError{Error: err.Error()})
 		}
@@ -174,6 +276,12 @@ func sRestDelete(c echo.Context) error {
 func RestServerConfigurator(e *echo.Echo, root string, configs map[string]interface{}) error {
 	e.GET(root, sRestGet)
 	e.GET(root+":path", sRestGet)
+	e.GET(root+"@", sRestGetHistory)
+	e.GET(root+"@:time/", sRestGetAt)
+	e.GET(root+"@:time/:path", sRestGetAt)
+	readOnly := []string{http.MethodPost, http.MethodPut, http.MethodDelete}
+	e.Match(readOnly, root+"@:time/", sRestReadOnly)
+	e.Match(readOnly, root+"@:time/:path", sRestReadOnly)
 	e.POST(root, sRestPost)
 	e.POST(root+":path", sRestPost)
 	e.PUT(root+":path", sRestPut)
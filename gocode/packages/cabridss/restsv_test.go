@@ -0,0 +1,101 @@
+package cabridss
+
+import (
+	"encoding/json"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRestAtTime(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestRestAtTime", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1 := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC).Unix()
+	dss.SetCurrentTime(t1)
+	if err = dss.Mkns("", 0, []string{"a.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "a.txt", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	dss.SetCurrentTime(t1 + 24*3600)
+	if err = dss.Updatens("", 0, []string{"a.txt", "b.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "a.txt", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "b.txt", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	sv, err := NewRestServer("", WebDssServerConfig{WebServerConfig: WebServerConfig{Addr: ":3000"}, Dss: dss})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+
+	url := "http://localhost:3000/"
+	for _, c := range []struct {
+		method string
+		path   string
+		status int
+		rbs    string
+	}{
+		{http.MethodGet, "a.txt", http.StatusOK, "v2"},
+		{http.MethodGet, "@2024-05-01T12:00:00Z/a.txt", http.StatusOK, "v1"},
+		{http.MethodGet, "@2024-05-01T12:00:00Z/", http.StatusOK, "[\"a.txt\"]\n"},
+		{http.MethodGet, "@2024-05-02T12:00:00Z/", http.StatusOK, "[\"a.txt\",\"b.txt\"]\n"},
+		{http.MethodGet, "@1714564800/a.txt", http.StatusOK, "v1"},
+		{http.MethodGet, "@2024-05-01T12:00:00Z/b.txt", http.StatusConflict, ""},
+		{http.MethodGet, "@yesterday/a.txt", http.StatusUnprocessableEntity, ""},
+		{http.MethodPut, "@2024-05-01T12:00:00Z/a.txt", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "@?resolution=w", http.StatusUnprocessableEntity, ""},
+	} {
+		st, rbs, err := davRequest(c.method, url+c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st != c.status || (c.rbs != "" && string(rbs) != c.rbs) {
+			t.Fatalf("TestRestAtTime %s %s status %d body %s", c.method, c.path, st, rbs)
+		}
+	}
+
+	_, rbs, err := davRequest(http.MethodGet, url+"@?resolution=d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hcs []mRestHistoryChunk
+	if err = json.Unmarshal(rbs, &hcs); err != nil {
+		t.Fatal(err)
+	}
+	if len(hcs) != 1 || hcs[0].Start != "2024-05-01T00:00:00Z" || hcs[0].End != "2024-05-03T00:00:00Z" || hcs[0].Count != 5 {
+		t.Fatalf("TestRestAtTime history %v", hcs)
+	}
+
+	view, err := dss.AtTime(t1 + 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = view.GetContentWriter("a.txt", 0, nil, nil); err == nil {
+		t.Fatal("TestRestAtTime view is not read-only")
+	}
+	if err = view.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if children, err := dss.Lsns(""); err != nil || len(children) != 2 {
+		t.Fatalf("TestRestAtTime Lsns after view Close %v %v", children, err)
+	}
+}
@@ -0,0 +1,133 @@
+package cabridss
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionRule keeps history entries whose age is below Within, one per Period unless Period is "all"
+type RetentionRule struct {
+	Period string // "all", "hourly", "daily", "weekly", "monthly" or "yearly"
+	Within int64  // maximum age in nanoseconds of the entries to which the rule applies, zero meaning forever
+}
+
+func (rr RetentionRule) String() string {
+	if rr.Within == 0 {
+		return fmt.Sprintf("%s:forever", rr.Period)
+	}
+	return fmt.Sprintf("%s:%dh", rr.Period, rr.Within/int64(time.Hour))
+}
+
+// RetentionPolicy is a grandfather-father-son list of rules, the first rule applying to an entry age wins,
+// entries older than any rule are dropped
+type RetentionPolicy []RetentionRule
+
+func (rp RetentionPolicy) String() string {
+	var srs []string
+	for _, rr := range rp {
+		srs = append(srs, rr.String())
+	}
+	return strings.Join(srs, ",")
+}
+
+var retentionPeriods = map[string]string{
+	"all":     "",
+	"hourly":  "2006010215",
+	"daily":   "20060102",
+	"weekly":  "",
+	"monthly": "200601",
+	"yearly":  "2006",
+}
+
+func retentionDuration(sd string) (int64, error) {
+	if sd == "forever" {
+		return 0, nil
+	}
+	units := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour, 'm': 30 * 24 * time.Hour, 'y': 365 * 24 * time.Hour}
+	if len(sd) < 2 {
+		return 0, fmt.Errorf("invalid duration %s", sd)
+	}
+	unit, ok := units[sd[len(sd)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid duration unit in %s (must be h, d, w, m or y)", sd)
+	}
+	n, err := strconv.Atoi(sd[:len(sd)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid duration %s", sd)
+	}
+	return int64(n) * int64(unit), nil
+}
+
+// ParseRetentionPolicy parses a comma-separated list of period:duration rules
+//
+// period is one of all, hourly, daily, weekly, monthly or yearly,
+// duration is a positive number followed by h, d, w, m (30 days) or y (365 days), or forever,
+// for instance "all:48h,daily:30d,weekly:1y,monthly:forever"
+func ParseRetentionPolicy(spec string) (RetentionPolicy, error) {
+	var rp RetentionPolicy
+	for _, sr := range strings.Split(spec, ",") {
+		items := strings.Split(strings.TrimSpace(sr), ":")
+		if len(items) != 2 {
+			return nil, fmt.Errorf("in ParseRetentionPolicy: invalid rule %s (must be period:duration)", sr)
+		}
+		if _, ok := retentionPeriods[items[0]]; !ok {
+			return nil, fmt.Errorf("in ParseRetentionPolicy: invalid period %s (must be all, hourly, daily, weekly, monthly or yearly)", items[0])
+		}
+		within, err := retentionDuration(items[1])
+		if err != nil {
+			return nil, fmt.Errorf("in ParseRetentionPolicy: %w", err)
+		}
+		if len(rp) > 0 && (rp[len(rp)-1].Within == 0 || (within != 0 && within <= rp[len(rp)-1].Within)) {
+			return nil, fmt.Errorf("in ParseRetentionPolicy: rule %s must apply to older entries than the previous one", sr)
+		}
+		rp = append(rp, RetentionRule{Period: items[0], Within: within})
+	}
+	return rp, nil
+}
+
+func retentionBucket(period string, t int64) string {
+	ut := time.Unix(0, t).UTC()
+	if period == "weekly" {
+		y, w := ut.ISOWeek()
+		return fmt.Sprintf("%d-%d", y, w)
+	}
+	return ut.Format(retentionPeriods[period])
+}
+
+// Drop returns the history entries of a single DSS entry, sorted by start as returned by GetHistory,
+// which are not kept by the policy at time now (ns)
+//
+// the current entry is always kept, the age of an entry is measured from its start,
+// and the latest entry started in each period is kept, the one which was visible at the end of the period
+func (rp RetentionPolicy) Drop(his []HistoryInfo, now int64) []HistoryInfo {
+	var drop []HistoryInfo
+	kept := map[string]int{}
+	for i, hi := range his {
+		current := hi.End == MAX_TIME
+		age := now - hi.Start
+		ri := -1
+		for j, rr := range rp {
+			if rr.Within == 0 || age <= rr.Within {
+				ri = j
+				break
+			}
+		}
+		if ri == -1 {
+			if !current {
+				drop = append(drop, hi)
+			}
+			continue
+		}
+		if rp[ri].Period == "all" {
+			continue
+		}
+		bucket := fmt.Sprintf("%d-%s", ri, retentionBucket(rp[ri].Period, hi.Start))
+		if prev, ok := kept[bucket]; ok {
+			drop = append(drop, his[prev])
+		}
+		kept[bucket] = i
+	}
+	return drop
+}
@@ -0,0 +1,89 @@
+package cabridss
+
+import (
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"testing"
+	"time"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	rp, err := ParseRetentionPolicy("all:48h,daily:30d,weekly:1y,monthly:forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.String() != "all:48h,daily:720h,weekly:8760h,monthly:forever" {
+		t.Fatal(rp)
+	}
+	for _, spec := range []string{"", "all", "often:1d", "all:1s", "all:0d", "all:2d,daily:1d", "all:forever,daily:1d"} {
+		if _, err = ParseRetentionPolicy(spec); err == nil {
+			t.Fatalf("TestParseRetentionPolicy %s should fail", spec)
+		}
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestApplyRetention", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	// ten daily versions of a.txt from Wednesday 2024-05-01
+	t1 := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC).Unix()
+	dss.SetCurrentTime(t1)
+	if err = dss.Mkns("", 0, []string{"a.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		dss.SetCurrentTime(t1 + int64(i)*24*3600)
+		if err = writeTestContent(dss, "a.txt", []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rp, err := ParseRetentionPolicy("all:2d,weekly:forever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := t1 + 9*24*3600 + 3600
+	mHes, err := dss.ApplyRetention("", true, true, false, rp, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mHes) != 1 || len(mHes["a.txt"]) != 6 {
+		t.Fatalf("TestApplyRetention evaluate %v", mHes)
+	}
+	if mHes, err = dss.GetHistory("a.txt", false, "s"); err != nil || len(mHes["a.txt"]) != 10 {
+		t.Fatalf("TestApplyRetention evaluate removed %v %v", mHes, err)
+	}
+	if _, err = dss.ApplyRetention("", true, false, false, rp, now); err != nil {
+		t.Fatal(err)
+	}
+	if mHes, err = dss.GetHistory("a.txt", false, "s"); err != nil || len(mHes["a.txt"]) != 4 {
+		t.Fatalf("TestApplyRetention history %v %v", mHes, err)
+	}
+	// the latest versions of each week, the one of yesterday and the current one are kept
+	for i, hi := range mHes["a.txt"] {
+		if hi.HMeta.Size != 2 || UnixUTC(hi.Start).String()[:10] != []string{"2024-05-05", "2024-05-08", "2024-05-09", "2024-05-10"}[i] {
+			t.Fatalf("TestApplyRetention history %v", mHes)
+		}
+	}
+	if mHes, err = dss.ApplyRetention("", true, false, false, rp, now); err != nil || len(mHes) != 0 {
+		t.Fatalf("TestApplyRetention again %v %v", mHes, err)
+	}
+	if _, errs := dss.ScanStorage(false, true, false); errs == nil || len(*errs) != 6 {
+		t.Fatalf("TestApplyRetention ScanStorage should report 6 unused contents %v", errs)
+	}
+	if _, errs := dss.ScanStorage(true, false, false); errs != nil {
+		t.Fatalf("TestApplyRetention ScanStorage after purge %v", errs)
+	}
+}
@@ -2,6 +2,10 @@ package cabridss
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -9,8 +13,10 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/google/uuid"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
 	"io"
+	"io/fs"
 	"os"
 	"strings"
 	"sync"
@@ -21,6 +27,28 @@ type S3Meta struct {
 	Length int64
 }
 
+// S3Part is an uploaded part of a multipart upload
+type S3Part struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
+// S3Multipart is a pending multipart upload
+type S3Multipart struct {
+	Key       string
+	UploadId  string
+	Initiated time.Time
+}
+
+const (
+	S3_MIN_PART_SIZE        = 5 * 1024 * 1024  // S3 minimum size of all parts but the last one
+	S3_MAX_PARTS            = 10000            // S3 maximum number of parts of a multipart upload
+	OBS_MULTIPART_THRESHOLD = 64 * 1024 * 1024 // default content size above which a multipart upload is used
+	OBS_MULTIPART_PART_SIZE = 16 * 1024 * 1024 // default multipart upload part size
+	OBS_PART_RETRIES        = 3                // number of attempts to upload a part
+)
+
 type IS3Session interface {
 	Initialize() error
 	Check() error
@@ -30,8 +58,113 @@ type IS3Session interface {
 	Get(key string) ([]byte, error)
 	Upload(key string, r io.Reader) error
 	Download(key string) (io.ReadCloser, error)
+	DownloadRange(key string, offset, length int64) (io.ReadCloser, error)
 	Delete(key string) error
 	DeleteAll(prefix string) error
+	CreateMultipart(key string) (string, error)
+	UploadPart(key, uploadId string, number int, content []byte) (string, error)
+	CompleteMultipart(key, uploadId string, parts []S3Part) error
+	AbortMultipart(key, uploadId string) error
+	ListMultiparts(prefix string) ([]S3Multipart, error)
+	ListParts(key, uploadId string) ([]S3Part, error)
+}
+
+func partETag(content []byte) string {
+	h := md5.Sum(content)
+	return hex.EncodeToString(h[:])
+}
+
+// resumableMultipart returns the most recent pending upload of key and its uploaded parts by number,
+// or creates a new upload if none
+func resumableMultipart(is3 IS3Session, key string) (string, map[int]S3Part, error) {
+	mps, err := is3.ListMultiparts(key)
+	if err != nil {
+		return "", nil, err
+	}
+	var last *S3Multipart
+	for i, mp := range mps {
+		if mp.Key == key && (last == nil || mp.Initiated.After(last.Initiated)) {
+			last = &mps[i]
+		}
+	}
+	if last != nil {
+		if parts, err := is3.ListParts(key, last.UploadId); err == nil {
+			done := map[int]S3Part{}
+			for _, p := range parts {
+				done[p.Number] = p
+			}
+			return last.UploadId, done, nil
+		}
+	}
+	uploadId, err := is3.CreateMultipart(key)
+	return uploadId, nil, err
+}
+
+// CheckMultipartPartSize checks a multipart upload part size, 0 meaning the default OBS_MULTIPART_PART_SIZE
+func CheckMultipartPartSize(partSize int64) error {
+	if partSize != 0 && partSize < S3_MIN_PART_SIZE {
+		return fmt.Errorf("multipart part size %d is invalid (must be at least %d)", partSize, S3_MIN_PART_SIZE)
+	}
+	return nil
+}
+
+// multipartPartSize returns the part size for uploading size bytes, at least partSize
+// but large enough for the upload not to exceed S3_MAX_PARTS parts
+func multipartPartSize(size, partSize int64) int64 {
+	return max(partSize, S3_MIN_PART_SIZE, (size+S3_MAX_PARTS-1)/S3_MAX_PARTS)
+}
+
+// resumedPartSize returns the part size of a resumed upload, given by its first part if not the last one,
+// or 0 if it cannot be used for uploading size bytes
+func resumedPartSize(done map[int]S3Part, size int64) int64 {
+	first, ok := done[1]
+	if !ok || first.Size >= size || first.Size < multipartPartSize(size, 0) {
+		return 0
+	}
+	return first.Size
+}
+
+// UploadMultipart uploads the size bytes of r as object key in parts of partSize bytes,
+// the part size being increased if needed as computed by multipartPartSize
+// each part is attempted OBS_PART_RETRIES times, on failure the upload is left pending
+// so that a later UploadMultipart of the same key resumes it, skipping the parts already uploaded
+// and keeping the part size of the pending upload
+func UploadMultipart(is3 IS3Session, key string, r io.ReaderAt, size, partSize int64) error {
+	partSize = multipartPartSize(size, partSize)
+	uploadId, done, err := resumableMultipart(is3, key)
+	if err != nil {
+		return fmt.Errorf("in UploadMultipart: %w", err)
+	}
+	if rps := resumedPartSize(done, size); rps != 0 {
+		partSize = rps
+	}
+	var parts []S3Part
+	bs := make([]byte, partSize)
+	for number, offset := 1, int64(0); offset < size; number, offset = number+1, offset+partSize {
+		pbs := bs[:min(partSize, size-offset)]
+		if _, err = r.ReadAt(pbs, offset); err != nil && err != io.EOF {
+			return fmt.Errorf("in UploadMultipart: %w", err)
+		}
+		part := S3Part{Number: number, ETag: partETag(pbs), Size: int64(len(pbs))}
+		if dp, ok := done[number]; ok && dp == part {
+			parts = append(parts, part)
+			continue
+		}
+		for attempt := 1; ; attempt++ {
+			if part.ETag, err = is3.UploadPart(key, uploadId, number, pbs); err == nil {
+				break
+			}
+			if attempt == OBS_PART_RETRIES {
+				return fmt.Errorf("in UploadMultipart: part %d: %w", number, err)
+			}
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		parts = append(parts, part)
+	}
+	if err = is3.CompleteMultipart(key, uploadId, parts); err != nil {
+		return fmt.Errorf("in UploadMultipart: %w", err)
+	}
+	return nil
 }
 
 func (s3s *s3Session) Initialize() error {
@@ -209,6 +342,33 @@ func (s3s *s3Session) Download(key string) (io.ReadCloser, error) {
 	return res.Body, nil
 }
 
+// DownloadRange downloads length bytes of the object from offset, up to its end if length is negative
+func (s3s *s3Session) DownloadRange(key string, offset, length int64) (io.ReadCloser, error) {
+	rg := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		rg = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+	res, err := s3s.s3Svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s3s.config.Container),
+		Key:    aws.String(key),
+		Range:  aws.String(rg),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s3s.mock != nil {
+		rc, err := s3s.mock.DownloadRange(key, offset, length)
+		if err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+		if rc != nil {
+			rc.Close()
+		}
+	}
+	return res.Body, nil
+}
+
 func (s3s *s3Session) Delete(key string) error {
 	if _, err := s3s.s3Svc.DeleteObject(&s3.DeleteObjectInput{
 		Bucket: aws.String(s3s.config.Container),
@@ -268,6 +428,100 @@ func (s3s *s3Session) DeleteAll(prefix string) error {
 	return nil
 }
 
+func (s3s *s3Session) CreateMultipart(key string) (string, error) {
+	res, err := s3s.s3Svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s3s.config.Container),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	return *res.UploadId, nil
+}
+
+func (s3s *s3Session) UploadPart(key, uploadId string, number int, content []byte) (string, error) {
+	res, err := s3s.s3Svc.UploadPart(&s3.UploadPartInput{
+		Bucket:     aws.String(s3s.config.Container),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadId),
+		PartNumber: aws.Int64(int64(number)),
+		Body:       bytes.NewReader(content),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(*res.ETag, "\""), nil
+}
+
+func (s3s *s3Session) CompleteMultipart(key, uploadId string, parts []S3Part) error {
+	var cps []*s3.CompletedPart
+	for _, p := range parts {
+		cps = append(cps, &s3.CompletedPart{ETag: aws.String(p.ETag), PartNumber: aws.Int64(int64(p.Number))})
+	}
+	if _, err := s3s.s3Svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s3s.config.Container),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: cps},
+	}); err != nil {
+		return err
+	}
+	if s3s.mock != nil {
+		// the mock only mirrors the resulting object
+		rc, err := s3s.Download(key)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return s3s.mock.Upload(key, rc)
+	}
+	return nil
+}
+
+func (s3s *s3Session) AbortMultipart(key, uploadId string) error {
+	_, err := s3s.s3Svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s3s.config.Container),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+	return err
+}
+
+func (s3s *s3Session) ListMultiparts(prefix string) ([]S3Multipart, error) {
+	var res []S3Multipart
+	err := s3s.s3Svc.ListMultipartUploadsPages(&s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s3s.config.Container),
+		Prefix: aws.String(prefix),
+	}, func(output *s3.ListMultipartUploadsOutput, b bool) bool {
+		for _, u := range output.Uploads {
+			res = append(res, S3Multipart{Key: *u.Key, UploadId: *u.UploadId, Initiated: *u.Initiated})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s3s *s3Session) ListParts(key, uploadId string) ([]S3Part, error) {
+	var res []S3Part
+	err := s3s.s3Svc.ListPartsPages(&s3.ListPartsInput{
+		Bucket:   aws.String(s3s.config.Container),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	}, func(output *s3.ListPartsOutput, b bool) bool {
+		for _, p := range output.Parts {
+			res = append(res, S3Part{Number: int(*p.PartNumber), ETag: strings.Trim(*p.ETag, "\""), Size: *p.Size})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 type s3Session struct {
 	getMock func(IS3Session) IS3Session
 	mock    IS3Session
@@ -299,6 +553,9 @@ func (s3m *s3sMockFs) List(prefix string) ([]string, error) {
 			return nil, fmt.Errorf("in List: %w", err)
 		}
 		for _, fi := range tfi {
+			if fi.IsDir() {
+				continue
+			}
 			s3m.cache[fi.Name()] = true
 		}
 	}
@@ -418,6 +675,28 @@ func (s3m *s3sMockFs) Download(key string) (io.ReadCloser, error) {
 	return rc, nil
 }
 
+func (s3m *s3sMockFs) DownloadRange(key string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(ufpath.Join(s3m.root, key))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if s3m.mock != nil {
+		mrc, err := s3m.mock.DownloadRange(key, offset, length)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if mrc != nil {
+			mrc.Close()
+		}
+	}
+	return newLimitReadCloser(f, length), nil
+}
+
 func (s3m *s3sMockFs) Delete(key string) error {
 	s3m.lock.Lock()
 	defer s3m.lock.Unlock()
@@ -457,6 +736,156 @@ func (s3m *s3sMockFs) DeleteAll(prefix string) error {
 	return nil
 }
 
+type s3sMockMultipart struct {
+	Key       string    `json:"key"`
+	Initiated time.Time `json:"initiated"`
+}
+
+func (s3m *s3sMockFs) multipartDir(uploadId string) string {
+	return ufpath.Join(s3m.root, ".multipart", uploadId)
+}
+
+func (s3m *s3sMockFs) loadMultipart(key, uploadId string) error {
+	bs, err := os.ReadFile(ufpath.Join(s3m.multipartDir(uploadId), "upload"))
+	if err != nil {
+		return fmt.Errorf("no such upload %s: %w", uploadId, err)
+	}
+	var smm s3sMockMultipart
+	if err = json.Unmarshal(bs, &smm); err != nil {
+		return err
+	}
+	if smm.Key != key {
+		return fmt.Errorf("upload %s key %s is not %s", uploadId, smm.Key, key)
+	}
+	return nil
+}
+
+func (s3m *s3sMockFs) CreateMultipart(key string) (string, error) {
+	uploadId := uuid.New().String()
+	if err := os.MkdirAll(s3m.multipartDir(uploadId), 0o777); err != nil {
+		return "", err
+	}
+	bs, err := json.Marshal(s3sMockMultipart{Key: key, Initiated: time.Now()})
+	if err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(ufpath.Join(s3m.multipartDir(uploadId), "upload"), bs, 0o666); err != nil {
+		return "", err
+	}
+	if s3m.mock != nil {
+		if _, err = s3m.mock.CreateMultipart(key); err != nil {
+			return "", err
+		}
+	}
+	return uploadId, nil
+}
+
+func (s3m *s3sMockFs) UploadPart(key, uploadId string, number int, content []byte) (string, error) {
+	if err := s3m.loadMultipart(key, uploadId); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(ufpath.Join(s3m.multipartDir(uploadId), fmt.Sprintf("part-%05d", number)), content, 0o666); err != nil {
+		return "", err
+	}
+	if s3m.mock != nil {
+		if _, err := s3m.mock.UploadPart(key, uploadId, number, content); err != nil {
+			return "", err
+		}
+	}
+	return partETag(content), nil
+}
+
+func (s3m *s3sMockFs) CompleteMultipart(key, uploadId string, parts []S3Part) error {
+	if err := s3m.loadMultipart(key, uploadId); err != nil {
+		return err
+	}
+	readers := make([]io.Reader, 0, len(parts))
+	for _, p := range parts {
+		f, err := os.Open(ufpath.Join(s3m.multipartDir(uploadId), fmt.Sprintf("part-%05d", p.Number)))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	if err := s3m.Upload(key, io.MultiReader(readers...)); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s3m.multipartDir(uploadId)); err != nil {
+		return err
+	}
+	if s3m.mock != nil {
+		return s3m.mock.CompleteMultipart(key, uploadId, parts)
+	}
+	return nil
+}
+
+func (s3m *s3sMockFs) AbortMultipart(key, uploadId string) error {
+	if err := s3m.loadMultipart(key, uploadId); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s3m.multipartDir(uploadId)); err != nil {
+		return err
+	}
+	if s3m.mock != nil {
+		return s3m.mock.AbortMultipart(key, uploadId)
+	}
+	return nil
+}
+
+func (s3m *s3sMockFs) ListMultiparts(prefix string) ([]S3Multipart, error) {
+	des, err := os.ReadDir(ufpath.Join(s3m.root, ".multipart"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	var res []S3Multipart
+	for _, de := range des {
+		bs, err := os.ReadFile(ufpath.Join(s3m.multipartDir(de.Name()), "upload"))
+		if err != nil {
+			continue
+		}
+		var smm s3sMockMultipart
+		if err = json.Unmarshal(bs, &smm); err != nil || !strings.HasPrefix(smm.Key, prefix) {
+			continue
+		}
+		res = append(res, S3Multipart{Key: smm.Key, UploadId: de.Name(), Initiated: smm.Initiated})
+	}
+	if s3m.mock != nil {
+		if _, err = s3m.mock.ListMultiparts(prefix); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s3m *s3sMockFs) ListParts(key, uploadId string) ([]S3Part, error) {
+	if err := s3m.loadMultipart(key, uploadId); err != nil {
+		return nil, err
+	}
+	des, err := os.ReadDir(s3m.multipartDir(uploadId))
+	if err != nil {
+		return nil, err
+	}
+	var res []S3Part
+	for _, de := range des {
+		var number int
+		if _, err := fmt.Sscanf(de.Name(), "part-%05d", &number); err != nil {
+			continue
+		}
+		bs, err := os.ReadFile(ufpath.Join(s3m.multipartDir(uploadId), de.Name()))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, S3Part{Number: number, ETag: partETag(bs), Size: int64(len(bs))})
+	}
+	if s3m.mock != nil {
+		if _, err = s3m.mock.ListParts(key, uploadId); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
 type s3sMockFs struct {
 	getMock func(IS3Session) IS3Session
 	root    string
@@ -519,6 +948,13 @@ func (s3t s3sMockTests) Download(key string) (io.ReadCloser, error) {
 	return nil, nil
 }
 
+func (s3t s3sMockTests) DownloadRange(key string, offset, length int64) (io.ReadCloser, error) {
+	if err := s3t.testsCb(s3t, "DownloadRange", key, offset, length); err != nil {
+		return nil, err.(error)
+	}
+	return nil, nil
+}
+
 func (s3t s3sMockTests) Delete(key string) error {
 	if err := s3t.testsCb(s3t, "Delete", key); err != nil {
 		return err.(error)
@@ -533,6 +969,48 @@ func (s3t s3sMockTests) DeleteAll(prefix string) error {
 	return nil
 }
 
+func (s3t s3sMockTests) CreateMultipart(key string) (string, error) {
+	if err := s3t.testsCb(s3t, "CreateMultipart", key); err != nil {
+		return "", err.(error)
+	}
+	return "", nil
+}
+
+func (s3t s3sMockTests) UploadPart(key, uploadId string, number int, content []byte) (string, error) {
+	if err := s3t.testsCb(s3t, "UploadPart", key, number); err != nil {
+		return "", err.(error)
+	}
+	return "", nil
+}
+
+func (s3t s3sMockTests) CompleteMultipart(key, uploadId string, parts []S3Part) error {
+	if err := s3t.testsCb(s3t, "CompleteMultipart", key, parts); err != nil {
+		return err.(error)
+	}
+	return nil
+}
+
+func (s3t s3sMockTests) AbortMultipart(key, uploadId string) error {
+	if err := s3t.testsCb(s3t, "AbortMultipart", key, uploadId); err != nil {
+		return err.(error)
+	}
+	return nil
+}
+
+func (s3t s3sMockTests) ListMultiparts(prefix string) ([]S3Multipart, error) {
+	if err := s3t.testsCb(s3t, "ListMultiparts", prefix); err != nil {
+		return nil, err.(error)
+	}
+	return nil, nil
+}
+
+func (s3t s3sMockTests) ListParts(key, uploadId string) ([]S3Part, error) {
+	if err := s3t.testsCb(s3t, "ListParts", key, uploadId); err != nil {
+		return nil, err.(error)
+	}
+	return nil, nil
+}
+
 type s3sMockTests struct {
 	parent  IS3Session
 	testsCb func(args ...any) interface{}
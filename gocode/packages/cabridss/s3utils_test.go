@@ -21,3 +21,35 @@ func TestS3SessionList(t *testing.T) {
 		println(k, m.Length)
 	}
 }
+
+func TestMultipartPartSize(t *testing.T) {
+	const mb = 1024 * 1024
+	for _, c := range []struct {
+		size, partSize, expected int64
+	}{
+		{100 * mb, OBS_MULTIPART_PART_SIZE, OBS_MULTIPART_PART_SIZE},
+		{100 * mb, 0, S3_MIN_PART_SIZE},
+		{S3_MAX_PARTS * OBS_MULTIPART_PART_SIZE, OBS_MULTIPART_PART_SIZE, OBS_MULTIPART_PART_SIZE},
+		{S3_MAX_PARTS*OBS_MULTIPART_PART_SIZE + 1, OBS_MULTIPART_PART_SIZE, OBS_MULTIPART_PART_SIZE + 1},
+		{1024 * 1024 * mb, OBS_MULTIPART_PART_SIZE, (1024*1024*mb + S3_MAX_PARTS - 1) / S3_MAX_PARTS},
+	} {
+		ps := multipartPartSize(c.size, c.partSize)
+		if ps != c.expected || (c.size+ps-1)/ps > S3_MAX_PARTS {
+			t.Fatalf("TestMultipartPartSize size %d part size %d: %d expected %d", c.size, c.partSize, ps, c.expected)
+		}
+	}
+	size := int64(200 * mb)
+	if rps := resumedPartSize(map[int]S3Part{1: {Number: 1, Size: 32 * mb}, 2: {Number: 2, Size: 32 * mb}}, size); rps != 32*mb {
+		t.Fatalf("TestMultipartPartSize resumed part size %d", rps)
+	}
+	for _, done := range []map[int]S3Part{
+		nil,
+		{2: {Number: 2, Size: 32 * mb}},
+		{1: {Number: 1, Size: size}},
+		{1: {Number: 1, Size: mb}},
+	} {
+		if rps := resumedPartSize(done, size); rps != 0 {
+			t.Fatalf("TestMultipartPartSize resumed part size %d for %v", rps, done)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package cabridss
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// stored meta data may be signed with the ed25519 signing key of the identity writing them:
+// Signer is then the signing public key and Sig the signature of the JSON meta data without Sig.
+// The signing identity is the first ACL user identity having a signing key, or else the default identity.
+//
+// The trust list of accepted signers is configured per repository in the client configuration,
+// the signing keys of the client identities being always trusted.
+// With the "flag" policy, unsigned and untrusted meta data are reported by AuditIndex and ScanStorage,
+// with the "reject" policy they cannot be read either.
+
+const (
+	TrustPolicyFlag   = "flag"
+	TrustPolicyReject = "reject"
+)
+
+// RepoTrust is the trust list of a repository
+type RepoTrust struct {
+	Policy  string   `json:"policy"`  // TrustPolicyFlag or TrustPolicyReject
+	Signers []string `json:"signers"` // trusted signing public keys
+}
+
+// GenSigningKey adds a new ed25519 signing key to an identity
+func GenSigningKey(idc IdentityConfig) (IdentityConfig, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return idc, fmt.Errorf("in GenSigningKey: %w", err)
+	}
+	idc.SignPKey = base64.StdEncoding.EncodeToString(pub)
+	idc.SignKey = base64.StdEncoding.EncodeToString(priv.Seed())
+	return idc, nil
+}
+
+// CheckSignPKey checks a signing public key encoded as a string
+func CheckSignPKey(spk string) error {
+	if pub, err := base64.StdEncoding.DecodeString(spk); err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid signing public key %s", spk)
+	}
+	return nil
+}
+
+// VerifyMetaSignature returns the signer of a meta data, or "" if it is unsigned,
+// and an error if the signature is invalid
+func VerifyMetaSignature(meta Meta) (string, error) {
+	if meta.Sig == "" {
+		return "", nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(meta.Sig)
+	if err != nil {
+		return meta.Signer, fmt.Errorf("in VerifyMetaSignature: %w", err)
+	}
+	if err = CheckSignPKey(meta.Signer); err != nil {
+		return meta.Signer, fmt.Errorf("in VerifyMetaSignature: %w", err)
+	}
+	pub, _ := base64.StdEncoding.DecodeString(meta.Signer)
+	meta.Sig = ""
+	bs, err := json.Marshal(meta)
+	if err != nil {
+		return meta.Signer, fmt.Errorf("in VerifyMetaSignature: %w", err)
+	}
+	if !ed25519.Verify(pub, bs, sig) {
+		return meta.Signer, fmt.Errorf("in VerifyMetaSignature: invalid signature of %s by %s", meta.Path, meta.Signer)
+	}
+	return meta.Signer, nil
+}
+
+// setSigning sets the signing key and the repository trust list from the user configuration
+func (odbi *oDssBaseImpl) setSigning(uc UserConfig) error {
+	odbi.signKey, odbi.signPKey, odbi.trust = nil, "", nil
+	idcs := []IdentityConfig{}
+	for _, user := range odbi.aclusers {
+		for _, idc := range uc.Identities {
+			if idc.PKey == user || idc.Alias == user {
+				idcs = append(idcs, idc)
+			}
+		}
+	}
+	idcs = append(idcs, uc.GetIdentity(""))
+	for _, idc := range idcs {
+		if idc.SignKey == "" {
+			continue
+		}
+		seed, err := base64.StdEncoding.DecodeString(idc.SignKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return fmt.Errorf("in setSigning: invalid signing key for identity %s", idc.Alias)
+		}
+		odbi.signKey = ed25519.NewKeyFromSeed(seed)
+		odbi.signPKey = base64.StdEncoding.EncodeToString(odbi.signKey.Public().(ed25519.PublicKey))
+		break
+	}
+	rt, ok := uc.Trusts[odbi.repoId]
+	if odbi.repoId == "" || !ok || rt.Policy == "" {
+		return nil
+	}
+	odbi.trust = map[string]bool{}
+	for _, signer := range rt.Signers {
+		odbi.trust[signer] = true
+	}
+	for _, idc := range uc.Identities {
+		if idc.SignKey != "" && idc.SignPKey != "" {
+			odbi.trust[idc.SignPKey] = true
+		}
+	}
+	odbi.trustReject = rt.Policy == TrustPolicyReject
+	return nil
+}
+
+// encodeMeta returns the JSON meta data, signed if a signing key is configured
+func (odbi *oDssBaseImpl) encodeMeta(meta Meta) ([]byte, error) {
+	meta.Signer, meta.Sig = "", ""
+	if odbi.signKey != nil {
+		meta.Signer = odbi.signPKey
+		bs, err := json.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+		meta.Sig = base64.StdEncoding.EncodeToString(ed25519.Sign(odbi.signKey, bs))
+	}
+	if odbi.metamockcbs != nil && odbi.metamockcbs.MockMarshal != nil {
+		return odbi.metamockcbs.MockMarshal(meta)
+	}
+	return json.Marshal(meta)
+}
+
+// checkMetaTrust returns an error if a trust policy is configured and meta is unsigned, badly signed or untrusted
+func (odbi *oDssBaseImpl) checkMetaTrust(meta Meta) error {
+	if odbi.trust == nil {
+		return nil
+	}
+	signer, err := VerifyMetaSignature(meta)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err.Error(), ErrUntrustedMeta)
+	}
+	if signer == "" {
+		return fmt.Errorf("%s is not signed: %w", meta.Path, ErrUntrustedMeta)
+	}
+	if !odbi.trust[signer] {
+		return fmt.Errorf("%s is signed by %s: %w", meta.Path, signer, ErrUntrustedMeta)
+	}
+	return nil
+}
+
+// checkReadTrust returns an error if meta cannot be read with the "reject" trust policy
+func (odbi *oDssBaseImpl) checkReadTrust(meta Meta) error {
+	if !odbi.trustReject {
+		return nil
+	}
+	return odbi.checkMetaTrust(meta)
+}
@@ -0,0 +1,132 @@
+package cabridss
+
+import (
+	"errors"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestOlfSignedMeta(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestOlfSignedMeta", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	cfg, root := ufpath.Join(tfs.Path(), "cfg"), ufpath.Join(tfs.Path(), "olf")
+	if err = os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	uc, err := GetUserConfig(DssBaseConfig{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idc, err := GenSigningKey(uc.GetIdentity(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = UserConfigPutIdentity(DssBaseConfig{}, cfg, idc); err != nil {
+		t.Fatal(err)
+	}
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(root, "index.bdb"), false, false)
+	}
+	olfConfig := func(configDir string) OlfConfig {
+		return OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: root, ConfigDir: configDir, GetIndex: getIndex}, Root: root, Size: "s"}
+	}
+
+	dss, err := CreateOlfDss(olfConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoId := dss.GetRepoId()
+	if err = dss.Mkns("", 0, []string{"a.txt", "b.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "a.txt", []byte("TestOlfSignedMeta a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := dss.GetMeta("a.txt", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer, err := VerifyMetaSignature(meta.(Meta)); err != nil || signer != idc.SignPKey {
+		t.Fatalf("TestOlfSignedMeta signer %s error %v", signer, err)
+	}
+	dss.Close()
+
+	// unsigned meta data written without signing key
+	if err = UserConfigPutIdentity(DssBaseConfig{}, cfg, IdentityConfig{Alias: idc.Alias, PKey: idc.PKey, Secret: idc.Secret}); err != nil {
+		t.Fatal(err)
+	}
+	if dss, err = NewOlfDss(olfConfig(cfg), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeTestContent(dss, "b.txt", []byte("TestOlfSignedMeta b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	dss.Close()
+
+	if uc, err = GetUserConfig(DssBaseConfig{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	uc.PutIdentity(idc)
+	uc.Trusts = map[string]RepoTrust{repoId: {Policy: TrustPolicyFlag}}
+	if err = SaveUserConfig(DssBaseConfig{}, cfg, uc); err != nil {
+		t.Fatal(err)
+	}
+	if dss, err = NewOlfDss(olfConfig(cfg), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dss.GetMeta("b.txt", true); err != nil {
+		t.Fatal(err)
+	}
+	mai, err := dss.AuditIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mai) != 1 {
+		t.Fatalf("TestOlfSignedMeta AuditIndex %v", mai)
+	}
+	for _, aiis := range mai {
+		for _, aii := range aiis {
+			if aii.Error != "Untrusted" {
+				t.Fatalf("TestOlfSignedMeta AuditIndex %v", mai)
+			}
+		}
+	}
+	sti, errs := dss.ScanStorage(true, false, false)
+	if errs != nil || len(sti.Untrusted) != 1 || len(sti.Path2Error) != 0 {
+		t.Fatalf("TestOlfSignedMeta ScanStorage %v %v %v", sti.Untrusted, sti.Path2Error, errs)
+	}
+	if _, errs = dss.ScanStorage(false, true, false); errs != nil {
+		t.Fatalf("TestOlfSignedMeta ScanStorage purge %v", errs)
+	}
+	rc, err := dss.GetContentReader("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := io.ReadAll(rc); err != nil || string(bs) != "TestOlfSignedMeta b.txt" {
+		t.Fatalf("TestOlfSignedMeta content of untrusted meta data %s %v", bs, err)
+	}
+	rc.Close()
+	dss.Close()
+
+	uc.Trusts[repoId] = RepoTrust{Policy: TrustPolicyReject}
+	if err = SaveUserConfig(DssBaseConfig{}, cfg, uc); err != nil {
+		t.Fatal(err)
+	}
+	if dss, err = NewOlfDss(olfConfig(cfg), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if _, err = dss.GetMeta("a.txt", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dss.GetMeta("b.txt", true); !errors.Is(err, ErrUntrustedMeta) {
+		t.Fatalf("TestOlfSignedMeta reject policy should fail %v", err)
+	}
+}
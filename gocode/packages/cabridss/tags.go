@@ -0,0 +1,146 @@
+package cabridss
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Tag names an immutable point in the DSS history
+type Tag struct {
+	Name  string `json:"name"`
+	Time  int64  `json:"time"`  // POSIX time of the tagged DSS state
+	Ctime int64  `json:"ctime"` // POSIX time of the tag creation
+}
+
+func (tag Tag) String() string {
+	return fmt.Sprintf("%s %s", UnixUTC(tag.Time*1e9), tag.Name)
+}
+
+var tagNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+// CheckTagName checks that a tag name starts with a letter, followed by letters, digits, '.', '_' or '-'
+//
+// this way it cannot be mistaken for a timestamp
+func CheckTagName(name string) error {
+	if !tagNameRe.MatchString(name) {
+		return fmt.Errorf("tag name %s is invalid (must start with a letter, followed by letters, digits, '.', '_' or '-')", name)
+	}
+	return nil
+}
+
+func decodeTags(bs []byte) (map[string]Tag, error) {
+	tags := map[string]Tag{}
+	if len(bs) == 0 {
+		return tags, nil
+	}
+	if err := json.Unmarshal(bs, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (odbi *oDssBaseImpl) loadAllTags() (map[string]Tag, error) {
+	bs, err := odbi.me.loadTags()
+	if err != nil {
+		return nil, err
+	}
+	return decodeTags(bs)
+}
+
+func (odbi *oDssBaseImpl) storeAllTags(tags map[string]Tag) error {
+	bs, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	return odbi.me.storeTags(bs)
+}
+
+func (odbi *oDssBaseImpl) createTag(name string, slsttime int64) error {
+	if err := CheckTagName(name); err != nil {
+		return fmt.Errorf("in CreateTag: %w", err)
+	}
+	if odbi.lsttime != 0 {
+		return fmt.Errorf("in CreateTag: read-only DSS")
+	}
+	now := time.Now().Unix()
+	if odbi.mockct != 0 {
+		now = odbi.mockct / 1e9
+	}
+	if slsttime == 0 {
+		slsttime = now
+	}
+	tags, err := odbi.loadAllTags()
+	if err != nil {
+		return fmt.Errorf("in CreateTag: %w", err)
+	}
+	if _, ok := tags[name]; ok {
+		return fmt.Errorf("in CreateTag: tag %s already exists", name)
+	}
+	tags[name] = Tag{Name: name, Time: slsttime, Ctime: now}
+	if err = odbi.storeAllTags(tags); err != nil {
+		return fmt.Errorf("in CreateTag: %w", err)
+	}
+	return nil
+}
+
+func (odbi *oDssBaseImpl) listTags() ([]Tag, error) {
+	tags, err := odbi.loadAllTags()
+	if err != nil {
+		return nil, fmt.Errorf("in ListTags: %w", err)
+	}
+	var res []Tag
+	for _, tag := range tags {
+		res = append(res, tag)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Time == res[j].Time {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].Time < res[j].Time
+	})
+	return res, nil
+}
+
+func (odbi *oDssBaseImpl) deleteTag(name string) error {
+	if odbi.lsttime != 0 {
+		return fmt.Errorf("in DeleteTag: read-only DSS")
+	}
+	tags, err := odbi.loadAllTags()
+	if err != nil {
+		return fmt.Errorf("in DeleteTag: %w", err)
+	}
+	if _, ok := tags[name]; !ok {
+		return fmt.Errorf("in DeleteTag: no such tag %s", name)
+	}
+	delete(tags, name)
+	if err = odbi.storeAllTags(tags); err != nil {
+		return fmt.Errorf("in DeleteTag: %w", err)
+	}
+	return nil
+}
+
+func (odbi *oDssBaseImpl) resolveTag(name string) (int64, error) {
+	tags, err := odbi.loadAllTags()
+	if err != nil {
+		return 0, fmt.Errorf("in ResolveTag: %w", err)
+	}
+	tag, ok := tags[name]
+	if !ok {
+		return 0, fmt.Errorf("in ResolveTag: no such tag %s", name)
+	}
+	return tag.Time, nil
+}
+
+// pinningTag returns the name of a tag pinning the history entry or ""
+func pinningTag(tags map[string]Tag, start, end int64) string {
+	pinning := ""
+	for _, tag := range tags {
+		if t := tag.Time * 1e9; start <= t && t <= end && (pinning == "" || tag.Name < pinning) {
+			pinning = tag.Name
+		}
+	}
+	return pinning
+}
@@ -0,0 +1,124 @@
+package cabridss
+
+import (
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTags(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestTags", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	getIndex := func(config DssBaseConfig, _ string) (Index, error) {
+		return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+	}
+	dss, err := CreateOlfDss(OlfConfig{DssBaseConfig: DssBaseConfig{LocalPath: tfs.Path(), GetIndex: getIndex}, Root: tfs.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dss.Close()
+	if tags, err := dss.ListTags(); err != nil || len(tags) != 0 {
+		t.Fatalf("TestTags no tag %v %v", tags, err)
+	}
+	// five daily versions of a.txt, the third one being tagged
+	t1 := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC).Unix()
+	dss.SetCurrentTime(t1)
+	if err = dss.Mkns("", 0, []string{"a.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		dss.SetCurrentTime(t1 + int64(i)*24*3600)
+		if err = writeTestContent(dss, "a.txt", []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 {
+			if err = dss.CreateTag("release-1.0", 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err = dss.CreateTag("release-1.0", 0); err == nil {
+		t.Fatal("TestTags duplicate tag should fail")
+	}
+	if err = dss.CreateTag("1.0", 0); err == nil {
+		t.Fatal("TestTags invalid tag name should fail")
+	}
+	if err = dss.CreateTag("first", t1); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := dss.ListTags()
+	if err != nil || len(tags) != 2 || tags[0].Name != "first" || tags[1].Name != "release-1.0" {
+		t.Fatalf("TestTags ListTags %v %v", tags, err)
+	}
+	tt, err := dss.ResolveTag("release-1.0")
+	if err != nil || tt != t1+2*24*3600 {
+		t.Fatalf("TestTags ResolveTag %d %v", tt, err)
+	}
+	if _, err = dss.ResolveTag("none"); err == nil {
+		t.Fatal("TestTags ResolveTag none should fail")
+	}
+
+	view, err := dss.AtTime(tt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := view.GetContentReader("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(bs) != "v2" {
+		t.Fatalf("TestTags content at tag %s %v", bs, err)
+	}
+	if err = view.CreateTag("other", 0); err == nil {
+		t.Fatal("TestTags CreateTag on a read-only view should fail")
+	}
+	if err = view.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// retention keeps tagged versions unless forced
+	rp, err := ParseRetentionPolicy("all:1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := t1 + 4*24*3600 + 3600
+	if mHes, err := dss.ApplyRetention("", true, true, true, rp, now); err != nil || len(mHes["a.txt"]) != 4 {
+		t.Fatalf("TestTags ApplyRetention forced evaluate %v %v", mHes, err)
+	}
+	if mHes, err := dss.ApplyRetention("", true, false, false, rp, now); err != nil || len(mHes["a.txt"]) != 2 {
+		t.Fatalf("TestTags ApplyRetention %v %v", mHes, err)
+	}
+	mHes, err := dss.GetHistory("a.txt", false, "s")
+	if err != nil || len(mHes["a.txt"]) != 3 {
+		t.Fatalf("TestTags history after retention %v %v", mHes, err)
+	}
+
+	// removing history refuses to drop tagged versions unless forced
+	if _, err = dss.RemoveHistory("a.txt", false, false, false, 0, t1+4*24*3600); err == nil {
+		t.Fatal("TestTags RemoveHistory of pinned versions should fail")
+	}
+	if mHes, err = dss.GetHistory("a.txt", false, "s"); err != nil || len(mHes["a.txt"]) != 3 {
+		t.Fatalf("TestTags history after refused removal %v %v", mHes, err)
+	}
+	if err = dss.DeleteTag("first"); err != nil {
+		t.Fatal(err)
+	}
+	if err = dss.DeleteTag("first"); err == nil {
+		t.Fatal("TestTags DeleteTag of a deleted tag should fail")
+	}
+	if _, err = dss.RemoveHistory("a.txt", false, false, true, 0, t1+4*24*3600); err != nil {
+		t.Fatal(err)
+	}
+	if mHes, err = dss.GetHistory("a.txt", false, "s"); err != nil || len(mHes["a.txt"]) != 1 {
+		t.Fatalf("TestTags history after forced removal %v %v", mHes, err)
+	}
+}
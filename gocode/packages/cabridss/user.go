@@ -12,6 +12,7 @@ type UserConfig struct {
 	ClientId   string `json:"clientId"`
 	Identities []IdentityConfig
 	Internal   IdentityConfig
+	Trusts     map[string]RepoTrust `json:"trusts,omitempty"` // trust lists of meta data signers by repository id
 }
 
 func (uc *UserConfig) PutIdentity(identity IdentityConfig) {
@@ -3,6 +3,7 @@ package cabridss
 import (
 	"fmt"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
 	"strings"
 	"testing"
 )
@@ -94,3 +95,55 @@ func TestGetUserConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRecoveryShares(t *testing.T) {
+	tfs, err := testfs.CreateFs("TestRecoveryShares", tfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	uc, err := GetUserConfig(DssBaseConfig{}, ufpath.Join(tfs.Path(), "cfg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id1, err := GenIdentity("id1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc.PutIdentity(id1)
+	shares, err := RecoveryShares(uc, 5, 3)
+	if err != nil || len(shares) != 5 {
+		t.Fatal(err, shares)
+	}
+	ruc, err := RecoverUserConfig(shares[4] + "\n" + shares[1] + shares[2])
+	if err != nil || ruc.ClientId != uc.ClientId || ruc.Internal != uc.Internal || len(ruc.Identities) != 2 || ruc.GetIdentity("id1") != id1 {
+		t.Fatal(err, ruc)
+	}
+	if _, err = RecoverUserConfig(shares[0] + shares[3]); err == nil || !strings.Contains(err.Error(), "3 required") {
+		t.Fatal("TestRecoveryShares below threshold should fail", err)
+	}
+	other, err := RecoveryShares(uc, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = RecoverUserConfig(shares[0] + shares[1] + other[2]); err == nil {
+		t.Fatal("TestRecoveryShares shares from different sets should fail")
+	}
+	lines := strings.Split(shares[0], "\n")
+	typo := []byte(lines[2])
+	typo[5] = map[bool]byte{true: 'B', false: 'A'}[typo[5] == 'A']
+	lines[2] = string(typo)
+	if _, err = RecoverUserConfig(strings.Join(lines, "\n") + shares[1] + shares[2]); err == nil || !strings.Contains(err.Error(), "typos") {
+		t.Fatal("TestRecoveryShares typo should be detected", err)
+	}
+	if err = RestoreUserConfig(DssBaseConfig{}, ufpath.Join(tfs.Path(), "cfg"), ruc); err == nil {
+		t.Fatal("TestRecoveryShares restoring over an existing configuration should fail")
+	}
+	if err = RestoreUserConfig(DssBaseConfig{ConfigPassword: "TestRecoveryShares"}, ufpath.Join(tfs.Path(), "rcfg"), ruc); err != nil {
+		t.Fatal(err)
+	}
+	ruc2, err := GetUserConfig(DssBaseConfig{ConfigPassword: "TestRecoveryShares"}, ufpath.Join(tfs.Path(), "rcfg"))
+	if err != nil || ruc2.ClientId != uc.ClientId || ruc2.GetIdentity("id1") != id1 {
+		t.Fatal(err, ruc2)
+	}
+}
@@ -228,6 +228,8 @@ func getInitStorageInfo() StorageInfo {
 		ExistingEcs:   map[string]bool{},
 		Path2Content:  map[string]string{},
 		Path2CContent: map[string]string{},
+		Path2Manifest: map[string]string{},
 		Path2Error:    map[string]error{},
+		Untrusted:     map[string]string{},
 	}
 }
@@ -27,6 +27,7 @@ type WebServerConfig struct {
 	TlsNoCheck        bool   // no check of certificate by https client
 	BasicAuthUser     string
 	BasicAuthPassword string
+	ApiTokens         string // if not empty, API tokens file authenticating requests (see webtoken.go)
 }
 
 type WebServer interface {
@@ -46,6 +47,7 @@ type TlsConfig struct {
 	noClientCheck     bool
 	basicAuthUser     string
 	basicAuthPassword string
+	apiTokens         string
 }
 
 func getTlsClientConfig(tlsConfig *TlsConfig) (*tls.Config, error) {
@@ -74,6 +76,7 @@ func getTlsServerConfig(wsConfig WebServerConfig) *TlsConfig {
 		noClientCheck:     wsConfig.TlsNoCheck,
 		basicAuthUser:     wsConfig.BasicAuthUser,
 		basicAuthPassword: wsConfig.BasicAuthPassword,
+		apiTokens:         wsConfig.ApiTokens,
 	}
 }
 
@@ -87,6 +90,7 @@ type eServer struct {
 	shutReq           chan interface{}
 	shutResp          chan interface{}
 	closed            bool
+	apiTokens         *apiTokenStore
 }
 
 type eCustomContext struct {
@@ -139,15 +143,6 @@ func (esv *eServer) Serve() error {
 	esv.shutResp = make(chan interface{})
 	go func() {
 		var err error
-		if esv.tlsConfig != nil && esv.tlsConfig.basicAuthUser != "" {
-			esv.e.Use(middleware.BasicAuth(func(username, password string, c echo.Context) (bool, error) {
-				if subtle.ConstantTimeCompare([]byte(username), []byte(esv.tlsConfig.basicAuthUser)) == 1 &&
-					subtle.ConstantTimeCompare([]byte(password), []byte(esv.tlsConfig.basicAuthPassword)) == 1 {
-					return true, nil
-				}
-				return false, nil
-			}))
-		}
 		if esv.tlsConfig == nil {
 			err = esv.e.Start(esv.addr)
 		} else {
@@ -212,6 +207,40 @@ func (esv *eServer) Serve() error {
 	return nil
 }
 
+// apiRoot returns the DSS root of a request route
+func (esv *eServer) apiRoot(c echo.Context) string {
+	root := ""
+	for r := range esv.customConfigs {
+		if (strings.HasPrefix(c.Path(), r) || c.Path()+"/" == r) && len(r) > len(root) {
+			root = r
+		}
+	}
+	return root
+}
+
+// isCheckRequest tells if the request is a server check, not requiring an API token
+func (esv *eServer) isCheckRequest(c echo.Context) bool {
+	root := esv.apiRoot(c)
+	return esv.tlsConfig.apiTokens != "" && root != "" && c.Path() == root+"check"
+}
+
+func (esv *eServer) authenticate(username, password string, c echo.Context) (bool, error) {
+	if esv.tlsConfig.basicAuthUser != "" &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(esv.tlsConfig.basicAuthUser)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(esv.tlsConfig.basicAuthPassword)) == 1 {
+		return true, nil
+	}
+	if esv.apiTokens == nil {
+		return false, nil
+	}
+	root := esv.apiRoot(c)
+	valid, allowed := esv.apiTokens.authenticate(username, password, root, apiOperation(c.Request().Method, strings.TrimPrefix(c.Path(), root)))
+	if valid && !allowed {
+		return false, echo.NewHTTPError(http.StatusForbidden, "the API token doesn't allow this operation")
+	}
+	return allowed, nil
+}
+
 func (esv *eServer) Shutdown() error {
 	if esv.closed {
 		return nil
@@ -238,14 +267,7 @@ func (esv *eServer) ConfigureApi(
 	shutdownCallback func(root string, customConfigs map[string]interface{}) error,
 	ctor func(e *echo.Echo, root string, customConfigs map[string]interface{}) error,
 ) error {
-	if root == "" {
-		root = "/"
-	} else if root[0] != '/' {
-		root = "/" + root
-	}
-	if root[len(root)-1] != '/' {
-		root += "/"
-	}
+	root = normalizeApiRoot(root)
 	if esv.firstRoot == "" {
 		esv.firstRoot = root
 	}
@@ -266,6 +288,9 @@ func NewEServer(addr string, hasLog bool, tlsConfig *TlsConfig) WebServer {
 		customConfigs:     map[string]interface{}{},
 		shutdownCallbacks: map[string]func(root string, customConfigs map[string]interface{}) error{},
 	}
+	if tlsConfig != nil && tlsConfig.apiTokens != "" {
+		esv.apiTokens = &apiTokenStore{path: tlsConfig.apiTokens}
+	}
 	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			cc := &eCustomContext{Context: c, esv: esv}
@@ -277,6 +302,12 @@ func NewEServer(addr string, hasLog bool, tlsConfig *TlsConfig) WebServer {
 	if hasLog {
 		e.Use(middleware.Logger())
 	}
+	if tlsConfig != nil && (tlsConfig.basicAuthUser != "" || tlsConfig.apiTokens != "") {
+		e.Use(middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
+			Skipper:   esv.isCheckRequest,
+			Validator: esv.authenticate,
+		}))
+	}
 	return esv
 }
 
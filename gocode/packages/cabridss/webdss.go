@@ -120,12 +120,18 @@ func (wdi *webDssImpl) initialize(me oDssProxy, config interface{}, lsttime int6
 	}
 	wdi.repoId = mIed.RepoId
 	wdi.repoEncrypted = mIed.Encrypted
+	wdi.repoCompressed = mIed.Compressed
+	wdi.repoCompression = mIed.Compression
+	wdi.repoConvergent = mIed.Convergent
 	if wdi.repoId == "" {
 		return fmt.Errorf("in initialize: the repository has no id")
 	}
 	if !mIed.PersistentIndex {
 		return fmt.Errorf("in initialize: the repository has no persistent index")
 	}
+	if err = wdi.setSigning(uc); err != nil {
+		return fmt.Errorf("in initialize: %w", err)
+	}
 
 	var udd *mUpdatedData
 	if !mIed.ClientIsKnown {
@@ -187,8 +193,8 @@ func (wdi *webDssImpl) xRemoveMeta(meta Meta) error {
 	return wdi.index.removeMeta(ipath, meta.Itime)
 }
 
-func (wdi *webDssImpl) webPushContent(size int64, ch string, mbs []byte, emid string, cf afero.File) error {
-	jsonArgs, err := json.Marshal(mPushContentIn{Size: size, Ch: ch, Mbs: mbs, Emid: emid})
+func (wdi *webDssImpl) webPushContent(size int64, ch string, mbs []byte, emid string, basisCh string, cf afero.File) error {
+	jsonArgs, err := json.Marshal(mPushContentIn{Size: size, Ch: ch, Mbs: mbs, Emid: emid, BasisCh: basisCh})
 	if err != nil {
 		return fmt.Errorf("in webPushContent: %w", err)
 	}
@@ -201,7 +207,11 @@ func (wdi *webDssImpl) webPushContent(size int64, ch string, mbs []byte, emid st
 		hdler := webContentWriterHandler{header: make([]byte, 16+len(jsonArgs)), rCloser: file}
 		copy(hdler.header, lja)
 		copy(hdler.header[16:], jsonArgs)
-		req, err := http.NewRequest(http.MethodPost, wdi.apc.Url()+"pushContent", nil)
+		urlPath := "pushContent"
+		if basisCh != "" {
+			urlPath = "pushContentDelta"
+		}
+		req, err := http.NewRequest(http.MethodPost, wdi.apc.Url()+urlPath, nil)
 		req.Body = &hdler
 		req.Header.Set(echo.HeaderContentType, echo.MIMEOctetStream)
 		return req, nil
@@ -221,7 +231,7 @@ func (wdi *webDssImpl) webPushContent(size int64, ch string, mbs []byte, emid st
 	return nil
 }
 
-func (wdi *webDssImpl) libPushContent(size int64, ch string, mbs []byte, emid string, cf afero.File) error {
+func (wdi *webDssImpl) libPushContent(size int64, ch string, mbs []byte, emid string, basisCh string, cf afero.File) error {
 	wdc := wdi.apc.GetConfig().(webDssClientConfig)
 	ccf, err := os.Open(cf.Name())
 	if err != nil {
@@ -229,13 +239,23 @@ func (wdi *webDssImpl) libPushContent(size int64, ch string, mbs []byte, emid st
 	}
 	defer ccf.Close()
 	proxy := wdc.libDss.(*ODss).proxy
-	wter, err := proxy.spGetContentWriter(contentWriterCbs{
+	cwcbs := contentWriterCbs{
 		getMetaBytes: func(iErr error, size int64, ch string) ([]byte, string, error) {
 			return mbs, emid, nil
 		},
-	}, nil)
+	}
+	var wter io.WriteCloser
+	if basisCh != "" {
+		wter, err = proxy.spGetContentDeltaWriter(basisCh, cwcbs, nil)
+	} else {
+		wter, err = proxy.spGetContentWriter(cwcbs, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("in libPushContent: %w", err)
+	}
 	n, err := io.Copy(wter, ccf)
-	if err != nil || n != size {
+	if err != nil || (basisCh == "" && n != size) {
+		wter.Close()
 		return fmt.Errorf("in libPushContent: %v %d %d", err, n, size)
 	}
 	if err = wter.Close(); err != nil {
@@ -245,11 +265,16 @@ func (wdi *webDssImpl) libPushContent(size int64, ch string, mbs []byte, emid st
 }
 
 func (wdi *webDssImpl) pushContent(size int64, ch string, mbs []byte, emid string, cf afero.File) error {
+	return wdi.pushContentOrDelta(size, ch, mbs, emid, "", cf)
+}
+
+// pushContentOrDelta pushes the content in cf or, if basisCh is not empty, its delta against the basisCh content
+func (wdi *webDssImpl) pushContentOrDelta(size int64, ch string, mbs []byte, emid string, basisCh string, cf afero.File) error {
 	var err error
 	if wdi.libApi {
-		err = wdi.libPushContent(size, ch, mbs, emid, cf)
+		err = wdi.libPushContent(size, ch, mbs, emid, basisCh, cf)
 	} else {
-		err = wdi.webPushContent(size, ch, mbs, emid, cf)
+		err = wdi.webPushContent(size, ch, mbs, emid, basisCh, cf)
 	}
 	if err != nil {
 		return err
@@ -293,7 +318,59 @@ func (wdi *webDssImpl) spGetContentWriter(cwcbs contentWriterCbs, acl []ACLEntry
 	})
 }
 
-func (wdi *webDssImpl) spWebGetContentReader(ch string) (io.ReadCloser, error) {
+func (wdi *webDssImpl) spGetContentDeltaWriter(basisCh string, cwcbs contentWriterCbs, acl []ACLEntry) (io.WriteCloser, error) {
+	return NewTempFileWriteCloserWithCb(wdi.getAfs(), "", "dw", func(err error, _ int64, _ string, wcwc *WriteCloserWithCb) error {
+		var (
+			size int64
+			ch   string
+		)
+		outError := err
+		defer func() {
+			if cwcbs.closeCb != nil {
+				cwcbs.closeCb(outError, size, ch)
+			}
+		}()
+		if err != nil {
+			outError = fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+			return outError
+		}
+		cf := wcwc.Underlying.(afero.File)
+		if size, ch, err = readDeltaTrailer(wdi.getAfs(), cf.Name()); err != nil {
+			outError = fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+			return outError
+		}
+		mbs, emid, err := cwcbs.getMetaBytes(nil, size, ch)
+		if err != nil {
+			outError = fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+			return outError
+		}
+		meta, err := wdi.decodeMeta(mbs)
+		if err != nil {
+			outError = fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+			return outError
+		}
+		if err := wdi.pushContentOrDelta(size, ch, mbs, emid, basisCh, cf); err != nil {
+			outError = fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+			return outError
+		}
+		if err := wdi.index.storeMeta(meta.Path, meta.Itime, mbs); err != nil {
+			outError = fmt.Errorf("in spGetContentDeltaWriter: %w", err)
+			return outError
+		}
+		return nil
+	})
+}
+
+func (wdi *webDssImpl) spGetContentSignatures(ch string, blockSize int) (*ContentSignatures, error) {
+	out, err := cSpGetContentSignatures(wdi.apc, ch, blockSize)
+	if err != nil {
+		return nil, fmt.Errorf("in spGetContentSignatures: %w", err)
+	}
+	return out.Sigs, nil
+}
+
+// spWebGetContentReader gets the content ch, or only its range hRange if not empty
+func (wdi *webDssImpl) spWebGetContentReader(ch string, hRange string) (io.ReadCloser, error) {
 	reqBody, err := json.Marshal(mSpGetContentReader{Ch: ch})
 	if err != nil {
 		return nil, fmt.Errorf("in spWebGetContentReader: %w", err)
@@ -303,6 +380,9 @@ func (wdi *webDssImpl) spWebGetContentReader(ch string) (io.ReadCloser, error) {
 		return nil, fmt.Errorf("in spWebGetContentReader: %w", err)
 	}
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if hRange != "" {
+		req.Header.Set("Range", hRange)
+	}
 	resp, err := wdi.apc.(*apiClient).client.Do(req, nil)
 	if err != nil {
 		return nil, fmt.Errorf("in spWebGetContentReader: %w", err)
@@ -339,13 +419,26 @@ func (wdi *webDssImpl) spGetContentReader(ch string) (io.ReadCloser, error) {
 	if wdi.libApi {
 		return wdi.spLibGetContentReader(ch)
 	}
-	return wdi.spWebGetContentReader(ch)
+	return wdi.spWebGetContentReader(ch, "")
 }
 
 func (wdi *webDssImpl) doGetContentReader(npath string, meta Meta) (io.ReadCloser, error) {
 	return wdi.spGetContentReader(meta.Ch)
 }
 
+func (wdi *webDssImpl) spGetContentRangeReader(ch string, offset, length int64) (io.ReadCloser, error) {
+	if wdi.libApi {
+		wdc := wdi.apc.GetConfig().(webDssClientConfig)
+		proxy := wdc.libDss.(*ODss).proxy
+		return proxy.spGetContentRangeReader(ch, offset, length)
+	}
+	return wdi.spWebGetContentReader(ch, httpRange(offset, length))
+}
+
+func (wdi *webDssImpl) doGetContentRangeReader(npath string, meta Meta, offset, length int64) (io.ReadCloser, error) {
+	return wdi.spGetContentRangeReader(meta.Ch, offset, length)
+}
+
 func (wdi *webDssImpl) queryContent(ch string) (exist bool, err error) {
 	ex, err := cQueryContent(wdi.apc, ch)
 	if err != nil {
@@ -363,6 +456,51 @@ func (wdi *webDssImpl) removeContent(ch string) error {
 
 }
 
+func (wdi *webDssImpl) pushChunk(ch string, bs []byte) error {
+	if err := cPushChunk(wdi.apc, ch, bs); err != nil {
+		return fmt.Errorf("in pushChunk: %v", err)
+	}
+	return nil
+}
+
+func (wdi *webDssImpl) contentSize(ch string) (int64, error) { panic("inconsistent") }
+
+func (wdi *webDssImpl) loadChunkManifest(ch string) ([]string, error) { panic("inconsistent") }
+
+func (wdi *webDssImpl) storeChunkManifest(ch string, chunks []string) error { panic("inconsistent") }
+
+func (wdi *webDssImpl) removeChunkManifest(ch string) error { panic("inconsistent") }
+
+func (wdi *webDssImpl) loadTags() ([]byte, error) {
+	mt, err := cLoadTags(wdi.apc)
+	if err != nil {
+		return nil, fmt.Errorf("in loadTags: %v", err)
+	}
+	return mt.Bs, nil
+}
+
+func (wdi *webDssImpl) storeTags(bs []byte) error {
+	if err := cStoreTags(wdi.apc, bs); err != nil {
+		return fmt.Errorf("in storeTags: %v", err)
+	}
+	return nil
+}
+
+func (wdi *webDssImpl) loadConvergenceKey() ([]byte, error) {
+	mck, err := cLoadConvergenceKey(wdi.apc)
+	if err != nil {
+		return nil, fmt.Errorf("in loadConvergenceKey: %v", err)
+	}
+	return mck.Bs, nil
+}
+
+func (wdi *webDssImpl) storeConvergenceKey(bs []byte) error {
+	if err := cStoreConvergenceKey(wdi.apc, bs); err != nil {
+		return fmt.Errorf("in storeConvergenceKey: %v", err)
+	}
+	return nil
+}
+
 func (wdi *webDssImpl) spClose() error {
 	if !wdi.libApi {
 		return nil
@@ -372,6 +510,13 @@ func (wdi *webDssImpl) spClose() error {
 	return proxy.close()
 }
 
+func (wdi *webDssImpl) atTime(lsttime int64) oDssProxy {
+	view := *wdi
+	view.me = &view
+	view.lsttime = lsttime
+	return &view
+}
+
 func (wdi *webDssImpl) dumpIndex() string {
 	rdi, err := cDumpIndex(wdi.apc)
 	if err != nil {
@@ -398,6 +543,7 @@ func (wdi *webDssImpl) spScanPhysicalStorageClient(checksum bool, sts *mSPS, sti
 	copyMap(sti.ExistingCs, sts.Sti.ExistingCs)
 	copyMap(sti.ExistingEcs, sts.Sti.ExistingEcs)
 	copyMap(sti.Path2Error, sts.Sti.Path2Error)
+	copyMap(sti.Untrusted, sts.Sti.Untrusted)
 	errs = &sts.Errs
 }
 
@@ -658,3 +658,37 @@ func TestWebClientOlfMultiHistory(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestWebDssClientOlfLargeNs(t *testing.T) {
+	ucpCount := 0
+	var sv WebServer
+	var err error
+	defer func() {
+		if sv != nil {
+			sv.Shutdown()
+		}
+	}()
+	if err := runTestLargeNs(t, false,
+		func(tfs *testfs.Fs) error {
+			getPIndex := func(config DssBaseConfig, _ string) (Index, error) {
+				return NewPIndex(ufpath.Join(tfs.Path(), "index.bdb"), false, false)
+			}
+			sv, err = createWebDssServer(tfs, ":3000", "",
+				CreateNewParams{Create: true, DssType: "olf", Root: tfs.Path(), Size: "s", GetIndex: getPIndex},
+			)
+			return err
+		},
+		func(tfs *testfs.Fs) (HDss, error) {
+			ucpCount += 1
+			dss, err := NewWebDss(
+				WebDssConfig{
+					DssBaseConfig: DssBaseConfig{
+						ConfigDir: ufpath.Join(tfs.Path(), fmt.Sprintf(".cabri-i%d", ucpCount)),
+						WebPort:   "3000",
+					}},
+				0, nil)
+			return dss, err
+		}); err != nil {
+		t.Fatal(err)
+	}
+}
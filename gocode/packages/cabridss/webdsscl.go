@@ -16,6 +16,9 @@ type mInitialized struct {
 	RepoId          string `json:"repoId"`
 	PersistentIndex bool   `json:"persistentIndex"`
 	Encrypted       bool   `json:"encrypted"`
+	Compressed      bool   `json:"compressed"`
+	Compression     string `json:"compression"`
+	Convergent      bool   `json:"convergent"`
 	ClientIsKnown   bool   `json:"clientIsKnown"`
 }
 
@@ -42,10 +45,11 @@ type mRemoveMeta struct {
 }
 
 type mPushContentIn struct {
-	Size int64  `json:"size"`
-	Ch   string `json:"ch"`
-	Mbs  []byte `json:"bs,string"`
-	Emid string `json:"emid"`
+	Size    int64  `json:"size"`
+	Ch      string `json:"ch"`
+	Mbs     []byte `json:"bs,string"`
+	Emid    string `json:"emid"`
+	BasisCh string `json:"basisCh,omitempty"`
 }
 
 type mLoadMetaIn struct {
@@ -62,6 +66,31 @@ type mSpGetContentReader struct {
 	Ch string `json:"ch"`
 }
 
+type mSpGetContentSignaturesIn struct {
+	Ch        string `json:"ch"`
+	BlockSize int    `json:"blockSize"`
+}
+
+type mContentSignatures struct {
+	mError
+	Sigs *ContentSignatures `json:"sigs"`
+}
+
+type mPushChunk struct {
+	Ch string `json:"ch"`
+	Bs []byte `json:"bs,string"`
+}
+
+type mTags struct {
+	mError
+	Bs []byte `json:"bs,string"`
+}
+
+type mConvergenceKey struct {
+	mError
+	Bs []byte `json:"bs,string"`
+}
+
 type mExist struct {
 	mError
 	Exist bool `json:"exist"`
@@ -92,6 +121,9 @@ func aInitialize(clId string, dss HDss) *mInitialized {
 		RepoId:          dss.GetRepoId(),
 		PersistentIndex: dss.GetIndex().IsPersistent(),
 		Encrypted:       dss.IsRepoEncrypted(),
+		Compressed:      dss.IsRepoCompressed(),
+		Compression:     dss.GetRepoCompression(),
+		Convergent:      dss.IsRepoConvergent(),
 		ClientIsKnown:   cik,
 	}
 }
@@ -144,6 +176,34 @@ func aLoadMeta(npath string, time int64, dss HDss) *mLoadMetaOut {
 	return &mLoadMetaOut{Bs: bs}
 }
 
+func aLoadTags(dss HDss) *mTags {
+	bs, err := dss.(*ODss).proxy.loadTags()
+	if err != nil {
+		return &mTags{mError: mError{Error: err.Error()}}
+	}
+	return &mTags{Bs: bs}
+}
+
+func aStoreTags(bs []byte, dss HDss) error {
+	return dss.(*ODss).proxy.storeTags(bs)
+}
+
+func aLoadConvergenceKey(dss HDss) *mConvergenceKey {
+	bs, err := dss.(*ODss).proxy.loadConvergenceKey()
+	if err != nil {
+		return &mConvergenceKey{mError: mError{Error: err.Error()}}
+	}
+	return &mConvergenceKey{Bs: bs}
+}
+
+func aStoreConvergenceKey(bs []byte, dss HDss) error {
+	return dss.(*ODss).proxy.storeConvergenceKey(bs)
+}
+
+func aPushChunk(ch string, bs []byte, dss HDss) error {
+	return dss.(*ODss).proxy.pushChunk(ch, bs)
+}
+
 func aQueryContent(ch string, dss HDss) *mExist {
 	ex, err := dss.(*ODss).proxy.queryContent(ch)
 	if err != nil {
@@ -152,6 +212,14 @@ func aQueryContent(ch string, dss HDss) *mExist {
 	return &mExist{Exist: ex}
 }
 
+func aSpGetContentSignatures(ch string, blockSize int, dss HDss) *mContentSignatures {
+	sigs, err := dss.(*ODss).proxy.spGetContentSignatures(ch, blockSize)
+	if err != nil {
+		return &mContentSignatures{mError: mError{Error: err.Error()}}
+	}
+	return &mContentSignatures{Sigs: sigs}
+}
+
 func aRemoveContent(ch string, dss HDss) error {
 	return dss.(*ODss).proxy.removeContent(ch)
 }
@@ -287,6 +355,82 @@ func cXRemoveMeta(apc WebApiClient, npath string, time int64) error {
 	return nil
 }
 
+func cLoadTags(apc WebApiClient) (*mTags, error) {
+	wdc := apc.GetConfig().(webDssClientConfig)
+	var out mTags
+	if wdc.LibApi {
+		out = *aLoadTags(wdc.libDss)
+	} else {
+		_, err := apc.SimpleDoAsJson(http.MethodGet, apc.Url()+"loadTags", nil, &out)
+		if err != nil {
+			return nil, fmt.Errorf("in cLoadTags: %v", err)
+		}
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("in cLoadTags: %s", out.Error)
+	}
+	return &out, nil
+}
+
+func cStoreTags(apc WebApiClient, bs []byte) error {
+	wdc := apc.GetConfig().(webDssClientConfig)
+	var err error
+	if wdc.LibApi {
+		err = aStoreTags(bs, wdc.libDss)
+	} else {
+		_, err = apc.SimpleDoAsJson(http.MethodPut, apc.Url()+"storeTags", mTags{Bs: bs}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("in cStoreTags: %v", err)
+	}
+	return nil
+}
+
+func cLoadConvergenceKey(apc WebApiClient) (*mConvergenceKey, error) {
+	wdc := apc.GetConfig().(webDssClientConfig)
+	var out mConvergenceKey
+	if wdc.LibApi {
+		out = *aLoadConvergenceKey(wdc.libDss)
+	} else {
+		_, err := apc.SimpleDoAsJson(http.MethodGet, apc.Url()+"loadConvergenceKey", nil, &out)
+		if err != nil {
+			return nil, fmt.Errorf("in cLoadConvergenceKey: %v", err)
+		}
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("in cLoadConvergenceKey: %s", out.Error)
+	}
+	return &out, nil
+}
+
+func cStoreConvergenceKey(apc WebApiClient, bs []byte) error {
+	wdc := apc.GetConfig().(webDssClientConfig)
+	var err error
+	if wdc.LibApi {
+		err = aStoreConvergenceKey(bs, wdc.libDss)
+	} else {
+		_, err = apc.SimpleDoAsJson(http.MethodPut, apc.Url()+"storeConvergenceKey", mConvergenceKey{Bs: bs}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("in cStoreConvergenceKey: %v", err)
+	}
+	return nil
+}
+
+func cPushChunk(apc WebApiClient, ch string, bs []byte) error {
+	wdc := apc.GetConfig().(webDssClientConfig)
+	var err error
+	if wdc.LibApi {
+		err = aPushChunk(ch, bs, wdc.libDss)
+	} else {
+		_, err = apc.SimpleDoAsJson(http.MethodPut, apc.Url()+"pushChunk", mPushChunk{Ch: ch, Bs: bs}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("in cPushChunk: %v", err)
+	}
+	return nil
+}
+
 func cQueryContent(apc WebApiClient, ch string) (*mExist, error) {
 	wdc := apc.GetConfig().(webDssClientConfig)
 	var out mExist
@@ -304,6 +448,24 @@ func cQueryContent(apc WebApiClient, ch string) (*mExist, error) {
 	return &out, nil
 }
 
+func cSpGetContentSignatures(apc WebApiClient, ch string, blockSize int) (*mContentSignatures, error) {
+	wdc := apc.GetConfig().(webDssClientConfig)
+	var out mContentSignatures
+	if wdc.LibApi {
+		out = *aSpGetContentSignatures(ch, blockSize, wdc.libDss)
+	} else {
+		_, err := apc.SimpleDoAsJson(http.MethodPost, apc.Url()+"spGetContentSignatures",
+			mSpGetContentSignaturesIn{Ch: ch, BlockSize: blockSize}, &out)
+		if err != nil {
+			return nil, fmt.Errorf("in cSpGetContentSignatures: %v", err)
+		}
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("in cSpGetContentSignatures: %s", out.Error)
+	}
+	return &out, nil
+}
+
 func cRemoveContent(apc WebApiClient, ch string) error {
 	wdc := apc.GetConfig().(webDssClientConfig)
 	var err error
@@ -95,7 +95,53 @@ func sXRemoveMeta(c echo.Context) error {
 	return c.JSON(http.StatusOK, nil)
 }
 
-func sPushContent(c echo.Context) error {
+func sLoadTags(c echo.Context) error {
+	dss := GetCustomConfig(c).(WebDssServerConfig).Dss
+	return c.JSON(http.StatusOK, aLoadTags(dss))
+}
+
+func sStoreTags(c echo.Context) error {
+	var st mTags
+	if err := c.Bind(&st); err != nil {
+		return NewServerErr("sStoreTags", err)
+	}
+	dss := GetCustomConfig(c).(WebDssServerConfig).Dss
+	if err := aStoreTags(st.Bs, dss); err != nil {
+		return NewServerErr("sStoreTags", err)
+	}
+	return c.JSON(http.StatusOK, nil)
+}
+
+func sLoadConvergenceKey(c echo.Context) error {
+	dss := GetCustomConfig(c).(WebDssServerConfig).Dss
+	return c.JSON(http.StatusOK, aLoadConvergenceKey(dss))
+}
+
+func sStoreConvergenceKey(c echo.Context) error {
+	var sck mConvergenceKey
+	if err := c.Bind(&sck); err != nil {
+		return NewServerErr("sStoreConvergenceKey", err)
+	}
+	dss := GetCustomConfig(c).(WebDssServerConfig).Dss
+	if err := aStoreConvergenceKey(sck.Bs, dss); err != nil {
+		return NewServerErr("sStoreConvergenceKey", err)
+	}
+	return c.JSON(http.StatusOK, nil)
+}
+
+func sPushChunk(c echo.Context) error {
+	var pc mPushChunk
+	if err := c.Bind(&pc); err != nil {
+		return NewServerErr("sPushChunk", err)
+	}
+	dss := GetCustomConfig(c).(WebDssServerConfig).Dss
+	if err := aPushChunk(pc.Ch, pc.Bs, dss); err != nil {
+		return NewServerErr("sPushChunk", err)
+	}
+	return c.JSON(http.StatusOK, nil)
+}
+
+func sPushContentWhatever(c echo.Context, isDelta bool) error {
 	req := c.Request()
 	slja := make([]byte, 16)
 	if n, err := req.Body.Read(slja); n != 16 || err != nil {
@@ -115,16 +161,26 @@ func sPushContent(c echo.Context) error {
 		return NewServerErr("sPushContent", err)
 	}
 	oDss := GetCustomConfig(c).(WebDssServerConfig).Dss.(*ODss)
-	wter, err := oDss.proxy.spGetContentWriter(contentWriterCbs{
+	cwcbs := contentWriterCbs{
 		getMetaBytes: func(iErr error, size int64, ch string) (mbs []byte, emid string, oErr error) {
+			if isDelta && (size != args.Size || ch != args.Ch) {
+				return nil, "", fmt.Errorf("delta result %d %s does not match %d %s", size, ch, args.Size, args.Ch)
+			}
 			return args.Mbs, args.Emid, nil
 		},
-	}, nil)
+	}
+	var wter io.WriteCloser
+	if isDelta {
+		wter, err = oDss.proxy.spGetContentDeltaWriter(args.BasisCh, cwcbs, nil)
+	} else {
+		wter, err = oDss.proxy.spGetContentWriter(cwcbs, nil)
+	}
 	if err != nil {
 		return NewServerErr("sPushContent", err)
 	}
 	n, err := io.Copy(wter, req.Body)
-	if err != nil || n != args.Size {
+	if err != nil || (!isDelta && n != args.Size) {
+		wter.Close()
 		return NewServerErr("sPushContent", fmt.Errorf("%v %d %d", err, n, args.Size))
 	}
 	if err = wter.Close(); err != nil {
@@ -133,6 +189,14 @@ func sPushContent(c echo.Context) error {
 	return c.JSON(http.StatusOK, &mError{})
 }
 
+func sPushContent(c echo.Context) error {
+	return sPushContentWhatever(c, false)
+}
+
+func sPushContentDelta(c echo.Context) error {
+	return sPushContentWhatever(c, true)
+}
+
 func sLoadMeta(c echo.Context) error {
 	var lm mLoadMetaIn
 	if err := c.Bind(&lm); err != nil {
@@ -150,7 +214,18 @@ func sSpGetContentReader(c echo.Context) error {
 	oDss := GetCustomConfig(c).(WebDssServerConfig).Dss.(*ODss)
 	resp := c.Response()
 	resp.Writer.Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
-	rder, err := oDss.proxy.spGetContentReader(args.Ch)
+	status := http.StatusOK
+	var rder io.ReadCloser
+	var err error
+	if hr := c.Request().Header.Get("Range"); hr != "" {
+		var offset, length int64
+		if offset, length, err = parseHttpRange(hr, -1); err == nil {
+			status = http.StatusPartialContent
+			rder, err = oDss.proxy.spGetContentRangeReader(args.Ch, offset, length)
+		}
+	} else {
+		rder, err = oDss.proxy.spGetContentReader(args.Ch)
+	}
 	if err != nil {
 		resp.WriteHeader(http.StatusOK)
 		sErr := err.Error()
@@ -159,12 +234,21 @@ func sSpGetContentReader(c echo.Context) error {
 		return nil
 	}
 	defer rder.Close()
-	resp.WriteHeader(http.StatusOK)
+	resp.WriteHeader(status)
 	io.Copy(resp.Writer, strings.NewReader(internal.Int64ToStr16(int64(0))))
 	io.Copy(resp.Writer, rder)
 	return nil
 }
 
+func sSpGetContentSignatures(c echo.Context) error {
+	var args mSpGetContentSignaturesIn
+	if err := c.Bind(&args); err != nil {
+		return NewServerErr("sSpGetContentSignatures", err)
+	}
+	dss := GetCustomConfig(c).(WebDssServerConfig).Dss
+	return c.JSON(http.StatusOK, aSpGetContentSignatures(args.Ch, args.BlockSize, dss))
+}
+
 func sQueryContent(c echo.Context) error {
 	ch := ""
 	if err := echo.PathParamsBinder(c).String("ch", &ch).BindError(); err != nil {
@@ -225,13 +309,20 @@ func WebDssServerConfigurator(e *echo.Echo, root string, configs map[string]inte
 	e.DELETE(root+"removeMeta", sRemoveMeta)
 	e.DELETE(root+"xRemoveMeta", sXRemoveMeta)
 	e.POST(root+"pushContent", sPushContent)
+	e.POST(root+"pushContentDelta", sPushContentDelta)
+	e.PUT(root+"pushChunk", sPushChunk)
 	e.POST(root+"loadMeta", sLoadMeta)
 	e.POST(root+"spGetContentReader", sSpGetContentReader)
+	e.POST(root+"spGetContentSignatures", sSpGetContentSignatures)
 	e.GET(root+"queryContent/:ch", sQueryContent)
 	e.DELETE(root+"removeContent/:ch", sRemoveContent)
 	e.GET(root+"dumpIndex", sDumpIndex)
 	e.GET(root+"scanPhysicalStorage", sScanPhysicalStorage)
 	e.GET(root+"loadIndex", sLoadIndex)
+	e.GET(root+"loadTags", sLoadTags)
+	e.PUT(root+"storeTags", sStoreTags)
+	e.GET(root+"loadConvergenceKey", sLoadConvergenceKey)
+	e.PUT(root+"storeConvergenceKey", sStoreConvergenceKey)
 	return nil
 }
 
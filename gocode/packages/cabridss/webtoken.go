@@ -0,0 +1,260 @@
+package cabridss
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// web API servers may authenticate requests with named API tokens presented with HTTP basic authentication,
+// the token name being the user and the token itself the password.
+// Tokens are stored as their SHA-256 hash in the apiTokens file of the server configuration directory,
+// with the DSS roots and the operations they allow and their expiry time.
+// The file is reloaded when it changes, so that adding or revoking tokens doesn't require restarting the server.
+
+const (
+	ApiOpRead  = "read"  // read meta data and content
+	ApiOpWrite = "write" // create and update entries
+	ApiOpAdmin = "admin" // remove history, scan and purge storage, store tags and the convergence key
+)
+
+// ApiToken is a named API token, the token itself being known by its hash only
+type ApiToken struct {
+	Name    string   `json:"name"`
+	Hash    string   `json:"hash"`    // hex SHA-256 of the token
+	Roots   []string `json:"roots"`   // DSS roots the token is valid for, all if empty
+	Ops     []string `json:"ops"`     // allowed operations ApiOpRead, ApiOpWrite and/or ApiOpAdmin
+	Created int64    `json:"created"` // creation time
+	Expires int64    `json:"expires"` // expiry time, never if zero
+}
+
+// ApiTokensPath returns the path of the API tokens file in configDir
+func ApiTokensPath(configDir string) string {
+	return filepath.Join(configDir, "apiTokens")
+}
+
+func apiTokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// normalizeApiRoot returns a web API root as configured in a server, with leading and trailing slashes
+func normalizeApiRoot(root string) string {
+	if root == "" {
+		root = "/"
+	} else if root[0] != '/' {
+		root = "/" + root
+	}
+	if root[len(root)-1] != '/' {
+		root += "/"
+	}
+	return root
+}
+
+// CheckApiOp checks an API operation
+func CheckApiOp(op string) error {
+	if op != ApiOpRead && op != ApiOpWrite && op != ApiOpAdmin {
+		return fmt.Errorf("operation %s is invalid (must be %s, %s or %s)", op, ApiOpRead, ApiOpWrite, ApiOpAdmin)
+	}
+	return nil
+}
+
+// IsExpired tells if the token is expired at time now
+func (at ApiToken) IsExpired(now int64) bool {
+	return at.Expires != 0 && at.Expires <= now
+}
+
+func (at ApiToken) allows(root, op string, now int64) bool {
+	if at.IsExpired(now) {
+		return false
+	}
+	rootOk := len(at.Roots) == 0
+	for _, r := range at.Roots {
+		if normalizeApiRoot(r) == root {
+			rootOk = true
+			break
+		}
+	}
+	if !rootOk {
+		return false
+	}
+	for _, o := range at.Ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadApiTokens loads the API tokens stored in path, none if the file doesn't exist
+func LoadApiTokens(path string) ([]ApiToken, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("in LoadApiTokens: %w", err)
+	}
+	var tokens []ApiToken
+	if err = json.Unmarshal(bs, &tokens); err != nil {
+		return nil, fmt.Errorf("in LoadApiTokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func saveApiTokens(path string, tokens []ApiToken) error {
+	if tokens == nil {
+		tokens = []ApiToken{}
+	}
+	bs, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, bs, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// AddApiToken generates a new token for the API token at and stores it in path, the token being returned
+// for being handed over, as it cannot be retrieved later
+func AddApiToken(path string, at ApiToken) (string, error) {
+	if at.Name == "" || strings.Contains(at.Name, ":") {
+		return "", fmt.Errorf("in AddApiToken: invalid token name \"%s\"", at.Name)
+	}
+	if len(at.Ops) == 0 {
+		return "", fmt.Errorf("in AddApiToken: no operation allowed for token %s", at.Name)
+	}
+	for _, op := range at.Ops {
+		if err := CheckApiOp(op); err != nil {
+			return "", fmt.Errorf("in AddApiToken: %w", err)
+		}
+	}
+	tokens, err := LoadApiTokens(path)
+	if err != nil {
+		return "", fmt.Errorf("in AddApiToken: %w", err)
+	}
+	for _, t := range tokens {
+		if t.Name == at.Name {
+			return "", fmt.Errorf("in AddApiToken: token %s already exists", at.Name)
+		}
+	}
+	bs := make([]byte, 32)
+	if _, err = rand.Read(bs); err != nil {
+		return "", fmt.Errorf("in AddApiToken: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(bs)
+	at.Hash = apiTokenHash(token)
+	if at.Created == 0 {
+		at.Created = time.Now().Unix()
+	}
+	if err = saveApiTokens(path, append(tokens, at)); err != nil {
+		return "", fmt.Errorf("in AddApiToken: %w", err)
+	}
+	return token, nil
+}
+
+// RevokeApiToken removes the API token name from path
+func RevokeApiToken(path, name string) error {
+	tokens, err := LoadApiTokens(path)
+	if err != nil {
+		return fmt.Errorf("in RevokeApiToken: %w", err)
+	}
+	var kept []ApiToken
+	for _, t := range tokens {
+		if t.Name != name {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(tokens) {
+		return fmt.Errorf("in RevokeApiToken: no such token %s", name)
+	}
+	if err = saveApiTokens(path, kept); err != nil {
+		return fmt.Errorf("in RevokeApiToken: %w", err)
+	}
+	return nil
+}
+
+// apiTokenStore authenticates requests with the API tokens file, reloaded when modified
+type apiTokenStore struct {
+	path   string
+	mx     sync.Mutex
+	mtime  time.Time
+	size   int64
+	tokens map[string]ApiToken
+}
+
+func (ats *apiTokenStore) reload() error {
+	fi, err := os.Stat(ats.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			ats.tokens = map[string]ApiToken{}
+			return nil
+		}
+		return err
+	}
+	if ats.tokens != nil && fi.ModTime().Equal(ats.mtime) && fi.Size() == ats.size {
+		return nil
+	}
+	tokens, err := LoadApiTokens(ats.path)
+	if err != nil {
+		return err
+	}
+	ats.tokens = map[string]ApiToken{}
+	for _, t := range tokens {
+		ats.tokens[t.Name] = t
+	}
+	ats.mtime, ats.size = fi.ModTime(), fi.Size()
+	return nil
+}
+
+// authenticate tells if the token is a valid one, and if so if it allows the operation op on root
+func (ats *apiTokenStore) authenticate(name, token, root, op string) (valid bool, allowed bool) {
+	ats.mx.Lock()
+	defer ats.mx.Unlock()
+	if err := ats.reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "API tokens %s cannot be loaded: %v\n", ats.path, err)
+		return false, false
+	}
+	at, ok := ats.tokens[name]
+	if !ok || subtle.ConstantTimeCompare([]byte(apiTokenHash(token)), []byte(at.Hash)) != 1 {
+		return false, false
+	}
+	now := time.Now().Unix()
+	if at.IsExpired(now) {
+		return false, false
+	}
+	return true, at.allows(root, op, now)
+}
+
+// apiOperation returns the operation of a request given its method and its route below the DSS root
+func apiOperation(method, route string) string {
+	switch route {
+	// DSS remote API and wfs API
+	case "removeMeta", "xRemoveMeta", "removeContent/:ch", "scanPhysicalStorage",
+		"storeConvergenceKey", "storeTags",
+		"wfsSuEnableWrite/", "wfsSuEnableWrite/:npath":
+		return ApiOpAdmin
+	case "recordClient/:clId", "updateClient/:clId",
+		"queryMetaTimes", "loadMeta", "spGetContentReader", "spGetContentSignatures",
+		"wfsGetContentSignatures":
+		return ApiOpRead
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return ApiOpRead
+	}
+	return ApiOpWrite
+}
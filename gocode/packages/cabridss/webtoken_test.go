@@ -0,0 +1,102 @@
+package cabridss
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApiTokens(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestApiTokens", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	path := ApiTokensPath(tfs.Path())
+	reader, err := AddApiToken(path, ApiToken{Name: "reader", Roots: []string{"dss1"}, Ops: []string{ApiOpRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := AddApiToken(path, ApiToken{Name: "writer", Ops: []string{ApiOpRead, ApiOpWrite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := AddApiToken(path, ApiToken{Name: "admin", Ops: []string{ApiOpRead, ApiOpWrite, ApiOpAdmin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := AddApiToken(path, ApiToken{Name: "expired", Ops: []string{ApiOpRead}, Expires: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = AddApiToken(path, ApiToken{Name: "reader", Ops: []string{ApiOpRead}}); err == nil {
+		t.Fatal("TestApiTokens duplicate token name should fail")
+	}
+	if _, err = AddApiToken(path, ApiToken{Name: "other", Ops: []string{"delete"}}); err == nil {
+		t.Fatal("TestApiTokens invalid operation should fail")
+	}
+
+	s := NewEServer("localhost:3000", false, &TlsConfig{apiTokens: path})
+	ok := func(c echo.Context) error { return c.JSON(http.StatusOK, "ok") }
+	for _, root := range []string{"dss1", "dss2"} {
+		if err = s.ConfigureApi(root, nil, nil, func(e *echo.Echo, root string, _ map[string]interface{}) error {
+			e.GET(root+"loadIndex", ok)
+			e.POST(root+"loadMeta", ok)
+			e.POST(root+"storeMeta", ok)
+			e.DELETE(root+"removeMeta", ok)
+			e.PUT(root+"storeTags", ok)
+			e.PUT(root+"storeConvergenceKey", ok)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	status := func(method, url, user, password string) int {
+		req := httptest.NewRequest(method, url, nil)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		rec := httptest.NewRecorder()
+		s.getEcho().ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for _, c := range []struct {
+		method, url, user, password string
+		code                        int
+	}{
+		{http.MethodGet, "/dss1/check", "", "", http.StatusOK},
+		{http.MethodGet, "/dss1/loadIndex", "", "", http.StatusUnauthorized},
+		{http.MethodGet, "/dss1/loadIndex", "reader", reader, http.StatusOK},
+		{http.MethodPost, "/dss1/loadMeta", "reader", reader, http.StatusOK},
+		{http.MethodGet, "/dss1/loadIndex", "reader", admin, http.StatusUnauthorized},
+		{http.MethodPost, "/dss1/storeMeta", "reader", reader, http.StatusForbidden},
+		{http.MethodGet, "/dss2/loadIndex", "reader", reader, http.StatusForbidden},
+		{http.MethodPost, "/dss2/storeMeta", "writer", writer, http.StatusOK},
+		{http.MethodDelete, "/dss2/removeMeta", "writer", writer, http.StatusForbidden},
+		{http.MethodPut, "/dss1/storeTags", "writer", writer, http.StatusForbidden},
+		{http.MethodPut, "/dss1/storeConvergenceKey", "writer", writer, http.StatusForbidden},
+		{http.MethodPut, "/dss1/storeTags", "admin", admin, http.StatusOK},
+		{http.MethodPut, "/dss1/storeConvergenceKey", "admin", admin, http.StatusOK},
+		{http.MethodPost, "/dss2/storeMeta", "admin", admin, http.StatusOK},
+		{http.MethodDelete, "/dss2/removeMeta", "admin", admin, http.StatusOK},
+		{http.MethodGet, "/dss1/loadIndex", "expired", expired, http.StatusUnauthorized},
+	} {
+		if code := status(c.method, c.url, c.user, c.password); code != c.code {
+			t.Fatalf("TestApiTokens %s %s as %s status %d expected %d", c.method, c.url, c.user, code, c.code)
+		}
+	}
+
+	if err = RevokeApiToken(path, "reader"); err != nil {
+		t.Fatal(err)
+	}
+	if code := status(http.MethodGet, "/dss1/loadIndex", "reader", reader); code != http.StatusUnauthorized {
+		t.Fatalf("TestApiTokens revoked token status %d", code)
+	}
+	tokens, err := LoadApiTokens(path)
+	if err != nil || len(tokens) != 3 || tokens[0].Name != "writer" || tokens[0].Hash == admin {
+		t.Fatal(err, tokens)
+	}
+}
@@ -82,13 +82,13 @@ func (wdi *wfsDssImpl) GetContentWriter(npath string, mtime int64, acl []ACLEntr
 
 func (wdi *wfsDssImpl) GetContentReader(npath string) (rc io.ReadCloser, err error) {
 	if wdi.reducer == nil {
-		return cfsGetContentReader(wdi.apc, npath)
+		return cfsGetContentReader(wdi.apc, npath, "")
 	}
 	if err = wdi.reducer.Launch(
 		fmt.Sprintf("GetContentReader %s", npath),
 		func() error {
 			var iErr error
-			if rc, iErr = cfsGetContentReader(wdi.apc, npath); iErr != nil {
+			if rc, iErr = cfsGetContentReader(wdi.apc, npath, ""); iErr != nil {
 				return iErr
 			}
 			return nil
@@ -98,6 +98,71 @@ func (wdi *wfsDssImpl) GetContentReader(npath string) (rc io.ReadCloser, err err
 	return
 }
 
+func (wdi *wfsDssImpl) GetContentRangeReader(npath string, offset, length int64) (rc io.ReadCloser, err error) {
+	if wdi.reducer == nil {
+		return cfsGetContentReader(wdi.apc, npath, httpRange(offset, length))
+	}
+	if err = wdi.reducer.Launch(
+		fmt.Sprintf("GetContentRangeReader %s", npath),
+		func() error {
+			var iErr error
+			if rc, iErr = cfsGetContentReader(wdi.apc, npath, httpRange(offset, length)); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+func (wdi *wfsDssImpl) GetContentSignatures(npath string, blockSize int) (sigs *ContentSignatures, err error) {
+	if wdi.reducer == nil {
+		return cfsGetContentSignatures(wdi.apc, npath, blockSize)
+	}
+	if err = wdi.reducer.Launch(
+		fmt.Sprintf("GetContentSignatures %s", npath),
+		func() error {
+			var iErr error
+			if sigs, iErr = cfsGetContentSignatures(wdi.apc, npath, blockSize); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+func (wdi *wfsDssImpl) GetContentDeltaWriter(npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (wc io.WriteCloser, err error) {
+	if wdi.reducer == nil {
+		return cfsGetContentDeltaWriter(wdi.apc, npath, mtime, acl, cb)
+	}
+	if err = wdi.reducer.Launch(
+		fmt.Sprintf("GetContentDeltaWriter %s", npath),
+		func() error {
+			var iErr error
+			if wc, iErr = cfsGetContentDeltaWriter(wdi.apc, npath, mtime, acl, cb); iErr != nil {
+				return iErr
+			}
+			return nil
+		}); err != nil {
+		return
+	}
+	return
+}
+
+func (wdi *wfsDssImpl) CopyContent(spath, npath, ch string, mtime int64, acl []ACLEntry) (err error) {
+	if wdi.reducer == nil {
+		return cfsCopyContent(wdi.apc, spath, npath, ch, mtime, acl)
+	}
+	return wdi.reducer.Launch(
+		fmt.Sprintf("CopyContent %s", npath),
+		func() error {
+			return cfsCopyContent(wdi.apc, spath, npath, ch, mtime, acl)
+		})
+}
+
 func (wdi *wfsDssImpl) Symlink(npath, tpath string, mtime int64, acl []ACLEntry) (err error) {
 	if wdi.reducer == nil {
 		return cfsSymlink(wdi.apc, npath, tpath, mtime, acl)
@@ -116,6 +181,17 @@ func (wdi *wfsDssImpl) Symlink(npath, tpath string, mtime int64, acl []ACLEntry)
 	return
 }
 
+func (wdi *wfsDssImpl) Rename(src, dst string, mtime int64) (err error) {
+	if wdi.reducer == nil {
+		return cfsRename(wdi.apc, src, dst, mtime)
+	}
+	return wdi.reducer.Launch(
+		fmt.Sprintf("Rename %s", src),
+		func() error {
+			return cfsRename(wdi.apc, src, dst, mtime)
+		})
+}
+
 func (wdi *wfsDssImpl) Remove(npath string) (err error) {
 	if wdi.reducer == nil {
 		return cfsRemove(wdi.apc, npath)
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 type mfsMkupdateNs struct {
@@ -29,6 +30,16 @@ type mfsGetContentWriterIn struct {
 	ACL   []ACLEntry `json:"acl"`
 }
 
+type mfsGetContentSignaturesIn struct {
+	Npath     string `json:"npath"`
+	BlockSize int    `json:"blockSize"`
+}
+
+type mfsGetContentSignaturesOut struct {
+	mError
+	Sigs *ContentSignatures `json:"sigs"`
+}
+
 type mfsGetMetaOut struct {
 	mError
 	MetaOut Meta `json:"meta"`
@@ -41,6 +52,20 @@ type mfsSymlink struct {
 	ACL   []ACLEntry `json:"acl"`
 }
 
+type mfsCopyContent struct {
+	Spath string     `json:"spath"`
+	Npath string     `json:"npath"`
+	Ch    string     `json:"ch"`
+	Mtime int64      `json:"mtime,string"`
+	ACL   []ACLEntry `json:"acl"`
+}
+
+type mfsRename struct {
+	Src   string `json:"src"`
+	Dst   string `json:"dst"`
+	Mtime int64  `json:"mtime,string"`
+}
+
 func cfsInitialize(apc WebApiClient) error {
 	var out mError
 	_, err := apc.SimpleDoAsJson(http.MethodGet, apc.Url()+"wfsInitialize", nil, &out)
@@ -199,6 +224,14 @@ func newCfsClientWriterReader(ccw *cfsClientWriter, header []byte) *cfsClientWri
 }
 
 func cfsGetContentWriter(apc WebApiClient, npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (pcw io.WriteCloser, err error) {
+	return cfsGetContentWriterWhatever(apc, "wfsGetContentWriter", npath, mtime, acl, cb)
+}
+
+func cfsGetContentDeltaWriter(apc WebApiClient, npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (pcw io.WriteCloser, err error) {
+	return cfsGetContentWriterWhatever(apc, "wfsGetContentDeltaWriter", npath, mtime, acl, cb)
+}
+
+func cfsGetContentWriterWhatever(apc WebApiClient, urlPath string, npath string, mtime int64, acl []ACLEntry, cb WriteCloserCb) (pcw io.WriteCloser, err error) {
 	jsonArgs, err := json.Marshal(mfsGetContentWriterIn{Npath: npath, Mtime: mtime, ACL: acl})
 	if err != nil {
 		return
@@ -211,7 +244,7 @@ func cfsGetContentWriter(apc WebApiClient, npath string, mtime int64, acl []ACLE
 			resp *http.Response
 			bs   []byte
 		)
-		req, err = http.NewRequest(http.MethodPost, apc.Url()+"wfsGetContentWriter", nil)
+		req, err = http.NewRequest(http.MethodPost, apc.Url()+urlPath, nil)
 		lja := internal.Int64ToStr16(int64(len(jsonArgs)))
 		header := make([]byte, 16+len(jsonArgs))
 		copy(header, lja)
@@ -240,13 +273,17 @@ func cfsGetContentWriter(apc WebApiClient, npath string, mtime int64, acl []ACLE
 	return ccw, nil
 }
 
-func cfsGetContentReader(apc WebApiClient, npath string) (io.ReadCloser, error) {
+// cfsGetContentReader gets the content npath, or only its range hRange if not empty
+func cfsGetContentReader(apc WebApiClient, npath string, hRange string) (io.ReadCloser, error) {
 	epath := url.PathEscape(npath)
 	req, err := http.NewRequest(http.MethodGet, apc.Url()+"wfsGetContentReader/"+epath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("in cfsGetContentReader: %w", err)
 	}
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if hRange != "" {
+		req.Header.Set("Range", hRange)
+	}
 	resp, err := apc.(*apiClient).client.Do(req, nil)
 	if err != nil {
 		return nil, fmt.Errorf("in cfsGetContentReader: %w", err)
@@ -268,11 +305,27 @@ func cfsGetContentReader(apc WebApiClient, npath string) (io.ReadCloser, error)
 		if n, err := resp.Body.Read(sErr); n != int(lj) || (err != nil && err != io.EOF) {
 			return nil, fmt.Errorf("in cfsGetContentReader: %w", err)
 		}
+		if hRange != "" && strings.HasSuffix(string(sErr), ErrInvalidRange.Error()) {
+			return nil, fmt.Errorf("in cfsGetContentReader: %s: %w", sErr, ErrInvalidRange)
+		}
 		return nil, fmt.Errorf("in cfsGetContentReader: %s", sErr)
 	}
 	return resp.Body, nil
 }
 
+func cfsGetContentSignatures(apc WebApiClient, npath string, blockSize int) (*ContentSignatures, error) {
+	var out mfsGetContentSignaturesOut
+	_, err := apc.SimpleDoAsJson(http.MethodPost, apc.Url()+"wfsGetContentSignatures",
+		mfsGetContentSignaturesIn{Npath: npath, BlockSize: blockSize}, &out)
+	if err != nil {
+		return nil, fmt.Errorf("in cfsGetContentSignatures: %w", err)
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("in cfsGetContentSignatures: %s", out.Error)
+	}
+	return out.Sigs, nil
+}
+
 func cfsSymlink(apc WebApiClient, npath, tpath string, mtime int64, acl []ACLEntry) error {
 	var rer mError
 	_, err := apc.SimpleDoAsJson(http.MethodPost, apc.Url()+"wfsSymlink",
@@ -291,6 +344,42 @@ func cfsSymlink(apc WebApiClient, npath, tpath string, mtime int64, acl []ACLEnt
 	return nil
 }
 
+func cfsCopyContent(apc WebApiClient, spath, npath, ch string, mtime int64, acl []ACLEntry) error {
+	var rer mError
+	_, err := apc.SimpleDoAsJson(http.MethodPost, apc.Url()+"wfsCopyContent",
+		mfsCopyContent{
+			Spath: spath,
+			Npath: npath,
+			Ch:    ch,
+			Mtime: mtime,
+			ACL:   acl,
+		}, &rer)
+	if err != nil {
+		return fmt.Errorf("in cfsCopyContent: %w", err)
+	}
+	if rer.Error != "" {
+		return fmt.Errorf("in cfsCopyContent: %s", rer.Error)
+	}
+	return nil
+}
+
+func cfsRename(apc WebApiClient, src, dst string, mtime int64) error {
+	var rer mError
+	_, err := apc.SimpleDoAsJson(http.MethodPost, apc.Url()+"wfsRename",
+		mfsRename{
+			Src:   src,
+			Dst:   dst,
+			Mtime: mtime,
+		}, &rer)
+	if err != nil {
+		return fmt.Errorf("in cfsRename: %w", err)
+	}
+	if rer.Error != "" {
+		return fmt.Errorf("in cfsRename: %s", rer.Error)
+	}
+	return nil
+}
+
 func cfsRemove(apc WebApiClient, npath string) (err error) {
 	var rer mError
 	epath := url.PathEscape(npath)
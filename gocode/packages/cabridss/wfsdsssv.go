@@ -66,7 +66,7 @@ func sfsLsnsRoot(c echo.Context) error {
 	return sfsLsnsWhatever(c, "")
 }
 
-func sfsGetContentWriter(c echo.Context) error {
+func sfsGetContentWriterWhatever(c echo.Context, isDelta bool) error {
 	req := c.Request()
 	slja := make([]byte, 16)
 	if n, err := req.Body.Read(slja); n != 16 || err != nil {
@@ -86,16 +86,44 @@ func sfsGetContentWriter(c echo.Context) error {
 		return NewServerErr("sfsGetContentWriter", err)
 	}
 	dss := GetCustomConfig(c).(WfsDssServerConfig).Dss
-	wc, err := dss.GetContentWriter(args.Npath, args.Mtime, args.ACL, nil)
+	var wc io.WriteCloser
+	if isDelta {
+		wc, err = dss.GetContentDeltaWriter(args.Npath, args.Mtime, args.ACL, nil)
+	} else {
+		wc, err = dss.GetContentWriter(args.Npath, args.Mtime, args.ACL, nil)
+	}
 	if err != nil {
 		return c.JSON(http.StatusOK, &mError{Error: err.Error()})
 	}
-	defer wc.Close()
 	_, err = io.Copy(wc, req.Body)
+	cErr := wc.Close()
 	if err != nil {
 		return NewServerErr("sfsGetContentWriter", err)
 	}
-	return c.JSON(http.StatusOK, &mError{})
+	return c.JSON(http.StatusOK, err2mError(cErr))
+}
+
+func sfsGetContentWriter(c echo.Context) error {
+	return sfsGetContentWriterWhatever(c, false)
+}
+
+func sfsGetContentDeltaWriter(c echo.Context) error {
+	return sfsGetContentWriterWhatever(c, true)
+}
+
+func sfsGetContentSignatures(c echo.Context) error {
+	var gs mfsGetContentSignaturesIn
+	if err := c.Bind(&gs); err != nil {
+		return NewServerErr("sfsGetContentSignatures", err)
+	}
+	dss := GetCustomConfig(c).(WfsDssServerConfig).Dss
+	var out mfsGetContentSignaturesOut
+	sigs, err := dss.GetContentSignatures(gs.Npath, gs.BlockSize)
+	out.Sigs = sigs
+	if err != nil {
+		out.Error = err.Error()
+	}
+	return c.JSON(http.StatusOK, &out)
 }
 
 func sfsGetContentReader(c echo.Context) error {
@@ -107,7 +135,17 @@ func sfsGetContentReader(c echo.Context) error {
 	dss := GetCustomConfig(c).(WfsDssServerConfig).Dss
 	resp := c.Response()
 	resp.Writer.Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
-	rc, err := dss.GetContentReader(npath)
+	status := http.StatusOK
+	var rc io.ReadCloser
+	if hr := c.Request().Header.Get("Range"); hr != "" {
+		var offset, length int64
+		if offset, length, err = parseHttpRange(hr, -1); err == nil {
+			status = http.StatusPartialContent
+			rc, err = dss.GetContentRangeReader(npath, offset, length)
+		}
+	} else {
+		rc, err = dss.GetContentReader(npath)
+	}
 	if err != nil {
 		resp.WriteHeader(http.StatusOK)
 		sErr := err.Error()
@@ -116,7 +154,7 @@ func sfsGetContentReader(c echo.Context) error {
 		return nil
 	}
 	defer rc.Close()
-	resp.WriteHeader(http.StatusOK)
+	resp.WriteHeader(status)
 	io.Copy(resp.Writer, strings.NewReader(internal.Int64ToStr16(int64(0))))
 	io.Copy(resp.Writer, rc)
 	return nil
@@ -131,6 +169,24 @@ func sfsSymlink(c echo.Context) error {
 	return c.JSON(http.StatusOK, err2mError(dss.Symlink(sl.Npath, sl.Tpath, sl.Mtime, sl.ACL)))
 }
 
+func sfsCopyContent(c echo.Context) error {
+	dss := GetCustomConfig(c).(WfsDssServerConfig).Dss
+	var cc mfsCopyContent
+	if err := c.Bind(&cc); err != nil {
+		return NewServerErr("sfsCopyContent", err)
+	}
+	return c.JSON(http.StatusOK, err2mError(dss.CopyContent(cc.Spath, cc.Npath, cc.Ch, cc.Mtime, cc.ACL)))
+}
+
+func sfsRename(c echo.Context) error {
+	dss := GetCustomConfig(c).(WfsDssServerConfig).Dss
+	var rn mfsRename
+	if err := c.Bind(&rn); err != nil {
+		return NewServerErr("sfsRename", err)
+	}
+	return c.JSON(http.StatusOK, err2mError(dss.Rename(rn.Src, rn.Dst, rn.Mtime)))
+}
+
 func sfsRemove(c echo.Context) error {
 	var (
 		err   error
@@ -202,7 +258,11 @@ func WfsDssServerConfigurator(e *echo.Echo, root string, configs map[string]inte
 	e.GET(root+"wfsLsns/", sfsLsnsRoot)
 	e.POST(root+"wfsGetContentWriter", sfsGetContentWriter)
 	e.GET(root+"wfsGetContentReader/:npath", sfsGetContentReader)
+	e.POST(root+"wfsGetContentSignatures", sfsGetContentSignatures)
+	e.POST(root+"wfsGetContentDeltaWriter", sfsGetContentDeltaWriter)
 	e.POST(root+"wfsSymlink", sfsSymlink)
+	e.POST(root+"wfsCopyContent", sfsCopyContent)
+	e.POST(root+"wfsRename", sfsRename)
 	e.DELETE(root+"wfsRemove/:npath", sfsRemove)
 	e.GET(root+"wfsGetMeta/:npath", sfsGetMeta)
 	e.GET(root+"wfsGetMeta/", sfsGetMetaRoot)
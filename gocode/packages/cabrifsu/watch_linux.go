@@ -0,0 +1,172 @@
+//go:build linux
+
+package cabrifsu
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DONT_FOLLOW | unix.IN_ONLYDIR
+
+// Watcher reports the entries changed in a directory tree, using Linux inotify
+type Watcher struct {
+	root   string
+	fd     int
+	mux    sync.Mutex
+	wds    map[int]string // watch descriptors to directory paths relative to root
+	done   chan struct{}
+	wg     sync.WaitGroup
+	Events chan string // paths relative to root of the changed entries, "" if the whole tree must be checked
+	Errors chan error
+}
+
+// NewWatcher watches the directory tree under root recursively
+func NewWatcher(root string) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("in NewWatcher: %w", err)
+	}
+	w := &Watcher{
+		root:   strings.TrimSuffix(root, "/"),
+		fd:     fd,
+		wds:    map[int]string{},
+		done:   make(chan struct{}),
+		Events: make(chan string, 1024),
+		Errors: make(chan error, 1),
+	}
+	if err = w.addTree(""); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("in NewWatcher: %w", err)
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w, nil
+}
+
+// Close stops watching and closes the Events channel
+func (w *Watcher) Close() error {
+	close(w.done)
+	w.wg.Wait()
+	return unix.Close(w.fd)
+}
+
+func (w *Watcher) fullPath(rel string) string {
+	if rel == "" {
+		return w.root
+	}
+	return w.root + "/" + rel
+}
+
+func joinRel(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// addTree watches the directory rel and its sub-directories
+func (w *Watcher) addTree(rel string) error {
+	wd, err := unix.InotifyAddWatch(w.fd, w.fullPath(rel), watchMask)
+	if err != nil {
+		if rel != "" && (err == unix.ENOENT || err == unix.ENOTDIR) {
+			// removed in the meantime
+			return nil
+		}
+		return err
+	}
+	w.mux.Lock()
+	w.wds[wd] = rel
+	w.mux.Unlock()
+	entries, err := os.ReadDir(w.fullPath(rel))
+	if err != nil {
+		if rel != "" && os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err = w.addTree(joinRel(rel, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) send(rel string) bool {
+	select {
+	case w.Events <- rel:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *Watcher) loop() {
+	defer w.wg.Done()
+	defer close(w.Events)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	pfds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		n, err := unix.Poll(pfds, 200)
+		if err == unix.EINTR || n == 0 {
+			continue
+		}
+		if err != nil {
+			w.Errors <- fmt.Errorf("in Watcher: %w", err)
+			return
+		}
+		n, err = unix.Read(w.fd, buf)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			w.Errors <- fmt.Errorf("in Watcher: %w", err)
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			name := string(bytes.TrimRight(buf[offset+unix.SizeofInotifyEvent:offset+unix.SizeofInotifyEvent+int(ev.Len)], "\x00"))
+			offset += unix.SizeofInotifyEvent + int(ev.Len)
+			if !w.handle(ev, name) {
+				return
+			}
+		}
+	}
+}
+
+func (w *Watcher) handle(ev *unix.InotifyEvent, name string) bool {
+	if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+		return w.send("")
+	}
+	w.mux.Lock()
+	dir, ok := w.wds[int(ev.Wd)]
+	if ev.Mask&unix.IN_IGNORED != 0 {
+		delete(w.wds, int(ev.Wd))
+	}
+	w.mux.Unlock()
+	if !ok || name == "" {
+		return true
+	}
+	rel := joinRel(dir, name)
+	if ev.Mask&unix.IN_ISDIR != 0 && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		if err := w.addTree(rel); err != nil {
+			w.Errors <- fmt.Errorf("in Watcher: %w", err)
+			return false
+		}
+	}
+	return w.send(rel)
+}
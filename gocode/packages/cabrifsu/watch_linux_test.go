@@ -0,0 +1,58 @@
+//go:build linux
+
+package cabrifsu
+
+import (
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestWatcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(ufpath.Join(dir, "d1"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcher(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	expect := func(expected string) {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case rel := <-w.Events:
+				if rel == expected {
+					return
+				}
+			case err := <-w.Errors:
+				t.Fatal(err)
+			case <-timeout:
+				t.Fatalf("TestWatcher: no event for %s", expected)
+			}
+		}
+	}
+	if err = os.WriteFile(ufpath.Join(dir, "d1", "f1.txt"), []byte("content 1\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	expect("d1/f1.txt")
+	if err := os.Mkdir(ufpath.Join(dir, "d2"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	expect("d2")
+	if err = os.WriteFile(ufpath.Join(dir, "d2", "f2.txt"), []byte("content 2\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	expect("d2/f2.txt")
+	if err = os.Rename(ufpath.Join(dir, "d1", "f1.txt"), ufpath.Join(dir, "f1.txt")); err != nil {
+		t.Fatal(err)
+	}
+	expect("d1/f1.txt")
+	expect("f1.txt")
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package cabrifsu
+
+import "fmt"
+
+// Watcher reports the entries changed in a directory tree, only available on Linux
+type Watcher struct {
+	Events chan string
+	Errors chan error
+}
+
+func NewWatcher(root string) (*Watcher, error) {
+	return nil, fmt.Errorf("in NewWatcher: watching %s is only available on Linux", root)
+}
+
+func (w *Watcher) Close() error { return nil }
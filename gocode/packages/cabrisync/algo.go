@@ -65,6 +65,9 @@ func lrMetaErrs(outs []interface{}) (lErr, rErr error) {
 }
 
 func isExcluded(syc *syncCtx) bool {
+	if syc.isFiltered() {
+		return true
+	}
 	lfp := syc.left.fullPath()
 	for _, re := range syc.options.ExclList {
 		if re.MatchString(lfp) {
@@ -83,7 +86,15 @@ func isExcluded(syc *syncCtx) bool {
 	return false
 }
 
-func syncNs(ctx context.Context, syc *syncCtx) []SyncReportEntry {
+// entryDone provides the OnEntry callback with the entry just synchronized, the last one of entries
+func (syc *syncCtx) entryDone(entries []SyncReportEntry) {
+	if syc.options.OnEntry != nil && len(entries) > 0 {
+		syc.options.OnEntry(entries[len(entries)-1])
+	}
+}
+
+func syncNs(ctx context.Context, syc *syncCtx) (entries []SyncReportEntry) {
+	defer func() { syc.entryDone(entries) }()
 	syc.diagnose(">syncNs", false)
 	rent := SyncReportEntry{IsNs: true, LPath: syc.left.fullPath(), RPath: syc.right.fullPath()}
 	if syc.err != nil {
@@ -108,16 +119,29 @@ func syncNs(ctx context.Context, syc *syncCtx) []SyncReportEntry {
 	}
 
 	syc.eval(&rent)
+	if syc.err = syc.loadIgnoreFile(); syc.err == nil {
+		syc.err = syc.filterChildren()
+	}
+	if syc.err != nil {
+		rent.Err = syc.err
+		syc.diagnose(fmt.Sprintf("<syncNs %v", syc.err), true)
+		return []SyncReportEntry{rent}
+	}
 
 	syc.evalNsMerge()
+	if leftErr == nil && rightErr == nil {
+		syc.visitState()
+	}
+	syc.registerRemoved()
 	if !syc.options.Evaluate {
 		syc.mergeNsBefore(rent)
 	}
+	syc.copies = syc.newConflictCopies(rent)
 
 	chsSyc := make([]syncCtx, 0)
 	for _, pch := range syc.leftAndRight {
 		isNs := pch[len(pch)-1] == '/'
-		if isNs && !syc.options.InDepth {
+		if (isNs && !syc.options.InDepth) || !syc.isDirty(pch) {
 			continue
 		}
 		chsSyc = append(chsSyc, syc.makeChild(pch))
@@ -128,7 +152,7 @@ func syncNs(ctx context.Context, syc *syncCtx) []SyncReportEntry {
 		[]interface{}{chsSyc})
 	_ = iOuts
 
-	entries := make([]SyncReportEntry, 0)
+	entries = make([]SyncReportEntry, 0)
 	for _, chEntries := range plumber.Retype[[]SyncReportEntry](iOuts) {
 		for _, se := range chEntries {
 			entries = append(entries, se)
@@ -136,6 +160,7 @@ func syncNs(ctx context.Context, syc *syncCtx) []SyncReportEntry {
 	}
 
 	if !syc.options.Evaluate {
+		syc.mergeConflictCopies()
 		syc.mergeNsAfter(rent)
 	}
 	if syc.options.RefDiag != nil {
@@ -148,7 +173,8 @@ func syncNs(ctx context.Context, syc *syncCtx) []SyncReportEntry {
 	return entries
 }
 
-func syncContentOrSymLink(ctx context.Context, syc *syncCtx) []SyncReportEntry {
+func syncContentOrSymLink(ctx context.Context, syc *syncCtx) (entries []SyncReportEntry) {
+	defer func() { syc.entryDone(entries) }()
 	syc.diagnose(">syncContentOrSymLink", false)
 	rent := SyncReportEntry{IsNs: false, LPath: syc.left.fullPath(), RPath: syc.right.fullPath()}
 	if syc.err != nil {
@@ -160,6 +186,10 @@ func syncContentOrSymLink(ctx context.Context, syc *syncCtx) []SyncReportEntry {
 		rent.Excluded = true
 		return []SyncReportEntry{rent}
 	}
+	if syc.resumed() {
+		rent.Resumed = true
+		return []SyncReportEntry{rent}
+	}
 	iLrOuts := plumber.LaunchAndWait(ctx,
 		[]string{"SyncGetMetas"},
 		[]plumber.Launchable{plizedGetLRMeta},
@@ -171,8 +201,22 @@ func syncContentOrSymLink(ctx context.Context, syc *syncCtx) []SyncReportEntry {
 		syc.diagnose(fmt.Sprintf("<syncContentOrSymLink %v", syc.err), true)
 		return []SyncReportEntry{rent}
 	}
+	if syc.isFilteredOut() {
+		rent.Excluded = true
+		return []SyncReportEntry{rent}
+	}
 
 	syc.eval(&rent)
+	syc.evalConflict(&rent)
+	syc.registerExisting(&rent)
+	ms, moved := syc.moveSource(&rent)
+	if moved {
+		if ms.removed {
+			rent.MovedFrom = ms.path
+		} else {
+			rent.CopiedFrom = ms.path
+		}
+	}
 	if syc.options.RefDiag != nil {
 		if syc.options.RefDiag.Left[rent.LPath] != rent {
 			syc.diagnose("content panic", false)
@@ -181,9 +225,20 @@ func syncContentOrSymLink(ctx context.Context, syc *syncCtx) []SyncReportEntry {
 		}
 	}
 	if !syc.options.Evaluate {
-		if syc.err == nil && (rent.Created || rent.Updated || rent.MUpdated) {
+		if syc.err == nil && rent.ConflictCopy != "" {
+			if syc.err = syc.keepConflictCopy(rent.isRTL, rent.ConflictCopy); syc.err != nil {
+				rent.Err = syc.err
+			}
+		}
+		if syc.err == nil && rent.Err == nil && (rent.Created || rent.Updated || rent.MUpdated) {
 			if rent.isSymLink {
 				syc.err = syc.crUpSymLink(rent.isRTL)
+			} else if moved {
+				if syc.err = syc.copyContent(rent.isRTL, ms.path); syc.err != nil {
+					// the source may have changed or cannot be shared, the content is transferred as usual
+					rent.MovedFrom, rent.CopiedFrom = "", ""
+					syc.err = syc.crUpContent(rent.isRTL)
+				}
 			} else {
 				syc.err = syc.crUpContent(rent.isRTL)
 			}
@@ -191,6 +246,8 @@ func syncContentOrSymLink(ctx context.Context, syc *syncCtx) []SyncReportEntry {
 				rent.Err = syc.err
 			}
 		}
+		syc.recordState(&rent)
+		syc.journalEntry(&rent)
 	}
 	syc.diagnose("<syncContentOrSymLink", true)
 	return []SyncReportEntry{rent}
@@ -6,6 +6,7 @@ import (
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/plumber"
 	"regexp"
+	"sync"
 )
 
 type BeVerboseFunc func(level int, line string)
@@ -20,14 +21,24 @@ type SyncOptions struct {
 	Evaluate     bool // don't synchronize, just report work to be done
 	BiDir        bool // bidirectional synchronization, the latest modified content wins,
 	// if false synchronization is done from left to right
-	KeepContent bool                           // don't remove content deleted from one side in other side
-	NoCh        bool                           // don't evaluate checksum when not available, compare content's size and modification time
-	ExclList    []*regexp.Regexp               // list of regular expression patterns to exclude from sync
-	NoACL       bool                           // don't check ACL
-	LeftMapACL  map[string][]cabridss.ACLEntry // left to right ACL user names mapping
-	RightMapACL map[string][]cabridss.ACLEntry // right to left ACL user names mapping
-	BeVerbose   BeVerboseFunc                  // callback for process verbosity
-	RefDiag     *SyncRefDiag                   // a reference report for diagnosis
+	KeepContent   bool                           // don't remove content deleted from one side in other side
+	NoCh          bool                           // don't evaluate checksum when not available, compare content's size and modification time
+	ExclList      []*regexp.Regexp               // list of regular expression patterns to exclude from sync
+	Filter        *Filter                        // if not nil, gitignore-style rules and limits selecting the entries to synchronize
+	NoACL         bool                           // don't check ACL
+	Delta         bool                           // only transfer the differences of updated content when the target DSS supports it
+	Moves         bool                           // copy moved or copied content within the target DSS instead of transferring it
+	StateDir      string                         // if not empty, directory where the last synchronized state is kept to detect BiDir conflicts or synchronize incrementally
+	Incremental   bool                           // only synchronize the left entries whose metadata changed since the last synchronization, requires StateDir
+	Paths         []string                       // if not empty, only synchronize these paths relative to the left namespace, their sub-namespaces and their parent namespaces
+	OnConflict    ConflictPolicy                 // how content changed on both sides since the last synchronization is synchronized
+	CheckpointDir string                         // if not empty, directory where synchronized entries are journaled until the synchronization completes
+	Resume        bool                           // skip the entries journaled by an interrupted synchronization if unchanged since
+	LeftMapACL    map[string][]cabridss.ACLEntry // left to right ACL user names mapping
+	RightMapACL   map[string][]cabridss.ACLEntry // right to left ACL user names mapping
+	BeVerbose     BeVerboseFunc                  // callback for process verbosity
+	OnEntry       func(entry SyncReportEntry)    // if not nil, called with each entry as soon as its synchronization completes, calls are serialized
+	RefDiag       *SyncRefDiag                   // a reference report for diagnosis
 }
 
 func doSynchronize(ctx context.Context, ldss cabridss.Dss, lpath string, rdss cabridss.Dss, rpath string, options SyncOptions) (report SyncReport) {
@@ -44,12 +55,65 @@ func doSynchronize(ctx context.Context, ldss cabridss.Dss, lpath string, rdss ca
 	if options.BiDir {
 		ldss.SetSu()
 	}
+	if options.OnEntry != nil {
+		var mx sync.Mutex
+		onEntry := options.OnEntry
+		options.OnEntry = func(entry SyncReportEntry) {
+			mx.Lock()
+			defer mx.Unlock()
+			onEntry(entry)
+		}
+	}
 	syc := syncCtx{
 		options: options,
 		left:    sideCtx{options: options, dss: ldss, root: lpath, isNs: true, exist: true},
 		right:   sideCtx{options: options, dss: rdss, isRight: true, root: rpath, isNs: true, exist: true},
 	}
+	if options.Filter != nil {
+		if report.GErr = options.Filter.compile(); report.GErr != nil {
+			return
+		}
+		syc.ignores = options.Filter.ignore
+	}
+	if options.Moves && !options.NoCh {
+		syc.moves = newMoveSources()
+	}
+	if (options.BiDir || options.Incremental) && options.StateDir != "" {
+		var err error
+		if syc.state, err = loadSyncState(options.StateDir, ldss, lpath, rdss, rpath); err != nil {
+			report.GErr = err
+			return
+		}
+	}
+	if options.Incremental {
+		if report.GErr = syc.evalIncremental(ldss, lpath); report.GErr != nil {
+			return
+		}
+	}
+	if len(options.Paths) != 0 {
+		if report.GErr = syc.evalPaths(); report.GErr != nil {
+			return
+		}
+	}
+	if options.CheckpointDir != "" && !options.Evaluate {
+		var err error
+		if syc.ckpt, err = openCheckpoint(options.CheckpointDir, ldss, lpath, rdss, rpath, options.Resume); err != nil {
+			report.GErr = err
+			return
+		}
+	}
 	report.Entries = syncNs(ctx, &syc)
+	if options.Incremental && !report.HasErrors() {
+		syc.state.sf.Pending = false
+	}
+	if syc.state != nil && !options.Evaluate {
+		report.GErr = syc.state.save()
+	}
+	if syc.ckpt != nil {
+		if err := syc.ckpt.close(!report.HasErrors()); err != nil && report.GErr == nil {
+			report.GErr = err
+		}
+	}
 	return
 }
 
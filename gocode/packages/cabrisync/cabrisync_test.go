@@ -1594,3 +1594,719 @@ func TestExclude(t *testing.T) {
 		t.Fatalf("TestExclude failed %+v", rs4)
 	}
 }
+
+func TestSynchronizeDeltaFsyWebOlf(t *testing.T) {
+	optionalSkip(t)
+	bs := make([]byte, 2*1024*1024)
+	for i := range bs {
+		bs[i] = byte(i * 7 % 251)
+	}
+	tfsl, err := testfs.CreateFs("TestSynchronizeDeltaFsyWebOlfLeft", func(tfs *testfs.Fs) error {
+		return os.WriteFile(ufpath.Join(tfs.Path(), "big.bin"), bs, 0644)
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsl.Delete()
+	dssl, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsl.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tfsr, err := testfs.CreateFs("TestSynchronizeDeltaFsyWebOlfRight", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsr.Delete()
+	getPIndex := func(config cabridss.DssBaseConfig, _ string) (cabridss.Index, error) {
+		return cabridss.NewPIndex(ufpath.Join(tfsr.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(":3000", "",
+		cabridss.CreateNewParams{Create: true, DssType: "olf", Root: tfsr.Path(), Size: "s", GetIndex: getPIndex},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	dssr, err := cabridss.NewWebDss(
+		cabridss.WebDssConfig{
+			DssBaseConfig: cabridss.DssBaseConfig{
+				ConfigDir: ufpath.Join(tfsr.Path(), ".cabri"),
+				WebPort:   "3000",
+			}},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dssr.Close()
+	if err = dssr.Mkns("", time.Now().Unix(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	noDelta := false
+	beVerbose := func(level int, line string) {
+		if strings.Contains(line, "no delta") {
+			noDelta = true
+		}
+	}
+	report := Synchronize(nil, dssl, "", dssr, "", SyncOptions{InDepth: true, NoACL: true, Delta: true})
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.CreNum != 1 {
+		t.Fatalf("TestSynchronizeDeltaFsyWebOlf failed %+v", rs)
+	}
+	copy(bs[1024*1024:], "updated")
+	bs = append(bs, "appended"...)
+	if err = os.WriteFile(ufpath.Join(tfsl.Path(), "big.bin"), bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Hour)
+	if err = os.Chtimes(ufpath.Join(tfsl.Path(), "big.bin"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	report = Synchronize(nil, dssl, "", dssr, "", SyncOptions{InDepth: true, NoACL: true, Delta: true, BeVerbose: beVerbose})
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.UpdNum != 1 || noDelta {
+		t.Fatalf("TestSynchronizeDeltaFsyWebOlf failed %+v %v", rs, noDelta)
+	}
+	rc, err := dssr.GetContentReader("big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	rbs, err := io.ReadAll(rc)
+	if err != nil || string(rbs) != string(bs) {
+		t.Fatalf("TestSynchronizeDeltaFsyWebOlf content differs %v", err)
+	}
+}
+
+func TestSynchronizeMovesFsyEDssApiOlf(t *testing.T) {
+	optionalSkip(t)
+	tfsl, err := testfs.CreateFs("TestSynchronizeMovesFsyEDssApiOlfLeft", func(tfs *testfs.Fs) error {
+		if err := os.Mkdir(ufpath.Join(tfs.Path(), "d1"), 0755); err != nil {
+			return err
+		}
+		for i, name := range []string{"d1/a.txt", "d1/b.txt", "c.txt"} {
+			if err := os.WriteFile(ufpath.Join(tfs.Path(), name), []byte(strings.Repeat(fmt.Sprintf("content %d\n", i), 1000)), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsl.Delete()
+	dssl, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsl.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tfsr, err := testfs.CreateFs("TestSynchronizeMovesFsyEDssApiOlfRight", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsr.Delete()
+	if _, err = cabridss.CreateOlfDss(cabridss.OlfConfig{
+		DssBaseConfig: cabridss.DssBaseConfig{LocalPath: tfsr.Path(), Encrypted: true},
+		Root:          tfsr.Path(), Size: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	dssr, err := cabridss.NewEDss(
+		cabridss.EDssConfig{
+			WebDssConfig: cabridss.WebDssConfig{
+				DssBaseConfig: cabridss.DssBaseConfig{LibApi: true, ConfigDir: ufpath.Join(tfsr.Path(), ".cabri")},
+				LibApiDssConfig: cabridss.LibApiDssConfig{
+					IsOlf: true,
+					OlfCfg: cabridss.OlfConfig{
+						DssBaseConfig: cabridss.DssBaseConfig{
+							LocalPath: tfsr.Path(),
+							GetIndex: func(config cabridss.DssBaseConfig, _ string) (cabridss.Index, error) {
+								return cabridss.NewPIndex(ufpath.Join(tfsr.Path(), "index.bdb"), false, false)
+							},
+						}, Root: tfsr.Path(), Size: "s"},
+				},
+			},
+		},
+		0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dssr.Close()
+	if err = dssr.Mkns("", time.Now().Unix(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rs := Synchronize(nil, dssl, "", dssr, "", SyncOptions{InDepth: true, NoACL: true, Moves: true}).GetStats(); rs.ErrNum != 0 || rs.CreNum != 4 || rs.MovNum != 0 {
+		t.Fatalf("TestSynchronizeMovesFsyEDssApiOlf initial sync %+v", rs)
+	}
+
+	// d1 is renamed d2 and c.txt is copied to e.txt
+	if err = os.Rename(ufpath.Join(tfsl.Path(), "d1"), ufpath.Join(tfsl.Path(), "d2")); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(ufpath.Join(tfsl.Path(), "c.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(ufpath.Join(tfsl.Path(), "e.txt"), bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := Synchronize(nil, dssl, "", dssr, "", SyncOptions{InDepth: true, NoACL: true, Moves: true, Evaluate: true})
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.CreNum != 4 || rs.RmvNum != 3 || rs.MovNum != 3 {
+		t.Fatalf("TestSynchronizeMovesFsyEDssApiOlf evaluate %+v", rs)
+	}
+	transferred := 0
+	beVerbose := func(level int, line string) {
+		if strings.HasPrefix(line, ">crUpContent") {
+			transferred++
+		}
+	}
+	report = Synchronize(nil, dssl, "", dssr, "", SyncOptions{InDepth: true, NoACL: true, Moves: true, BeVerbose: beVerbose})
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.CreNum != 4 || rs.RmvNum != 3 || rs.MovNum != 3 || transferred != 0 {
+		t.Fatalf("TestSynchronizeMovesFsyEDssApiOlf sync %+v %d", rs, transferred)
+	}
+	for _, entry := range report.Entries {
+		if (entry.LPath == "d2/a.txt" && entry.MovedFrom != "d1/a.txt") || (entry.LPath == "e.txt" && entry.CopiedFrom != "c.txt") {
+			t.Fatalf("TestSynchronizeMovesFsyEDssApiOlf entry %+v", entry)
+		}
+	}
+	sb := strings.Builder{}
+	report.SortByPath().TextOutput(&sb, false)
+	if !strings.Contains(sb.String(), ">+ d2/b.txt - moved from d1/b.txt\n") {
+		t.Fatalf("TestSynchronizeMovesFsyEDssApiOlf output %s", sb.String())
+	}
+	rc, err := dssr.GetContentReader("e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbs, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(rbs) != string(bs) {
+		t.Fatalf("TestSynchronizeMovesFsyEDssApiOlf content differs %v", err)
+	}
+	if rs := Synchronize(nil, dssl, "", dssr, "", SyncOptions{InDepth: true, NoACL: true, Moves: true}).GetStats(); rs.ErrNum != 0 || rs.CreNum != 0 || rs.RmvNum != 0 || rs.UpdNum != 0 {
+		t.Fatalf("TestSynchronizeMovesFsyEDssApiOlf no change %+v", rs)
+	}
+}
+
+func TestSynchronizeConflictsFsyOlf(t *testing.T) {
+	optionalSkip(t)
+	tfsl, err := testfs.CreateFs("TestSynchronizeConflictsFsyOlfLeft", func(tfs *testfs.Fs) error {
+		if err := os.Mkdir(ufpath.Join(tfs.Path(), "d"), 0755); err != nil {
+			return err
+		}
+		for _, name := range []string{"a.txt", "b.txt", "d/c.txt", "e.txt"} {
+			if err := os.WriteFile(ufpath.Join(tfs.Path(), name), []byte("initial "+name), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsl.Delete()
+	dssl, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsl.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tfsr, err := testfs.CreateFs("TestSynchronizeConflictsFsyOlfRight", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsr.Delete()
+	dssr, err := cabridss.CreateOlfDss(cabridss.OlfConfig{DssBaseConfig: cabridss.DssBaseConfig{LocalPath: tfsr.Path()}, Root: tfsr.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dssr.Close()
+	if err = dssr.Mkns("", time.Now().Unix(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	stateDir := ufpath.Join(tfsr.Path(), "state")
+	sOpts := SyncOptions{InDepth: true, NoACL: true, BiDir: true, StateDir: stateDir}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.CreNum != 5 || rs.ConfNum != 0 {
+		t.Fatalf("TestSynchronizeConflictsFsyOlf initial sync %+v", rs)
+	}
+
+	now := time.Now().Unix()
+	writeLeft := func(name, content string, mtime int64) {
+		path := ufpath.Join(tfsl.Path(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, time.Unix(mtime, 0), time.Unix(mtime, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRight := func(name, content string, mtime int64) {
+		wc, err := dssr.GetContentWriter(name, mtime, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = wc.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err = wc.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readSide := func(dss cabridss.Dss, name string) string {
+		rc, err := dss.GetContentReader(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		bs, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bs)
+	}
+
+	// a.txt is changed on the left only, b.txt and d/c.txt on both sides
+	writeLeft("a.txt", "left a.txt", now+10)
+	writeLeft("b.txt", "left b.txt", now+10)
+	writeRight("b.txt", "right b.txt", now+20)
+	writeLeft("d/c.txt", "left d/c.txt", now+30)
+	writeRight("d/c.txt", "right d/c.txt", now+20)
+	evOpts := sOpts
+	evOpts.Evaluate = true
+	if rs := Synchronize(nil, dssl, "", dssr, "", evOpts).GetStats(); rs.ErrNum != 0 || rs.UpdNum != 3 || rs.ConfNum != 2 {
+		t.Fatalf("TestSynchronizeConflictsFsyOlf evaluate %+v", rs)
+	}
+	report := Synchronize(nil, dssl, "", dssr, "", sOpts)
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.UpdNum != 3 || rs.ConfNum != 2 {
+		t.Fatalf("TestSynchronizeConflictsFsyOlf sync %+v", rs)
+	}
+	stamp := func(mtime int64) string { return time.Unix(mtime, 0).UTC().Format("20060102T150405") }
+	lCopy := "b.conflict-left-" + stamp(now+10) + ".txt"
+	rCopy := "d/c.conflict-right-" + stamp(now+20) + ".txt"
+	for _, entry := range report.Entries {
+		if (entry.LPath == "b.txt" && entry.ConflictCopy != lCopy) || (entry.LPath == "d/c.txt" && entry.ConflictCopy != rCopy) {
+			t.Fatalf("TestSynchronizeConflictsFsyOlf entry %+v", entry)
+		}
+	}
+	if readSide(dssl, "b.txt") != "right b.txt" || readSide(dssl, lCopy) != "left b.txt" ||
+		readSide(dssr, "d/c.txt") != "left d/c.txt" || readSide(dssr, rCopy) != "right d/c.txt" {
+		t.Fatal("TestSynchronizeConflictsFsyOlf contents differ")
+	}
+	// the conflict copies are propagated, both namespaces being updated
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.CreNum != 2 || rs.UpdNum != 2 || rs.ConfNum != 0 {
+		t.Fatalf("TestSynchronizeConflictsFsyOlf conflict copies sync %+v", rs)
+	}
+
+	// e.txt is changed on both sides again
+	writeLeft("e.txt", "left e.txt", now+50)
+	writeRight("e.txt", "right e.txt", now+40)
+	abOpts := sOpts
+	abOpts.OnConflict = ConflictAbort
+	if rs := Synchronize(nil, dssl, "", dssr, "", abOpts).GetStats(); rs.ErrNum != 1 || rs.UpdNum != 0 || rs.ConfNum != 1 {
+		t.Fatalf("TestSynchronizeConflictsFsyOlf abort %+v", rs)
+	}
+	if readSide(dssr, "e.txt") != "right e.txt" {
+		t.Fatal("TestSynchronizeConflictsFsyOlf aborted content was synchronized")
+	}
+	rtOpts := sOpts
+	rtOpts.OnConflict = ConflictRight
+	if rs := Synchronize(nil, dssl, "", dssr, "", rtOpts).GetStats(); rs.ErrNum != 0 || rs.UpdNum != 1 || rs.ConfNum != 1 {
+		t.Fatalf("TestSynchronizeConflictsFsyOlf right %+v", rs)
+	}
+	if readSide(dssl, "e.txt") != "right e.txt" {
+		t.Fatal("TestSynchronizeConflictsFsyOlf right content did not win")
+	}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.CreNum != 0 || rs.UpdNum != 0 || rs.ConfNum != 0 {
+		t.Fatalf("TestSynchronizeConflictsFsyOlf no change %+v", rs)
+	}
+}
+
+type failingReaderDss struct {
+	cabridss.Dss
+	failing string
+}
+
+func (fdss *failingReaderDss) GetContentReader(npath string) (io.ReadCloser, error) {
+	if npath == fdss.failing {
+		return nil, fmt.Errorf("failing %s", npath)
+	}
+	return fdss.Dss.GetContentReader(npath)
+}
+
+func TestSynchronizeResumeFsyOlf(t *testing.T) {
+	optionalSkip(t)
+	tfsl, err := testfs.CreateFs("TestSynchronizeResumeFsyOlfLeft", func(tfs *testfs.Fs) error {
+		if err := os.Mkdir(ufpath.Join(tfs.Path(), "d"), 0755); err != nil {
+			return err
+		}
+		for _, name := range []string{"a.txt", "b.txt", "d/c.txt"} {
+			if err := os.WriteFile(ufpath.Join(tfs.Path(), name), []byte("initial "+name), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsl.Delete()
+	fsy, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsl.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	dssl := &failingReaderDss{Dss: fsy, failing: "b.txt"}
+	tfsr, err := testfs.CreateFs("TestSynchronizeResumeFsyOlfRight", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsr.Delete()
+	dssr, err := cabridss.CreateOlfDss(cabridss.OlfConfig{DssBaseConfig: cabridss.DssBaseConfig{LocalPath: tfsr.Path()}, Root: tfsr.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dssr.Close()
+	if err = dssr.Mkns("", time.Now().Unix(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	ckptDir := ufpath.Join(tfsr.Path(), "checkpoints")
+	sOpts := SyncOptions{InDepth: true, NoACL: true, CheckpointDir: ckptDir}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 1 || rs.ResNum != 0 {
+		t.Fatalf("TestSynchronizeResumeFsyOlf interrupted sync %+v", rs)
+	}
+	if des, err := os.ReadDir(ckptDir); err != nil || len(des) != 1 {
+		t.Fatalf("TestSynchronizeResumeFsyOlf no checkpoint %v", err)
+	}
+
+	// a.txt is changed after the interruption
+	dssl.failing = ""
+	if err = os.WriteFile(ufpath.Join(tfsl.Path(), "a.txt"), []byte("a.txt changed after the interruption"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sOpts.Resume = true
+	report := Synchronize(nil, dssl, "", dssr, "", sOpts)
+	// b.txt already listed in the right namespace is updated
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.ResNum != 1 || rs.CreNum != 0 || rs.UpdNum != 2 {
+		t.Fatalf("TestSynchronizeResumeFsyOlf resumed sync %+v", rs)
+	}
+	for _, entry := range report.Entries {
+		if entry.Resumed != (entry.LPath == "d/c.txt") {
+			t.Fatalf("TestSynchronizeResumeFsyOlf entry %+v", entry)
+		}
+	}
+	if des, err := os.ReadDir(ckptDir); err != nil || len(des) != 0 {
+		t.Fatalf("TestSynchronizeResumeFsyOlf checkpoint not removed %v", err)
+	}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.ResNum != 0 || rs.CreNum != 0 || rs.UpdNum != 0 {
+		t.Fatalf("TestSynchronizeResumeFsyOlf no change %+v", rs)
+	}
+}
+
+func TestSynchronizeIncrementalOlfFsy(t *testing.T) {
+	optionalSkip(t)
+	tfsl, err := testfs.CreateFs("TestSynchronizeIncrementalOlfFsyLeft", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsl.Delete()
+	dssl, err := cabridss.CreateOlfDss(cabridss.OlfConfig{
+		DssBaseConfig: cabridss.DssBaseConfig{
+			LocalPath: tfsl.Path(),
+			GetIndex: func(config cabridss.DssBaseConfig, _ string) (cabridss.Index, error) {
+				return cabridss.NewPIndex(ufpath.Join(tfsl.Path(), "index.bdb"), false, false)
+			},
+		},
+		Root: tfsl.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dssl.Close()
+	writeLeft := func(name, content string) {
+		wc, err := dssl.GetContentWriter(name, time.Now().Unix(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = wc.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err = wc.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ns := range []struct {
+		npath    string
+		children []string
+	}{{"", []string{"a.txt", "d/", "e/"}}, {"d", []string{"b.txt"}}, {"e", []string{"c.txt"}}} {
+		if err = dssl.Mkns(ns.npath, time.Now().Unix(), ns.children, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a.txt", "d/b.txt", "e/c.txt"} {
+		writeLeft(name, "initial "+name)
+	}
+	tfsr, err := testfs.CreateFs("TestSynchronizeIncrementalOlfFsyRight", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsr.Delete()
+	dssr, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsr.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	stateDir := ufpath.Join(tfsl.Path(), "state")
+	sOpts := SyncOptions{InDepth: true, NoACL: true, Incremental: true}
+	if report := Synchronize(nil, dssl, "", dssr, "", sOpts); report.GErr == nil {
+		t.Fatal("TestSynchronizeIncrementalOlfFsy incremental synchronization without state should fail")
+	}
+	sOpts.StateDir = stateDir
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.CreNum != 5 {
+		t.Fatalf("TestSynchronizeIncrementalOlfFsy initial sync %+v", rs)
+	}
+
+	// d/b.txt is changed on the left, e/c.txt on the right, which is ignored by incremental synchronization
+	writeLeft("d/b.txt", "d/b.txt changed on the left")
+	if err = os.WriteFile(ufpath.Join(tfsr.Path(), "e", "c.txt"), []byte("e/c.txt changed on the right"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := Synchronize(nil, dssl, "", dssr, "", sOpts)
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.UpdNum != 1 || len(report.Entries) != 3 {
+		t.Fatalf("TestSynchronizeIncrementalOlfFsy incremental sync %+v %d", rs, len(report.Entries))
+	}
+	for _, entry := range report.Entries {
+		if entry.LPath != "" && entry.LPath != "d" && entry.LPath != "d/b.txt" {
+			t.Fatalf("TestSynchronizeIncrementalOlfFsy entry %+v", entry)
+		}
+	}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.UpdNum != 0 {
+		t.Fatalf("TestSynchronizeIncrementalOlfFsy no change %+v", rs)
+	}
+	sOpts.Incremental = false
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.UpdNum != 1 {
+		t.Fatalf("TestSynchronizeIncrementalOlfFsy full sync %+v", rs)
+	}
+}
+
+func TestSynchronizeIncrementalWebOlfFsy(t *testing.T) {
+	optionalSkip(t)
+	tfsl, err := testfs.CreateFs("TestSynchronizeIncrementalWebOlfFsyLeft", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsl.Delete()
+	getPIndex := func(config cabridss.DssBaseConfig, _ string) (cabridss.Index, error) {
+		return cabridss.NewPIndex(ufpath.Join(tfsl.Path(), "index.bdb"), false, false)
+	}
+	sv, err := createWebDssServer(":3000", "",
+		cabridss.CreateNewParams{Create: true, DssType: "olf", Root: tfsl.Path(), Size: "s", GetIndex: getPIndex},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Shutdown()
+	newClient := func(name string) cabridss.HDss {
+		dss, err := cabridss.NewWebDss(
+			cabridss.WebDssConfig{
+				DssBaseConfig: cabridss.DssBaseConfig{
+					ConfigDir: ufpath.Join(tfsl.Path(), name),
+					WebPort:   "3000",
+				}},
+			0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dss
+	}
+	writeLeft := func(dss cabridss.Dss, name, content string) {
+		wc, err := dss.GetContentWriter(name, time.Now().Unix(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = wc.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err = wc.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dssl := newClient(".cabri-1")
+	for _, ns := range []struct {
+		npath    string
+		children []string
+	}{{"", []string{"a.txt", "d/"}}, {"d", []string{"b.txt"}}} {
+		if err = dssl.Mkns(ns.npath, time.Now().Unix(), ns.children, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a.txt", "d/b.txt"} {
+		writeLeft(dssl, name, "initial "+name)
+	}
+	tfsr, err := testfs.CreateFs("TestSynchronizeIncrementalWebOlfFsyRight", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsr.Delete()
+	dssr, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsr.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sOpts := SyncOptions{InDepth: true, NoACL: true, Incremental: true, StateDir: ufpath.Join(tfsl.Path(), "state")}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.CreNum != 3 {
+		t.Fatalf("TestSynchronizeIncrementalWebOlfFsy initial sync %+v", rs)
+	}
+
+	// a second client changes d/b.txt, which the left index of the first client does not know yet,
+	// then the first client changes a.txt with a later index time and is synchronized
+	dss2 := newClient(".cabri-2")
+	writeLeft(dss2, "d/b.txt", "d/b.txt changed by the second client")
+	dss2.Close()
+	writeLeft(dssl, "a.txt", "a.txt changed by the first client")
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.UpdNum != 1 {
+		t.Fatalf("TestSynchronizeIncrementalWebOlfFsy first client sync %+v", rs)
+	}
+	dssl.Close()
+
+	// d/b.txt is received by the first client with an index time older than the one of a.txt
+	dssl = newClient(".cabri-1")
+	defer dssl.Close()
+	report := Synchronize(nil, dssl, "", dssr, "", sOpts)
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.UpdNum != 1 || len(report.Entries) != 3 {
+		t.Fatalf("TestSynchronizeIncrementalWebOlfFsy incremental sync %+v %d", rs, len(report.Entries))
+	}
+	if bs, err := os.ReadFile(ufpath.Join(tfsr.Path(), "d", "b.txt")); err != nil || string(bs) != "d/b.txt changed by the second client" {
+		t.Fatal("TestSynchronizeIncrementalWebOlfFsy content differs", err)
+	}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.UpdNum != 0 {
+		t.Fatalf("TestSynchronizeIncrementalWebOlfFsy no change after the second client %+v", rs)
+	}
+}
+
+func TestSynchronizePathsFsyFsy(t *testing.T) {
+	optionalSkip(t)
+	tfsl, err := testfs.CreateFs("TestSynchronizePathsFsyFsyLeft", func(tfs *testfs.Fs) error {
+		for _, dir := range []string{"d", "e"} {
+			if err := os.Mkdir(ufpath.Join(tfs.Path(), dir), 0755); err != nil {
+				return err
+			}
+		}
+		for _, name := range []string{"a.txt", "d/b.txt", "e/c.txt"} {
+			if err := os.WriteFile(ufpath.Join(tfs.Path(), name), []byte("initial "+name), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsl.Delete()
+	dssl, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsl.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tfsr, err := testfs.CreateFs("TestSynchronizePathsFsyFsyRight", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsr.Delete()
+	dssr, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsr.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sOpts := SyncOptions{InDepth: true, NoACL: true}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.CreNum != 5 {
+		t.Fatalf("TestSynchronizePathsFsyFsy initial sync %+v", rs)
+	}
+
+	// d/b.txt and e/c.txt are changed, a new namespace f is created, d/b.txt and f are given
+	for _, name := range []string{"d/b.txt", "e/c.txt"} {
+		if err = os.WriteFile(ufpath.Join(tfsl.Path(), name), []byte(name+" changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.MkdirAll(ufpath.Join(tfsl.Path(), "f", "g"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(ufpath.Join(tfsl.Path(), "f", "g", "h.txt"), []byte("f/g/h.txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sOpts.Paths = []string{"d/b.txt", "f"}
+	report := Synchronize(nil, dssl, "", dssr, "", sOpts)
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.UpdNum != 2 || rs.CreNum != 3 {
+		t.Fatalf("TestSynchronizePathsFsyFsy paths sync %+v", rs)
+	}
+	for _, entry := range report.Entries {
+		if strings.HasPrefix(entry.LPath, "e") || entry.LPath == "a.txt" {
+			t.Fatalf("TestSynchronizePathsFsyFsy entry %+v", entry)
+		}
+	}
+	sOpts.Paths = []string{""}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.UpdNum != 1 {
+		t.Fatalf("TestSynchronizePathsFsyFsy full sync %+v", rs)
+	}
+	sOpts.BiDir = true
+	if report = Synchronize(nil, dssl, "", dssr, "", sOpts); report.GErr == nil {
+		t.Fatal("TestSynchronizePathsFsyFsy bidirectional sync of given paths should fail")
+	}
+}
+
+func TestSynchronizeFilterFsyFsy(t *testing.T) {
+	optionalSkip(t)
+	tfsl, err := testfs.CreateFs("TestSynchronizeFilterFsyFsyLeft", func(tfs *testfs.Fs) error {
+		for _, dir := range []string{"node_modules", "src/node_modules", "src/lib"} {
+			if err := os.MkdirAll(ufpath.Join(tfs.Path(), dir), 0755); err != nil {
+				return err
+			}
+		}
+		for name, content := range map[string]string{
+			".cabriignore":          "node_modules/\n*.log\n",
+			"a.txt":                 "a",
+			"big.txt":               "a content bigger than the ignore files",
+			"x.log":                 "x",
+			"node_modules/m.js":     "m",
+			"src/node_modules/n.js": "n",
+			"src/.cabriignore":      "!*.log\nlib/\n",
+			"src/y.log":             "y",
+			"src/lib/l.txt":         "l",
+		} {
+			if err := os.WriteFile(ufpath.Join(tfs.Path(), name), []byte(content), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsl.Delete()
+	dssl, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsl.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tfsr, err := testfs.CreateFs("TestSynchronizeFilterFsyFsyRight", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tfsr.Delete()
+	dssr, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsr.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sOpts := SyncOptions{InDepth: true, NoACL: true, Filter: &Filter{IgnoreFile: ".cabriignore", Ignore: []string{"/src/*.txt"}, MaxSize: 30}}
+	report := Synchronize(nil, dssl, "", dssr, "", sOpts)
+	if rs := report.GetStats(); rs.ErrNum != 0 || rs.CreNum != 5 {
+		t.Fatalf("TestSynchronizeFilterFsyFsy sync %+v", rs)
+	}
+	for _, name := range []string{".cabriignore", "a.txt", "src/.cabriignore", "src/y.log"} {
+		if _, err = os.Stat(ufpath.Join(tfsr.Path(), name)); err != nil {
+			t.Fatalf("TestSynchronizeFilterFsyFsy %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"big.txt", "x.log", "node_modules", "src/node_modules", "src/lib"} {
+		if _, err = os.Stat(ufpath.Join(tfsr.Path(), name)); err == nil {
+			t.Fatalf("TestSynchronizeFilterFsyFsy %s should not be synchronized", name)
+		}
+	}
+
+	sOpts.Filter = &Filter{Include: []string{"*.txt"}}
+	if rs := Synchronize(nil, dssl, "", dssr, "", sOpts).GetStats(); rs.ErrNum != 0 || rs.CreNum != 5 {
+		t.Fatalf("TestSynchronizeFilterFsyFsy include sync %+v", rs)
+	}
+	if _, err = os.Stat(ufpath.Join(tfsr.Path(), "x.log")); err == nil {
+		t.Fatal("TestSynchronizeFilterFsyFsy x.log should not be synchronized")
+	}
+	if _, err = os.Stat(ufpath.Join(tfsr.Path(), "src", "lib", "l.txt")); err != nil {
+		t.Fatal(err)
+	}
+}
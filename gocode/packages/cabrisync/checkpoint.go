@@ -0,0 +1,161 @@
+package cabrisync
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"os"
+	"sync"
+)
+
+// with a CheckpointDir, each content or symlink synchronized is journaled in a checkpoint file per pair
+// of namespaces, removed when the synchronization completes without error;
+// when resuming, a journaled entry is skipped without computing its checksum
+// as long as its size and modification time are unchanged on both sides
+
+type ckptHeader struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+type ckptEntry struct {
+	Path  string `json:"p"`
+	Size  int64  `json:"s"`
+	Mtime int64  `json:"m"`
+}
+
+type checkpoint struct {
+	mx      sync.Mutex
+	path    string
+	f       *os.File
+	entries map[string]ckptEntry
+}
+
+func openCheckpoint(dir string, ldss cabridss.Dss, lpath string, rdss cabridss.Dss, rpath string, resume bool) (*checkpoint, error) {
+	hdr := ckptHeader{Left: stateDssKey(ldss, lpath), Right: stateDssKey(rdss, rpath)}
+	ckpt := &checkpoint{
+		path:    ufpath.Join(dir, internal.BytesToSha256Str([]byte(hdr.Left+"|"+hdr.Right))+".ckpt"),
+		entries: map[string]ckptEntry{},
+	}
+	if resume {
+		if err := ckpt.load(hdr); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("in openCheckpoint: %w", err)
+	}
+	var err error
+	if len(ckpt.entries) > 0 {
+		ckpt.f, err = os.OpenFile(ckpt.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	} else {
+		if ckpt.f, err = os.OpenFile(ckpt.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600); err == nil {
+			err = ckpt.writeLine(hdr)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("in openCheckpoint: %w", err)
+	}
+	return ckpt, nil
+}
+
+func (ckpt *checkpoint) load(hdr ckptHeader) error {
+	f, err := os.Open(ckpt.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("in loadCheckpoint: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var fHdr ckptHeader
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &fHdr) != nil || fHdr != hdr {
+		return nil
+	}
+	for scanner.Scan() {
+		var ce ckptEntry
+		// the last line may be truncated if the synchronization was interrupted
+		if json.Unmarshal(scanner.Bytes(), &ce) == nil {
+			ckpt.entries[ce.Path] = ce
+		}
+	}
+	return nil
+}
+
+func (ckpt *checkpoint) writeLine(v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = ckpt.f.Write(append(bs, '\n'))
+	return err
+}
+
+// journal records a synchronized entry, errors are ignored as they only prevent resuming it
+func (ckpt *checkpoint) journal(path string, meta cabridss.IMeta) {
+	ckpt.mx.Lock()
+	defer ckpt.mx.Unlock()
+	_ = ckpt.writeLine(ckptEntry{Path: path, Size: meta.GetSize(), Mtime: meta.GetMtime()})
+}
+
+// done indicates if the journaled entry matches the meta
+func (ckpt *checkpoint) done(path string, meta cabridss.IMeta) bool {
+	ckpt.mx.Lock()
+	defer ckpt.mx.Unlock()
+	ce, ok := ckpt.entries[path]
+	return ok && meta != nil && !meta.GetIsNs() && ce.Size == meta.GetSize() && ce.Mtime == meta.GetMtime()
+}
+
+// close closes the checkpoint file, removing it if the synchronization completed
+func (ckpt *checkpoint) close(completed bool) error {
+	if err := ckpt.f.Close(); err != nil {
+		return fmt.Errorf("in closeCheckpoint: %w", err)
+	}
+	if completed {
+		if err := os.Remove(ckpt.path); err != nil {
+			return fmt.Errorf("in closeCheckpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// resumed checks if the entry was synchronized by the interrupted synchronization and is unchanged since
+func (syc *syncCtx) resumed() bool {
+	if syc.ckpt == nil || len(syc.ckpt.entries) == 0 || !syc.left.exist || !syc.right.exist {
+		return false
+	}
+	rpath := syc.left.relPath()
+	if _, ok := syc.ckpt.entries[rpath]; !ok {
+		return false
+	}
+	left, right := syc.left, syc.right
+	left.options.NoCh, right.options.NoCh = true, true
+	if left.getMeta() != nil || right.getMeta() != nil || !syc.ckpt.done(rpath, left.meta) || !syc.ckpt.done(rpath, right.meta) {
+		return false
+	}
+	syc.diagnose("=resumed", false)
+	if syc.state != nil && syc.options.BiDir {
+		syc.state.record(rpath, left.meta)
+	}
+	return true
+}
+
+// journalEntry journals the synchronized entry in the checkpoint
+func (syc *syncCtx) journalEntry(rent *SyncReportEntry) {
+	if syc.ckpt == nil || rent.Err != nil || rent.Kept {
+		return
+	}
+	ori := syc.left
+	if rent.isRTL {
+		ori = syc.right
+	}
+	if !ori.exist || ori.meta == nil {
+		return
+	}
+	syc.ckpt.journal(syc.left.relPath(), ori.meta)
+}
@@ -0,0 +1,365 @@
+package cabrisync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// with BiDir and a StateDir, the contents synchronized by a run are recorded in a state file per pair
+// of namespaces, the next run uses them as a merge base: a content changed on both sides since
+// is a conflict resolved according to the OnConflict policy
+
+// ConflictPolicy indicates how a content changed on both sides since the last synchronization is synchronized
+type ConflictPolicy int
+
+const (
+	ConflictKeepBoth ConflictPolicy = iota // the latest modified content wins, the other one is kept aside as a conflict copy
+	ConflictLeft                           // the left-side content wins
+	ConflictRight                          // the right-side content wins
+	ConflictAbort                          // the content is not synchronized and reported in error
+)
+
+// ErrConflict is reported for conflicting contents with the ConflictAbort policy
+var ErrConflict = errors.New("content changed on both sides since the last synchronization")
+
+type stateEntry struct {
+	Size  int64  `json:"size"`
+	Mtime int64  `json:"mtime"`
+	Ch    string `json:"ch"`
+}
+
+// changed indicates if the content was changed since it was recorded
+func (se stateEntry) changed(meta cabridss.IMeta) bool {
+	if meta.GetSize() != se.Size || meta.GetMtime() != se.Mtime {
+		return true
+	}
+	ch := meta.GetChUnsafe()
+	return ch != "" && se.Ch != "" && ch != se.Ch
+}
+
+type syncStateFile struct {
+	Left    string                `json:"left"`
+	Right   string                `json:"right"`
+	Entries map[string]stateEntry `json:"entries"` // indexed by the path relative to the synchronized namespaces
+	ClId    string                `json:"clId"`    // client id recorded in the left index for incremental synchronization
+	Pending bool                  `json:"pending"` // the changes provided by the left index were not all synchronized
+}
+
+type syncState struct {
+	mx   sync.Mutex
+	path string
+	sf   syncStateFile
+	nss  map[string]map[string]bool // children of the namespaces visited by the run, by relative path
+}
+
+func stateDssKey(dss cabridss.Dss, path string) string {
+	id := ""
+	if hdss, ok := dss.(cabridss.HDss); ok {
+		id = hdss.GetRepoId()
+	} else if fsy, ok := dss.(*cabridss.FsyDss); ok {
+		id = "fsy:" + fsy.GetRoot()
+	}
+	return id + "@" + path
+}
+
+func loadSyncState(dir string, ldss cabridss.Dss, lpath string, rdss cabridss.Dss, rpath string) (*syncState, error) {
+	sst := &syncState{
+		sf:  syncStateFile{Left: stateDssKey(ldss, lpath), Right: stateDssKey(rdss, rpath), Entries: map[string]stateEntry{}},
+		nss: map[string]map[string]bool{},
+	}
+	sst.path = ufpath.Join(dir, internal.BytesToSha256Str([]byte(sst.sf.Left+"|"+sst.sf.Right))+".json")
+	bs, err := os.ReadFile(sst.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sst, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("in loadSyncState: %w", err)
+	}
+	var sf syncStateFile
+	if err = json.Unmarshal(bs, &sf); err != nil {
+		return nil, fmt.Errorf("in loadSyncState: %s %w", sst.path, err)
+	}
+	if sf.Entries != nil {
+		sst.sf.Entries = sf.Entries
+	}
+	sst.sf.ClId, sst.sf.Pending = sf.ClId, sf.Pending
+	return sst, nil
+}
+
+func (sst *syncState) save() error {
+	sst.mx.Lock()
+	defer sst.mx.Unlock()
+	sst.prune()
+	bs, err := json.Marshal(sst.sf)
+	if err != nil {
+		return fmt.Errorf("in saveSyncState: %w", err)
+	}
+	if err = os.MkdirAll(ufpath.Dir(sst.path), 0o700); err != nil {
+		return fmt.Errorf("in saveSyncState: %w", err)
+	}
+	tmp := sst.path + ".tmp"
+	if err = os.WriteFile(tmp, bs, 0o600); err != nil {
+		return fmt.Errorf("in saveSyncState: %w", err)
+	}
+	if err = os.Rename(tmp, sst.path); err != nil {
+		return fmt.Errorf("in saveSyncState: %w", err)
+	}
+	return nil
+}
+
+// visit records the children existing on either side of a namespace visited by the run
+func (sst *syncState) visit(path string, children []string) {
+	sst.mx.Lock()
+	defer sst.mx.Unlock()
+	chs := map[string]bool{}
+	for _, ch := range children {
+		chs[ch] = true
+	}
+	sst.nss[path] = chs
+}
+
+// prune drops the entries removed on both sides, ie missing from the children of a visited ancestor namespace
+func (sst *syncState) prune() {
+	for path := range sst.sf.Entries {
+		parts := strings.Split(path, "/")
+		for i := range parts {
+			chs, ok := sst.nss[strings.Join(parts[:i], "/")]
+			if !ok {
+				continue
+			}
+			name := parts[i]
+			if i < len(parts)-1 {
+				name += "/"
+			}
+			if !chs[name] {
+				delete(sst.sf.Entries, path)
+				break
+			}
+		}
+	}
+}
+
+func (sst *syncState) base(path string) (stateEntry, bool) {
+	sst.mx.Lock()
+	defer sst.mx.Unlock()
+	se, ok := sst.sf.Entries[path]
+	return se, ok
+}
+
+func (sst *syncState) record(path string, meta cabridss.IMeta) {
+	sst.mx.Lock()
+	defer sst.mx.Unlock()
+	sst.sf.Entries[path] = stateEntry{Size: meta.GetSize(), Mtime: meta.GetMtime(), Ch: meta.GetChUnsafe()}
+}
+
+type copiesSide struct {
+	path     string
+	acl      []cabridss.ACLEntry
+	children []string
+	added    []string
+}
+
+// conflictCopies tracks the conflict copies added to a namespace while its children are synchronized
+type conflictCopies struct {
+	mx       sync.Mutex
+	mtime    int64
+	left     copiesSide
+	right    copiesSide
+	reserved map[string]bool
+}
+
+func (syc *syncCtx) newConflictCopies(rent SyncReportEntry) *conflictCopies {
+	if syc.state == nil || !syc.options.BiDir {
+		return nil
+	}
+	mtime, lAcl, rAcl := syc.evalMergeNsMeta(rent)
+	return &conflictCopies{
+		mtime:    mtime,
+		left:     copiesSide{path: syc.left.fullPath(), acl: lAcl, children: append([]string{}, syc.leftMg...)},
+		right:    copiesSide{path: syc.right.fullPath(), acl: rAcl, children: append([]string{}, syc.rightMg...)},
+		reserved: map[string]bool{},
+	}
+}
+
+func (ccs *conflictCopies) side(isRight bool) *copiesSide {
+	if isRight {
+		return &ccs.right
+	}
+	return &ccs.left
+}
+
+// reserve provides an unused conflict copy name for the content name modified at mtime
+func (ccs *conflictCopies) reserve(isRight bool, name string, mtime int64) string {
+	ccs.mx.Lock()
+	defer ccs.mx.Unlock()
+	sd := ccs.side(isRight)
+	ext := ufpath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		base, ext = name, ""
+	}
+	sideName := "left"
+	if isRight {
+		sideName = "right"
+	}
+	stamp := time.Unix(mtime, 0).UTC().Format("20060102T150405")
+	for i := 1; ; i++ {
+		cname := fmt.Sprintf("%s.conflict-%s-%s%s", base, sideName, stamp, ext)
+		if i > 1 {
+			cname = fmt.Sprintf("%s.conflict-%s-%s-%d%s", base, sideName, stamp, i, ext)
+		}
+		key := fmt.Sprintf("%t:%s", isRight, cname)
+		if ccs.reserved[key] {
+			continue
+		}
+		found := false
+		for _, ch := range sd.children {
+			if ch == cname {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ccs.reserved[key] = true
+			return cname
+		}
+	}
+}
+
+// add adds the conflict copy name to the namespace children
+func (ccs *conflictCopies) add(dss cabridss.Dss, isRight bool, cname string) error {
+	ccs.mx.Lock()
+	defer ccs.mx.Unlock()
+	sd := ccs.side(isRight)
+	children := append(append([]string{}, sd.children...), cname)
+	if err := dss.Updatens(sd.path, ccs.mtime, children, sd.acl); err != nil {
+		return err
+	}
+	sd.children = children
+	sd.added = append(sd.added, cname)
+	return nil
+}
+
+// evalConflict detects a content changed on both sides since the last synchronization and applies the policy
+func (syc *syncCtx) evalConflict(rent *SyncReportEntry) {
+	if syc.state == nil || !syc.options.BiDir || !rent.Updated || rent.isSymLink || !syc.left.exist || !syc.right.exist {
+		return
+	}
+	base, ok := syc.state.base(syc.left.relPath())
+	if !ok || !base.changed(syc.left.meta) || !base.changed(syc.right.meta) {
+		return
+	}
+	rent.Conflict = true
+	switch syc.options.OnConflict {
+	case ConflictLeft:
+		rent.isRTL = false
+	case ConflictRight:
+		rent.isRTL = true
+	case ConflictAbort:
+		rent.Updated, rent.MUpdated = false, false
+		rent.Err = fmt.Errorf("in evalConflict: %s %w", syc.left.relPath(), ErrConflict)
+	default:
+		if syc.pCopies == nil {
+			// no parent namespace to keep the conflict copy in
+			rent.Updated, rent.MUpdated = false, false
+			rent.Err = fmt.Errorf("in evalConflict: %s %w, no namespace to keep a conflict copy", syc.left.relPath(), ErrConflict)
+			return
+		}
+		tgt := syc.right
+		if rent.isRTL {
+			tgt = syc.left
+		}
+		tgt.path = syc.pCopies.reserve(tgt.isRight, tgt.path, tgt.meta.GetMtime())
+		rent.ConflictCopy = tgt.fullPath()
+	}
+}
+
+// keepConflictCopy copies the target content aside before it is overwritten
+func (syc *syncCtx) keepConflictCopy(isRTL bool, cpath string) error {
+	syc.diagnose(">keepConflictCopy", false)
+	tgt := syc.right
+	if isRTL {
+		tgt = syc.left
+	}
+	err := func() error {
+		if err := syc.pCopies.add(tgt.dss, tgt.isRight, ufpath.Base(cpath)); err != nil {
+			return err
+		}
+		mtime, acl := tgt.meta.GetMtime(), tgt.meta.GetAcl()
+		if err := tgt.dss.CopyContent(tgt.fullPath(), cpath, tgt.meta.GetChUnsafe(), mtime, acl); err == nil {
+			return nil
+		}
+		in, err := tgt.dss.GetContentReader(tgt.fullPath())
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		var cbErr error
+		out, err := tgt.dss.GetContentWriter(cpath, mtime, acl, func(err error, size int64, ch string) {
+			if err == nil && size != tgt.meta.GetSize() {
+				err = fmt.Errorf("size %d", size)
+			}
+			cbErr = err
+		})
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		if err = out.Close(); err != nil {
+			return err
+		}
+		return cbErr
+	}()
+	if err != nil {
+		err = fmt.Errorf("in keepConflictCopy: %c%s %w", tgt.arrow(), cpath, err)
+		syc.diagnose(fmt.Sprintf("<keepConflictCopy %v", err), false)
+		return err
+	}
+	syc.diagnose("<keepConflictCopy", false)
+	return nil
+}
+
+// mergeConflictCopies adds the conflict copies to the namespace children to be merged
+func (syc *syncCtx) mergeConflictCopies() {
+	if syc.copies == nil {
+		return
+	}
+	syc.leftMg = append(syc.leftMg, syc.copies.left.added...)
+	syc.rightMg = append(syc.rightMg, syc.copies.right.added...)
+	syc.leftRight = append(syc.leftRight, syc.copies.right.added...)
+}
+
+// visitState records the namespace children to prune the state of the contents removed on both sides
+func (syc *syncCtx) visitState() {
+	if syc.state == nil || !syc.options.BiDir || syc.options.Evaluate {
+		return
+	}
+	syc.state.visit(syc.left.relPath(), syc.leftAndRight)
+}
+
+// recordState records the synchronized content as the merge base of the next synchronization
+func (syc *syncCtx) recordState(rent *SyncReportEntry) {
+	if syc.state == nil || !syc.options.BiDir || syc.options.Evaluate || rent.Err != nil || rent.isSymLink {
+		return
+	}
+	ori := syc.left
+	if rent.isRTL {
+		ori = syc.right
+	}
+	if !ori.exist || ori.meta == nil || ori.meta.GetIsNs() {
+		return
+	}
+	syc.state.record(syc.left.relPath(), ori.meta)
+}
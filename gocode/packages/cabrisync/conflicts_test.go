@@ -0,0 +1,57 @@
+package cabrisync
+
+import (
+	"errors"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"testing"
+)
+
+func TestConflictKeepBothWithoutCopies(t *testing.T) {
+	sst := &syncState{sf: syncStateFile{Entries: map[string]stateEntry{"a.txt": {Size: 1, Mtime: 1}}}}
+	syc := syncCtx{
+		options: SyncOptions{BiDir: true},
+		state:   sst,
+		left:    sideCtx{path: "a.txt", exist: true, meta: cabridss.Meta{Size: 2, Mtime: 2}},
+		right:   sideCtx{path: "a.txt", exist: true, isRight: true, meta: cabridss.Meta{Size: 3, Mtime: 3}},
+	}
+	rent := SyncReportEntry{Updated: true}
+	syc.evalConflict(&rent)
+	if !rent.Conflict || rent.Updated || !errors.Is(rent.Err, ErrConflict) {
+		t.Fatalf("TestConflictKeepBothWithoutCopies %+v", rent)
+	}
+}
+
+func TestSyncStatePrune(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestSyncStatePrune", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	sst, err := loadSyncState(tfs.Path(), nil, "l", nil, "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"a.txt", "b.txt", "d/c.txt", "e/f.txt", "g/h.txt"} {
+		sst.record(path, cabridss.Meta{Size: 1, Mtime: 1})
+	}
+	// b.txt and the e namespace were removed on both sides, g was not visited
+	sst.visit("", []string{"a.txt", "d/", "g/"})
+	sst.visit("d", []string{"c.txt"})
+	if err = sst.save(); err != nil {
+		t.Fatal(err)
+	}
+	sst, err = loadSyncState(tfs.Path(), nil, "l", nil, "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sst.sf.Entries) != 3 {
+		t.Fatalf("TestSyncStatePrune %v", sst.sf.Entries)
+	}
+	for _, path := range []string{"a.txt", "d/c.txt", "g/h.txt"} {
+		if _, ok := sst.base(path); !ok {
+			t.Fatalf("TestSyncStatePrune %s %v", path, sst.sf.Entries)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package cabrisync
 import (
 	"fmt"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
-	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
 )
 
 type sideCtx struct {
@@ -18,6 +17,7 @@ type sideCtx struct {
 	created     bool     // actually created
 	actualMtime int64    // actual mtime
 	exCh        []string // existing children
+	exChSet     map[string]bool
 	meta        cabridss.IMeta
 }
 
@@ -31,6 +31,15 @@ type syncCtx struct {
 	rightMg      []string // right merged (existing + added + keep removed) children
 	rmRight      []string // right children removed
 	leftRight    []string // right children left after remove
+	moves        *moveSources
+	state        *syncState      // last synchronized state for conflicts detection
+	copies       *conflictCopies // conflict copies added to the namespace
+	pCopies      *conflictCopies // conflict copies added to the parent namespace
+	ckpt         *checkpoint     // journal of synchronized entries
+	dirty        map[string]bool // if not nil, relative paths to be synchronized incrementally
+	dirtyTrees   map[string]bool // relative paths whose whole subtree is to be synchronized
+	ignores      *ignoreLevel    // ignore rules applying to the namespace children
+	filtered     map[string]bool // namespace children existing on one side only which are filtered out
 }
 
 func (sdc *sideCtx) arrow() rune {
@@ -154,16 +163,23 @@ func (syc *syncCtx) makeChild(path string) syncCtx {
 	}
 
 	return syncCtx{
-		options: syc.options,
-		err:     syc.pErr(),
+		options:    syc.options,
+		err:        syc.pErr(),
+		moves:      syc.moves,
+		state:      syc.state,
+		pCopies:    syc.copies,
+		ckpt:       syc.ckpt,
+		dirty:      syc.dirty,
+		dirtyTrees: syc.dirtyTrees,
+		ignores:    syc.ignores,
 		left: sideCtx{
 			options: syc.options, dss: syc.left.dss,
 			root: syc.left.root, pPath: syc.left.relPath(), isNs: isNs, path: npath,
-			exist: syc.left.exist && (internal.NpType(path)).ExistIn(syc.left.exCh)},
+			exist: syc.left.exist && syc.left.exChSet[path]},
 		right: sideCtx{
 			options: syc.options, dss: syc.right.dss, isRight: true,
 			root: syc.right.root, pPath: syc.right.relPath(), isNs: isNs, path: npath,
-			exist: syc.right.exist && (internal.NpType(path)).ExistIn(syc.right.exCh)},
+			exist: syc.right.exist && syc.right.exChSet[path]},
 	}
 }
 
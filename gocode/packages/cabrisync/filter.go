@@ -0,0 +1,310 @@
+package cabrisync
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Filter selects the entries to be synchronized, in addition to SyncOptions.ExclList
+//
+// patterns follow the gitignore syntax: globs with *, ? and [...], ** matching any number of namespaces,
+// a leading ! negating the pattern, a trailing / restricting it to namespaces,
+// and a pattern containing a / being anchored to the namespace of the ignore file or to the synchronized one
+type Filter struct {
+	IgnoreFile string   // name of the ignore files whose patterns apply to the entries below their namespace, none if empty
+	Ignore     []string // patterns of the entries to ignore, applying before the ignore files ones
+	Include    []string // if not empty, patterns of the only contents to synchronize, namespaces being always traversed
+	MinSize    int64    // contents smaller than this size are not synchronized
+	MaxSize    int64    // if not zero, contents larger than this size are not synchronized
+	NewerThan  int64    // if not zero, contents modified before this unix time are not synchronized
+	OlderThan  int64    // if not zero, contents modified after this unix time are not synchronized
+	ignore     *ignoreLevel
+	include    []ignorePattern
+}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreLevel holds the patterns of an ignore file, or the ones given in the Filter
+type ignoreLevel struct {
+	parent   *ignoreLevel
+	base     string // relative path of the namespace of the ignore file
+	patterns []ignorePattern
+}
+
+func globToRegexp(glob string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			// zero or more namespaces
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob):
+			sb.WriteString(".*")
+			i += 1
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class in %s", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return sb.String(), nil
+}
+
+// parsePattern parses a gitignore-style pattern, returns nil for blank and comment lines
+func parsePattern(line string) (*ignorePattern, error) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+	ip := ignorePattern{}
+	if line[0] == '!' {
+		ip.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		ip.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil, nil
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	sre, err := globToRegexp(line)
+	if err != nil {
+		return nil, err
+	}
+	if !anchored {
+		sre = "(?:.*/)?" + sre
+	}
+	if ip.re, err = regexp.Compile("^" + sre + "$"); err != nil {
+		return nil, fmt.Errorf("pattern %s: %v", line, err)
+	}
+	return &ip, nil
+}
+
+func parsePatterns(lines []string) ([]ignorePattern, error) {
+	var ips []ignorePattern
+	for _, line := range lines {
+		ip, err := parsePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		if ip != nil {
+			ips = append(ips, *ip)
+		}
+	}
+	return ips, nil
+}
+
+// matchPatterns returns if the last pattern matching rel negates it or not, and if one matched
+func matchPatterns(ips []ignorePattern, rel string, isNs bool) (negate, matched bool) {
+	for i := len(ips) - 1; i >= 0; i-- {
+		if ips[i].dirOnly && !isNs {
+			continue
+		}
+		if ips[i].re.MatchString(rel) {
+			return ips[i].negate, true
+		}
+	}
+	return false, false
+}
+
+// isIgnored evaluates the patterns of the ignore levels, the deepest ones having precedence
+func (il *ignoreLevel) isIgnored(rel string, isNs bool) bool {
+	for ; il != nil; il = il.parent {
+		lrel := rel
+		if il.base != "" {
+			if !strings.HasPrefix(rel, il.base+"/") {
+				continue
+			}
+			lrel = rel[len(il.base)+1:]
+		}
+		if negate, matched := matchPatterns(il.patterns, lrel, isNs); matched {
+			return !negate
+		}
+	}
+	return false
+}
+
+func (flt *Filter) compile() (err error) {
+	flt.ignore = &ignoreLevel{}
+	if flt.ignore.patterns, err = parsePatterns(flt.Ignore); err != nil {
+		return fmt.Errorf("in Filter ignore rules: %w", err)
+	}
+	if flt.include, err = parsePatterns(flt.Include); err != nil {
+		return fmt.Errorf("in Filter include rules: %w", err)
+	}
+	return nil
+}
+
+func (flt *Filter) isNotIncluded(rel string) bool {
+	if len(flt.include) == 0 {
+		return false
+	}
+	negate, matched := matchPatterns(flt.include, rel, false)
+	return !matched || negate
+}
+
+func (flt *Filter) hasLimits() bool {
+	return flt.MinSize != 0 || flt.MaxSize != 0 || flt.NewerThan != 0 || flt.OlderThan != 0
+}
+
+func (flt *Filter) isOutOfLimits(meta cabridss.IMeta) bool {
+	size, mtime := meta.GetSize(), meta.GetMtime()
+	return size < flt.MinSize || (flt.MaxSize != 0 && size > flt.MaxSize) ||
+		(flt.NewerThan != 0 && mtime < flt.NewerThan) || (flt.OlderThan != 0 && mtime > flt.OlderThan)
+}
+
+func (syc *syncCtx) childRelPath(ch string) string {
+	rel := strings.TrimSuffix(ch, "/")
+	if rp := syc.left.relPath(); rp != "" {
+		rel = rp + "/" + rel
+	}
+	return rel
+}
+
+// isFiltered indicates if the entry is excluded by the ignore or include rules
+func (syc *syncCtx) isFiltered() bool {
+	rel := syc.left.relPath()
+	if syc.options.Filter == nil || rel == "" {
+		return false
+	}
+	if syc.ignores.isIgnored(rel, syc.left.isNs) {
+		return true
+	}
+	return !syc.left.isNs && syc.options.Filter.isNotIncluded(rel)
+}
+
+// isFilteredOut indicates if the content is excluded by its size or modification time on its origin side
+func (syc *syncCtx) isFilteredOut() bool {
+	if syc.options.Filter == nil {
+		return false
+	}
+	if syc.left.exist {
+		return syc.options.Filter.isOutOfLimits(syc.left.meta)
+	}
+	if syc.options.BiDir && syc.right.exist {
+		return syc.options.Filter.isOutOfLimits(syc.right.meta)
+	}
+	return false
+}
+
+// filterChildren registers the namespace children existing on one side only which are filtered out,
+// so that they are neither propagated to nor removed from the other side
+func (syc *syncCtx) filterChildren() error {
+	flt := syc.options.Filter
+	if flt == nil {
+		return nil
+	}
+	syc.filtered = map[string]bool{}
+	check := func(sdc, other *sideCtx, isOrigin bool) error {
+		if !sdc.exist {
+			return nil
+		}
+		for _, ch := range sdc.exCh {
+			if other.exist && other.exChSet[ch] {
+				continue
+			}
+			isNs := ch[len(ch)-1] == '/'
+			rel := syc.childRelPath(ch)
+			if syc.ignores.isIgnored(rel, isNs) || (!isNs && flt.isNotIncluded(rel)) {
+				syc.filtered[ch] = true
+				continue
+			}
+			if isNs || !isOrigin || !flt.hasLimits() {
+				continue
+			}
+			cpath := ch
+			if fp := sdc.fullPath(); fp != "" {
+				cpath = fp + "/" + ch
+			}
+			meta, err := sdc.dss.GetMeta(cpath, false)
+			if err != nil {
+				return fmt.Errorf("in filterChildren: %w", err)
+			}
+			if flt.isOutOfLimits(meta) {
+				syc.filtered[ch] = true
+			}
+		}
+		return nil
+	}
+	if err := check(&syc.left, &syc.right, true); err != nil {
+		return err
+	}
+	return check(&syc.right, &syc.left, syc.options.BiDir)
+}
+
+// loadIgnoreFile reads the ignore file of the namespace, on the left side or on the right one in BiDir mode,
+// its patterns applying to the namespace children
+func (syc *syncCtx) loadIgnoreFile() error {
+	if syc.options.Filter == nil || syc.options.Filter.IgnoreFile == "" {
+		return nil
+	}
+	name := syc.options.Filter.IgnoreFile
+	sdc := &syc.left
+	if !syc.left.exist || !syc.left.exChSet[name] {
+		if !syc.options.BiDir || !syc.right.exist || !syc.right.exChSet[name] {
+			return nil
+		}
+		sdc = &syc.right
+	}
+	ipath := name
+	if fp := sdc.fullPath(); fp != "" {
+		ipath = fp + "/" + name
+	}
+	rc, err := sdc.dss.GetContentReader(ipath)
+	if err != nil {
+		return fmt.Errorf("in loadIgnoreFile: %w", err)
+	}
+	defer rc.Close()
+	var lines []string
+	scanner := bufio.NewScanner(io.LimitReader(rc, 1024*1024))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("in loadIgnoreFile %s: %w", ipath, err)
+	}
+	ips, err := parsePatterns(lines)
+	if err != nil {
+		return fmt.Errorf("in loadIgnoreFile %s: %w", ipath, err)
+	}
+	if len(ips) != 0 {
+		syc.ignores = &ignoreLevel{parent: syc.ignores, base: syc.left.relPath(), patterns: ips}
+	}
+	return nil
+}
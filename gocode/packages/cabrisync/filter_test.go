@@ -0,0 +1,56 @@
+package cabrisync
+
+import (
+	"testing"
+)
+
+func TestIgnorePatterns(t *testing.T) {
+	ips, err := parsePatterns([]string{
+		"# comment", "", "node_modules/", "*.log", "!keep.log", "/build", "doc/**/*.pdf", "a/**", `\#hash`, "tmp?.[ch]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := &ignoreLevel{patterns: ips}
+	sub, err := parsePatterns([]string{"!*.log", "local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deeper := &ignoreLevel{parent: root, base: "src/app", patterns: sub}
+	for _, tc := range []struct {
+		il      *ignoreLevel
+		rel     string
+		isNs    bool
+		ignored bool
+	}{
+		{root, "node_modules", true, true},
+		{root, "src/node_modules", true, true},
+		{root, "src/node_modules", false, false},
+		{root, "x.log", false, true},
+		{root, "src/x.log", false, true},
+		{root, "src/keep.log", false, false},
+		{root, "build", true, true},
+		{root, "src/build", true, false},
+		{root, "doc/a.pdf", false, true},
+		{root, "doc/x/y/a.pdf", false, true},
+		{root, "src/doc/a.pdf", false, false},
+		{root, "a", true, false},
+		{root, "a/b/c", false, true},
+		{root, "#hash", false, true},
+		{root, "tmp1.c", false, true},
+		{root, "tmp12.c", false, false},
+		{root, "tmp1.o", false, false},
+		{deeper, "src/app/x.log", false, false},
+		{deeper, "src/x.log", false, true},
+		{deeper, "src/app/local", true, true},
+		{deeper, "local", true, false},
+		{deeper, "src/app/node_modules", true, true},
+	} {
+		if tc.il.isIgnored(tc.rel, tc.isNs) != tc.ignored {
+			t.Fatalf("TestIgnorePatterns %s %v: ignored should be %v", tc.rel, tc.isNs, tc.ignored)
+		}
+	}
+	if _, err = parsePatterns([]string{"[abc"}); err == nil {
+		t.Fatal("TestIgnorePatterns unterminated class should fail")
+	}
+}
@@ -0,0 +1,115 @@
+package cabrisync
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"strings"
+)
+
+// in incremental mode, the left index provides the entries changed since the last synchronization,
+// only these entries and their ancestor namespaces are synchronized, the other ones being skipped
+// without listing them
+// the changes are those indexed for a client id recorded in the left index for the pair of namespaces,
+// whatever their index time, they are discarded and a full synchronization is run when the previous one failed
+// the same mechanism restricts the synchronization to given paths, such as the ones reported by a file watcher
+
+// evalIncremental sets the relative paths to be synchronized
+func (syc *syncCtx) evalIncremental(ldss cabridss.Dss, lpath string) error {
+	if syc.options.BiDir {
+		return fmt.Errorf("incremental synchronization is not available in bidirectional mode")
+	}
+	if syc.state == nil {
+		return fmt.Errorf("incremental synchronization requires a state directory")
+	}
+	hdss, ok := ldss.(cabridss.HDss)
+	if !ok {
+		return fmt.Errorf("incremental synchronization requires an indexed left DSS")
+	}
+	if syc.state.sf.ClId == "" {
+		syc.state.sf.ClId = uuid.New().String()
+	}
+	changed, isFull, err := hdss.GetChanges(lpath, syc.state.sf.ClId, syc.state.sf.Pending)
+	if err != nil {
+		return fmt.Errorf("in evalIncremental: %w", err)
+	}
+	// the changes are consumed, they are pending until the synchronization succeeds
+	syc.state.sf.Pending = true
+	if err = syc.state.save(); err != nil {
+		return fmt.Errorf("in evalIncremental: %w", err)
+	}
+	if isFull {
+		return nil
+	}
+	syc.dirty = map[string]bool{"": true}
+	for _, path := range changed {
+		rel := strings.TrimSuffix(path, "/")
+		if lpath != "" {
+			rel = strings.TrimPrefix(strings.TrimPrefix(rel, lpath), "/")
+		}
+		syc.markDirty(rel)
+	}
+	return nil
+}
+
+// evalPaths sets the relative paths to be synchronized from the paths provided in the options,
+// a path being synchronized with its whole subtree
+func (syc *syncCtx) evalPaths() error {
+	if syc.options.BiDir {
+		return fmt.Errorf("synchronization of given paths is not available in bidirectional mode")
+	}
+	if syc.options.Incremental {
+		return fmt.Errorf("synchronization of given paths is not available in incremental mode")
+	}
+	syc.dirty = map[string]bool{"": true}
+	syc.dirtyTrees = map[string]bool{}
+	for _, path := range syc.options.Paths {
+		rel := strings.Trim(path, "/")
+		if rel == "" {
+			// the whole namespace is synchronized
+			syc.dirty = nil
+			syc.dirtyTrees = nil
+			return nil
+		}
+		syc.dirtyTrees[rel] = true
+		syc.markDirty(rel)
+	}
+	return nil
+}
+
+// markDirty registers the relative path rel and its ancestors as to be synchronized
+func (syc *syncCtx) markDirty(rel string) {
+	for rel != "" && !syc.dirty[rel] {
+		syc.dirty[rel] = true
+		ix := strings.LastIndex(rel, "/")
+		if ix < 0 {
+			break
+		}
+		rel = rel[:ix]
+	}
+}
+
+// isDirty indicates if the pch child must be synchronized
+func (syc *syncCtx) isDirty(pch string) bool {
+	if syc.dirty == nil || !syc.left.exChSet[pch] {
+		return true
+	}
+	rel := strings.TrimSuffix(pch, "/")
+	if rp := syc.left.relPath(); rp != "" {
+		rel = rp + "/" + rel
+	}
+	if syc.dirty[rel] {
+		return true
+	}
+	for rel != "" {
+		if syc.dirtyTrees[rel] {
+			return true
+		}
+		ix := strings.LastIndex(rel, "/")
+		if ix < 0 {
+			break
+		}
+		rel = rel[:ix]
+	}
+	return false
+}
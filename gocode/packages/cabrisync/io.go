@@ -16,6 +16,10 @@ func (sdc *sideCtx) lsnsMeta() (err error) {
 			return fmt.Errorf("in lsnsMeta: %c%s %w", sdc.arrow(), sdc.fullPath(), err)
 		}
 		sdc.exCh = sdc.meta.GetChildren()
+		sdc.exChSet = make(map[string]bool, len(sdc.exCh))
+		for _, ch := range sdc.exCh {
+			sdc.exChSet[ch] = true
+		}
 		sdc.actualMtime = sdc.meta.GetMtime()
 		sdc.diagnose("<lsnsMeta")
 	}
@@ -185,6 +189,15 @@ func (syc *syncCtx) crUpContent(isRTL bool) error {
 		}
 	}
 
+	var sigs *cabridss.ContentSignatures
+	if syc.options.Delta && tgt.exist && tgt.meta != nil && !tgt.meta.GetIsSymLink() && ori.meta.GetSize() >= cabridss.DELTA_MIN_SIZE {
+		var err error
+		if sigs, err = tgt.dss.GetContentSignatures(tgt.fullPath(), cabridss.DeltaBlockSize(tgt.meta.GetSize())); err != nil {
+			syc.diagnose(fmt.Sprintf("=crUpContent no delta %v", err), false)
+			sigs = nil
+		}
+	}
+
 	in, err := ori.dss.GetContentReader(ori.fullPath())
 	if err != nil {
 		syc.diagnose(fmt.Sprintf("<crUpContent %v", err), false)
@@ -195,18 +208,26 @@ func (syc *syncCtx) crUpContent(isRTL bool) error {
 	var out io.WriteCloser
 	doCopy := func() error {
 		var err error
-		out, err = tgt.dss.GetContentWriter(
-			tgt.fullPath(), ori.meta.GetMtime(), syc.mapACL(ori.meta.GetAcl(), isRTL),
-			func(err error, size int64, ch string) {
-				if err != nil || size != ori.meta.GetSize() || (ori.meta.GetChUnsafe() != "" && ch != ori.meta.GetChUnsafe()) {
-					closeErr = fmt.Errorf("%s error %w size %d ch %s", tErrPrefix, err, size, ch)
-				}
-			})
+		cb := func(err error, size int64, ch string) {
+			if err != nil || size != ori.meta.GetSize() || (ori.meta.GetChUnsafe() != "" && ch != ori.meta.GetChUnsafe()) {
+				closeErr = fmt.Errorf("%s error %w size %d ch %s", tErrPrefix, err, size, ch)
+			}
+		}
+		if sigs != nil {
+			out, err = tgt.dss.GetContentDeltaWriter(tgt.fullPath(), ori.meta.GetMtime(), syc.mapACL(ori.meta.GetAcl(), isRTL), cb)
+		} else {
+			out, err = tgt.dss.GetContentWriter(tgt.fullPath(), ori.meta.GetMtime(), syc.mapACL(ori.meta.GetAcl(), isRTL), cb)
+		}
 		if err != nil {
 			err = fmt.Errorf("%s %w", tErrPrefix, err)
 			return err
 		}
-		if _, err = io.Copy(out, in); err != nil {
+		if sigs != nil {
+			_, _, err = cabridss.WriteDelta(sigs, in, out)
+		} else {
+			_, err = io.Copy(out, in)
+		}
+		if err != nil {
 			out.Close()
 			err = fmt.Errorf("%s %w", tErrPrefix, err)
 			return err
@@ -0,0 +1,149 @@
+package cabrisync
+
+import (
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"sync"
+)
+
+// moved or copied contents are detected by checksum and size within a synchronization run:
+// target contents to be removed are registered before the children of a namespace are synchronized,
+// the other target contents as they are evaluated, and a created content matching one of them
+// is copied within the target DSS instead of being transferred
+
+type moveSource struct {
+	path    string // full path in the target DSS
+	removed bool   // the source is removed from the target DSS during the run
+}
+
+type moveSources struct {
+	mx      sync.Mutex
+	sources map[string]moveSource
+}
+
+func newMoveSources() *moveSources {
+	return &moveSources{sources: map[string]moveSource{}}
+}
+
+func moveKey(isRight bool, ch string, size int64) string {
+	return fmt.Sprintf("%t:%s:%d", isRight, ch, size)
+}
+
+// register records some target content, a removed one being preferred as a source
+func (mvs *moveSources) register(isRight bool, path string, meta cabridss.IMeta, removed bool) {
+	if meta == nil || meta.GetIsNs() || meta.GetIsSymLink() || meta.GetChUnsafe() == "" {
+		return
+	}
+	mvs.mx.Lock()
+	defer mvs.mx.Unlock()
+	key := moveKey(isRight, meta.GetChUnsafe(), meta.GetSize())
+	if ms, ok := mvs.sources[key]; ok && (ms.removed || !removed) {
+		return
+	}
+	mvs.sources[key] = moveSource{path: path, removed: removed}
+}
+
+// lookup finds a target content other than path with the checksum and size of meta
+func (mvs *moveSources) lookup(isRight bool, path string, meta cabridss.IMeta) (moveSource, bool) {
+	if meta == nil || meta.GetChUnsafe() == "" {
+		return moveSource{}, false
+	}
+	mvs.mx.Lock()
+	defer mvs.mx.Unlock()
+	ms, ok := mvs.sources[moveKey(isRight, meta.GetChUnsafe(), meta.GetSize())]
+	if !ok || ms.path == path {
+		return moveSource{}, false
+	}
+	return ms, true
+}
+
+// registerRemoved records the target contents removed from the namespace, recursively if in depth
+func (syc *syncCtx) registerRemoved() {
+	if syc.moves == nil || !syc.left.exist {
+		return
+	}
+	var walk func(path string, isNs bool)
+	walk = func(path string, isNs bool) {
+		if isNs && !syc.options.InDepth {
+			return
+		}
+		gpath := path
+		if isNs {
+			gpath = cabridss.AppendSlashIf(path)
+		}
+		meta, err := syc.right.dss.GetMeta(gpath, true)
+		if err != nil {
+			syc.diagnose(fmt.Sprintf("=registerRemoved %s %v", path, err), false)
+			return
+		}
+		if !isNs {
+			syc.moves.register(true, path, meta, true)
+			return
+		}
+		for _, child := range meta.GetChildren() {
+			cIsNs := child[len(child)-1] == '/'
+			if cIsNs {
+				child = child[:len(child)-1]
+			}
+			walk(path+"/"+child, cIsNs)
+		}
+	}
+	for _, rch := range syc.rmRight {
+		isNs := rch[len(rch)-1] == '/'
+		name := rch
+		if isNs {
+			name = rch[:len(rch)-1]
+		}
+		path := name
+		if rp := syc.right.fullPath(); rp != "" {
+			path = rp + "/" + name
+		}
+		walk(path, isNs)
+	}
+}
+
+// moveSource looks for a target content the created content can be copied from
+func (syc *syncCtx) moveSource(rent *SyncReportEntry) (moveSource, bool) {
+	if syc.moves == nil || !rent.Created || rent.isSymLink {
+		return moveSource{}, false
+	}
+	ori, tgt := syc.left, syc.right
+	if rent.isRTL {
+		ori, tgt = syc.right, syc.left
+	}
+	return syc.moves.lookup(tgt.isRight, tgt.fullPath(), ori.meta)
+}
+
+// registerExisting records the target contents remaining after the entry synchronization
+func (syc *syncCtx) registerExisting(rent *SyncReportEntry) {
+	if syc.moves == nil || rent.Updated || rent.Removed {
+		return
+	}
+	if syc.right.exist {
+		syc.moves.register(true, syc.right.fullPath(), syc.right.meta, false)
+	}
+	if syc.options.BiDir && syc.left.exist {
+		syc.moves.register(false, syc.left.fullPath(), syc.left.meta, false)
+	}
+}
+
+func (syc *syncCtx) copyContent(isRTL bool, spath string) error {
+	syc.diagnose(">copyContent", false)
+	ori := syc.left
+	tgt := syc.right
+	if isRTL {
+		ori = syc.right
+		tgt = syc.left
+	}
+	if err := tgt.dss.CopyContent(spath, tgt.fullPath(), ori.meta.GetChUnsafe(), ori.meta.GetMtime(), syc.mapACL(ori.meta.GetAcl(), isRTL)); err != nil {
+		err = fmt.Errorf("in copyContent: %c%s %w", tgt.arrow(), tgt.fullPath(), err)
+		syc.diagnose(fmt.Sprintf("<copyContent %v", err), false)
+		return err
+	}
+	if !tgt.exist {
+		tgt.created = true
+	}
+	tgt.actualMtime = ori.meta.GetMtime()
+	syc.diagnose("<copyContent", false)
+	return nil
+}
@@ -1,21 +1,26 @@
 package cabrisync
 
-import "github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
-
 func (syc *syncCtx) evalNsMerge() {
 	syc.leftAndRight = append(syc.leftAndRight, syc.left.exCh...)
 	syc.leftMg = append(syc.leftMg, syc.left.exCh...)
-	syc.leftRight = append(syc.leftRight, syc.left.exCh...)
 	syc.rightMg = append(syc.rightMg, syc.right.exCh...)
 	for _, lch := range syc.left.exCh {
-		if !internal.NpType(lch).ExistIn(syc.right.exCh) {
+		if !syc.right.exChSet[lch] && syc.filtered[lch] {
+			// filtered out children are not propagated
+			continue
+		}
+		syc.leftRight = append(syc.leftRight, lch)
+		if !syc.right.exChSet[lch] {
 			syc.rightMg = append(syc.rightMg, lch)
 		}
 	}
 	for _, rch := range syc.right.exCh {
-		if !internal.NpType(rch).ExistIn(syc.left.exCh) {
+		if !syc.left.exChSet[rch] {
 			syc.leftAndRight = append(syc.leftAndRight, rch)
-			if !syc.options.KeepContent {
+			if syc.filtered[rch] {
+				// filtered out children are neither propagated nor removed
+				syc.leftRight = append(syc.leftRight, rch)
+			} else if !syc.options.KeepContent {
 				if syc.options.BiDir {
 					syc.leftMg = append(syc.leftMg, rch)
 					syc.leftRight = append(syc.leftRight, rch)
@@ -1,24 +1,35 @@
 package cabrisync
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
 	"io"
+	"io/fs"
 	"sort"
 )
 
 type SyncReportEntry struct {
-	IsNs      bool // entry is a namespace
-	isSymLink bool
-	LPath     string // content's path in left DSS
-	RPath     string // content's path in right DSS
-	isRTL     bool   // if BiDir is active, indicates the synchronization is reversed: right to left
-	Created   bool   // content is created on target
-	Updated   bool   // content is updated on target
-	Removed   bool   // content is removed on target
-	Kept      bool   // content is kept on target
-	MUpdated  bool   // meta data is updated on target
-	Excluded  bool   // content was excluded
-	Err       error  // if entry synchronization has errors
+	IsNs         bool // entry is a namespace
+	isSymLink    bool
+	LPath        string // content's path in left DSS
+	RPath        string // content's path in right DSS
+	isRTL        bool   // if BiDir is active, indicates the synchronization is reversed: right to left
+	Created      bool   // content is created on target
+	Updated      bool   // content is updated on target
+	Removed      bool   // content is removed on target
+	Kept         bool   // content is kept on target
+	MUpdated     bool   // meta data is updated on target
+	Excluded     bool   // content was excluded
+	Resumed      bool   // content was synchronized by the interrupted synchronization being resumed
+	MovedFrom    string // if not empty, content was created from this path removed in target DSS
+	CopiedFrom   string // if not empty, content was created from this path existing in target DSS
+	Conflict     bool   // content was changed on both sides since the last synchronization
+	ConflictCopy string // if not empty, path of the conflict copy of the overwritten content in target DSS
+	Err          error  // if entry synchronization has errors
 }
 
 // SyncReport provides the Synchronize execution result
@@ -29,12 +40,15 @@ type SyncReport struct {
 
 // SyncStats provides SyncReport statistics
 type SyncStats struct {
-	CreNum  int // number of created entries
-	UpdNum  int // number of updated entries
-	RmvNum  int // number of removed entries
-	KeptNum int // number of kept entries
-	MUpNum  int // number of meta data updated entries
-	ErrNum  int // number of errors (excl. GErr)
+	CreNum  int `json:"creNum"`  // number of created entries
+	UpdNum  int `json:"updNum"`  // number of updated entries
+	RmvNum  int `json:"rmvNum"`  // number of removed entries
+	KeptNum int `json:"keptNum"` // number of kept entries
+	MUpNum  int `json:"mUpNum"`  // number of meta data updated entries
+	MovNum  int `json:"movNum"`  // number of created entries moved or copied within the target DSS
+	ConfNum int `json:"confNum"` // number of entries changed on both sides since the last synchronization
+	ResNum  int `json:"resNum"`  // number of entries skipped as synchronized by the interrupted synchronization
+	ErrNum  int `json:"errNum"`  // number of errors (excl. GErr)
 }
 
 // HasErrors indicates if any synchronization error occurred
@@ -69,6 +83,15 @@ func (sr SyncReport) GetStats() SyncStats {
 		if entry.MUpdated {
 			syst.MUpNum++
 		}
+		if entry.MovedFrom != "" || entry.CopiedFrom != "" {
+			syst.MovNum++
+		}
+		if entry.Conflict {
+			syst.ConfNum++
+		}
+		if entry.Resumed {
+			syst.ResNum++
+		}
 		if entry.Err != nil {
 			syst.ErrNum++
 		}
@@ -113,8 +136,19 @@ func (sr SyncReport) doTextOutput(out io.Writer, summary, dispRight bool) {
 		if entry.RPath != entry.LPath || dispRight {
 			rpathOmitIf = entry.RPath
 		}
+		from := ""
+		if entry.MovedFrom != "" {
+			from = " moved from " + entry.MovedFrom
+		} else if entry.CopiedFrom != "" {
+			from = " copied from " + entry.CopiedFrom
+		}
+		if entry.ConflictCopy != "" {
+			from += " conflict kept as " + entry.ConflictCopy
+		} else if entry.Conflict {
+			from += " conflict"
+		}
 		if entry.Err == nil && (!summary || (c != '.' && c != ';')) {
-			out.Write([]byte(fmt.Sprintf("%c%c %s %s\n", arrow, c, entry.LPath, rpathOmitIf)))
+			out.Write([]byte(fmt.Sprintf("%c%c %s %s%s\n", arrow, c, entry.LPath, rpathOmitIf, from)))
 		} else if entry.Err != nil {
 			c = '?'
 			out.Write([]byte(fmt.Sprintf("%c%c %s %s %v\n", arrow, c, entry.LPath, rpathOmitIf, entry.Err)))
@@ -137,6 +171,177 @@ func (sr SyncReport) SummaryOutput(out io.Writer, dispRight bool) {
 	sr.doTextOutput(out, true, dispRight)
 }
 
+// SyncAction is the action performed on an entry, as displayed by TextOutput
+type SyncAction string
+
+const (
+	SyncNone       SyncAction = "none"       // entry is unchanged
+	SyncCreate     SyncAction = "create"     // entry is created on target
+	SyncUpdate     SyncAction = "update"     // entry is updated on target
+	SyncMetaUpdate SyncAction = "metaUpdate" // entry meta data are updated on target
+	SyncRemove     SyncAction = "remove"     // entry is removed on target
+	SyncKeep       SyncAction = "keep"       // entry removed from one side is kept on target
+	SyncExclude    SyncAction = "exclude"    // entry is excluded from synchronization
+)
+
+// SyncError is the serializable form of a synchronization error
+type SyncError struct {
+	Kind    string `json:"kind"` // "conflict", "notFound", "permission", "password" or "other"
+	Message string `json:"message"`
+}
+
+// SyncRecord is the serializable form of a SyncReportEntry, or of the final statistics
+type SyncRecord struct {
+	Type         string     `json:"type"` // "entry", or "stats" for the final record
+	IsNs         bool       `json:"isNs,omitempty"`
+	LPath        string     `json:"lPath,omitempty"`
+	RPath        string     `json:"rPath,omitempty"`
+	RTL          bool       `json:"rtl,omitempty"` // synchronization is right to left
+	Action       SyncAction `json:"action,omitempty"`
+	Resumed      bool       `json:"resumed,omitempty"`
+	MovedFrom    string     `json:"movedFrom,omitempty"`
+	CopiedFrom   string     `json:"copiedFrom,omitempty"`
+	Conflict     bool       `json:"conflict,omitempty"`
+	ConflictCopy string     `json:"conflictCopy,omitempty"`
+	Error        *SyncError `json:"error,omitempty"` // entry error, or global error for the final record
+	Stats        *SyncStats `json:"stats,omitempty"`
+}
+
+// SyncReportData is the serializable form of a SyncReport
+type SyncReportData struct {
+	Entries []SyncRecord `json:"entries"`
+	Stats   SyncStats    `json:"stats"`
+	Error   *SyncError   `json:"error,omitempty"` // global error if synchronization aborted
+}
+
+func newSyncError(err error, conflict bool) *SyncError {
+	if err == nil {
+		return nil
+	}
+	se := SyncError{Kind: "other", Message: err.Error()}
+	switch {
+	case conflict:
+		se.Kind = "conflict"
+	case errors.Is(err, fs.ErrNotExist):
+		se.Kind = "notFound"
+	case errors.Is(err, fs.ErrPermission):
+		se.Kind = "permission"
+	case errors.Is(err, cabridss.ErrPasswordRequired):
+		se.Kind = "password"
+	}
+	return &se
+}
+
+// Record provides the serializable form of the entry
+func (entry SyncReportEntry) Record() SyncRecord {
+	rec := SyncRecord{
+		Type: "entry", IsNs: entry.IsNs, LPath: entry.LPath, RPath: entry.RPath, RTL: entry.isRTL,
+		Action: SyncNone, Resumed: entry.Resumed, MovedFrom: entry.MovedFrom, CopiedFrom: entry.CopiedFrom,
+		Conflict: entry.Conflict, ConflictCopy: entry.ConflictCopy, Error: newSyncError(entry.Err, entry.Conflict),
+	}
+	switch true {
+	case entry.MUpdated:
+		rec.Action = SyncMetaUpdate
+	case entry.Created:
+		rec.Action = SyncCreate
+	case entry.Updated:
+		rec.Action = SyncUpdate
+	case entry.Removed:
+		rec.Action = SyncRemove
+	case entry.Kept:
+		rec.Action = SyncKeep
+	case entry.Excluded:
+		rec.Action = SyncExclude
+	}
+	return rec
+}
+
+// unchanged tells if the record is omitted from summary reports
+func (rec SyncRecord) unchanged() bool {
+	return rec.Error == nil && (rec.Action == SyncNone || rec.Action == SyncExclude)
+}
+
+func (sr SyncReport) records(summary bool) []SyncRecord {
+	recs := []SyncRecord{}
+	for _, entry := range sr.Entries {
+		rec := entry.Record()
+		if summary && rec.unchanged() {
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// Data provides the serializable form of the report, without unchanged and excluded entries if summary is set
+func (sr SyncReport) Data(summary bool) SyncReportData {
+	return SyncReportData{Entries: sr.records(summary), Stats: sr.GetStats(), Error: newSyncError(sr.GErr, false)}
+}
+
+// JSONOutput writes the report on given output as a single JSON SyncReportData document
+func (sr SyncReport) JSONOutput(out io.Writer, summary bool) error {
+	return json.NewEncoder(out).Encode(sr.Data(summary))
+}
+
+// NDJSONOutput writes the report on given output as newline delimited JSON SyncRecord,
+// one per entry followed by the final statistics one
+func (sr SyncReport) NDJSONOutput(out io.Writer, summary bool) error {
+	for _, entry := range sr.Entries {
+		if err := entry.NDJSONOutput(out, summary); err != nil {
+			return err
+		}
+	}
+	return sr.NDJSONStatsOutput(out)
+}
+
+// NDJSONOutput writes the entry on given output as a newline delimited JSON SyncRecord,
+// nothing if summary is set and the entry is unchanged or excluded,
+// so that the report is streamed with SyncOptions.OnEntry while synchronizing
+func (entry SyncReportEntry) NDJSONOutput(out io.Writer, summary bool) error {
+	rec := entry.Record()
+	if summary && rec.unchanged() {
+		return nil
+	}
+	return json.NewEncoder(out).Encode(rec)
+}
+
+// NDJSONStatsOutput writes the final statistics record of the report on given output
+func (sr SyncReport) NDJSONStatsOutput(out io.Writer) error {
+	stats := sr.GetStats()
+	return json.NewEncoder(out).Encode(SyncRecord{Type: "stats", Stats: &stats, Error: newSyncError(sr.GErr, false)})
+}
+
+// ReadSyncReportData reads a report written by JSONOutput or NDJSONOutput
+func ReadSyncReportData(in io.Reader) (SyncReportData, error) {
+	var srd SyncReportData
+	bs, err := io.ReadAll(in)
+	if err != nil {
+		return srd, err
+	}
+	bs = bytes.TrimSpace(bs)
+	if err = json.Unmarshal(bs, &srd); err == nil && srd.Entries != nil {
+		return srd, nil
+	}
+	srd = SyncReportData{Entries: []SyncRecord{}}
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec SyncRecord
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return srd, fmt.Errorf("in ReadSyncReportData: %w", err)
+		}
+		if rec.Type == "stats" {
+			if rec.Stats != nil {
+				srd.Stats = *rec.Stats
+			}
+			srd.Error = rec.Error
+			continue
+		}
+		srd.Entries = append(srd.Entries, rec)
+	}
+	return srd, scanner.Err()
+}
+
 // SyncRefDiag provides a reference report indexed by left and right paths for diagnosis purpose
 type SyncRefDiag struct {
 	Left  map[string]SyncReportEntry
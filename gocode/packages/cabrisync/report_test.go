@@ -0,0 +1,104 @@
+package cabrisync
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestSyncReportOutputs(t *testing.T) {
+	sr := SyncReport{Entries: []SyncReportEntry{
+		{IsNs: true, LPath: "", RPath: "", Updated: true},
+		{LPath: "a.txt", RPath: "a.txt", Created: true},
+		{LPath: "b.txt", RPath: "b.txt"},
+		{LPath: "c.txt", RPath: "c.txt", Err: fmt.Errorf("in getMeta: %w", fs.ErrNotExist)},
+		{LPath: "d.txt", RPath: "d.txt", Updated: true, isRTL: true, Conflict: true, ConflictCopy: "d.conflict-left-1.txt"},
+	}}
+	var out bytes.Buffer
+	if err := sr.NDJSONOutput(&out, true); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 5 ||
+		!strings.Contains(lines[1], `"action":"create"`) || !strings.Contains(lines[2], `"kind":"notFound"`) ||
+		!strings.Contains(lines[3], `"rtl":true`) || !strings.Contains(lines[4], `"type":"stats"`) {
+		t.Fatalf("TestSyncReportOutputs ndjson %s", out.String())
+	}
+	srd, err := ReadSyncReportData(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srd.Entries) != 4 || srd.Stats.UpdNum != 2 || srd.Stats.ErrNum != 1 || srd.Error != nil ||
+		srd.Entries[3].ConflictCopy != "d.conflict-left-1.txt" {
+		t.Fatalf("TestSyncReportOutputs ndjson data %+v", srd)
+	}
+
+	sr.GErr = fmt.Errorf("aborted")
+	out.Reset()
+	if err = sr.JSONOutput(&out, false); err != nil {
+		t.Fatal(err)
+	}
+	if srd, err = ReadSyncReportData(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(srd.Entries) != 5 || srd.Entries[2].Action != SyncNone || srd.Stats.CreNum != 1 ||
+		srd.Error == nil || srd.Error.Kind != "other" || srd.Error.Message != "aborted" {
+		t.Fatalf("TestSyncReportOutputs json data %+v", srd)
+	}
+}
+
+func TestSyncReportStream(t *testing.T) {
+	optionalSkip(t)
+	tfsl, err := testfs.CreateFs("TestSyncReportStreamLeft", basicTfsStartup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfsl.Delete()
+	dssl, err := cabridss.NewFsyDss(cabridss.FsyConfig{}, tfsl.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tfsr, err := testfs.CreateFs("TestSyncReportStreamRight", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfsr.Delete()
+	dssr, err := cabridss.CreateOlfDss(cabridss.OlfConfig{DssBaseConfig: cabridss.DssBaseConfig{LocalPath: tfsr.Path()}, Root: tfsr.Path(), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dssr.Close()
+
+	var out bytes.Buffer
+	streamed := map[string]int{}
+	sr := Synchronize(nil, dssl, "", dssr, "", SyncOptions{InDepth: true, OnEntry: func(entry SyncReportEntry) {
+		streamed[entry.LPath]++
+		if err := entry.NDJSONOutput(&out, false); err != nil {
+			t.Error(err)
+		}
+	}})
+	if sr.HasErrors() {
+		t.Fatal(sr.GErr, sr.GetStats())
+	}
+	if len(streamed) != len(sr.Entries) {
+		t.Fatalf("TestSyncReportStream %d entries streamed, %d reported", len(streamed), len(sr.Entries))
+	}
+	for _, entry := range sr.Entries {
+		if streamed[entry.LPath] != 1 {
+			t.Fatalf("TestSyncReportStream %s streamed %d times", entry.LPath, streamed[entry.LPath])
+		}
+	}
+	if err = sr.NDJSONStatsOutput(&out); err != nil {
+		t.Fatal(err)
+	}
+	srd, err := ReadSyncReportData(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srd.Entries) != len(sr.Entries) || srd.Stats != sr.GetStats() || srd.Entries[len(srd.Entries)-1].LPath != "" {
+		t.Fatalf("TestSyncReportStream data %+v", srd)
+	}
+}
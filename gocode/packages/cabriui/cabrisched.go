@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabrisync"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/joule"
 	"io/fs"
 	"net/http"
@@ -35,14 +36,27 @@ type SCabriSyncSpec struct {
 	KeepContent   bool     `yaml:"keepContent"`
 	NoCh          bool     `yaml:"noCh"`
 	NoACL         bool     `yaml:"noACL"`
+	IgnoreFile    string   `yaml:"ignoreFile"` // name of the ignore files, defaults to .cabriignore
+	Ignore        []string `yaml:"ignore"`     // gitignore-style patterns to ignore
+	Include       []string `yaml:"include"`    // gitignore-style patterns of the only contents to synchronize
+	MinSize       string   `yaml:"minSize"`
+	MaxSize       string   `yaml:"maxSize"`
+	NewerThan     string   `yaml:"newerThan"` // time
+	OlderThan     string   `yaml:"olderThan"` // time
 	MapACL        []string `yaml:"mapACL"`
 	Summary       bool     `yaml:"summary"`
+	Output        string   `yaml:"output"`     // json or ndjson to keep the reports, see "cabri schedule --reports"
+	ReportFile    string   `yaml:"reportFile"` // file of the json or ndjson report, a temporary one by default
 	Verbose       bool     `yaml:"verbose"`
 	VerboseLevel  int      `yaml:"verboseLevel"`
-	LeftTime      string   `yaml:"leftTime"`
-	RightTime     string   `yaml:"rightTime"`
+	LeftTime      string   `yaml:"leftTime"`   // time or tag
+	RightTime     string   `yaml:"rightTime"`  // time or tag
+	Watch         bool     `yaml:"watch"`      // keep running and synchronize the changes of the fsy left DSS, see "cabri cli sync --watch"
+	WatchDelay    int      `yaml:"watchDelay"` // seconds without change before synchronizing the changed entries
+	WatchFull     int      `yaml:"watchFull"`  // seconds between full synchronizations
 	LeftDss       string   `yaml:"leftDss"`
 	RightDss      string   `yaml:"rightDss"`
+	Options       string   `yaml:"options"` // other options of the command, for instance --pfile
 }
 
 type SGitSpec struct {
@@ -55,11 +69,23 @@ type SGitSpec struct {
 	PullOptions     string `yaml:"pullOptions"`
 }
 
+type SRetainSpec struct {
+	SScheduleBase `yaml:"base"`
+	Dss           string `yaml:"dss"`     // DSS entry to which the policy applies, as dss-type:/path/to/dss@path/in/dss
+	Policy        string `yaml:"policy"`  // retention policy, see "cabri cli dss retain"
+	Options       string `yaml:"options"` // other options of the command, for instance --pfile
+	Recursive     bool   `yaml:"recursive"`
+	DryRun        bool   `yaml:"dryRun"`
+	Force         bool   `yaml:"force"`
+	NoPurge       bool   `yaml:"noPurge"`
+}
+
 type SScheduledAction struct {
 	SScheduleBase `yaml:"base"`
-	Type          string         `yaml:"type"` // currently "cabriSync", "git" or "cmd"
+	Type          string         `yaml:"type"` // currently "cabriSync", "git", "retain" or "cmd"
 	CabriSyncSpec SCabriSyncSpec `yaml:"cabriSyncSpec"`
 	GitSpec       SGitSpec       `yaml:"gitSpec"`
+	RetainSpec    SRetainSpec    `yaml:"retainSpec"`
 	CmdLine       string         `yaml:"cmdLine"`
 }
 
@@ -77,24 +103,33 @@ type CabriScheduleSpec map[string]struct {
 	Actions         []SScheduledAction `yaml:"actions"`
 }
 
+// ScheduleSyncReport is the report of a cabriSync action run with a json or ndjson output
+type ScheduleSyncReport struct {
+	Time   int64                    `json:"time"`
+	Action int                      `json:"action"` // index of the action in the schedule entry
+	Report cabrisync.SyncReportData `json:"report"`
+}
+
 type ScheduleRunStatus struct {
 	label     string
 	IsRunning bool `json:"isRunning"`
 	uow       joule.UnitOfWork
-	Count     int    `json:"count"`
-	LastTime  int64  `json:"lastTime"`
-	LastOut   string `json:"lastOut"`
-	LastErr   string `json:"lastErr"`
-	LastRunOk bool   `json:"lastRunOk"`
+	Count     int                  `json:"count"`
+	LastTime  int64                `json:"lastTime"`
+	LastOut   string               `json:"lastOut"`
+	LastErr   string               `json:"lastErr"`
+	LastRunOk bool                 `json:"lastRunOk"`
+	Reports   []ScheduleSyncReport `json:"reports"` // latest reports, the most recent first
 }
 
 type ScheduleConfig struct {
-	ctx       context.Context
-	cancel    context.CancelFunc
-	mux       sync.Mutex
-	Spec      CabriScheduleSpec
-	isExiting bool
-	run       map[string]*ScheduleRunStatus
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mux         sync.Mutex
+	Spec        CabriScheduleSpec
+	isExiting   bool
+	run         map[string]*ScheduleRunStatus
+	keepReports int
 }
 
 func logSchedule(ctx context.Context, line string) {
@@ -176,9 +211,113 @@ func (srs *ScheduleRunStatus) doRunGit(sc *ScheduleConfig, action SScheduledActi
 	return
 }
 
+func (srs *ScheduleRunStatus) doRunCabriSync(sc *ScheduleConfig, action SScheduledAction, cs SCabriSyncSpec) (lastCommand string, stdout, stderr []byte, err error) {
+	if cs.LeftDss == "" || cs.RightDss == "" {
+		err = fmt.Errorf("cabriSync action requires left and right DSS")
+		return
+	}
+	cabri, err := os.Executable()
+	if err != nil {
+		return
+	}
+	lastCommand = fmt.Sprintf("%s cli sync", cabri)
+	if cs.Options != "" {
+		lastCommand += " " + cs.Options
+	}
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{cs.Recursive, "--recursive"}, {cs.DryRun, "--dryrun"}, {cs.BiDir, "--bidir"},
+		{cs.KeepContent, "--keep"}, {cs.NoCh, "--nocheck"}, {cs.NoACL, "--noacl"},
+		{cs.Summary, "--summary"}, {cs.Verbose, "--verbose"}, {cs.Watch, "--watch"},
+	} {
+		if flag.set {
+			lastCommand += " " + flag.name
+		}
+	}
+	if cs.VerboseLevel != 0 {
+		lastCommand += fmt.Sprintf(" --debug %d", cs.VerboseLevel)
+	}
+	for _, flag := range []struct {
+		values []string
+		name   string
+	}{
+		{cs.LeftUsers, "--leftuser"}, {cs.LeftACL, "--leftacl"}, {cs.RightUsers, "--user"},
+		{cs.RightACL, "--acl"}, {cs.MapACL, "--macl"}, {cs.Ignore, "--ignore"}, {cs.Include, "--include"},
+	} {
+		for _, value := range flag.values {
+			lastCommand += fmt.Sprintf(" %s %s", flag.name, value)
+		}
+	}
+	for _, flag := range []struct {
+		value string
+		name  string
+	}{
+		{cs.Output, "--output"}, {cs.ReportFile, "--report"}, {cs.IgnoreFile, "--ignorefile"}, {cs.MinSize, "--minsize"}, {cs.MaxSize, "--maxsize"},
+		{cs.NewerThan, "--newer"}, {cs.OlderThan, "--older"},
+	} {
+		if flag.value != "" {
+			lastCommand += fmt.Sprintf(" %s %s", flag.name, flag.value)
+		}
+	}
+	if cs.WatchDelay != 0 {
+		lastCommand += fmt.Sprintf(" --watchdelay %d", cs.WatchDelay)
+	}
+	if cs.WatchFull != 0 {
+		lastCommand += fmt.Sprintf(" --watchfull %d", cs.WatchFull)
+	}
+	if cs.LeftTime != "" {
+		lastCommand += " --lefttime " + cs.LeftTime
+	}
+	if cs.RightTime != "" {
+		lastCommand += " --righttime " + cs.RightTime
+	}
+	lastCommand += " " + cs.LeftDss + " " + cs.RightDss
+	stdout, stderr, err = srs.doRunCommand(sc, action, lastCommand, "")
+	return
+}
+
+func (srs *ScheduleRunStatus) doRunRetain(sc *ScheduleConfig, action SScheduledAction, rs SRetainSpec) (lastCommand string, stdout, stderr []byte, err error) {
+	if rs.Dss == "" {
+		err = fmt.Errorf("retain action requires a DSS entry")
+		return
+	}
+	cabri, err := os.Executable()
+	if err != nil {
+		return
+	}
+	lastCommand = fmt.Sprintf("%s cli dss retain", cabri)
+	if rs.Options != "" {
+		lastCommand += " " + rs.Options
+	}
+	if rs.Policy != "" {
+		lastCommand += " --policy " + rs.Policy
+	}
+	if rs.Recursive {
+		lastCommand += " --recursive"
+	}
+	if rs.DryRun {
+		lastCommand += " --dryrun"
+	}
+	if rs.Force {
+		lastCommand += " --force"
+	}
+	if rs.NoPurge {
+		lastCommand += " --nopurge"
+	}
+	lastCommand += " " + rs.Dss
+	stdout, stderr, err = srs.doRunCommand(sc, action, lastCommand, "")
+	return
+}
+
 func (srs *ScheduleRunStatus) doRun(sc *ScheduleConfig, action SScheduledAction) (lastCommand string, stdout, stderr []byte, err error) {
-	if action.Type == "git" {
+	if action.Type == "cabriSync" {
+		lastCommand, stdout, stderr, err = srs.doRunCabriSync(sc, action, action.CabriSyncSpec)
+	} else if action.Type == "git" {
 		lastCommand, stdout, stderr, err = srs.doRunGit(sc, action, action.GitSpec)
+	} else if action.Type == "retain" {
+		lastCommand, stdout, stderr, err = srs.doRunRetain(sc, action, action.RetainSpec)
 	} else if action.Type == "cmd" {
 		stdout, stderr, err = srs.doRunCommand(sc, action, action.CmdLine, "")
 	} else {
@@ -201,11 +340,28 @@ func (srs *ScheduleRunStatus) run(sc *ScheduleConfig) (isRunning bool, err error
 	go func() {
 		srs.Count++
 		srs.LastTime = time.Now().UnixNano()
-		for _, action := range sc.Spec[srs.label].Actions {
+		for ix, action := range sc.Spec[srs.label].Actions {
 			if action.Verbose {
 				logSchedule(sc.ctx, fmt.Sprintf("%s %s running", srs.label, action))
 			}
+			keep := sc.keepReports > 0 && action.Type == "cabriSync" &&
+				(action.CabriSyncSpec.Output == "json" || action.CabriSyncSpec.Output == "ndjson")
+			tmpReport := ""
+			if keep && action.CabriSyncSpec.ReportFile == "" {
+				var errTmp error
+				if tmpReport, errTmp = tmpSyncReportFile(); errTmp != nil {
+					logSchedule(sc.ctx, fmt.Sprintf("%s: cannot create the report file of action %d: %v", srs.label, ix, errTmp))
+					keep = false
+				}
+				action.CabriSyncSpec.ReportFile = tmpReport
+			}
 			lc, so, se, err := srs.doRun(sc, action)
+			if keep {
+				srs.keepReport(sc, ix, action.CabriSyncSpec.ReportFile)
+			}
+			if tmpReport != "" {
+				_ = os.Remove(tmpReport)
+			}
 			if err != nil && lc != "" {
 				logSchedule(sc.ctx, fmt.Sprintf("error on command \"%s\" in action %s\n", lc, srs.label))
 			}
@@ -238,6 +394,34 @@ func (srs *ScheduleRunStatus) run(sc *ScheduleConfig) (isRunning bool, err error
 	return false, nil
 }
 
+func tmpSyncReportFile() (string, error) {
+	f, err := os.CreateTemp("", "cabri-report-*")
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+func (srs *ScheduleRunStatus) keepReport(sc *ScheduleConfig, ix int, reportFile string) {
+	rf, err := os.Open(reportFile)
+	if err != nil {
+		logSchedule(sc.ctx, fmt.Sprintf("%s: cannot read the report of action %d: %v", srs.label, ix, err))
+		return
+	}
+	defer rf.Close()
+	srd, err := cabrisync.ReadSyncReportData(rf)
+	if err != nil {
+		logSchedule(sc.ctx, fmt.Sprintf("%s: cannot read the report of action %d: %v", srs.label, ix, err))
+		return
+	}
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	srs.Reports = append([]ScheduleSyncReport{{Time: time.Now().UnixNano(), Action: ix, Report: srd}}, srs.Reports...)
+	if len(srs.Reports) > sc.keepReports {
+		srs.Reports = srs.Reports[:sc.keepReports]
+	}
+}
+
 func NewServerErr(where string, err error) error {
 	return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("in %s: %v", where, err))
 }
@@ -255,6 +439,8 @@ func sSchedGet(c echo.Context) error {
 	if !ok {
 		return NewServerErr("sRestGet", fmt.Errorf("sSchedGet, no such scheduled entry: %s", label))
 	}
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
 	return c.JSON(http.StatusOK, &srs)
 }
 
@@ -210,6 +210,18 @@ func CheckTimeStamp(value string) (int64, error) {
 	return internal.CheckTimeStamp(value)
 }
 
+// CheckTimeOrTag accepts either a timestamp as CheckTimeStamp does or a DSS tag name, resolved when the DSS is opened
+func CheckTimeOrTag(value string) (unix int64, tag string, err error) {
+	if unix, err = CheckTimeStamp(value); err == nil {
+		return
+	}
+	if cabridss.CheckTagName(value) == nil {
+		return 0, value, nil
+	}
+	err = fmt.Errorf("%s must be either a RFC3339 timestamp (eg 2020-08-13T11:56:41Z), a unix time integer or a tag name", value)
+	return
+}
+
 func GetBaseConfig(opts BaseOptions, index int, root, localPath, mp string) (cabridss.DssBaseConfig, error) {
 	cd, err := ConfigDir(opts)
 	if err != nil {
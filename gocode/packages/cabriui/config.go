@@ -7,17 +7,25 @@ import (
 	"fmt"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/joule"
+	"os"
+	"strconv"
+	"strings"
 )
 
 type ConfigOptions struct {
 	BaseOptions
-	Encrypt bool
-	Decrypt bool
-	Dump    bool
-	Gen     bool
-	Get     bool
-	Put     bool
-	Remove  bool
+	Encrypt  bool
+	Decrypt  bool
+	Dump     bool
+	Gen      bool
+	Get      bool
+	Put      bool
+	Remove   bool
+	Ssh      bool
+	Pass     bool
+	Recovery bool
+	Recover  bool
+	GenSign  bool
 }
 
 type ConfigVars struct {
@@ -116,6 +124,12 @@ func configGet(ctx context.Context) error {
 			return fmt.Errorf("identity for alias %s not found", alias)
 		}
 		configOut(ctx, fmt.Sprintf("PKey: %s\nSecret: %s\n", ic.PKey, ic.Secret))
+		if ic.Salt != "" {
+			configOut(ctx, fmt.Sprintf("Salt: %s\n", ic.Salt))
+		}
+		if ic.SignPKey != "" {
+			configOut(ctx, fmt.Sprintf("SignPKey: %s\n", ic.SignPKey))
+		}
 	}
 	return nil
 }
@@ -153,6 +167,145 @@ func configPut(ctx context.Context) error {
 	return cabridss.SaveUserConfig(cabridss.DssBaseConfig{ConfigPassword: ure.MasterPassword}, ure.ConfigDir, ure.UserConfig)
 }
 
+func configSsh(ctx context.Context) error {
+	ure, err := GetUiRunEnv[ConfigOptions, *ConfigVars](ctx, false, false)
+	if err != nil {
+		return err
+	}
+	args := configCtx(ctx).args
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("<alias> <public-key-file> [<private-key-file>] not provided")
+	}
+	var pub, priv []byte
+	if args[1] != "" {
+		if pub, err = os.ReadFile(args[1]); err != nil {
+			return err
+		}
+	}
+	if len(args) == 3 {
+		if priv, err = os.ReadFile(args[2]); err != nil {
+			return err
+		}
+	}
+	ic, err := cabridss.SshIdentity(args[0], pub, priv, "")
+	if errors.Is(err, cabridss.ErrPasswordRequired) {
+		ic, err = cabridss.SshIdentity(args[0], pub, priv, configUow(ctx).UiSecret("please enter the SSH key passphrase: "))
+	}
+	if err != nil {
+		return err
+	}
+	ure.UserConfig.PutIdentity(ic)
+	return cabridss.SaveUserConfig(cabridss.DssBaseConfig{ConfigPassword: ure.MasterPassword}, ure.ConfigDir, ure.UserConfig)
+}
+
+func configPass(ctx context.Context) error {
+	ure, err := GetUiRunEnv[ConfigOptions, *ConfigVars](ctx, false, false)
+	if err != nil {
+		return err
+	}
+	args := configCtx(ctx).args
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("<alias> [<salt>] not provided")
+	}
+	salt := ""
+	if len(args) == 2 {
+		salt = args[1]
+	}
+	pass := configUow(ctx).UiSecret("please enter the identity passphrase: ")
+	if salt == "" {
+		if pass != configUow(ctx).UiSecret("please enter the identity passphrase again: ") {
+			return fmt.Errorf("passphrases differ")
+		}
+	}
+	ic, err := cabridss.PassphraseIdentity(args[0], pass, salt)
+	if err != nil {
+		return err
+	}
+	ure.UserConfig.PutIdentity(ic)
+	if err = cabridss.SaveUserConfig(cabridss.DssBaseConfig{ConfigPassword: ure.MasterPassword}, ure.ConfigDir, ure.UserConfig); err != nil {
+		return err
+	}
+	configOut(ctx, fmt.Sprintf("PKey: %s\nSalt: %s\n", ic.PKey, ic.Salt))
+	return nil
+}
+
+func configRecovery(ctx context.Context) error {
+	args := configCtx(ctx).args
+	if len(args) != 2 {
+		return fmt.Errorf("<shares> <threshold> not provided")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	m, err := strconv.Atoi(args[1])
+	if err != nil {
+		return err
+	}
+	ure, err := GetUiRunEnv[ConfigOptions, *ConfigVars](ctx, false, false)
+	if err != nil {
+		return err
+	}
+	shares, err := cabridss.RecoveryShares(ure.UserConfig, n, m)
+	if err != nil {
+		return err
+	}
+	configOut(ctx, strings.Join(shares, "\n"))
+	return nil
+}
+
+func configRecover(ctx context.Context) error {
+	args := configCtx(ctx).args
+	if len(args) == 0 {
+		return fmt.Errorf("<share-file>... not provided")
+	}
+	var sb strings.Builder
+	for _, arg := range args {
+		bs, err := os.ReadFile(arg)
+		if err != nil {
+			return err
+		}
+		sb.Write(bs)
+		sb.WriteString("\n")
+	}
+	uc, err := cabridss.RecoverUserConfig(sb.String())
+	if err != nil {
+		return err
+	}
+	mp, err := MasterPassword(configUow(ctx), configOpts(ctx).BaseOptions, 0)
+	if err != nil {
+		return err
+	}
+	cd, err := ConfigDir(configOpts(ctx).BaseOptions)
+	if err != nil {
+		return err
+	}
+	return cabridss.RestoreUserConfig(cabridss.DssBaseConfig{ConfigPassword: mp}, cd, uc)
+}
+
+func configGenSign(ctx context.Context) error {
+	ure, err := GetUiRunEnv[ConfigOptions, *ConfigVars](ctx, false, false)
+	if err != nil {
+		return err
+	}
+	args := configCtx(ctx).args
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	for _, alias := range args {
+		ic := ure.UserConfig.GetIdentity(alias)
+		if ic.PKey == "" || ic.Secret == "" {
+			return fmt.Errorf("identity with secret for alias %s not found", alias)
+		}
+		if ic, err = cabridss.GenSigningKey(ic); err != nil {
+			return err
+		}
+		ure.UserConfig.PutIdentity(ic)
+		configOut(ctx, fmt.Sprintf("SignPKey: %s\n", ic.SignPKey))
+	}
+	return cabridss.SaveUserConfig(cabridss.DssBaseConfig{ConfigPassword: ure.MasterPassword}, ure.ConfigDir, ure.UserConfig)
+}
+
 func configRemove(ctx context.Context) error {
 	ure, err := GetUiRunEnv[ConfigOptions, *ConfigVars](ctx, false, false)
 	if err != nil {
@@ -206,6 +359,21 @@ func configRun(ctx context.Context) error {
 	if opts.Remove {
 		err = configRemove(ctx)
 	}
+	if opts.Ssh {
+		err = configSsh(ctx)
+	}
+	if opts.Pass {
+		err = configPass(ctx)
+	}
+	if opts.Recovery {
+		err = configRecovery(ctx)
+	}
+	if opts.Recover {
+		err = configRecover(ctx)
+	}
+	if opts.GenSign {
+		err = configGenSign(ctx)
+	}
 	if err != nil {
 		if errors.Is(err, cabridss.ErrPasswordRequired) {
 			return cabridss.ErrPasswordRequired
@@ -6,14 +6,22 @@ import (
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/internal"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/joule"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
 	"io"
+	"sort"
 	"strings"
 	"time"
 )
 
 type DSSMkOptions struct {
 	BaseOptions
-	Size string
+	Size               string
+	Chunked            bool
+	Compressed         bool
+	Compression        string
+	Convergent         bool
+	MultipartThreshold int // obs content size in MiB above which multipart upload is used, negative disables it
+	MultipartPartSize  int // obs multipart upload part size in MiB
 }
 
 type DSSMkVars struct {
@@ -66,6 +74,10 @@ func dssMkRun(ctx context.Context) error {
 			return lerr
 		}
 		oc.Encrypted = encrypted
+		oc.Chunked = opts.Chunked
+		oc.Compressed = opts.Compressed || opts.Compression != ""
+		oc.Compression = opts.Compression
+		oc.Convergent = opts.Convergent
 		if encrypted {
 			if oc.XImpl == "" {
 				oc.XImpl = "bdb"
@@ -82,6 +94,11 @@ func dssMkRun(ctx context.Context) error {
 			return err
 		}
 		oc.Encrypted = encrypted
+		oc.Chunked = opts.Chunked
+		oc.Compressed = opts.Compressed || opts.Compression != ""
+		oc.Compression = opts.Compression
+		oc.Convergent = opts.Convergent
+		oc.MultipartThreshold, oc.MultipartPartSize = mibSize(opts.MultipartThreshold), mibSize(opts.MultipartPartSize)
 		if dss, err = cabridss.CreateObsDss(oc); err != nil {
 			return err
 		}
@@ -91,6 +108,11 @@ func dssMkRun(ctx context.Context) error {
 			return err
 		}
 		sc.Encrypted = encrypted
+		sc.Chunked = opts.Chunked
+		sc.Compressed = opts.Compressed || opts.Compression != ""
+		sc.Compression = opts.Compression
+		sc.Convergent = opts.Convergent
+		sc.MultipartThreshold, sc.MultipartPartSize = mibSize(opts.MultipartThreshold), mibSize(opts.MultipartPartSize)
 		if dss, err = cabridss.CreateObsDss(sc); err != nil {
 			return err
 		}
@@ -176,6 +198,69 @@ func dssMknsRun(ctx context.Context) error {
 	return nil
 }
 
+type DSSMvOptions struct {
+	BaseOptions
+}
+
+type DSSMvVars struct {
+	baseVars
+}
+
+func DSSMvStartup(cr *joule.CLIRunner[DSSMvOptions]) error {
+	_ = cr.AddUow("command",
+		func(ctx context.Context, work joule.UnitOfWork, i interface{}) (interface{}, error) {
+			(*uiCtxFrom[DSSMvOptions, *DSSMvVars](ctx)).vars = &DSSMvVars{baseVars: baseVars{uow: work}}
+			return nil, dssMvRun(ctx)
+		})
+	return nil
+}
+
+func DSSMvShutdown(cr *joule.CLIRunner[DSSMvOptions]) error {
+	return cr.GetUow("command").GetError()
+}
+
+func dssMvCtx(ctx context.Context) *uiContext[DSSMvOptions, *DSSMvVars] {
+	return uiCtxFrom[DSSMvOptions, *DSSMvVars](ctx)
+}
+
+func dssMvRun(ctx context.Context) error {
+	args := dssMvCtx(ctx).args
+	dssType, root, src, _ := CheckDssPath(args[0])
+	dst := args[1]
+	var (
+		dss cabridss.Dss
+		err error
+	)
+	if _, err = GetUiRunEnv[DSSMvOptions, *DSSMvVars](ctx, dssType[0] == 'x', false); err != nil {
+		return err
+	}
+	if dssType == "fsy" {
+		if dss, err = cabridss.NewFsyDss(cabridss.FsyConfig{}, root); err != nil {
+			return err
+		}
+	} else if strings.HasPrefix(dssType, "wfsapi+") {
+		if dss, err = NewWfsDss[DSSMvOptions, *DSSMvVars](ctx, nil, NewHDssArgs{}); err != nil {
+			return err
+		}
+	} else if dss, err = NewHDss[DSSMvOptions, *DSSMvVars](ctx, nil, NewHDssArgs{}); err != nil {
+		return err
+	}
+	// a namespace may be given without its trailing slash
+	if !strings.HasSuffix(src, "/") {
+		if meta, err := dss.GetMeta(src+"/", false); err == nil && meta.GetIsNs() {
+			src += "/"
+		}
+	}
+	if strings.HasSuffix(src, "/") && !strings.HasSuffix(dst, "/") {
+		dst += "/"
+	}
+	if err = dss.Rename(src, dst, time.Now().Unix()); err != nil {
+		dss.Close()
+		return err
+	}
+	return dss.Close()
+}
+
 type DSSGetPutOptions struct {
 	BaseOptions
 }
@@ -459,7 +544,14 @@ func dssScanRun(ctx context.Context) error {
 			dssScanOut(ctx, fmt.Sprintf("%s\n", hc))
 		}
 	} else {
-		_ = sti
+		var paths []string
+		for path := range sti.Untrusted {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			dssScanOut(ctx, fmt.Sprintf("untrusted %s: %s\n", path, sti.Untrusted[path]))
+		}
 	}
 	return nil
 }
@@ -547,20 +639,43 @@ func dssLsHistoUow(ctx context.Context) joule.UnitOfWork {
 
 func dssLsHistoOut(ctx context.Context, s string) { dssLsHistoUow(ctx).UiStrOut(s) }
 
+// signedHistoryInfo displays a history entry followed by its signer if any
+type signedHistoryInfo struct {
+	cabridss.HistoryInfo
+	signer string
+}
+
+func (shi signedHistoryInfo) String() string {
+	if shi.signer == "" {
+		return shi.HistoryInfo.String()
+	}
+	return shi.HistoryInfo.String() + " " + shi.signer
+}
+
 func dssLsHistoRun(ctx context.Context) error {
+	args := dssLsHistoCtx(ctx).args
+	dssType, _, npath, _ := CheckDssPath(args[0])
+	ure, err := GetUiRunEnv[DSSLsHistoOptions, *DSSLsHistoVars](ctx, dssType[0] == 'x', false)
+	if err != nil {
+		return err
+	}
 	dss, err := NewHDss[DSSLsHistoOptions, *DSSLsHistoVars](ctx, nil, NewHDssArgs{})
 	if err != nil {
 		return err
 	}
 	defer dss.Close()
-	args := dssLsHistoCtx(ctx).args
-	_, _, npath, _ := CheckDssPath(args[0])
 
 	mHes, err := dss.GetHistory(npath, dssLsHistoOpts(ctx).Recursive, dssLsHistoOpts(ctx).Resolution)
 	if err != nil {
 		return err
 	}
-	dssLsHistoOut(ctx, fmt.Sprintf("%s\n", internal.MapSliceStringer[cabridss.HistoryInfo]{Map: mHes}))
+	mShes := map[string][]signedHistoryInfo{}
+	for k, hes := range mHes {
+		for _, he := range hes {
+			mShes[k] = append(mShes[k], signedHistoryInfo{HistoryInfo: he, signer: MetaSigner(ure.UserConfig, he.HMeta)})
+		}
+	}
+	dssLsHistoOut(ctx, fmt.Sprintf("%s\n", internal.MapSliceStringer[signedHistoryInfo]{Map: mShes}))
 	return nil
 }
 
@@ -568,6 +683,7 @@ type DSSRmHistoOptions struct {
 	BaseOptions
 	Recursive bool
 	DryRun    bool
+	Force     bool
 	StartTime string
 	EndTime   string
 }
@@ -611,7 +727,7 @@ func dssRmHistoRun(ctx context.Context) error {
 	_, _, npath, _ := CheckDssPath(args[0])
 	st, _ := CheckTimeStamp(dssRmHistoOpts(ctx).StartTime)
 	et, _ := CheckTimeStamp(dssRmHistoOpts(ctx).EndTime)
-	mHes, err := dss.RemoveHistory(npath, dssRmHistoOpts(ctx).Recursive, dssRmHistoOpts(ctx).DryRun, st, et)
+	mHes, err := dss.RemoveHistory(npath, dssRmHistoOpts(ctx).Recursive, dssRmHistoOpts(ctx).DryRun, dssRmHistoOpts(ctx).Force, st, et)
 	if err != nil {
 		return err
 	}
@@ -619,6 +735,393 @@ func dssRmHistoRun(ctx context.Context) error {
 	return nil
 }
 
+type DSSRetainOptions struct {
+	BaseOptions
+	Recursive bool
+	DryRun    bool
+	Force     bool
+	Policy    string
+	NoPurge   bool
+}
+
+type DSSRetainVars struct {
+	baseVars
+}
+
+func DSSRetainStartup(cr *joule.CLIRunner[DSSRetainOptions]) error {
+	_ = cr.AddUow("command",
+		func(ctx context.Context, work joule.UnitOfWork, i interface{}) (interface{}, error) {
+			(*uiCtxFrom[DSSRetainOptions, *DSSRetainVars](ctx)).vars = &DSSRetainVars{baseVars: baseVars{uow: work}}
+			return nil, dssRetainRun(ctx)
+		})
+	return nil
+}
+
+func DSSRetainShutdown(cr *joule.CLIRunner[DSSRetainOptions]) error {
+	return cr.GetUow("command").GetError()
+}
+
+func dssRetainCtx(ctx context.Context) *uiContext[DSSRetainOptions, *DSSRetainVars] {
+	return uiCtxFrom[DSSRetainOptions, *DSSRetainVars](ctx)
+}
+
+func dssRetainOpts(ctx context.Context) DSSRetainOptions { return (*dssRetainCtx(ctx)).opts }
+
+func dssRetainUow(ctx context.Context) joule.UnitOfWork {
+	return getUnitOfWork[DSSRetainOptions, *DSSRetainVars](ctx)
+}
+
+func dssRetainOut(ctx context.Context, s string) { dssRetainUow(ctx).UiStrOut(s) }
+
+func dssRetainRun(ctx context.Context) error {
+	dss, err := NewHDss[DSSRetainOptions, *DSSRetainVars](ctx, nil, NewHDssArgs{})
+	if err != nil {
+		return err
+	}
+	defer dss.Close()
+	opts := dssRetainOpts(ctx)
+	args := dssRetainCtx(ctx).args
+	_, _, npath, _ := CheckDssPath(args[0])
+	rp, err := cabridss.ParseRetentionPolicy(opts.Policy)
+	if err != nil {
+		return err
+	}
+	mHes, err := dss.ApplyRetention(npath, opts.Recursive, opts.DryRun, opts.Force, rp, 0)
+	if err != nil {
+		return err
+	}
+	dssRetainOut(ctx, fmt.Sprintf("%s\n", internal.MapSliceStringer[cabridss.HistoryInfo]{Map: mHes}))
+	if opts.DryRun || opts.NoPurge {
+		return nil
+	}
+	if dss.GetIndex() == nil || !dss.GetIndex().IsPersistent() {
+		dssRetainOut(ctx, "unused content not purged as the DSS index is not persistent\n")
+		return nil
+	}
+	// the purge reports each content removed as an error, a second scan checks that none remains
+	_, perr := dss.ScanStorage(false, true, false)
+	purged := 0
+	if perr != nil {
+		purged = len(*perr)
+	}
+	if _, perr = dss.ScanStorage(false, false, false); perr != nil {
+		return perr
+	}
+	dssRetainOut(ctx, fmt.Sprintf("purged %d unused content(s)\n", purged))
+	return nil
+}
+
+type DSSTagOptions struct {
+	BaseOptions
+	Create string
+	Delete string
+	Time   string
+}
+
+type DSSTagVars struct {
+	baseVars
+}
+
+func DSSTagStartup(cr *joule.CLIRunner[DSSTagOptions]) error {
+	_ = cr.AddUow("command",
+		func(ctx context.Context, work joule.UnitOfWork, i interface{}) (interface{}, error) {
+			(*uiCtxFrom[DSSTagOptions, *DSSTagVars](ctx)).vars = &DSSTagVars{baseVars: baseVars{uow: work}}
+			return nil, dssTagRun(ctx)
+		})
+	return nil
+}
+
+func DSSTagShutdown(cr *joule.CLIRunner[DSSTagOptions]) error {
+	return cr.GetUow("command").GetError()
+}
+
+func dssTagCtx(ctx context.Context) *uiContext[DSSTagOptions, *DSSTagVars] {
+	return uiCtxFrom[DSSTagOptions, *DSSTagVars](ctx)
+}
+
+func dssTagOpts(ctx context.Context) DSSTagOptions { return (*dssTagCtx(ctx)).opts }
+
+func dssTagUow(ctx context.Context) joule.UnitOfWork {
+	return getUnitOfWork[DSSTagOptions, *DSSTagVars](ctx)
+}
+
+func dssTagOut(ctx context.Context, s string) { dssTagUow(ctx).UiStrOut(s) }
+
+func dssTagRun(ctx context.Context) error {
+	dss, err := NewHDss[DSSTagOptions, *DSSTagVars](ctx, nil, NewHDssArgs{})
+	if err != nil {
+		return err
+	}
+	defer dss.Close()
+	opts := dssTagOpts(ctx)
+	if opts.Create != "" {
+		st, _ := CheckTimeStamp(opts.Time)
+		return dss.CreateTag(opts.Create, st)
+	}
+	if opts.Delete != "" {
+		return dss.DeleteTag(opts.Delete)
+	}
+	tags, err := dss.ListTags()
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		dssTagOut(ctx, fmt.Sprintf("%s\n", tag))
+	}
+	return nil
+}
+
+type DSSRekeyOptions struct {
+	BaseOptions
+	Recursive bool
+	MapUsers  []string
+	Revoke    []string
+	Content   bool
+	NoPurge   bool
+}
+
+type DSSRekeyVars struct {
+	baseVars
+}
+
+func DSSRekeyStartup(cr *joule.CLIRunner[DSSRekeyOptions]) error {
+	_ = cr.AddUow("command",
+		func(ctx context.Context, work joule.UnitOfWork, i interface{}) (interface{}, error) {
+			(*uiCtxFrom[DSSRekeyOptions, *DSSRekeyVars](ctx)).vars = &DSSRekeyVars{baseVars: baseVars{uow: work}}
+			return nil, dssRekeyRun(ctx)
+		})
+	return nil
+}
+
+func DSSRekeyShutdown(cr *joule.CLIRunner[DSSRekeyOptions]) error {
+	return cr.GetUow("command").GetError()
+}
+
+func dssRekeyCtx(ctx context.Context) *uiContext[DSSRekeyOptions, *DSSRekeyVars] {
+	return uiCtxFrom[DSSRekeyOptions, *DSSRekeyVars](ctx)
+}
+
+func dssRekeyOpts(ctx context.Context) DSSRekeyOptions { return (*dssRekeyCtx(ctx)).opts }
+
+func dssRekeyUow(ctx context.Context) joule.UnitOfWork {
+	return getUnitOfWork[DSSRekeyOptions, *DSSRekeyVars](ctx)
+}
+
+func dssRekeyOut(ctx context.Context, s string) { dssRekeyUow(ctx).UiStrOut(s) }
+
+// rekeyMapUsers maps the public keys of the identities given by their alias
+func rekeyMapUsers(opts DSSRekeyOptions, uc cabridss.UserConfig) (map[string][]string, error) {
+	pkey := func(alias string) (string, error) {
+		if idc := uc.GetIdentity(alias); idc.PKey != "" {
+			return idc.PKey, nil
+		}
+		return "", fmt.Errorf("no such alias: \"%s\"", alias)
+	}
+	mu := map[string][]string{}
+	for _, mapping := range opts.MapUsers {
+		ou, nu, _ := strings.Cut(mapping, ":")
+		op, err := pkey(ou)
+		if err != nil {
+			return nil, err
+		}
+		np, err := pkey(nu)
+		if err != nil {
+			return nil, err
+		}
+		mu[op] = append(mu[op], np)
+	}
+	for _, user := range opts.Revoke {
+		op, err := pkey(user)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := mu[op]; ok {
+			return nil, fmt.Errorf("user \"%s\" cannot be both mapped and revoked", user)
+		}
+		mu[op] = []string{}
+	}
+	return mu, nil
+}
+
+func dssRekeyRun(ctx context.Context) error {
+	opts := dssRekeyOpts(ctx)
+	ure, err := GetUiRunEnv[DSSRekeyOptions, *DSSRekeyVars](ctx, true, false)
+	if err != nil {
+		return err
+	}
+	mu, err := rekeyMapUsers(opts, ure.UserConfig)
+	if err != nil {
+		return err
+	}
+	dss, err := NewHDss[DSSRekeyOptions, *DSSRekeyVars](ctx, nil, NewHDssArgs{})
+	if err != nil {
+		return err
+	}
+	defer dss.Close()
+	args := dssRekeyCtx(ctx).args
+	_, _, npath, _ := CheckDssPath(args[0])
+	mHes, err := dss.Rekey(npath, opts.Recursive, cabridss.RekeyOptions{
+		MapUsers:   mu,
+		Content:    opts.Content,
+		NoPurge:    opts.NoPurge,
+		JournalDir: ufpath.Join(ure.ConfigDir, "rekey"),
+	})
+	if mHes != nil {
+		dssRekeyOut(ctx, fmt.Sprintf("%s\n", internal.MapSliceStringer[cabridss.HistoryInfo]{Map: mHes}))
+	}
+	return err
+}
+
+// CheckRekeyMapping checks a <old-user:new-user> mapping of identity aliases
+func CheckRekeyMapping(mapping string) error {
+	if ou, nu, ok := strings.Cut(mapping, ":"); !ok || ou == nu || strings.Contains(nu, ":") {
+		return fmt.Errorf("user mapping %s is invalid (must be <old-user:new-user>, the default identity being empty)", mapping)
+	}
+	return nil
+}
+
+type DSSTrustOptions struct {
+	BaseOptions
+	Policy  string
+	Signers []string
+	Untrust []string
+}
+
+type DSSTrustVars struct {
+	baseVars
+}
+
+func DSSTrustStartup(cr *joule.CLIRunner[DSSTrustOptions]) error {
+	_ = cr.AddUow("command",
+		func(ctx context.Context, work joule.UnitOfWork, i interface{}) (interface{}, error) {
+			(*uiCtxFrom[DSSTrustOptions, *DSSTrustVars](ctx)).vars = &DSSTrustVars{baseVars: baseVars{uow: work}}
+			return nil, dssTrustRun(ctx)
+		})
+	return nil
+}
+
+func DSSTrustShutdown(cr *joule.CLIRunner[DSSTrustOptions]) error {
+	return cr.GetUow("command").GetError()
+}
+
+func dssTrustCtx(ctx context.Context) *uiContext[DSSTrustOptions, *DSSTrustVars] {
+	return uiCtxFrom[DSSTrustOptions, *DSSTrustVars](ctx)
+}
+
+func dssTrustOpts(ctx context.Context) DSSTrustOptions { return (*dssTrustCtx(ctx)).opts }
+
+func dssTrustUow(ctx context.Context) joule.UnitOfWork {
+	return getUnitOfWork[DSSTrustOptions, *DSSTrustVars](ctx)
+}
+
+func dssTrustOut(ctx context.Context, s string) { dssTrustUow(ctx).UiStrOut(s) }
+
+// trustSignPKey returns the signing public key of an identity given by its alias, or the signing public key itself
+func trustSignPKey(uc cabridss.UserConfig, signer string) (string, error) {
+	for _, idc := range uc.Identities {
+		if idc.Alias == signer && idc.SignPKey != "" {
+			return idc.SignPKey, nil
+		}
+	}
+	if err := cabridss.CheckSignPKey(signer); err != nil {
+		return "", fmt.Errorf("signer %s is neither an identity alias with a signing key nor a signing public key", signer)
+	}
+	return signer, nil
+}
+
+func dssTrustRun(ctx context.Context) error {
+	opts := dssTrustOpts(ctx)
+	args := dssTrustCtx(ctx).args
+	dssType, _, _ := CheckDssSpec(args[0])
+	ure, err := GetUiRunEnv[DSSTrustOptions, *DSSTrustVars](ctx, dssType[0] == 'x', false)
+	if err != nil {
+		return err
+	}
+	dss, err := NewHDss[DSSTrustOptions, *DSSTrustVars](ctx, nil, NewHDssArgs{})
+	if err != nil {
+		return err
+	}
+	repoId := dss.GetRepoId()
+	if err = dss.Close(); err != nil {
+		return err
+	}
+	if repoId == "" {
+		return fmt.Errorf("DSS %s has no repository id", args[0])
+	}
+
+	uc := ure.UserConfig
+	rt := uc.Trusts[repoId]
+	changed := opts.Policy != "" || len(opts.Signers) != 0 || len(opts.Untrust) != 0
+	if opts.Policy != "" {
+		rt.Policy = opts.Policy
+	}
+	for _, signer := range opts.Signers {
+		spk, err := trustSignPKey(uc, signer)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, tspk := range rt.Signers {
+			if tspk == spk {
+				found = true
+				break
+			}
+		}
+		if !found {
+			rt.Signers = append(rt.Signers, spk)
+		}
+	}
+	for _, signer := range opts.Untrust {
+		spk, err := trustSignPKey(uc, signer)
+		if err != nil {
+			return err
+		}
+		var signers []string
+		for _, tspk := range rt.Signers {
+			if tspk != spk {
+				signers = append(signers, tspk)
+			}
+		}
+		rt.Signers = signers
+	}
+	if changed {
+		if uc.Trusts == nil {
+			uc.Trusts = map[string]cabridss.RepoTrust{}
+		}
+		if rt.Policy == "none" {
+			delete(uc.Trusts, repoId)
+		} else {
+			uc.Trusts[repoId] = rt
+		}
+		if err = cabridss.SaveUserConfig(cabridss.DssBaseConfig{ConfigPassword: ure.MasterPassword}, ure.ConfigDir, uc); err != nil {
+			return err
+		}
+	}
+
+	rt, ok := uc.Trusts[repoId]
+	if !ok {
+		dssTrustOut(ctx, fmt.Sprintf("repository %s: no trust policy\n", repoId))
+		return nil
+	}
+	dssTrustOut(ctx, fmt.Sprintf("repository %s: %s policy\n", repoId, rt.Policy))
+	for _, spk := range rt.Signers {
+		if name := signerName(uc, spk); name != spk {
+			dssTrustOut(ctx, fmt.Sprintf("%s %s\n", spk, name))
+		} else {
+			dssTrustOut(ctx, fmt.Sprintf("%s\n", spk))
+		}
+	}
+	return nil
+}
+
+// CheckTrustPolicy checks a trust policy, "none" removing the trust list
+func CheckTrustPolicy(policy string) error {
+	if policy != cabridss.TrustPolicyFlag && policy != cabridss.TrustPolicyReject && policy != "none" {
+		return fmt.Errorf("trust policy %s is invalid (must be %s, %s or none)", policy, cabridss.TrustPolicyFlag, cabridss.TrustPolicyReject)
+	}
+	return nil
+}
+
 type DSSCleanOptions struct {
 	BaseOptions
 }
@@ -680,9 +1183,77 @@ func dssCleanRun(ctx context.Context) error {
 	return cabridss.CleanObsDss(config)
 }
 
+type DSSAbortMpOptions struct {
+	BaseOptions
+	Older time.Duration
+}
+
+type DSSAbortMpVars struct {
+	baseVars
+}
+
+func DSSAbortMpStartup(cr *joule.CLIRunner[DSSAbortMpOptions]) error {
+	_ = cr.AddUow("command",
+		func(ctx context.Context, work joule.UnitOfWork, i interface{}) (interface{}, error) {
+			(*uiCtxFrom[DSSAbortMpOptions, *DSSAbortMpVars](ctx)).vars = &DSSAbortMpVars{baseVars: baseVars{uow: work}}
+			return nil, dssAbortMpRun(ctx)
+		})
+	return nil
+}
+
+func DSSAbortMpShutdown(cr *joule.CLIRunner[DSSAbortMpOptions]) error {
+	return cr.GetUow("command").GetError()
+}
+
+func dssAbortMpCtx(ctx context.Context) *uiContext[DSSAbortMpOptions, *DSSAbortMpVars] {
+	return uiCtxFrom[DSSAbortMpOptions, *DSSAbortMpVars](ctx)
+}
+
+func dssAbortMpOpts(ctx context.Context) DSSAbortMpOptions { return (*dssAbortMpCtx(ctx)).opts }
+
+func dssAbortMpUow(ctx context.Context) joule.UnitOfWork {
+	return getUnitOfWork[DSSAbortMpOptions, *DSSAbortMpVars](ctx)
+}
+
+func dssAbortMpOut(ctx context.Context, s string) { dssAbortMpUow(ctx).UiStrOut(s) }
+
+func dssAbortMpRun(ctx context.Context) error {
+	opts := dssAbortMpOpts(ctx)
+	args := dssAbortMpCtx(ctx).args
+	dssType, root, _ := CheckDssSpec(args[0])
+	var (
+		config cabridss.ObsConfig
+		err    error
+		mp     string
+	)
+	if mp, err = MasterPassword(dssAbortMpUow(ctx), opts.BaseOptions, 0); err != nil {
+		return err
+	}
+	if dssType == "obs" || dssType == "xobs" {
+		config, err = GetObsConfig(opts.BaseOptions, 0, root, mp)
+		if err != nil {
+			return err
+		}
+	} else if dssType == "smf" {
+		config, err = GetSmfConfig(opts.BaseOptions, 0, root, mp)
+		if err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("DSS type %s is not (yet) supported", dssType)
+	}
+	aborted, err := cabridss.AbortObsMultiparts(config, opts.Older)
+	for _, mpu := range aborted {
+		dssAbortMpOut(ctx, fmt.Sprintf("aborted upload of %s initiated %s\n", mpu.Key, mpu.Initiated.Format(time.RFC3339)))
+	}
+	return err
+}
+
 type DSSConfigOptions struct {
 	BaseOptions
-	Raw bool
+	Raw                bool
+	MultipartThreshold int // if not 0 obs content size in MiB above which multipart upload is used, negative disables it
+	MultipartPartSize  int // if not 0 obs multipart upload part size in MiB
 }
 
 type DSSConfigVars struct {
@@ -779,6 +1350,17 @@ func dssConfigRun(ctx context.Context) error {
 		pc.Container = config.Container
 		changed = true
 	}
+	if dssConfigOpts(ctx).MultipartThreshold != 0 {
+		pc.MultipartThreshold = mibSize(dssConfigOpts(ctx).MultipartThreshold)
+		changed = true
+	}
+	if dssConfigOpts(ctx).MultipartPartSize != 0 {
+		pc.MultipartPartSize = mibSize(dssConfigOpts(ctx).MultipartPartSize)
+		changed = true
+	}
+	if err := cabridss.CheckMultipartPartSize(pc.MultipartPartSize); err != nil {
+		return err
+	}
 	if changed {
 		if err := cabridss.OverwriteDssConfig(config.DssBaseConfig, &pc); err != nil {
 			return err
@@ -788,12 +1370,21 @@ func dssConfigRun(ctx context.Context) error {
 		dssConfigOut(ctx, fmt.Sprintf(
 			"--obsrg %s --obsep %s --obsct %s --obsak %s --obssk %s\n",
 			pc.Region, pc.Endpoint, pc.Container, pc.AccessKey, pc.SecretKey))
+		if pc.MultipartThreshold != 0 || pc.MultipartPartSize != 0 {
+			dssConfigOut(ctx, fmt.Sprintf(
+				"--mpthreshold %d --mppartsize %d\n", pc.MultipartThreshold/mib, pc.MultipartPartSize/mib))
+		}
 	} else {
 		dssConfigOut(ctx, fmt.Sprintf("%+v\n", pc))
 	}
 	return nil
 }
 
+const mib = 1024 * 1024
+
+// mibSize returns a size option given in MiB in bytes
+func mibSize(size int) int64 { return int64(size) * mib }
+
 func CheckResol(resol string) error {
 	trs := []string{"s", "m", "h", "d"}
 	for _, tr := range trs {
@@ -803,3 +1394,12 @@ func CheckResol(resol string) error {
 	}
 	return fmt.Errorf("resolution %s is invalid (must be s for seconds, m for minutes, h for hours or d for days)", resol)
 }
+
+func CheckTagName(name string) error {
+	return cabridss.CheckTagName(name)
+}
+
+func CheckRetentionPolicy(policy string) error {
+	_, err := cabridss.ParseRetentionPolicy(policy)
+	return err
+}
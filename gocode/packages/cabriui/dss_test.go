@@ -1,6 +1,7 @@
 package cabriui
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
@@ -302,3 +303,45 @@ func TestdssTestUnlockRun(t *testing.T) {
 	}
 
 }
+
+func TestDSSMultipartConfig(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestDSSMultipartConfig", func(f *testfs.Fs) error {
+		return os.Mkdir(filepath.Join(f.Path(), "smf"), 0o777)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	ds := fmt.Sprintf("smf:%s/smf", tfs.Path())
+	if err = CLIRun[DSSMkOptions, *DSSMkVars](
+		nil, os.Stdout, os.Stderr,
+		DSSMkOptions{MultipartPartSize: 4}, []string{ds},
+		DSSMkStartup, DSSMkShutdown); err == nil {
+		t.Fatal("TestDSSMultipartConfig part size below 5 MiB should fail")
+	}
+	if err = CLIRun[DSSMkOptions, *DSSMkVars](
+		nil, os.Stdout, os.Stderr,
+		DSSMkOptions{MultipartThreshold: 128, MultipartPartSize: 8}, []string{ds},
+		DSSMkStartup, DSSMkShutdown); err != nil {
+		t.Fatal(err)
+	}
+	config := func(opts DSSConfigOptions) (string, error) {
+		var outBuf bytes.Buffer
+		err := CLIRun[DSSConfigOptions, *DSSConfigVars](
+			nil, &outBuf, os.Stderr,
+			opts, []string{ds},
+			DSSConfigStartup, DSSConfigShutdown)
+		return outBuf.String(), err
+	}
+	out, err := config(DSSConfigOptions{})
+	if err != nil || !strings.Contains(out, "--mpthreshold 128 --mppartsize 8\n") {
+		t.Fatalf("TestDSSMultipartConfig config %s %v", out, err)
+	}
+	if _, err = config(DSSConfigOptions{MultipartPartSize: 1}); err == nil {
+		t.Fatal("TestDSSMultipartConfig part size below 5 MiB should fail")
+	}
+	if out, err = config(DSSConfigOptions{MultipartThreshold: -1, MultipartPartSize: 32}); err != nil || !strings.Contains(out, "--mpthreshold -1 --mppartsize 32\n") {
+		t.Fatalf("TestDSSMultipartConfig config %s %v", out, err)
+	}
+}
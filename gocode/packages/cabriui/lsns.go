@@ -22,9 +22,9 @@ type LsnsOptions struct {
 	LastTime  string
 }
 
-func (los LsnsOptions) getLastTime() (lastTime int64) {
+func (los LsnsOptions) getLastTime() (lastTime int64, lastTag string) {
 	if los.LastTime != "" {
-		lastTime, _ = CheckTimeStamp(los.LastTime)
+		lastTime, lastTag, _ = CheckTimeOrTag(los.LastTime)
 	}
 	return
 }
@@ -33,6 +33,7 @@ type LsnsVars struct {
 	baseVars
 	dssType string
 	dss     cabridss.Dss
+	uc      cabridss.UserConfig
 	root    string
 	npath   string
 }
@@ -70,9 +71,11 @@ func lsns(ctx context.Context, dssPath string) error {
 	)
 	vars := lsnsVars(ctx)
 	vars.dssType, vars.root, vars.npath, _ = CheckDssPath(dssPath)
-	if _, err = GetUiRunEnv[LsnsOptions, *LsnsVars](ctx, vars.dssType[0] == 'x', false); err != nil {
+	ure, err := GetUiRunEnv[LsnsOptions, *LsnsVars](ctx, vars.dssType[0] == 'x', false)
+	if err != nil {
 		return err
 	}
+	vars.uc = ure.UserConfig
 	if vars.dssType == "fsy" {
 		if vars.dss, err = cabridss.NewFsyDss(
 			cabridss.FsyConfig{
@@ -86,9 +89,12 @@ func lsns(ctx context.Context, dssPath string) error {
 			NewHDssArgs{}); err != nil {
 			return err
 		}
-	} else if vars.dss, err = NewHDss[LsnsOptions, *LsnsVars](ctx, nil,
-		NewHDssArgs{Lasttime: lsnsOpts(ctx).getLastTime()}); err != nil {
-		return err
+	} else {
+		lasttime, lasttag := lsnsOpts(ctx).getLastTime()
+		if vars.dss, err = NewHDss[LsnsOptions, *LsnsVars](ctx, nil,
+			NewHDssArgs{Lasttime: lasttime, Lasttag: lasttag}); err != nil {
+			return err
+		}
 	}
 
 	sorted := isLsnsSorted(ctx)
@@ -189,6 +195,13 @@ func outMeta(ctx context.Context, meta cabridss.IMeta) {
 	ll := "\n"
 	if lsnsOpts(ctx).Long {
 		ll = fmt.Sprintf("\n            \t%v\n", meta.GetAcl())
+		if m, ok := meta.(cabridss.Meta); ok && lsnsVars(ctx).dssType != "fsy" {
+			signer := MetaSigner(lsnsVars(ctx).uc, m)
+			if signer == "" {
+				signer = "unsigned"
+			}
+			ll = fmt.Sprintf("\n            \t%v %s\n", meta.GetAcl(), signer)
+		}
 	}
 	if !lsnsOpts(ctx).Checksum {
 		lsnsOut(ctx, fmt.Sprintf("%12d %s %s%s", meta.GetSize(), t, meta.GetPath(), ll))
@@ -0,0 +1,316 @@
+package cabriui
+
+import (
+	"context"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabrisync"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/joule"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/plumber"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
+	"regexp"
+	"runtime/debug"
+	"strings"
+)
+
+const (
+	RestoreOverwrite = "overwrite"
+	RestoreRename    = "rename"
+	RestoreSkip      = "skip"
+)
+
+type RestoreOptions struct {
+	SyncOptions
+	Index    int    // history index of the restored entry as listed by lshisto, from 1, negative from the latest, 0 if unused
+	Conflict string // policy when the target content exists and differs: overwrite, rename or skip
+	Suffix   string // suffix of the restored content name with the rename policy
+}
+
+type RestoreVars struct {
+	baseVars
+}
+
+func RestoreStartup(cr *joule.CLIRunner[RestoreOptions]) error {
+	_ = cr.AddUow("command",
+		func(ctx context.Context, work joule.UnitOfWork, i interface{}) (interface{}, error) {
+			(*uiCtxFrom[RestoreOptions, *RestoreVars](ctx)).vars = &RestoreVars{baseVars: baseVars{uow: work}}
+			return nil, restore(ctx, cr.Args[0], cr.Args[1])
+		})
+	return nil
+}
+
+func RestoreShutdown(cr *joule.CLIRunner[RestoreOptions]) error {
+	return cr.GetUow("command").GetError()
+}
+
+func restoreCtx(ctx context.Context) *uiContext[RestoreOptions, *RestoreVars] {
+	return uiCtxFrom[RestoreOptions, *RestoreVars](ctx)
+}
+
+func restoreOpts(ctx context.Context) RestoreOptions { return (*restoreCtx(ctx)).opts }
+
+func restoreUow(ctx context.Context) joule.UnitOfWork {
+	return getUnitOfWork[RestoreOptions, *RestoreVars](ctx)
+}
+
+func restoreOut(ctx context.Context, s string) { restoreUow(ctx).UiStrOut(s) }
+
+func CheckRestoreConflict(conflict string) error {
+	if conflict != RestoreOverwrite && conflict != RestoreRename && conflict != RestoreSkip {
+		return fmt.Errorf("conflict policy %s is invalid (must be %s, %s or %s)", conflict, RestoreOverwrite, RestoreRename, RestoreSkip)
+	}
+	return nil
+}
+
+// restoreAtIndex returns a view of the DSS when the entry npath was in its index-th history state
+func restoreAtIndex(dss cabridss.Dss, npath string, index int) (cabridss.Dss, error) {
+	hdss, ok := dss.(cabridss.HDss)
+	if !ok {
+		return nil, fmt.Errorf("the source DSS has no history")
+	}
+	mHes, err := hdss.GetHistory(npath, false, "s")
+	if err != nil {
+		return nil, err
+	}
+	his := mHes[npath]
+	if index < 0 {
+		index += len(his) + 1
+	}
+	if index < 1 || index > len(his) {
+		return nil, fmt.Errorf("history index %d is out of range for %s, which has %d entries", index, npath, len(his))
+	}
+	// the DSS is viewed at the end of the entry state, the latest state being the current one
+	hi := his[index-1]
+	if hi.End == cabridss.MAX_TIME {
+		return dss, nil
+	}
+	slsttime := hi.End / 1e9
+	if slsttime*1e9 < hi.Start {
+		return nil, fmt.Errorf("history index %d of %s lasted less than a second and cannot be restored", index, npath)
+	}
+	return hdss.AtTime(slsttime)
+}
+
+// restoreExcl returns the exclusion pattern of the entry name in the source namespace ns
+func restoreExcl(ns, name string) *regexp.Regexp {
+	fp := strings.TrimSuffix(name, "/")
+	if ns != "" {
+		fp = ns + "/" + fp
+	}
+	return regexp.MustCompile("^" + regexp.QuoteMeta(fp) + "/?$")
+}
+
+// restoreRenamed copies the content lpath of the source DSS to rpath in the target one
+func restoreRenamed(ldss cabridss.Dss, lpath string, rdss cabridss.Dss, rpath string, acl []cabridss.ACLEntry) error {
+	lmeta, err := ldss.GetMeta(lpath, false)
+	if err != nil {
+		return err
+	}
+	parent, name := ufpath.Split(rpath)
+	parent = cabridss.RemoveSlashIf(parent)
+	pmeta, err := rdss.GetMeta(cabridss.AppendSlashIf(parent), false)
+	if err != nil {
+		return err
+	}
+	children := pmeta.GetChildren()
+	found := false
+	for _, child := range children {
+		if child == name {
+			found = true
+		}
+	}
+	if !found {
+		if err = rdss.Updatens(parent, pmeta.GetMtime(), append(children, name), pmeta.GetAcl()); err != nil {
+			return err
+		}
+	}
+	rc, err := ldss.GetContentReader(lpath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	wc, err := rdss.GetContentWriter(rpath, lmeta.GetMtime(), acl, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(wc, rc); err != nil {
+		wc.Close()
+		return err
+	}
+	return wc.Close()
+}
+
+func restoreSync(ctx context.Context, args cabrisync.SyncArgs) cabrisync.SyncReport {
+	iOutputs := plumber.LaunchAndWait(ctx,
+		[]string{"Restored"},
+		[]plumber.Launchable{cabrisync.PlizedSynchronize},
+		[]interface{}{args},
+	)
+	return plumber.Retype[cabrisync.SyncReport](iOutputs)[0]
+}
+
+func restore(ctx context.Context, ldssPath, rdssPath string) error {
+	obsIx := 0
+	ldss, lpath, lure, err := str2dss[RestoreOptions, *RestoreVars](ctx, ldssPath, false, &obsIx)
+	if err != nil {
+		return err
+	}
+	rdss, rpath, rure, err := str2dss[RestoreOptions, *RestoreVars](ctx, rdssPath, true, &obsIx)
+	if err != nil {
+		ldss.Close()
+		return err
+	}
+	err = doRestore(ctx, ldss, lpath, lure, rdss, rpath, rure)
+	if errClose := ldss.Close(); errClose != nil {
+		if err == nil {
+			err = errClose
+		}
+	}
+	if errClose := rdss.Close(); errClose != nil {
+		if err == nil {
+			err = errClose
+		}
+	}
+	return err
+}
+
+func doRestore(ctx context.Context, ldss cabridss.Dss, lpath string, lure UiRunEnv, rdss cabridss.Dss, rpath string, rure UiRunEnv) error {
+	opts := restoreOpts(ctx)
+	if opts.MapACL == nil {
+		opts.MapACL = []string{":"}
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = RestoreOverwrite
+	}
+	if opts.Suffix == "" {
+		opts.Suffix = ".restored"
+	}
+	lmacl, rmacl, err := uiMapACL(opts.SyncOptions, lure, rure)
+	if err != nil {
+		return err
+	}
+	el, err := exclList(opts.SyncOptions)
+	if err != nil {
+		return err
+	}
+	racl, err := rure.ACLOrDefault()
+	if err != nil {
+		return err
+	}
+
+	isNs := true
+	if _, err = ldss.GetMeta(cabridss.AppendSlashIf(lpath), false); err != nil {
+		if _, err = ldss.GetMeta(lpath, false); err != nil {
+			return fmt.Errorf("no such entry %s in the source DSS", lpath)
+		}
+		isNs = false
+	}
+	if opts.Index != 0 {
+		hpath := lpath
+		if isNs {
+			hpath = cabridss.AppendSlashIf(lpath)
+		}
+		if ldss, err = restoreAtIndex(ldss, hpath, opts.Index); err != nil {
+			return err
+		}
+	}
+	inDepth := opts.Recursive
+	if !isNs {
+		// a single content is restored by synchronizing its parent namespace without its siblings
+		var name string
+		lpath, name = ufpath.Split(lpath)
+		lpath = cabridss.RemoveSlashIf(lpath)
+		inDepth = false
+		lchildren, err := ldss.Lsns(lpath)
+		if err != nil {
+			return err
+		}
+		rchildren, err := rdss.Lsns(rpath)
+		if err != nil {
+			return err
+		}
+		// excluded paths are matched on the source side, including for target only entries
+		for _, child := range append(lchildren, rchildren...) {
+			if child == name {
+				continue
+			}
+			re := restoreExcl(lpath, child)
+			if re.MatchString(rpath) {
+				return fmt.Errorf("target namespace %s has the name of a sibling of %s, please restore their parent namespace", rpath, name)
+			}
+			el = append(el, re)
+		}
+	}
+	if opts.MaxThread != 0 {
+		debug.SetMaxThreads(opts.MaxThread)
+	}
+	sArgs := cabrisync.SyncArgs{LDss: ldss, LPath: lpath, RDss: rdss, RPath: rpath,
+		SOpts: cabrisync.SyncOptions{
+			InDepth:     inDepth,
+			Evaluate:    true,
+			KeepContent: true,
+			NoCh:        opts.NoCh,
+			ExclList:    el,
+			NoACL:       opts.NoACL,
+			LeftMapACL:  lmacl,
+			RightMapACL: rmacl,
+		},
+	}
+
+	// evaluate first which existing target content would be updated
+	sr := restoreSync(ctx, sArgs)
+	if sr.GErr != nil {
+		return sr.GErr
+	}
+	conflicts := map[string]string{}
+	if opts.Conflict != RestoreOverwrite {
+		for _, entry := range sr.Entries {
+			if !entry.IsNs && entry.Updated {
+				conflicts[entry.LPath] = entry.RPath
+				sArgs.SOpts.ExclList = append(sArgs.SOpts.ExclList, regexp.MustCompile("^"+regexp.QuoteMeta(entry.LPath)+"$"))
+			}
+		}
+	}
+	if !opts.DryRun || len(conflicts) != 0 {
+		sArgs.SOpts.Evaluate = opts.DryRun
+		if sr = restoreSync(ctx, sArgs); sr.GErr != nil {
+			return sr.GErr
+		}
+	}
+	if opts.Conflict == RestoreRename {
+		for i, entry := range sr.Entries {
+			rpath, ok := conflicts[entry.LPath]
+			if !ok || !entry.Excluded {
+				continue
+			}
+			entry.RPath, entry.Excluded, entry.Created = rpath+opts.Suffix, false, true
+			if !opts.DryRun {
+				entry.Err = restoreRenamed(ldss, entry.LPath, rdss, entry.RPath, racl)
+			}
+			sr.Entries[i] = entry
+		}
+	}
+
+	skipped := 0
+	if opts.Conflict == RestoreSkip {
+		skipped = len(conflicts)
+	}
+	stats := sr.GetStats()
+	if opts.DryRun || opts.Verbose {
+		ssr := sr.SortByPath()
+		wrt := restoreUow(ctx).UiOutWriter()
+		if opts.Summary {
+			ssr.SummaryOutput(wrt, opts.DisplayRight)
+		} else {
+			ssr.TextOutput(wrt, opts.DisplayRight)
+		}
+		restoreOut(ctx, fmt.Sprintf(
+			"created: %d, updated %d, kept %d, touched %d, skipped %d, error(s) %d\n",
+			stats.CreNum, stats.UpdNum, stats.KeptNum, stats.MUpNum, skipped, stats.ErrNum))
+	}
+	if stats.ErrNum > 0 {
+		return fmt.Errorf("some errors encountered")
+	}
+	return nil
+}
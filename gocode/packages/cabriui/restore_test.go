@@ -0,0 +1,141 @@
+package cabriui
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/testfs"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func restoreWrite(dss cabridss.Dss, npath, content string) error {
+	wc, err := dss.GetContentWriter(npath, time.Now().Unix(), nil, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = wc.Write([]byte(content)); err != nil {
+		return err
+	}
+	return wc.Close()
+}
+
+func restoreCheck(tfs *testfs.Fs, expected map[string]string) error {
+	for name, content := range expected {
+		bs, err := os.ReadFile(ufpath.Join(tfs.Path(), "fsy", name))
+		if content == "" {
+			if err == nil {
+				return fmt.Errorf("restoreCheck %s exists", name)
+			}
+			continue
+		}
+		if err != nil || string(bs) != content {
+			return fmt.Errorf("restoreCheck %s content %s error %v", name, bs, err)
+		}
+	}
+	return nil
+}
+
+func TestRestore(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestRestore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	for _, dir := range []string{"fsy", "olf"} {
+		if err = os.Mkdir(ufpath.Join(tfs.Path(), dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	olf, err := cabridss.CreateOlfDss(cabridss.OlfConfig{
+		DssBaseConfig: cabridss.DssBaseConfig{LocalPath: ufpath.Join(tfs.Path(), "olf")},
+		Root:          ufpath.Join(tfs.Path(), "olf"), Size: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1 := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC).Unix()
+	olf.SetCurrentTime(t1)
+	if err = olf.Mkns("", 0, []string{"a.txt", "d/"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = olf.Mkns("d", 0, []string{"b.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for npath, content := range map[string]string{"a.txt": "a1", "d/b.txt": "b1"} {
+		if err = restoreWrite(olf, npath, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	olf.SetCurrentTime(t1 + 24*3600)
+	for npath, content := range map[string]string{"a.txt": "a2", "d/b.txt": "b2"} {
+		if err = restoreWrite(olf, npath, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = olf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(ufpath.Join(tfs.Path(), "fsy", "a.txt"), []byte("current"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(opts RestoreOptions, src string) (string, error) {
+		var outBuf bytes.Buffer
+		opts.NoACL = true
+		err := CLIRun[RestoreOptions, *RestoreVars](
+			nil, &outBuf, os.Stderr,
+			opts, []string{
+				fmt.Sprintf("olf:%s@%s", ufpath.Join(tfs.Path(), "olf"), src),
+				fmt.Sprintf("fsy:%s@", ufpath.Join(tfs.Path(), "fsy")),
+			},
+			RestoreStartup, RestoreShutdown)
+		return outBuf.String(), err
+	}
+	sLastTime := time.Unix(t1+3600, 0).UTC().Format(time.RFC3339)
+
+	out, err := run(RestoreOptions{SyncOptions: SyncOptions{DryRun: true, LeftTime: sLastTime}, Conflict: RestoreRename}, "a.txt")
+	if err != nil || !strings.Contains(out, ">+ a.txt a.txt.restored\n") {
+		t.Fatal(out, err)
+	}
+	if err = restoreCheck(tfs, map[string]string{"a.txt": "current", "a.txt.restored": ""}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = run(RestoreOptions{SyncOptions: SyncOptions{LeftTime: sLastTime}, Conflict: RestoreRename}, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err = restoreCheck(tfs, map[string]string{"a.txt": "current", "a.txt.restored": "a1", "d/b.txt": ""}); err != nil {
+		t.Fatal(err)
+	}
+	out, err = run(RestoreOptions{SyncOptions: SyncOptions{Verbose: true}, Index: -1, Conflict: RestoreSkip}, "a.txt")
+	if err != nil || !strings.Contains(out, "skipped 1,") {
+		t.Fatal(out, err)
+	}
+	if err = restoreCheck(tfs, map[string]string{"a.txt": "current"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = run(RestoreOptions{SyncOptions: SyncOptions{Recursive: true, LeftTime: sLastTime}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = restoreCheck(tfs, map[string]string{"a.txt": "a1", "a.txt.restored": "a1", "d/b.txt": "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = run(RestoreOptions{SyncOptions: SyncOptions{Recursive: true}}, "d"); err != nil {
+		t.Fatal(err)
+	}
+	if err = restoreCheck(tfs, map[string]string{"a.txt": "a1", "b.txt": "b2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = run(RestoreOptions{Index: 1}, "d/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err = restoreCheck(tfs, map[string]string{"b.txt": "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = run(RestoreOptions{Index: 3}, "a.txt"); err == nil {
+		t.Fatal("index out of range")
+	}
+}
@@ -17,6 +17,7 @@ type ScheduleOptions struct {
 	SpecFile string
 	HasHttp  bool
 	Address  string
+	Reports  int
 }
 
 type ScheduleVars struct {
@@ -73,9 +74,15 @@ func schedule(ctx context.Context, cr *joule.CLIRunner[ScheduleOptions]) error {
 	t, err := yaml.Marshal(spec)
 	_ = t
 	scheduleErr(ctx, fmt.Sprintf("Running %v\n", os.Args))
-	sc := ScheduleConfig{ctx: ctx, cancel: cr.CancelFunc(), Spec: spec, run: map[string]*ScheduleRunStatus{}}
-	for k, _ := range spec {
+	sc := ScheduleConfig{ctx: ctx, cancel: cr.CancelFunc(), Spec: spec, run: map[string]*ScheduleRunStatus{}, keepReports: opts.Reports}
+	for k, entry := range spec {
 		sc.run[k] = &ScheduleRunStatus{label: k, LastTime: time.Now().UnixNano()}
+		for _, action := range entry.Actions {
+			if action.Type == "cabriSync" && action.CabriSyncSpec.Watch {
+				// watching actions are started at once, then restarted if they stop
+				sc.run[k].LastTime = 0
+			}
+		}
 	}
 	cr.SetWorkDelay(time.Second)
 	var ws cabridss.WebServer
@@ -8,12 +8,22 @@ import (
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabrisync"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/joule"
 	"github.com/t-beigbeder/otvl_cabri/gocode/packages/plumber"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"io"
 	"os"
 	"regexp"
 	"runtime/debug"
+	"strconv"
 	"strings"
 )
 
+var syncConflictPolicies = map[string]cabrisync.ConflictPolicy{
+	"keep":  cabrisync.ConflictKeepBoth,
+	"left":  cabrisync.ConflictLeft,
+	"right": cabrisync.ConflictRight,
+	"abort": cabrisync.ConflictAbort,
+}
+
 type SyncOptions struct {
 	BaseOptions
 	Recursive    bool
@@ -23,18 +33,45 @@ type SyncOptions struct {
 	NoCh         bool
 	Exclude      []string
 	ExcludeFrom  []string
+	IgnoreFile   string
+	Ignore       []string
+	Include      []string
+	MinSize      string
+	MaxSize      string
+	NewerThan    string
+	OlderThan    string
 	NoACL        bool
+	Delta        bool
+	Moves        bool
+	Conflicts    string
+	Resume       bool
+	Incremental  bool
 	MapACL       []string
 	Summary      bool
+	Output       string
+	ReportFile   string
 	DisplayRight bool
 	Verbose      bool
 	VerboseLevel int
 	LeftTime     string
 	RightTime    string
+	Watch        bool
+	WatchDelay   int
+	WatchFull    int
+}
+
+func (sos SyncOptions) getSyncOptions() SyncOptions {
+	return sos
+}
+
+type syncOptionsEr interface {
+	BaseOptionsEr
+	getSyncOptions() SyncOptions
 }
 
 type SyncVars struct {
 	baseVars
+	reportWrt io.Writer
 }
 
 func SyncStartup(cr *joule.CLIRunner[SyncOptions]) error {
@@ -50,6 +87,13 @@ func SyncShutdown(cr *joule.CLIRunner[SyncOptions]) error {
 	return cr.GetUow("command").GetError()
 }
 
+func CheckSyncConflicts(conflicts string) error {
+	if _, ok := syncConflictPolicies[conflicts]; !ok && conflicts != "" {
+		return fmt.Errorf("conflict policy %s is invalid (must be keep, left, right or abort)", conflicts)
+	}
+	return nil
+}
+
 func syncCtx(ctx context.Context) *uiContext[SyncOptions, *SyncVars] {
 	return uiCtxFrom[SyncOptions, *SyncVars](ctx)
 }
@@ -64,40 +108,50 @@ func syncOut(ctx context.Context, s string) { syncUow(ctx).UiStrOut(s) }
 
 func syncErr(ctx context.Context, s string) { syncUow(ctx).UiStrErr(s) }
 
-func str2dss(ctx context.Context, dssPath string, isRight bool, obsIx *int) (cabridss.Dss, string, UiRunEnv, error) {
+// syncReportWriter returns the writer of the json or ndjson report, the report file if any
+func syncReportWriter(ctx context.Context) io.Writer {
+	if wrt := syncVars(ctx).reportWrt; wrt != nil {
+		return wrt
+	}
+	return syncUow(ctx).UiOutWriter()
+}
+
+func str2dss[OT syncOptionsEr, VT baseVarsEr](ctx context.Context, dssPath string, isRight bool, obsIx *int) (cabridss.Dss, string, UiRunEnv, error) {
+	opts := uiCtxFrom[OT, VT](ctx).opts.getSyncOptions()
 	var (
 		dss      cabridss.Dss
 		path     string
 		ure      UiRunEnv
 		err      error
 		lasttime int64
+		lasttag  string
 		slt      string
 	)
 	dssType, root, path, _ := CheckDssPath(dssPath)
 	// will setup users and ACL for right-side DSS
-	if ure, err = GetUiRunEnv[SyncOptions, *SyncVars](ctx, dssType[0] == 'x', !isRight); err != nil {
+	if ure, err = GetUiRunEnv[OT, VT](ctx, dssType[0] == 'x', !isRight); err != nil {
 		return nil, "", ure, err
 	}
 	if isRight {
-		slt = syncOpts(ctx).RightTime
+		slt = opts.RightTime
 	} else {
 		// fix users and ACL for left-side DSS
-		if ure.UiACL, err = CheckUiACL(syncOpts(ctx).LeftACL); err != nil {
+		if ure.UiACL, err = CheckUiACL(opts.LeftACL); err != nil {
 			return nil, "", ure, err
 		}
-		ure.UiUsers = syncOpts(ctx).LeftUsers
+		ure.UiUsers = opts.LeftUsers
 		if _, err = ure.ACLOrDefault(); err != nil {
 			return nil, "", ure, err
 		}
-		slt = syncOpts(ctx).LeftTime
+		slt = opts.LeftTime
 	}
 	if slt != "" {
-		lasttime, _ = CheckTimeStamp(slt)
+		lasttime, lasttag, _ = CheckTimeOrTag(slt)
 	}
 	if dssType == "fsy" {
 		if dss, err = cabridss.NewFsyDss(
 			cabridss.FsyConfig{
-				DssBaseConfig: cabridss.DssBaseConfig{ReducerLimit: syncOpts(ctx).RedLimit},
+				DssBaseConfig: cabridss.DssBaseConfig{ReducerLimit: opts.RedLimit},
 			},
 			root); err != nil {
 			return nil, "", ure, err
@@ -108,7 +162,7 @@ func str2dss(ctx context.Context, dssPath string, isRight bool, obsIx *int) (cab
 		if isRight {
 			dx = 1
 		}
-		if dss, err = NewWfsDss[SyncOptions, *SyncVars](ctx, nil,
+		if dss, err = NewWfsDss[OT, VT](ctx, nil,
 			NewHDssArgs{DssIx: dx}); err != nil {
 			return nil, "", ure, err
 		}
@@ -118,8 +172,8 @@ func str2dss(ctx context.Context, dssPath string, isRight bool, obsIx *int) (cab
 		if isRight {
 			dx = 1
 		}
-		nhArgs := NewHDssArgs{DssIx: dx, ObsIx: *obsIx, Lasttime: lasttime}
-		dss, err = NewHDss[SyncOptions, *SyncVars](ctx, nil, nhArgs)
+		nhArgs := NewHDssArgs{DssIx: dx, ObsIx: *obsIx, Lasttime: lasttime, Lasttag: lasttag}
+		dss, err = NewHDss[OT, VT](ctx, nil, nhArgs)
 		*obsIx += 1
 		if err != nil {
 			return nil, "", ure, err
@@ -242,6 +296,47 @@ func exclList(opts SyncOptions) ([]*regexp.Regexp, error) {
 	return res, nil
 }
 
+// CheckSize accepts a number of bytes optionally followed by K, M, G or T for powers of 1024
+func CheckSize(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	mul := int64(1)
+	if ix := strings.IndexByte("KMGT", value[len(value)-1]); ix >= 0 {
+		mul = int64(1) << (10 * (ix + 1))
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("size %s must be a positive integer optionally followed by K, M, G or T", value)
+	}
+	return n * mul, nil
+}
+
+func syncFilter(opts SyncOptions) (*cabrisync.Filter, error) {
+	if opts.IgnoreFile == "" && len(opts.Ignore) == 0 && len(opts.Include) == 0 &&
+		opts.MinSize == "" && opts.MaxSize == "" && opts.NewerThan == "" && opts.OlderThan == "" {
+		return nil, nil
+	}
+	var (
+		flt = cabrisync.Filter{IgnoreFile: opts.IgnoreFile, Ignore: opts.Ignore, Include: opts.Include}
+		err error
+	)
+	if flt.MinSize, err = CheckSize(opts.MinSize); err != nil {
+		return nil, err
+	}
+	if flt.MaxSize, err = CheckSize(opts.MaxSize); err != nil {
+		return nil, err
+	}
+	if flt.NewerThan, err = CheckTimeStamp(opts.NewerThan); err != nil {
+		return nil, err
+	}
+	if flt.OlderThan, err = CheckTimeStamp(opts.OlderThan); err != nil {
+		return nil, err
+	}
+	return &flt, nil
+}
+
 func synchronize(ctx context.Context, ldssPath, rdssPath string) error {
 	var (
 		err error
@@ -250,12 +345,20 @@ func synchronize(ctx context.Context, ldssPath, rdssPath string) error {
 	if opts.MapACL == nil {
 		opts.MapACL = []string{":"}
 	}
+	if opts.ReportFile != "" && (opts.Output == "json" || opts.Output == "ndjson") {
+		rf, err := os.Create(opts.ReportFile)
+		if err != nil {
+			return err
+		}
+		defer rf.Close()
+		syncVars(ctx).reportWrt = rf
+	}
 	obsIx := 0
-	ldss, lpath, lure, err := str2dss(ctx, ldssPath, false, &obsIx)
+	ldss, lpath, lure, err := str2dss[SyncOptions, *SyncVars](ctx, ldssPath, false, &obsIx)
 	if err != nil {
 		return err
 	}
-	rdss, rpath, rure, err := str2dss(ctx, rdssPath, true, &obsIx)
+	rdss, rpath, rure, err := str2dss[SyncOptions, *SyncVars](ctx, rdssPath, true, &obsIx)
 	if err != nil {
 		ldss.Close()
 		return err
@@ -270,6 +373,10 @@ func synchronize(ctx context.Context, ldssPath, rdssPath string) error {
 	if err != nil {
 		return err
 	}
+	flt, err := syncFilter(opts)
+	if err != nil {
+		return err
+	}
 	var beVerbose cabrisync.BeVerboseFunc
 	if opts.VerboseLevel >= 2 {
 		beVerbose = func(level int, line string) {
@@ -280,27 +387,43 @@ func synchronize(ctx context.Context, ldssPath, rdssPath string) error {
 		}
 	}
 	sOpts := cabrisync.SyncOptions{
-		InDepth:     opts.Recursive,
-		Evaluate:    opts.DryRun,
-		BiDir:       opts.BiDir,
-		KeepContent: opts.KeepContent,
-		NoCh:        opts.NoCh,
-		ExclList:    el,
-		NoACL:       opts.NoACL,
-		LeftMapACL:  lmacl,
-		RightMapACL: rmacl,
-		BeVerbose:   beVerbose,
+		InDepth:       opts.Recursive,
+		Evaluate:      opts.DryRun,
+		BiDir:         opts.BiDir,
+		KeepContent:   opts.KeepContent,
+		NoCh:          opts.NoCh,
+		ExclList:      el,
+		Filter:        flt,
+		NoACL:         opts.NoACL,
+		Delta:         opts.Delta,
+		Moves:         opts.Moves,
+		OnConflict:    syncConflictPolicies[opts.Conflicts],
+		CheckpointDir: ufpath.Join(lure.ConfigDir, "syncckpt"),
+		Resume:        opts.Resume,
+		Incremental:   opts.Incremental,
+		LeftMapACL:    lmacl,
+		RightMapACL:   rmacl,
+		BeVerbose:     beVerbose,
+	}
+	if opts.Conflicts != "" || opts.Incremental {
+		sOpts.StateDir = ufpath.Join(lure.ConfigDir, "syncstate")
+	}
+	if opts.Output == "ndjson" {
+		// entries are streamed as soon as synchronized, the final statistics being written by reportSync
+		sOpts.OnEntry = func(entry cabrisync.SyncReportEntry) {
+			_ = entry.NDJSONOutput(syncReportWriter(ctx), opts.Summary)
+		}
 	}
 	if opts.MaxThread != 0 {
 		debug.SetMaxThreads(opts.MaxThread)
 	}
-	iOutputs := plumber.LaunchAndWait(ctx,
-		[]string{"Synchronized"},
-		[]plumber.Launchable{cabrisync.PlizedSynchronize},
-		[]interface{}{cabrisync.SyncArgs{LDss: ldss, LPath: lpath, RDss: rdss, RPath: rpath, SOpts: sOpts}},
-	)
-	outputs := plumber.Retype[cabrisync.SyncReport](iOutputs)
-	sr := outputs[0]
+	sArgs := cabrisync.SyncArgs{LDss: ldss, LPath: lpath, RDss: rdss, RPath: rpath, SOpts: sOpts}
+	var sr cabrisync.SyncReport
+	if opts.Watch {
+		err = watchSync(ctx, ldssPath, sArgs)
+	} else {
+		sr = runSync(ctx, sArgs)
+	}
 
 	if errClose := ldss.Close(); errClose != nil {
 		if err == nil {
@@ -313,6 +436,49 @@ func synchronize(ctx context.Context, ldssPath, rdssPath string) error {
 		}
 	}
 
+	if opts.Watch {
+		return err
+	}
+	return reportSync(ctx, sr)
+}
+
+func runSync(ctx context.Context, sArgs cabrisync.SyncArgs) cabrisync.SyncReport {
+	iOutputs := plumber.LaunchAndWait(ctx,
+		[]string{"Synchronized"},
+		[]plumber.Launchable{cabrisync.PlizedSynchronize},
+		[]interface{}{sArgs},
+	)
+	return plumber.Retype[cabrisync.SyncReport](iOutputs)[0]
+}
+
+func CheckSyncOutput(output string) error {
+	if output != "" && output != "text" && output != "json" && output != "ndjson" {
+		return fmt.Errorf("output format %s is invalid (must be text, json or ndjson)", output)
+	}
+	return nil
+}
+
+func reportSync(ctx context.Context, sr cabrisync.SyncReport) error {
+	opts := syncOpts(ctx)
+	if opts.Output == "json" || opts.Output == "ndjson" {
+		wrt := syncReportWriter(ctx)
+		var err error
+		if opts.Output == "json" {
+			err = sr.SortByPath().JSONOutput(wrt, opts.Summary)
+		} else {
+			err = sr.NDJSONStatsOutput(wrt)
+		}
+		if sr.GErr != nil {
+			return sr.GErr
+		}
+		if err != nil {
+			return err
+		}
+		if sr.GetStats().ErrNum > 0 {
+			return fmt.Errorf("some errors encountered")
+		}
+		return nil
+	}
 	if sr.GErr != nil {
 		return sr.GErr
 	}
@@ -325,9 +491,20 @@ func synchronize(ctx context.Context, ldssPath, rdssPath string) error {
 		} else {
 			ssr.TextOutput(wrt, opts.DisplayRight)
 		}
+		moved := ""
+		if opts.Moves {
+			moved = fmt.Sprintf(" (moved or copied %d)", stats.MovNum)
+		}
+		others := ""
+		if opts.Conflicts != "" {
+			others += fmt.Sprintf(", conflict(s) %d", stats.ConfNum)
+		}
+		if opts.Resume {
+			others += fmt.Sprintf(", resumed %d", stats.ResNum)
+		}
 		syncOut(ctx, fmt.Sprintf(
-			"created: %d, updated %d, removed %d, kept %d, touched %d, error(s) %d\n",
-			stats.CreNum, stats.UpdNum, stats.RmvNum, stats.KeptNum, stats.MUpNum, stats.ErrNum))
+			"created: %d%s, updated %d, removed %d, kept %d, touched %d%s, error(s) %d\n",
+			stats.CreNum, moved, stats.UpdNum, stats.RmvNum, stats.KeptNum, stats.MUpNum, others, stats.ErrNum))
 	}
 	if stats.ErrNum > 0 {
 		return fmt.Errorf("some errors encountered")
@@ -119,6 +119,44 @@ func TestSynchronizeArboTiny(t *testing.T) {
 
 }
 
+func TestSynchronizeReportFile(t *testing.T) {
+	optionalSkip(t)
+	tfs, err := testfs.CreateFs("TestSynchronizeReportFile", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Delete()
+	fsy, _, _ := syncCreateFsyOlf(t, tfs)
+	syncGenArboTiny(t, fsy)
+	reportFile := ufpath.Join(tfs.Path(), "report.ndjson")
+	for _, output := range []string{"ndjson", "json"} {
+		syncOptions := SyncOptions{Recursive: true, NoACL: true, Verbose: true, Output: output, ReportFile: reportFile}
+		var outBuf bytes.Buffer
+		err = CLIRun[SyncOptions, *SyncVars](
+			nil, &outBuf, os.Stderr,
+			syncOptions, []string{
+				fmt.Sprintf("fsy:%s@", ufpath.Join(tfs.Path(), "fsy")),
+				fmt.Sprintf("olf:%s@", ufpath.Join(tfs.Path(), "olf")),
+			},
+			SyncStartup, SyncShutdown)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outBuf.Len() != 0 {
+			t.Fatalf("TestSynchronizeReportFile %s output %s", output, outBuf.String())
+		}
+		rf, err := os.Open(reportFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srd, err := cabrisync.ReadSyncReportData(rf)
+		rf.Close()
+		if err != nil || len(srd.Entries) == 0 {
+			t.Fatalf("TestSynchronizeReportFile %s report %v %v", output, srd, err)
+		}
+	}
+}
+
 func syncGenArboBase(t *testing.T, dss cabridss.Dss) cabritbx.RandGen {
 	const rndNb = 500
 	rg := cabritbx.NewRanGen(cabritbx.GetDefaultConfig(), dss)
@@ -554,3 +592,16 @@ func TestCheckUiAclMap(t *testing.T) {
 	}
 	_, _ = lm, rm
 }
+
+func TestCheckSize(t *testing.T) {
+	for value, expected := range map[string]int64{"": 0, "100": 100, "2K": 2048, "10M": 10 << 20, "1G": 1 << 30, "1T": 1 << 40} {
+		if size, err := CheckSize(value); err != nil || size != expected {
+			t.Fatalf("TestCheckSize %s: %d %v", value, size, err)
+		}
+	}
+	for _, value := range []string{"K", "-1", "1.5M", "10X"} {
+		if _, err := CheckSize(value); err == nil {
+			t.Fatalf("TestCheckSize %s should fail", value)
+		}
+	}
+}
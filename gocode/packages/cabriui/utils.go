@@ -72,6 +72,28 @@ func (ure UiRunEnv) ACLOrDefault() ([]cabridss.ACLEntry, error) {
 	return nil, fmt.Errorf("in UiRunEnv.ACLOrDefault: no default public key")
 }
 
+// MetaSigner describes the signer of meta data, by its identity alias if known, or "" if they are not signed
+func MetaSigner(uc cabridss.UserConfig, meta cabridss.Meta) string {
+	signer, err := cabridss.VerifyMetaSignature(meta)
+	if signer == "" {
+		return ""
+	}
+	if err != nil {
+		return "invalid signature by " + signerName(uc, signer)
+	}
+	return "signed by " + signerName(uc, signer)
+}
+
+// signerName returns the quoted alias of the identity having the signing public key spk, or spk if unknown
+func signerName(uc cabridss.UserConfig, spk string) string {
+	for _, idc := range uc.Identities {
+		if idc.SignPKey == spk {
+			return fmt.Sprintf("\"%s\"", idc.Alias)
+		}
+	}
+	return spk
+}
+
 var masterPassword string
 
 func MasterPassword(uow joule.UnitOfWork, opts BaseOptions, askNumber int) (string, error) {
@@ -198,6 +220,7 @@ func GetUiRunEnv[OT BaseOptionsEr, VT baseVarsEr](ctx context.Context, encrypted
 
 type NewHDssArgs struct {
 	Lasttime  int64
+	Lasttag   string // if not empty, tag resolved as Lasttime
 	DssIx     int
 	ObsIx     int
 	IsMapping bool
@@ -206,6 +229,23 @@ type NewHDssArgs struct {
 func NewHDss[OT BaseOptionsEr, VT baseVarsEr](
 	ctx context.Context, setCfgFunc func(bc *cabridss.DssBaseConfig), nhArgs NewHDssArgs,
 ) (cabridss.HDss, error) {
+	if nhArgs.Lasttag != "" {
+		tArgs := nhArgs
+		tArgs.Lasttag = ""
+		tArgs.Lasttime = 0
+		dss, err := NewHDss[OT, VT](ctx, setCfgFunc, tArgs)
+		if err != nil {
+			return nil, err
+		}
+		nhArgs.Lasttime, err = dss.ResolveTag(nhArgs.Lasttag)
+		if errClose := dss.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			return nil, err
+		}
+		nhArgs.Lasttag = ""
+	}
 	uictx := uiCtxFrom[OT, VT](ctx)
 	bo := uictx.opts.getBaseOptions()
 	ucArgs := uictx.args
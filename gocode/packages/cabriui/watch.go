@@ -0,0 +1,95 @@
+package cabriui
+
+import (
+	"context"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabrifsu"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabrisync"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/ufpath"
+	"sort"
+	"time"
+)
+
+const (
+	defaultWatchDelay = 2    // seconds without change before synchronizing the changed entries
+	defaultWatchFull  = 3600 // seconds between full synchronizations
+	watchMaxDelays    = 10   // changed entries are synchronized after at most this number of delays
+)
+
+func logWatch(ctx context.Context, line string) {
+	syncErr(ctx, fmt.Sprintf("%s Watch: %s\n", cabridss.UnixUTC(time.Now().UnixNano()).String(), line))
+}
+
+// watchSync synchronizes the fsy left namespace fully, then the entries changed in it as soon as they are stable,
+// with a periodic full synchronization to catch up with missed changes and errors
+func watchSync(ctx context.Context, ldssPath string, sArgs cabrisync.SyncArgs) error {
+	opts := syncOpts(ctx)
+	_, root, path, _ := CheckDssPath(ldssPath)
+	w, err := cabrifsu.NewWatcher(ufpath.Join(root, path))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	delay := time.Duration(opts.WatchDelay) * time.Second
+	if opts.WatchDelay <= 0 {
+		delay = defaultWatchDelay * time.Second
+	}
+	fullPeriod := time.Duration(opts.WatchFull) * time.Second
+	if opts.WatchFull <= 0 {
+		fullPeriod = defaultWatchFull * time.Second
+	}
+
+	doSync := func(paths []string) {
+		sArgs.SOpts.Paths = paths
+		if len(paths) == 0 {
+			logWatch(ctx, "full synchronization")
+		} else if opts.Verbose {
+			logWatch(ctx, fmt.Sprintf("synchronizing %v", paths))
+		}
+		if err := reportSync(ctx, runSync(ctx, sArgs)); err != nil {
+			logWatch(ctx, fmt.Sprintf("error %v, changes will be synchronized again at next full synchronization", err))
+		}
+	}
+
+	doSync(nil)
+	var (
+		pending  = map[string]bool{}
+		first    time.Time
+		debounce <-chan time.Time
+		full     = time.After(fullPeriod)
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err = <-w.Errors:
+			return err
+		case rel, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if len(pending) == 0 {
+				first = time.Now()
+			}
+			pending[rel] = true
+			if time.Since(first) < watchMaxDelays*delay {
+				debounce = time.After(delay)
+			}
+		case <-debounce:
+			paths := make([]string, 0, len(pending))
+			for rel := range pending {
+				paths = append(paths, rel)
+			}
+			sort.Strings(paths)
+			pending = map[string]bool{}
+			debounce = nil
+			doSync(paths)
+		case <-full:
+			pending = map[string]bool{}
+			debounce = nil
+			doSync(nil)
+			full = time.After(fullPeriod)
+		}
+	}
+}
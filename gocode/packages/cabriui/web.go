@@ -11,14 +11,15 @@ type WebApiOptions struct {
 	BaseOptions
 	HasLog        bool
 	IsRest        bool
+	IsDav         bool
 	TlsKey        string // certificate key file on https server
 	LastTime      string
 	TlsClientCert string // untrusted CA on https client
 }
 
-func (wos WebApiOptions) getLastTime() (lastTime int64) {
+func (wos WebApiOptions) getLastTime() (lastTime int64, lastTag string) {
 	if wos.LastTime != "" {
-		lastTime, _ = CheckTimeStamp(wos.LastTime)
+		lastTime, lastTag, _ = CheckTimeOrTag(wos.LastTime)
 	}
 	return
 }
@@ -64,8 +65,12 @@ func addHdssServerItem(ctx context.Context, args []string, ix int, opts WebApiOp
 		err = fmt.Errorf("mapping %s requires certificate and key files", args[ix])
 		return
 	}
+	apiTokens, err := webApiTokens(ure, args[ix], isTls)
+	if err != nil {
+		return
+	}
 	var dss cabridss.Dss
-	if !opts.IsRest {
+	if !opts.IsRest && !opts.IsDav {
 		var params cabridss.CreateNewParams
 		dssSubType := dssType
 		if dssType[0] == 'x' {
@@ -82,7 +87,8 @@ func addHdssServerItem(ctx context.Context, args []string, ix int, opts WebApiOp
 		params.RedLimit = opts.RedLimit
 		dss, err = cabridss.CreateOrNewDss(params)
 	} else {
-		dss, err = NewHDss[WebApiOptions, *WebApiVars](ctx, nil, NewHDssArgs{DssIx: ix, ObsIx: *obsIx, Lasttime: webApiOpts(ctx).getLastTime(), IsMapping: true})
+		lasttime, lasttag := webApiOpts(ctx).getLastTime()
+		dss, err = NewHDss[WebApiOptions, *WebApiVars](ctx, nil, NewHDssArgs{DssIx: ix, ObsIx: *obsIx, Lasttime: lasttime, Lasttag: lasttag, IsMapping: true})
 	}
 	if err != nil {
 		return
@@ -91,18 +97,24 @@ func addHdssServerItem(ctx context.Context, args []string, ix int, opts WebApiOp
 		err = fmt.Errorf("DSS for url %s is not persistent", args[ix])
 		return
 	}
+	wsConfig := cabridss.WebServerConfig{
+		Addr:              addr,
+		HasLog:            opts.HasLog,
+		IsTls:             isTls,
+		TlsCert:           opts.TlsCert,
+		TlsKey:            opts.TlsKey,
+		TlsNoCheck:        opts.TlsNoCheck,
+		BasicAuthUser:     ure.BasicAuthUser,
+		BasicAuthPassword: ure.BasicAuthPassword,
+		ApiTokens:         apiTokens,
+	}
+	if opts.IsDav {
+		ure.Encrypted = dss.(cabridss.HDss).IsEncrypted()
+		return addDavServerItem(vars, addr, root, wsConfig, dss, ure)
+	}
 	config := cabridss.WebDssServerConfig{
-		WebServerConfig: cabridss.WebServerConfig{
-			Addr:              addr,
-			HasLog:            opts.HasLog,
-			IsTls:             isTls,
-			TlsCert:           opts.TlsCert,
-			TlsKey:            opts.TlsKey,
-			TlsNoCheck:        opts.TlsNoCheck,
-			BasicAuthUser:     ure.BasicAuthUser,
-			BasicAuthPassword: ure.BasicAuthPassword,
-		},
-		Dss: dss.(cabridss.HDss),
+		WebServerConfig: wsConfig,
+		Dss:             dss.(cabridss.HDss),
 	}
 	if opts.IsRest {
 		config.UserConfig = ure.UserConfig
@@ -138,22 +150,31 @@ func addFsyServerItem(ctx context.Context, args []string, ix int, opts WebApiOpt
 		err = fmt.Errorf("mapping %s requires certificate and key files", args[ix])
 		return
 	}
+	apiTokens, err := webApiTokens(ure, args[ix], isTls)
+	if err != nil {
+		return
+	}
 	var dss cabridss.Dss
 	if dss, err = cabridss.NewFsyDss(cabridss.FsyConfig{}, localPath); err != nil {
 		return
 	}
+	wsConfig := cabridss.WebServerConfig{
+		Addr:              addr,
+		HasLog:            opts.HasLog,
+		IsTls:             isTls,
+		TlsCert:           opts.TlsCert,
+		TlsKey:            opts.TlsKey,
+		TlsNoCheck:        opts.TlsNoCheck,
+		BasicAuthUser:     ure.BasicAuthUser,
+		BasicAuthPassword: ure.BasicAuthPassword,
+		ApiTokens:         apiTokens,
+	}
+	if opts.IsDav {
+		return addDavServerItem(vars, addr, root, wsConfig, dss, ure)
+	}
 	config := cabridss.WfsDssServerConfig{
-		WebServerConfig: cabridss.WebServerConfig{
-			Addr:              addr,
-			HasLog:            opts.HasLog,
-			IsTls:             isTls,
-			TlsCert:           opts.TlsCert,
-			TlsKey:            opts.TlsKey,
-			TlsNoCheck:        opts.TlsNoCheck,
-			BasicAuthUser:     ure.BasicAuthUser,
-			BasicAuthPassword: ure.BasicAuthPassword,
-		},
-		Dss: dss,
+		WebServerConfig: wsConfig,
+		Dss:             dss,
 	}
 	server, ok := vars.servers[addr]
 	if !ok {
@@ -182,6 +203,24 @@ func addFsyServerItem(ctx context.Context, args []string, ix int, opts WebApiOpt
 	return
 }
 
+func addDavServerItem(vars *WebApiVars, addr, root string, wsConfig cabridss.WebServerConfig, dss cabridss.Dss, ure UiRunEnv) (err error) {
+	config := cabridss.DavServerConfig{WebServerConfig: wsConfig, Dss: dss}
+	if config.ACL, err = ure.ACLOrDefault(); err != nil {
+		dss.Close()
+		return
+	}
+	server, ok := vars.servers[addr]
+	if !ok {
+		if vars.servers[addr], err = cabridss.NewDavServer(root, config); err != nil {
+			dss.Close()
+		}
+		return
+	}
+	return server.ConfigureApi(root, config, func(root string, customConfigs map[string]interface{}) error {
+		return customConfigs[root].(cabridss.DavServerConfig).Dss.Close()
+	}, cabridss.DavServerConfigurator)
+}
+
 func webApi(ctx context.Context, args []string) error {
 	opts := webApiOpts(ctx)
 	vars := webApiVars(ctx)
@@ -0,0 +1,123 @@
+package cabriui
+
+import (
+	"context"
+	"fmt"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/cabridss"
+	"github.com/t-beigbeder/otvl_cabri/gocode/packages/joule"
+	"os"
+	"strings"
+	"time"
+)
+
+type WebTokenOptions struct {
+	BaseOptions
+	Add     bool
+	List    bool
+	Revoke  bool
+	Roots   []string
+	Ops     []string
+	Expires string
+}
+
+type WebTokenVars struct {
+	baseVars
+}
+
+func WebTokenStartup(cr *joule.CLIRunner[WebTokenOptions]) error {
+	_ = cr.AddUow("command",
+		func(ctx context.Context, work joule.UnitOfWork, i interface{}) (interface{}, error) {
+			(*uiCtxFrom[WebTokenOptions, *WebTokenVars](ctx)).vars = &WebTokenVars{baseVars: baseVars{uow: work}}
+			return nil, webTokenRun(ctx)
+		})
+	return nil
+}
+
+func WebTokenShutdown(cr *joule.CLIRunner[WebTokenOptions]) error {
+	return cr.GetUow("command").GetError()
+}
+
+func webTokenCtx(ctx context.Context) *uiContext[WebTokenOptions, *WebTokenVars] {
+	return uiCtxFrom[WebTokenOptions, *WebTokenVars](ctx)
+}
+
+func webTokenOpts(ctx context.Context) WebTokenOptions { return (*webTokenCtx(ctx)).opts }
+
+func webTokenUow(ctx context.Context) joule.UnitOfWork {
+	return getUnitOfWork[WebTokenOptions, *WebTokenVars](ctx)
+}
+
+func webTokenOut(ctx context.Context, s string) { webTokenUow(ctx).UiStrOut(s) }
+
+func webTokenTime(t int64) string {
+	if t == 0 {
+		return "never"
+	}
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+func webTokenRun(ctx context.Context) error {
+	opts := webTokenOpts(ctx)
+	args := webTokenCtx(ctx).args
+	cd, err := ConfigDir(opts.BaseOptions)
+	if err != nil {
+		return err
+	}
+	path := cabridss.ApiTokensPath(cd)
+	if opts.Add {
+		at := cabridss.ApiToken{Name: args[0], Roots: opts.Roots, Ops: opts.Ops}
+		if len(at.Ops) == 0 {
+			at.Ops = []string{cabridss.ApiOpRead}
+		}
+		if opts.Expires != "" {
+			if at.Expires, err = CheckTimeStamp(opts.Expires); err != nil {
+				return err
+			}
+		}
+		token, err := cabridss.AddApiToken(path, at)
+		if err != nil {
+			return err
+		}
+		webTokenOut(ctx, fmt.Sprintf("%s\n", token))
+		return nil
+	}
+	if opts.Revoke {
+		for _, name := range args {
+			if err = cabridss.RevokeApiToken(path, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tokens, err := cabridss.LoadApiTokens(path)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, at := range tokens {
+		roots := "*"
+		if len(at.Roots) != 0 {
+			roots = strings.Join(at.Roots, ",")
+		}
+		state := ""
+		if at.IsExpired(now) {
+			state = " expired"
+		}
+		webTokenOut(ctx, fmt.Sprintf("%-16s %-16s %-24s %s %s%s\n",
+			at.Name, strings.Join(at.Ops, ","), roots, webTokenTime(at.Created), webTokenTime(at.Expires), state))
+	}
+	return nil
+}
+
+// webApiTokens returns the API tokens file the web servers authenticate requests with, if any,
+// such tokens requiring https mappings
+func webApiTokens(ure UiRunEnv, mapping string, isTls bool) (string, error) {
+	path := cabridss.ApiTokensPath(ure.ConfigDir)
+	if _, err := os.Stat(path); err != nil {
+		return "", nil
+	}
+	if !isTls {
+		return "", fmt.Errorf("mapping %s requires https with API tokens", mapping)
+	}
+	return path, nil
+}
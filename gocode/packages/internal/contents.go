@@ -3,16 +3,19 @@ package internal
 import (
 	"crypto/sha256"
 	"sort"
+	"strings"
 )
 
 // Ns2Content provides the namespace content
 // along with its truncated SHA256 checksum as a path
 func Ns2Content(children []string, size string) (string, string, string) {
 	sort.Strings(children)
-	content := ""
+	var sb strings.Builder
 	for _, child := range children {
-		content += child + "\n"
+		sb.WriteString(child)
+		sb.WriteByte('\n')
 	}
+	content := sb.String()
 	cs := sha256.Sum256([]byte(content))
 	path := ""
 	if size != "" {
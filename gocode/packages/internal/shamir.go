@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Shamir secret sharing over GF(2^8) with the AES reduction polynomial,
+// each byte of the secret being shared with its own random polynomial.
+// A share is the evaluation of the polynomials at a non-zero x, appended as its last byte.
+
+var gfExp [510]byte
+var gfLog [256]byte
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		// multiply by the generator 3
+		x ^= x << 1
+		if x&0x100 != 0 {
+			x ^= 0x11b
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// ShamirSplit splits secret into n shares, any m of them being required to rebuild it
+func ShamirSplit(secret []byte, n, m int) ([][]byte, error) {
+	if m < 2 || n < m || n > 255 {
+		return nil, fmt.Errorf("in ShamirSplit: invalid threshold %d for %d shares", m, n)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("in ShamirSplit: empty secret")
+	}
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+	coefs := make([]byte, m)
+	for j, b := range secret {
+		if _, err := rand.Read(coefs[1:]); err != nil {
+			return nil, fmt.Errorf("in ShamirSplit: %w", err)
+		}
+		coefs[0] = b
+		for i := range shares {
+			x := byte(i + 1)
+			// Horner evaluation
+			y := coefs[m-1]
+			for k := m - 2; k >= 0; k-- {
+				y = gfMul(y, x) ^ coefs[k]
+			}
+			shares[i][j] = y
+		}
+	}
+	return shares, nil
+}
+
+// ShamirCombine rebuilds the secret from shares, which must be at least the threshold given to ShamirSplit,
+// otherwise the result is random
+func ShamirCombine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("in ShamirCombine: at least two shares are required")
+	}
+	l := len(shares[0])
+	xs := make([]byte, len(shares))
+	for i, share := range shares {
+		if len(share) != l || l < 2 {
+			return nil, fmt.Errorf("in ShamirCombine: shares have different or invalid lengths")
+		}
+		xs[i] = share[l-1]
+		if xs[i] == 0 {
+			return nil, fmt.Errorf("in ShamirCombine: invalid share")
+		}
+		for k := 0; k < i; k++ {
+			if xs[k] == xs[i] {
+				return nil, fmt.Errorf("in ShamirCombine: duplicate share %d", xs[i])
+			}
+		}
+	}
+	// Lagrange interpolation at 0
+	secret := make([]byte, l-1)
+	for i, share := range shares {
+		basis := byte(1)
+		for k := range shares {
+			if k != i {
+				basis = gfMul(basis, gfDiv(xs[k], xs[k]^xs[i]))
+			}
+		}
+		for j := range secret {
+			secret[j] ^= gfMul(share[j], basis)
+		}
+	}
+	return secret, nil
+}
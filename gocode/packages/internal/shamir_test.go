@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShamir(t *testing.T) {
+	secret := []byte("TestShamir secret \x00\xff")
+	shares, err := ShamirSplit(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ixs := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var sel [][]byte
+		for _, ix := range ixs {
+			sel = append(sel, shares[ix])
+		}
+		res, err := ShamirCombine(sel)
+		if err != nil || !bytes.Equal(res, secret) {
+			t.Fatalf("TestShamir %v %v %v", ixs, res, err)
+		}
+	}
+	if res, _ := ShamirCombine(shares[:2]); bytes.Equal(res, secret) {
+		t.Fatal("TestShamir below threshold should not rebuild the secret")
+	}
+	if _, err = ShamirCombine([][]byte{shares[0], shares[0]}); err == nil {
+		t.Fatal("TestShamir duplicate shares should fail")
+	}
+	if _, err = ShamirSplit(secret, 2, 3); err == nil {
+		t.Fatal("TestShamir invalid threshold should fail")
+	}
+}